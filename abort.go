@@ -0,0 +1,98 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nawafswe/gomailer/message"
+)
+
+// ErrConnectionAborted is returned by SendCloser once its connection was closed because a send was aborted (see Hooks.OnAbort).
+var ErrConnectionAborted = errors.New("connection to smtp server was closed after an aborted send")
+
+// SendStage is the stage a send reached when it was aborted, reported to the OnAbort hooks.
+type SendStage int
+
+const (
+	// StageConnect indicates the connection to the SMTP server was being set up, nothing was sent.
+	StageConnect SendStage = iota
+	// StageEncode indicates the message was being prepared, nothing was sent.
+	StageEncode
+	// StageEnvelope indicates the MAIL and RCPT commands were being sent, the server discards the transaction.
+	StageEnvelope
+	// StageData indicates the message was being transferred, the server discards the incomplete message.
+	StageData
+	// StageAwaitingReply indicates the message was fully transferred but the server reply was not received,
+	// the message may have been accepted and delivered.
+	StageAwaitingReply
+)
+
+// String returns the name of the stage.
+func (s SendStage) String() string {
+	switch s {
+	case StageEncode:
+		return "encode"
+	case StageEnvelope:
+		return "envelope"
+	case StageData:
+		return "data"
+	case StageAwaitingReply:
+		return "awaiting reply"
+	default:
+		return "connect"
+	}
+}
+
+// MaybeSent reports whether the message may have been accepted by the server, so it should not be blindly resent.
+func (s SendStage) MaybeSent() bool {
+	return s == StageAwaitingReply
+}
+
+// interruptOnDone closes the connection as soon as ctx is done, interrupting the SMTP transaction in progress.
+// The returned function stops watching ctx, it returns once the connection is closed if ctx was done meanwhile.
+func (m *mailSender) interruptOnDone(ctx context.Context) (stop func()) {
+	closed := make(chan struct{})
+	stopAfter := context.AfterFunc(ctx, func() {
+		m.closeAborted()
+		close(closed)
+	})
+	return func() {
+		if !stopAfter() {
+			<-closed
+		}
+	}
+}
+
+// abort closes the connection when a transaction was started, as its state on the server is unknown,
+// invokes the OnAbort hooks and returns err joined with the context error.
+func (m *mailSender) abort(ctx context.Context, msg message.Message, err error) error {
+	stage := m.stage
+	if stage > StageEncode {
+		m.closeAborted()
+	}
+	m.mailer.hooks.onAbort(ctx, msg, stage, err)
+	ctxErr := m.mailer.doneErr(ctx)
+	if errors.Is(err, ctxErr) {
+		return err
+	}
+	return errors.Join(err, ctxErr)
+}
+
+// doneErr returns the error of ctx once it is done, or context.DeadlineExceeded once its deadline passed,
+// as the connection deadlines derived from it may expire before ctx reports it.
+func (m *Mailer) doneErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok && !m.now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// closeAborted closes the connection without QUIT, only once.
+func (m *mailSender) closeAborted() {
+	if !m.aborted.Swap(true) {
+		_ = m.Client.Close()
+	}
+}
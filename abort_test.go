@@ -0,0 +1,196 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mailerMock "github.com/nawafswe/gomailer/internal/mock"
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMailer_SendAbort(t *testing.T) {
+	msg := message.Message{
+		From:       testFromEmail,
+		Recipients: testRecipient,
+		Body:       "dummy body",
+	}
+	closedErr := fmt.Errorf("use of closed network connection")
+	tests := map[string]struct {
+		// expect sets the expectations of the mocks, calling cancel when the send is aborted.
+		expect        func(smtpMock *mailerMock.MockClient, writeCloserMock *mailerMock.MockwriteCloser, cancel context.CancelFunc)
+		beforeSend    bool
+		expectedStage SendStage
+	}{
+		"should keep the connection when aborted before the transaction started": {
+			beforeSend: true,
+			expect: func(smtpMock *mailerMock.MockClient, writeCloserMock *mailerMock.MockwriteCloser, cancel context.CancelFunc) {
+				smtpMock.EXPECT().Quit().Return(nil)
+			},
+			expectedStage: StageEncode,
+		},
+		"should close the connection when aborted while sending the envelope": {
+			expect: func(smtpMock *mailerMock.MockClient, writeCloserMock *mailerMock.MockwriteCloser, cancel context.CancelFunc) {
+				smtpMock.EXPECT().Mail(msg.From).DoAndReturn(func(string, ...string) error {
+					cancel()
+					return closedErr
+				})
+				smtpMock.EXPECT().Close().Return(nil)
+			},
+			expectedStage: StageEnvelope,
+		},
+		"should close the connection when aborted while transferring the message": {
+			expect: func(smtpMock *mailerMock.MockClient, writeCloserMock *mailerMock.MockwriteCloser, cancel context.CancelFunc) {
+				smtpMock.EXPECT().Mail(msg.From).Return(nil)
+				smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
+				smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+				writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func([]byte) (int, error) {
+					cancel()
+					return 0, closedErr
+				})
+				writeCloserMock.EXPECT().Close().Return(closedErr)
+				smtpMock.EXPECT().Close().Return(nil)
+			},
+			expectedStage: StageData,
+		},
+		"should report messages aborted while awaiting the reply as maybe sent": {
+			expect: func(smtpMock *mailerMock.MockClient, writeCloserMock *mailerMock.MockwriteCloser, cancel context.CancelFunc) {
+				smtpMock.EXPECT().Mail(msg.From).Return(nil)
+				smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
+				smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+				writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+					return len(b), nil
+				})
+				writeCloserMock.EXPECT().Close().DoAndReturn(func() error {
+					cancel()
+					return closedErr
+				})
+				smtpMock.EXPECT().Close().Return(nil)
+			},
+			expectedStage: StageAwaitingReply,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			// prepare mocks
+			smtpMock := mailerMock.NewMockClient(ctrl)
+			netConnMock := mailerMock.NewMockconn(ctrl)
+			writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+			// stub functions
+			newClient := func(conn net.Conn, host string) (Client, error) {
+				return smtpMock, nil
+			}
+			netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+				return netConnMock, nil
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var stages []SendStage
+			var hookErr error
+			mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone),
+				WithHooks(Hooks{
+					BeforeSend: func(ctx context.Context, msg message.Message, encoded []byte) error {
+						if tc.beforeSend {
+							cancel()
+						}
+						return nil
+					},
+					OnAbort: func(ctx context.Context, msg message.Message, stage SendStage, err error) {
+						stages = append(stages, stage)
+					},
+					OnError: func(ctx context.Context, msg message.Message, err error) {
+						hookErr = err
+					},
+				}),
+			)
+
+			// expect on mocks
+			tc.expect(smtpMock, writeCloserMock, cancel)
+
+			err := mailer.Send(ctx, msg)
+			assert.ErrorIs(t, err, context.Canceled)
+			assert.ErrorIs(t, hookErr, context.Canceled)
+			assert.Equal(t, []SendStage{tc.expectedStage}, stages)
+			assert.Equal(t, tc.expectedStage == StageAwaitingReply, stages[0].MaybeSent())
+		})
+	}
+
+	t.Run("should abort sends past their context deadline before the context reports it", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+
+		var stages []SendStage
+		mailer := NewMailer(testHost, testPort, "", "", WithHooks(Hooks{
+			OnAbort: func(ctx context.Context, msg message.Message, stage SendStage, err error) {
+				stages = append(stages, stage)
+			},
+		}))
+		sender := &mailSender{Client: smtpMock, mailer: mailer, endpoint: mailer.endpoint()}
+		deadline := time.Now().Add(time.Hour)
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+
+		// expect on mocks
+		smtpMock.EXPECT().Mail(msg.From).Return(nil)
+		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
+		smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+		writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func([]byte) (int, error) {
+			// the connection deadline derived from ctx expired, the context timer has not fired yet.
+			mailer.clock = func() time.Time { return deadline.Add(time.Second) }
+			return 0, fmt.Errorf("i/o timeout")
+		})
+		writeCloserMock.EXPECT().Close().Return(closedErr)
+		smtpMock.EXPECT().Close().Return(nil)
+
+		err := sender.SendContext(ctx, msg)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Nil(t, ctx.Err())
+		assert.Equal(t, []SendStage{StageData}, stages)
+	})
+	t.Run("should refuse to send over an aborted connection", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+
+		mailer := NewMailer(testHost, testPort, "", "")
+		sender := &mailSender{Client: smtpMock, mailer: mailer, endpoint: mailer.endpoint()}
+		ctx, cancel := context.WithCancel(context.Background())
+
+		// expect on mocks
+		smtpMock.EXPECT().Mail(msg.From).DoAndReturn(func(string, ...string) error {
+			cancel()
+			return closedErr
+		})
+		smtpMock.EXPECT().Close().Return(nil)
+
+		assert.ErrorIs(t, sender.SendContext(ctx, msg), context.Canceled)
+		assert.Equal(t, ErrConnectionAborted, sender.SendContext(context.Background(), msg))
+		assert.Nil(t, sender.Close())
+	})
+	t.Run("should report aborted connections", func(t *testing.T) {
+		// stub functions
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return nil, fmt.Errorf("dummy error")
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		var stages []SendStage
+		mailer := NewMailer(testHost, testPort, "", "", withNetDial(netDial), WithEncryption(EncryptionNone), WithHooks(Hooks{
+			OnAbort: func(ctx context.Context, msg message.Message, stage SendStage, err error) {
+				stages = append(stages, stage)
+			},
+		}))
+
+		assert.NotNil(t, mailer.Send(ctx, msg))
+		assert.Equal(t, []SendStage{StageConnect}, stages)
+	})
+}
@@ -0,0 +1,170 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// AdaptiveConcurrency adjusts the number of concurrent sends of a Mailer to the health of the relay (AIMD):
+// the limit grows by one send per window of sends while the relay answers promptly, and is cut by Backoff
+// when the smoothed latency degrades or the relay replies 4xx (e.g. 421 too many connections or 451 try again later).
+type AdaptiveConcurrency struct {
+	// Min is the lowest limit of concurrent sends, and the one the Mailer starts with. It is 1 when zero.
+	Min int
+	// Max is the highest limit of concurrent sends.
+	Max int
+	// Smoothing is the weight, between 0 and 1, of the latest send latency in the exponentially smoothed latency.
+	// It is 0.2 when zero.
+	Smoothing float64
+	// Tolerance is how many times the lowest smoothed latency observed the smoothed latency may reach
+	// before the limit is cut. It is 2 when zero.
+	Tolerance float64
+	// Backoff is the factor, between 0 and 1, the limit is multiplied by when cut. It is 0.5 when zero.
+	Backoff float64
+}
+
+// WithAdaptiveConcurrency configures Mailer to limit the concurrent Send calls, each holding a connection, to a limit
+// adapted to the relay latency and 4xx rate within c.Min and c.Max, so concurrent goroutines sharing the Mailer get
+// the most throughput the relay sustains without manual tuning. Sends exceeding the limit wait for their turn until
+// their context is done. The current limit is reported by Mailer.ConcurrencyLimit.
+func WithAdaptiveConcurrency(c AdaptiveConcurrency) func(*Mailer) {
+	return func(mailer *Mailer) {
+		if c.Min == 0 {
+			c.Min = 1
+		}
+		if c.Smoothing == 0 {
+			c.Smoothing = 0.2
+		}
+		if c.Tolerance == 0 {
+			c.Tolerance = 2
+		}
+		if c.Backoff == 0 {
+			c.Backoff = 0.5
+		}
+		switch {
+		case c.Min < 1 || c.Max < c.Min:
+			mailer.invalidOption("adaptive concurrency bounds %d-%d must be positive and ordered", c.Min, c.Max)
+		case c.Smoothing < 0 || c.Smoothing > 1:
+			mailer.invalidOption("adaptive concurrency smoothing %g must be between 0 and 1", c.Smoothing)
+		case c.Tolerance < 1:
+			mailer.invalidOption("adaptive concurrency tolerance %g must be at least 1", c.Tolerance)
+		case c.Backoff <= 0 || c.Backoff >= 1:
+			mailer.invalidOption("adaptive concurrency backoff %g must be between 0 and 1", c.Backoff)
+		default:
+			mailer.concurrency = &concurrencyLimiter{AdaptiveConcurrency: c, now: mailer.now, limit: float64(c.Min)}
+		}
+	}
+}
+
+// ConcurrencyLimit returns the current limit of concurrent sends, 0 when no adaptive concurrency is configured
+// (see WithAdaptiveConcurrency).
+func (m *Mailer) ConcurrencyLimit() int {
+	l := m.concurrency
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// concurrencyLimiter limits the concurrent sends of a Mailer to a limit adapted by their outcome.
+type concurrencyLimiter struct {
+	AdaptiveConcurrency
+	// now returns the current time of the Mailer clock.
+	now func() time.Time
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	// waiters are signaled, in order, when a send may be started.
+	waiters []chan struct{}
+	// smoothed and lowest are the smoothed latency and the lowest one observed, zero before the first send.
+	smoothed, lowest time.Duration
+	// cutAt is when the limit was last cut, sends started before do not cut it again.
+	cutAt time.Time
+}
+
+// acquire waits until a send may be started, it returns the function to call with the outcome of the send.
+// It does not wait when l is nil.
+func (l *concurrencyLimiter) acquire(ctx context.Context) (release func(error), err error) {
+	if l == nil {
+		return func(error) {}, nil
+	}
+	l.mu.Lock()
+	for l.inFlight >= int(l.limit) {
+		ready := make(chan struct{})
+		l.waiters = append(l.waiters, ready)
+		l.mu.Unlock()
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			l.mu.Lock()
+			l.waiters = slices.DeleteFunc(l.waiters, func(c chan struct{}) bool { return c == ready })
+			// the slot this waiter may have been signaled for goes to the next one.
+			l.signal()
+			l.mu.Unlock()
+			return nil, fmt.Errorf("failed to wait for a send slot: %w", ctx.Err())
+		}
+		l.mu.Lock()
+	}
+	l.inFlight++
+	start := l.now()
+	l.mu.Unlock()
+	return func(err error) { l.release(start, err) }, nil
+}
+
+// release ends the send started at start, adapting the limit to its latency and error.
+func (l *concurrencyLimiter) release(start time.Time, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	defer l.signal()
+
+	var smtpErr *SMTPError
+	if errors.As(err, &smtpErr) && smtpErr.Temporary() {
+		l.cut(start)
+		return
+	}
+	if err != nil {
+		// other failures, e.g. rejected recipients or an unreachable relay, say nothing about its load.
+		return
+	}
+	latency := l.now().Sub(start)
+	if l.smoothed == 0 {
+		l.smoothed = latency
+	} else {
+		l.smoothed = time.Duration(l.Smoothing*float64(latency) + (1-l.Smoothing)*float64(l.smoothed))
+	}
+	if l.lowest == 0 || l.smoothed < l.lowest {
+		l.lowest = l.smoothed
+	}
+	if float64(l.smoothed) > l.Tolerance*float64(l.lowest) {
+		l.cut(start)
+		return
+	}
+	// additive increase: one send more once a whole window of sends succeeded.
+	l.limit = min(l.limit+1/l.limit, float64(l.Max))
+}
+
+// cut multiplies the limit by the backoff, once for the sends started before the previous cut.
+func (l *concurrencyLimiter) cut(start time.Time) {
+	if start.Before(l.cutAt) {
+		return
+	}
+	l.limit = max(l.limit*l.Backoff, float64(l.Min))
+	l.cutAt = l.now()
+}
+
+// signal wakes up as many waiters as sends may be started, they check the limit again once they run.
+func (l *concurrencyLimiter) signal() {
+	n := min(int(l.limit)-l.inFlight, len(l.waiters))
+	for _, ready := range l.waiters[:max(n, 0)] {
+		close(ready)
+	}
+	l.waiters = l.waiters[max(n, 0):]
+}
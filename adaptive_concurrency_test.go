@@ -0,0 +1,137 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMailer_AdaptiveConcurrency(t *testing.T) {
+	now := time.Date(2024, time.March, 5, 10, 30, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	// send sends a message over the mailer taking the given latency and failing with the given error.
+	send := func(t *testing.T, mailer *Mailer, latency time.Duration, err error) {
+		release, acquireErr := mailer.concurrency.acquire(context.Background())
+		require.Nil(t, acquireErr)
+		now = now.Add(latency)
+		release(err)
+	}
+
+	t.Run("should grow the limit by one send per window of prompt sends up to the max", func(t *testing.T) {
+		mailer, err := NewMailerE(testHost, testPort, testUser, testPassword, withClock(clock), WithAdaptiveConcurrency(AdaptiveConcurrency{Max: 3}))
+		require.Nil(t, err)
+		assert.Equal(t, 1, mailer.ConcurrencyLimit())
+
+		var limits []int
+		for range 5 {
+			send(t, mailer, 10*time.Millisecond, nil)
+			limits = append(limits, mailer.ConcurrencyLimit())
+		}
+		assert.Equal(t, []int{2, 2, 2, 3, 3}, limits)
+	})
+	t.Run("should cut the limit on temporary rejections and slow sends down to the min", func(t *testing.T) {
+		mailer, err := NewMailerE(testHost, testPort, testUser, testPassword, withClock(clock), WithAdaptiveConcurrency(AdaptiveConcurrency{Min: 2, Max: 8}))
+		require.Nil(t, err)
+		mailer.concurrency.limit = 8
+
+		send(t, mailer, 10*time.Millisecond, fmt.Errorf("failed to send message: %w", &SMTPError{Code: 451}))
+		assert.Equal(t, 4, mailer.ConcurrencyLimit())
+		send(t, mailer, 10*time.Millisecond, &SMTPError{Code: 550})
+		send(t, mailer, 10*time.Millisecond, errors.New("connection refused"))
+		assert.Equal(t, 4, mailer.ConcurrencyLimit(), "permanent rejections and network errors keep the limit")
+
+		send(t, mailer, 10*time.Millisecond, nil)
+		// the smoothed latency reaches 0.8*10ms + 0.2*100ms = 28ms, more than twice the lowest one.
+		send(t, mailer, 100*time.Millisecond, nil)
+		assert.Equal(t, 2, mailer.ConcurrencyLimit())
+		send(t, mailer, 10*time.Millisecond, &SMTPError{Code: 421})
+		assert.Equal(t, 2, mailer.ConcurrencyLimit())
+	})
+	t.Run("should cut the limit once for the sends started before the previous cut", func(t *testing.T) {
+		mailer, err := NewMailerE(testHost, testPort, testUser, testPassword, withClock(clock), WithAdaptiveConcurrency(AdaptiveConcurrency{Max: 8}))
+		require.Nil(t, err)
+		mailer.concurrency.limit = 8
+
+		var releases []func(error)
+		for range 3 {
+			release, err := mailer.concurrency.acquire(context.Background())
+			require.Nil(t, err)
+			releases = append(releases, release)
+		}
+		now = now.Add(time.Second)
+		for _, release := range releases {
+			release(&SMTPError{Code: 421})
+		}
+		assert.Equal(t, 4, mailer.ConcurrencyLimit())
+	})
+	t.Run("should make sends exceeding the limit wait for a slot until their context is done", func(t *testing.T) {
+		mailer, err := NewMailerE(testHost, testPort, testUser, testPassword, withClock(clock), WithAdaptiveConcurrency(AdaptiveConcurrency{Max: 1}))
+		require.Nil(t, err)
+		release, err := mailer.concurrency.acquire(context.Background())
+		require.Nil(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = mailer.concurrency.acquire(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorContains(t, err, "failed to wait for a send slot")
+
+		acquired := make(chan error)
+		go func() {
+			release, err := mailer.concurrency.acquire(context.Background())
+			if err == nil {
+				release(nil)
+			}
+			acquired <- err
+		}()
+		select {
+		case <-acquired:
+			t.Fatal("expected the send to wait for the slot")
+		case <-time.After(10 * time.Millisecond):
+		}
+		release(nil)
+		assert.Nil(t, <-acquired)
+	})
+	t.Run("should not wait for a slot without adaptive concurrency", func(t *testing.T) {
+		mailer := NewMailer(testHost, testPort, testUser, testPassword)
+		release, err := mailer.concurrency.acquire(context.Background())
+		assert.Nil(t, err)
+		release(nil)
+		assert.Equal(t, 0, mailer.ConcurrencyLimit())
+	})
+	t.Run("should reject invalid adaptive concurrency", func(t *testing.T) {
+		tests := map[string]struct {
+			concurrency AdaptiveConcurrency
+			expectedErr string
+		}{
+			"should reject a max lower than the min": {
+				concurrency: AdaptiveConcurrency{Min: 4, Max: 2},
+				expectedErr: "adaptive concurrency bounds 4-2 must be positive and ordered",
+			},
+			"should reject a smoothing greater than 1": {
+				concurrency: AdaptiveConcurrency{Max: 2, Smoothing: 1.5},
+				expectedErr: "adaptive concurrency smoothing 1.5 must be between 0 and 1",
+			},
+			"should reject a tolerance lower than 1": {
+				concurrency: AdaptiveConcurrency{Max: 2, Tolerance: 0.5},
+				expectedErr: "adaptive concurrency tolerance 0.5 must be at least 1",
+			},
+			"should reject a backoff of 1": {
+				concurrency: AdaptiveConcurrency{Max: 2, Backoff: 1},
+				expectedErr: "adaptive concurrency backoff 1 must be between 0 and 1",
+			},
+		}
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				_, err := NewMailerE(testHost, testPort, testUser, testPassword, WithAdaptiveConcurrency(tc.concurrency))
+				assert.ErrorIs(t, err, ErrInvalidConfig)
+				assert.ErrorContains(t, err, tc.expectedErr)
+			})
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nawafswe/gomailer/message"
+)
+
+// addressValidation is the validation of the envelope recipients configured by WithAddressValidation.
+type addressValidation struct {
+	level message.ValidationLevel
+	opts  []message.ValidateOption
+}
+
+// WithAddressValidation configures Mailer to validate the envelope recipients of every message at the level before
+// the transaction starts, see message.ValidateAddress. A message with an invalid recipient is not sent, the error
+// joins the validation error of each invalid recipient. At message.LevelCallout, pass a Verifier with message.WithCallout.
+func WithAddressValidation(level message.ValidationLevel, opts ...message.ValidateOption) func(*Mailer) {
+	return func(mailer *Mailer) {
+		if level < message.LevelSyntax || level > message.LevelCallout {
+			mailer.invalidOption("address validation level %d is invalid", level)
+			return
+		}
+		mailer.addressValidation = &addressValidation{level: level, opts: opts}
+	}
+}
+
+// validateRecipients validates the envelope recipients as configured by WithAddressValidation.
+func (m *Mailer) validateRecipients(ctx context.Context, recipients []string) error {
+	if m.addressValidation == nil {
+		return nil
+	}
+	var errs []error
+	for _, r := range recipients {
+		if err := message.ValidateAddress(ctx, r, m.addressValidation.level, m.addressValidation.opts...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid recipients: %w", errors.Join(errs...))
+	}
+	return nil
+}
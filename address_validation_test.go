@@ -0,0 +1,87 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mailerMock "github.com/nawafswe/gomailer/internal/mock"
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+// verifierFunc is a message.AddressVerifier calling the function.
+type verifierFunc func(ctx context.Context, address string) error
+
+func (f verifierFunc) Verify(ctx context.Context, address string) error {
+	return f(ctx, address)
+}
+
+func TestMailer_AddressValidation(t *testing.T) {
+	rejected := errors.New("550 5.1.1 unknown user")
+	verifier := verifierFunc(func(ctx context.Context, address string) error {
+		if address == "unknown@[192.0.2.1]" {
+			return rejected
+		}
+		return nil
+	})
+	tests := map[string]struct {
+		recipients  []string
+		expectedErr error
+	}{
+		"should send messages to valid recipients": {
+			recipients: []string{"nawaf@[192.0.2.1]"},
+		},
+		"should refuse messages with an invalid recipient before the transaction starts": {
+			recipients: []string{"nawaf@[192.0.2.1]", "unknown@[192.0.2.1]"},
+			expectedErr: fmt.Errorf("failed to send message: %w", fmt.Errorf("failed to send message: %w",
+				fmt.Errorf("invalid recipients: %w", errors.Join(
+					fmt.Errorf("failed to validate address %q: %w", "unknown@[192.0.2.1]", rejected),
+				)),
+			)),
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			// prepare mocks
+			smtpMock := mailerMock.NewMockClient(ctrl)
+			netConnMock := mailerMock.NewMockconn(ctrl)
+			writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+
+			// stub functions
+			newClient := func(conn net.Conn, host string) (Client, error) {
+				return smtpMock, nil
+			}
+			netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+				return netConnMock, nil
+			}
+
+			mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone),
+				WithAddressValidation(message.LevelCallout, message.WithCallout(verifier)))
+			msg := message.Message{From: testFromEmail, Recipients: tc.recipients, Body: "dummy body"}
+
+			// expect on mocks
+			smtpMock.EXPECT().Quit().Return(nil)
+			if tc.expectedErr == nil {
+				smtpMock.EXPECT().Mail(msg.From).Return(nil)
+				smtpMock.EXPECT().Rcpt(tc.recipients[0]).Return(nil)
+				smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+				writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+					return len(b), nil
+				})
+				writeCloserMock.EXPECT().Close().Return(nil)
+			}
+
+			err := mailer.Send(context.Background(), msg)
+			assert.Equal(t, tc.expectedErr, err)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, rejected)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package gomailer
+
+import (
+	"fmt"
+	"net/smtp"
+	"slices"
+	"strings"
+)
+
+// AuthProvider provides an SMTP authentication mechanism the Mailer negotiates with servers advertising it,
+// e.g. NTLM, GSSAPI or the XOAUTH2 mechanism of a provider (see WithAuthMechanisms).
+type AuthProvider interface {
+	// Mechanism returns the name of the SASL mechanism, as advertised by the AUTH extension, e.g. "XOAUTH2".
+	Mechanism() string
+	// Auth returns the smtp.Auth authenticating to the server at host.
+	Auth(host string) smtp.Auth
+}
+
+// NewAuthProvider returns an AuthProvider of the mechanism, authenticating with the smtp.Auth returned by auth.
+func NewAuthProvider(mechanism string, auth func(host string) smtp.Auth) AuthProvider {
+	return authProvider{mechanism: mechanism, auth: auth}
+}
+
+// AuthPlain returns the AuthProvider of the PLAIN mechanism, which smtp.PlainAuth only allows over TLS or to localhost.
+func AuthPlain(username, password string) AuthProvider {
+	return NewAuthProvider(plainAuthMechanism, func(host string) smtp.Auth {
+		return smtp.PlainAuth("", username, password, host)
+	})
+}
+
+// AuthLogin returns the AuthProvider of the LOGIN mechanism.
+func AuthLogin(username, password string) AuthProvider {
+	return NewAuthProvider(loginAuthMechanism, func(string) smtp.Auth {
+		return newSmtpLoginAuth(username, password)
+	})
+}
+
+// AuthCRAMMD5 returns the AuthProvider of the CRAM-MD5 mechanism.
+func AuthCRAMMD5(username, secret string) AuthProvider {
+	return NewAuthProvider(crmAuthMechanism, func(string) smtp.Auth {
+		return smtp.CRAMMD5Auth(username, secret)
+	})
+}
+
+// authProvider is the AuthProvider returned by NewAuthProvider.
+type authProvider struct {
+	mechanism string
+	auth      func(host string) smtp.Auth
+}
+
+// Mechanism returns the name of the mechanism.
+func (p authProvider) Mechanism() string {
+	return p.mechanism
+}
+
+// Auth returns the smtp.Auth authenticating to the server at host.
+func (p authProvider) Auth(host string) smtp.Auth {
+	return p.auth(host)
+}
+
+// WithAuthMechanisms configures Mailer to authenticate with the first of the providers, in the given order, whose
+// mechanism the server advertises, in place of the CRAM-MD5, PLAIN and LOGIN selection from the Mailer credentials.
+// Connections to servers advertising none of them fail, e.g.
+//
+//	WithAuthMechanisms(gomailer.NewAuthProvider("XOAUTH2", xoauth2), gomailer.AuthPlain(username, password))
+//
+// Providers given by repeated calls are tried after the ones given before.
+func WithAuthMechanisms(providers ...AuthProvider) func(*Mailer) {
+	return func(mailer *Mailer) {
+		for _, p := range providers {
+			if p == nil || p.Mechanism() == "" {
+				mailer.invalidOption("auth provider must provide a mechanism")
+				return
+			}
+		}
+		mailer.authProviders = append(mailer.authProviders, providers...)
+	}
+}
+
+// negotiateAuth returns the smtp.Auth of the first auth provider whose mechanism is advertised in auths,
+// or an error wrapping ErrExtensionNotAdvertised when none is.
+func (m *Mailer) negotiateAuth(auths, host string) (auth, error) {
+	advertised := strings.Fields(auths)
+	mechanisms := make([]string, len(m.authProviders))
+	for i, p := range m.authProviders {
+		if slices.ContainsFunc(advertised, func(a string) bool { return strings.EqualFold(a, p.Mechanism()) }) {
+			return p.Auth(host), nil
+		}
+		mechanisms[i] = p.Mechanism()
+	}
+	return nil, fmt.Errorf("%w any of the authentication mechanisms %s", ErrExtensionNotAdvertised, strings.Join(mechanisms, ", "))
+}
@@ -0,0 +1,90 @@
+package gomailer
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/smtp"
+	"testing"
+	"time"
+
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+// xoauth2 is the XOAUTH2 mechanism of Gmail and Outlook, authenticating with an OAuth 2.0 access token.
+type xoauth2 struct {
+	username, token string
+}
+
+// Start returns the initial response carrying the user and the access token.
+func (a xoauth2) Start(_ *smtp.ServerInfo) (string, []byte, error) {
+	return "XOAUTH2", []byte("user=" + a.username + "\x01auth=Bearer " + a.token + "\x01\x01"), nil
+}
+
+// Next returns an empty response to the error challenge of the server.
+func (a xoauth2) Next(_ []byte, more bool) ([]byte, error) {
+	return nil, nil
+}
+
+func TestMailer_WithAuthMechanisms(t *testing.T) {
+	oauth := NewAuthProvider("XOAUTH2", func(string) smtp.Auth { return xoauth2{username: "user@example.com", token: "token"} })
+	tests := map[string]struct {
+		providers       []AuthProvider
+		advertised      string
+		expectedCommand string
+		expectedErr     error
+	}{
+		"should negotiate the first advertised mechanism in the given order": {
+			providers:       []AuthProvider{oauth, AuthPlain("user", "pass")},
+			advertised:      "AUTH PLAIN LOGIN xoauth2",
+			expectedCommand: "AUTH XOAUTH2 " + base64.StdEncoding.EncodeToString([]byte("user=user@example.com\x01auth=Bearer token\x01\x01")),
+		},
+		"should fall back to the next provider when a mechanism is not advertised": {
+			providers:       []AuthProvider{oauth, AuthLogin("user", "pass")},
+			advertised:      "AUTH LOGIN",
+			expectedCommand: "AUTH LOGIN",
+		},
+		"should fail when the server advertises none of the mechanisms": {
+			providers:   []AuthProvider{oauth, AuthCRAMMD5("user", "secret")},
+			advertised:  "AUTH PLAIN LOGIN",
+			expectedErr: ErrExtensionNotAdvertised,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			commands := make(chan string, 10)
+			go serveSMTP(serverConn, tc.advertised, map[string]string{"AUTH": "235 2.7.0 authenticated", "MAIL": "250 ok", "RCPT": "250 ok"}, commands)
+
+			// stub functions
+			netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+				return clientConn, nil
+			}
+
+			// the mechanisms are negotiated without the Mailer credentials.
+			mailer := NewMailer("localhost", testPort, "", "", withNetDial(netDial), WithEncryption(EncryptionNone), WithAuthMechanisms(tc.providers...))
+			err := mailer.Send(context.Background(), message.Message{From: testFromEmail, Recipients: testRecipient, Body: "hi"})
+			assert.ErrorIs(t, err, tc.expectedErr)
+			if tc.expectedErr != nil {
+				assert.ErrorContains(t, err, "any of the authentication mechanisms XOAUTH2, CRAM-MD5")
+				return
+			}
+			var got []string
+			for command := range commands {
+				got = append(got, command)
+			}
+			assert.Contains(t, got, tc.expectedCommand)
+		})
+	}
+	t.Run("should reject auth mechanisms along with auth", func(t *testing.T) {
+		_, err := NewMailerE(testHost, testPort, testUser, testPassword, WithAuth(smtp.PlainAuth("", testUser, testPassword, testHost)), WithAuthMechanisms(oauth))
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, "auth mechanisms are not negotiated when auth is given")
+	})
+	t.Run("should reject a provider without mechanism", func(t *testing.T) {
+		_, err := NewMailerE(testHost, testPort, testUser, testPassword, WithAuthMechanisms(NewAuthProvider("", nil)))
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, "auth provider must provide a mechanism")
+	})
+}
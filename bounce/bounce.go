@@ -0,0 +1,215 @@
+// Package bounce parses the delivery status notifications (DSN, RFC 3464) mail servers return for messages they could
+// not deliver, complementing the sending side for services processing returned mail:
+//
+//	report, err := bounce.Parse(r)
+//	if errors.Is(err, bounce.ErrNotReport) {
+//	    // an auto-reply or another message sent to the bounce address.
+//	}
+//	for _, rcpt := range report.Recipients {
+//	    if rcpt.Action == bounce.ActionFailed && rcpt.Status.Permanent() {
+//	        // stop sending to rcpt.FinalRecipient.
+//	    }
+//	}
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nawafswe/gomailer/smtpcode"
+)
+
+// ErrNotReport is returned by Parse for messages that are not delivery status notifications,
+// e.g. auto-replies sent to the bounce address.
+var ErrNotReport = errors.New("message is not a delivery status notification")
+
+// Action is the action the reporting server performed for a recipient (RFC 3464 section 2.3.3).
+type Action string
+
+const (
+	// ActionFailed indicates the message could not be delivered to the recipient, it was bounced.
+	ActionFailed Action = "failed"
+	// ActionDelayed indicates the delivery to the recipient is delayed, the server keeps retrying.
+	ActionDelayed Action = "delayed"
+	// ActionDelivered indicates the message was delivered to the recipient, as requested by DSN NOTIFY=SUCCESS.
+	ActionDelivered Action = "delivered"
+	// ActionRelayed indicates the message was relayed to a server not issuing delivery status notifications.
+	ActionRelayed Action = "relayed"
+	// ActionExpanded indicates the message was delivered to the recipient, a mailing list or alias, and forwarded.
+	ActionExpanded Action = "expanded"
+)
+
+// Report is a delivery status notification, reporting the status of the delivery of a message to its recipients.
+type Report struct {
+	// ReportingMTA is the server that issued the report, e.g. "mx.example.com".
+	ReportingMTA string
+	// EnvelopeID is the envelope identifier given to the returned message, see message.DSN.EnvelopeID.
+	EnvelopeID string
+	// ArrivalDate is the time the reporting server received the message, zero when not reported.
+	ArrivalDate time.Time
+	// Recipients are the recipients whose delivery is reported.
+	Recipients []Recipient
+	// MessageID is the Message-ID of the returned message, empty when its header fields were not returned.
+	MessageID string
+	// Headers are the header fields of the returned message, nil when they were not returned.
+	Headers mail.Header
+	// Text is the human readable explanation of the report, as displayed by mail clients.
+	Text string
+}
+
+// Recipient is the delivery status of a recipient of the returned message.
+type Recipient struct {
+	// FinalRecipient is the address the delivery was attempted to, e.g. after an alias was expanded.
+	FinalRecipient string
+	// OriginalRecipient is the address given to the RCPT command of the returned message, empty when not reported.
+	OriginalRecipient string
+	// Action is the action performed for the recipient, e.g. ActionFailed.
+	Action Action
+	// Status is the enhanced status code of the delivery, e.g. 5.1.1 for an unknown mailbox.
+	Status smtpcode.EnhancedCode
+	// RemoteMTA is the server that reported the status to the reporting server, empty when not reported.
+	RemoteMTA string
+	// DiagnosticCode is the reply code of the remote server, zero when it is not an SMTP diagnostic.
+	DiagnosticCode smtpcode.Code
+	// Diagnostic is the reply of the remote server, e.g. "550 5.1.1 <user@example.com>: Recipient address rejected".
+	Diagnostic string
+	// LastAttempt is the time of the last delivery attempt, zero when not reported.
+	LastAttempt time.Time
+}
+
+// Parse parses a delivery status notification, a multipart/report message with a message/delivery-status part
+// (or message/global-delivery-status for internationalized addresses, RFC 6533). The header fields of the returned
+// message are read from its text/rfc822-headers or message/rfc822 part. Messages of another type fail with ErrNotReport.
+func Parse(r io.Reader) (*Report, error) {
+	mm, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse bounce: %w", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(mm.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/report" || !strings.EqualFold(params["report-type"], "delivery-status") {
+		return nil, ErrNotReport
+	}
+	report := &Report{}
+	var status bool
+	mr := multipart.NewReader(mm.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse bounce: %w", err)
+		}
+		content, err := decodeTransferEncoding(part, part.Header.Get("Content-Transfer-Encoding"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse bounce: %w", err)
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch partType {
+		case "message/delivery-status", "message/global-delivery-status":
+			if err := report.parseStatus(content); err != nil {
+				return nil, fmt.Errorf("failed to parse bounce: %w", err)
+			}
+			status = true
+		case "text/rfc822-headers", "message/rfc822", "message/global-headers", "message/global":
+			if returned, err := mail.ReadMessage(io.MultiReader(bytes.NewReader(content), strings.NewReader("\r\n"))); err == nil {
+				report.Headers = returned.Header
+				report.MessageID = returned.Header.Get("Message-Id")
+			}
+		case "text/plain", "":
+			if report.Text == "" {
+				report.Text = strings.TrimSpace(string(content))
+			}
+		}
+	}
+	if !status {
+		return nil, fmt.Errorf("%w: missing delivery-status part", ErrNotReport)
+	}
+	return report, nil
+}
+
+// parseStatus parses the per-message fields, followed by the per-recipient fields of every recipient,
+// of a message/delivery-status part (RFC 3464 section 2.1).
+func (report *Report) parseStatus(content []byte) error {
+	tr := textproto.NewReader(bufio.NewReader(bytes.NewReader(bytes.TrimLeft(content, "\r\n"))))
+	fields, err := tr.ReadMIMEHeader()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid per-message fields: %w", err)
+	}
+	report.ReportingMTA = typedValue(fields.Get("Reporting-Mta"))
+	report.EnvelopeID = fields.Get("Original-Envelope-Id")
+	report.ArrivalDate = parseDate(fields.Get("Arrival-Date"))
+	for !errors.Is(err, io.EOF) {
+		fields, err = tr.ReadMIMEHeader()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("invalid per-recipient fields: %w", err)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		report.Recipients = append(report.Recipients, parseRecipient(fields))
+	}
+	if len(report.Recipients) == 0 {
+		return errors.New("no recipient is reported")
+	}
+	return nil
+}
+
+// parseRecipient returns the Recipient of per-recipient fields.
+func parseRecipient(fields textproto.MIMEHeader) Recipient {
+	rcpt := Recipient{
+		FinalRecipient:    typedValue(fields.Get("Final-Recipient")),
+		OriginalRecipient: typedValue(fields.Get("Original-Recipient")),
+		Action:            Action(strings.ToLower(fields.Get("Action"))),
+		RemoteMTA:         typedValue(fields.Get("Remote-Mta")),
+		LastAttempt:       parseDate(fields.Get("Last-Attempt-Date")),
+	}
+	rcpt.Status, _, _ = smtpcode.ParseEnhancedCode(fields.Get("Status"))
+	diagnosticType, diagnostic, ok := strings.Cut(fields.Get("Diagnostic-Code"), ";")
+	if ok {
+		rcpt.Diagnostic = strings.TrimSpace(diagnostic)
+		if strings.EqualFold(strings.TrimSpace(diagnosticType), "smtp") && len(rcpt.Diagnostic) >= 3 {
+			if code, err := strconv.Atoi(rcpt.Diagnostic[:3]); err == nil {
+				rcpt.DiagnosticCode = smtpcode.Code(code)
+			}
+		}
+	}
+	return rcpt
+}
+
+// typedValue returns the value of a field prefixed with its type, e.g. user@example.com for "rfc822; user@example.com".
+func typedValue(field string) string {
+	if _, value, ok := strings.Cut(field, ";"); ok {
+		return strings.TrimSpace(value)
+	}
+	return strings.TrimSpace(field)
+}
+
+// parseDate returns the time of an RFC 5322 date, zero when it is empty or invalid.
+func parseDate(s string) time.Time {
+	t, err := mail.ParseDate(s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// decodeTransferEncoding returns the content of body decoded from the given Content-Transfer-Encoding,
+// quoted-printable parts are decoded by multipart.Reader.
+func decodeTransferEncoding(body io.Reader, transferEncoding string) ([]byte, error) {
+	if strings.EqualFold(strings.TrimSpace(transferEncoding), "base64") {
+		return io.ReadAll(base64.NewDecoder(base64.StdEncoding, body))
+	}
+	return io.ReadAll(body)
+}
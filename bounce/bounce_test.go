@@ -0,0 +1,91 @@
+package bounce
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nawafswe/gomailer/smtpcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("should parse a Postfix bounce", func(t *testing.T) {
+		f, err := os.Open("testdata/postfix.eml")
+		require.Nil(t, err)
+		defer f.Close()
+
+		report, err := Parse(f)
+		require.Nil(t, err)
+		status, _, _ := smtpcode.ParseEnhancedCode("5.1.1")
+		delayed, _, _ := smtpcode.ParseEnhancedCode("4.4.1")
+		assert.Equal(t, "mx.example.org", report.ReportingMTA)
+		assert.Equal(t, "order-42", report.EnvelopeID)
+		assert.Equal(t, time.Date(2024, time.March, 5, 10, 30, 0, 0, time.UTC), report.ArrivalDate.UTC())
+		assert.Equal(t, []Recipient{
+			{
+				FinalRecipient:    "user@example.com",
+				OriginalRecipient: "user@example.com",
+				Action:            ActionFailed,
+				Status:            status,
+				RemoteMTA:         "mail.example.com",
+				DiagnosticCode:    smtpcode.MailboxUnavailable,
+				Diagnostic:        "550 5.1.1 <user@example.com>: Recipient address rejected: User unknown in virtual mailbox table",
+				LastAttempt:       report.Recipients[0].LastAttempt,
+			},
+			{
+				FinalRecipient: "other@example.net",
+				Action:         ActionDelayed,
+				Status:         delayed,
+				Diagnostic:     "connect to mx.example.net[192.0.2.1]:25: Connection timed out",
+			},
+		}, report.Recipients)
+		assert.Equal(t, time.Date(2024, time.March, 5, 10, 31, 2, 0, time.UTC), report.Recipients[0].LastAttempt.UTC())
+		assert.True(t, report.Recipients[0].Status.Permanent())
+		assert.Equal(t, "<1@example.org>", report.MessageID)
+		assert.Equal(t, "Your order", report.Headers.Get("Subject"))
+		assert.True(t, strings.HasPrefix(report.Text, "This is the mail system at host mx.example.org."))
+	})
+
+	tests := map[string]struct {
+		raw         string
+		expectedErr error
+		expected    string
+	}{
+		"should refuse a message that is not a report": {
+			raw:         "From: user@example.com\r\nSubject: Out of office\r\nContent-Type: text/plain\r\n\r\nI am away.\r\n",
+			expectedErr: ErrNotReport,
+		},
+		"should refuse a report of another type": {
+			raw:         "Content-Type: multipart/report; report-type=disposition-notification; boundary=b\r\n\r\n--b\r\nContent-Type: text/plain\r\n\r\nread\r\n--b--\r\n",
+			expectedErr: ErrNotReport,
+		},
+		"should refuse a report without delivery-status part": {
+			raw:         "Content-Type: multipart/report; report-type=delivery-status; boundary=b\r\n\r\n--b\r\nContent-Type: text/plain\r\n\r\nfailed\r\n--b--\r\n",
+			expectedErr: ErrNotReport,
+		},
+		"should parse a base64 encoded delivery-status part of an internationalized report": {
+			raw: "Content-Type: multipart/report; report-type=delivery-status; boundary=b\r\n\r\n" +
+				"--b\r\nContent-Type: message/global-delivery-status\r\nContent-Transfer-Encoding: base64\r\n\r\n" +
+				"UmVwb3J0aW5nLU1UQTogZG5zOyBteC5leGFtcGxlLm9yZwoKRmluYWwtUmVjaXBpZW50OiB1dGYt\r\n" +
+				"ODsgdXNlckBleGFtcGxlLmNvbQpBY3Rpb246IGZhaWxlZApTdGF0dXM6IDUuMi4yCg==\r\n--b--\r\n",
+			expected: "user@example.com",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			report, err := Parse(strings.NewReader(tc.raw))
+			assert.ErrorIs(t, err, tc.expectedErr)
+			if tc.expectedErr != nil {
+				assert.Nil(t, report)
+				return
+			}
+			if assert.Len(t, report.Recipients, 1) {
+				assert.Equal(t, tc.expected, report.Recipients[0].FinalRecipient)
+				assert.Equal(t, "5.2.2", report.Recipients[0].Status.String())
+			}
+		})
+	}
+}
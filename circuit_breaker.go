@@ -0,0 +1,92 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when a connection is not attempted because the circuit breaker is open (see WithCircuitBreaker).
+var ErrCircuitOpen = errors.New("circuit breaker is open, smtp server is failing")
+
+// CircuitBreaker stops connecting to an SMTP server that keeps failing.
+type CircuitBreaker struct {
+	// Threshold is the number of consecutive connection or authentication failures opening the circuit.
+	Threshold int
+	// Cooldown is how long the open circuit fails fast before a connection is tried again.
+	Cooldown time.Duration
+	// Fallback connects in place of the Mailer while the circuit is open, e.g. a secondary relay.
+	// Connections fail with ErrCircuitOpen when nil.
+	Fallback *Mailer
+}
+
+// WithCircuitBreaker configures Mailer to open the circuit after cb.Threshold consecutive connection or authentication
+// failures, so sends fail fast with ErrCircuitOpen for cb.Cooldown instead of waiting on a down relay, or go through
+// cb.Fallback when one is given. Once the cooldown passed, a single connection is tried: the circuit closes when it
+// succeeds and opens again for another cooldown when it fails. Failures of canceled sends are not counted.
+//
+// The OnWarning hooks are invoked with an error wrapping ErrCircuitOpen whenever the circuit opens.
+// Messages sent through the fallback are hooked, measured and traced by the fallback.
+func WithCircuitBreaker(cb CircuitBreaker) func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.circuitBreaker = &circuitBreaker{CircuitBreaker: cb, now: mailer.now}
+	}
+}
+
+// circuitBreaker counts the consecutive connection failures of a Mailer.
+type circuitBreaker struct {
+	CircuitBreaker
+	// now returns the current time of the Mailer clock.
+	now func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	// openUntil is when the open circuit lets a connection be tried again.
+	openUntil time.Time
+	// probing indicates a connection is being tried after the cooldown, the circuit stays open meanwhile.
+	probing bool
+}
+
+// allow reports whether a connection may be attempted, it always does when cb is nil or never opens.
+func (cb *circuitBreaker) allow() bool {
+	if cb == nil || cb.Threshold < 1 {
+		return true
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.failures < cb.Threshold {
+		return true
+	}
+	if cb.probing || cb.now().Before(cb.openUntil) {
+		return false
+	}
+	cb.probing = true
+	return true
+}
+
+// record counts the result of an allowed connection attempt, it reports whether it opened the circuit
+// and the number of consecutive failures.
+func (cb *circuitBreaker) record(ctx context.Context, err error) (opened bool, failures int) {
+	if cb == nil {
+		return false, 0
+	}
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	probing := cb.probing
+	cb.probing = false
+	if err == nil {
+		cb.failures = 0
+		return false, 0
+	}
+	if ctx.Err() != nil {
+		// the send was canceled, which says nothing about the server.
+		return false, cb.failures
+	}
+	cb.failures++
+	if cb.failures < cb.Threshold || (!probing && cb.failures > cb.Threshold) {
+		return false, cb.failures
+	}
+	cb.openUntil = cb.now().Add(cb.Cooldown)
+	return true, cb.failures
+}
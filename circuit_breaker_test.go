@@ -0,0 +1,150 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mailerMock "github.com/nawafswe/gomailer/internal/mock"
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMailer_CircuitBreaker(t *testing.T) {
+	msg := message.Message{
+		From:       testFromEmail,
+		Recipients: testRecipient,
+		Body:       "dummy body",
+	}
+	start := time.Date(2024, time.March, 5, 10, 30, 0, 0, time.UTC)
+	t.Run("should fail fast once the circuit opened and try again after the cooldown", func(t *testing.T) {
+		now := start
+		clock := func() time.Time { return now }
+		// stub functions
+		var dials int
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			dials++
+			return nil, fmt.Errorf("dummy error")
+		}
+
+		var warnings []error
+		mailer := NewMailer(testHost, testPort, "", "", withNetDial(netDial), withClock(clock), WithEncryption(EncryptionNone),
+			WithCircuitBreaker(CircuitBreaker{Threshold: 2, Cooldown: time.Minute}),
+			WithHooks(Hooks{
+				OnWarning: func(ctx context.Context, err error) {
+					warnings = append(warnings, err)
+				},
+			}),
+		)
+
+		assert.NotErrorIs(t, mailer.Send(context.Background(), msg), ErrCircuitOpen)
+		assert.Empty(t, warnings)
+		assert.NotErrorIs(t, mailer.Send(context.Background(), msg), ErrCircuitOpen)
+		assert.Len(t, warnings, 1)
+		assert.ErrorIs(t, warnings[0], ErrCircuitOpen)
+		assert.ErrorIs(t, mailer.Send(context.Background(), msg), ErrCircuitOpen)
+		assert.Equal(t, 2, dials)
+
+		// the connection tried after the cooldown fails, opening the circuit again.
+		now = now.Add(time.Minute)
+		assert.NotErrorIs(t, mailer.Send(context.Background(), msg), ErrCircuitOpen)
+		assert.ErrorIs(t, mailer.Send(context.Background(), msg), ErrCircuitOpen)
+		assert.Equal(t, 3, dials)
+		assert.Len(t, warnings, 2)
+	})
+	t.Run("should close the circuit once a connection succeeds", func(t *testing.T) {
+		now := start
+		clock := func() time.Time { return now }
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		fail := true
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			if fail {
+				return nil, fmt.Errorf("dummy error")
+			}
+			return netConnMock, nil
+		}
+
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), withClock(clock), WithEncryption(EncryptionNone),
+			WithCircuitBreaker(CircuitBreaker{Threshold: 1, Cooldown: time.Minute}),
+		)
+		_, err := mailer.ConnectAndAuthenticate()
+		assert.NotErrorIs(t, err, ErrCircuitOpen)
+		_, err = mailer.ConnectAndAuthenticate()
+		assert.ErrorIs(t, err, ErrCircuitOpen)
+
+		now, fail = now.Add(time.Minute), false
+		_, err = mailer.ConnectAndAuthenticate()
+		assert.Nil(t, err)
+		fail = true
+		_, err = mailer.ConnectAndAuthenticate()
+		assert.NotErrorIs(t, err, ErrCircuitOpen)
+	})
+	t.Run("should connect through the fallback while the circuit is open", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, addr string, t time.Duration) (net.Conn, error) {
+			if strings.HasPrefix(addr, testHost) {
+				return nil, fmt.Errorf("dummy error")
+			}
+			return netConnMock, nil
+		}
+
+		fallback := NewMailer("backup.example.com", testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone))
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone),
+			WithCircuitBreaker(CircuitBreaker{Threshold: 1, Cooldown: time.Minute, Fallback: fallback}),
+		)
+
+		// expect on mocks
+		smtpMock.EXPECT().Mail(msg.From).Return(nil)
+		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
+		smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+		smtpMock.EXPECT().Quit().Return(nil)
+		writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			return len(b), nil
+		})
+		writeCloserMock.EXPECT().Close().Return(nil)
+
+		assert.NotNil(t, mailer.Send(context.Background(), msg))
+		assert.Nil(t, mailer.Send(context.Background(), msg))
+	})
+	t.Run("should not count canceled sends", func(t *testing.T) {
+		// stub functions
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return nil, fmt.Errorf("dummy error")
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		mailer := NewMailer(testHost, testPort, "", "", withNetDial(netDial), WithEncryption(EncryptionNone),
+			WithCircuitBreaker(CircuitBreaker{Threshold: 1, Cooldown: time.Minute}),
+		)
+
+		assert.NotErrorIs(t, mailer.Send(ctx, msg), ErrCircuitOpen)
+		assert.NotErrorIs(t, mailer.Send(context.Background(), msg), ErrCircuitOpen)
+		assert.ErrorIs(t, mailer.Send(context.Background(), msg), ErrCircuitOpen)
+	})
+	t.Run("should refuse invalid circuit breakers", func(t *testing.T) {
+		_, err := NewMailerE(testHost, testPort, testUser, testPassword, WithCircuitBreaker(CircuitBreaker{Cooldown: time.Minute}))
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+		_, err = NewMailerE(testHost, testPort, testUser, testPassword, WithCircuitBreaker(CircuitBreaker{Threshold: 3}))
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+	})
+}
@@ -0,0 +1,164 @@
+package gomailer
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ConnectionReuse configures Mailer.Send to keep the authenticated connections open once a message was sent,
+// and to send the next messages over them instead of connecting and authenticating for every message.
+type ConnectionReuse struct {
+	// MaxIdle is the number of idle connections kept open, the connections of concurrent sends exceeding it are closed.
+	// It is 1 when zero.
+	MaxIdle int
+	// IdleTimeout closes the connections left idle for longer, SMTP servers commonly drop idle clients after a few
+	// minutes. Idle connections are kept until reused or closed by the server when zero.
+	IdleTimeout time.Duration
+	// MaxLifetime closes the connections open for longer, e.g. so connections pick up rotated credentials or
+	// rebalance across the servers of a load balancer. Connections are kept for any time when zero.
+	MaxLifetime time.Duration
+}
+
+// WithConnectionReuse configures Mailer.Send to reuse the connection of the previous send, as configured by r.
+// An idle connection is checked with RSET before it is reused, Send transparently connects again when the server
+// closed it. Sends requiring TLS (see SendOptions.RequireTLS) always connect. Idle connections are closed by
+// Mailer.CloseIdleConnections, which should be called once the Mailer is no longer used.
+func WithConnectionReuse(r ConnectionReuse) func(*Mailer) {
+	return func(mailer *Mailer) {
+		if r.MaxIdle == 0 {
+			r.MaxIdle = 1
+		}
+		if r.MaxIdle < 0 || r.IdleTimeout < 0 || r.MaxLifetime < 0 {
+			mailer.invalidOption("connection reuse max idle %d, idle timeout %s and max lifetime %s cannot be negative",
+				r.MaxIdle, r.IdleTimeout, r.MaxLifetime)
+			return
+		}
+		mailer.connCache = &connCache{ConnectionReuse: r, now: mailer.now}
+	}
+}
+
+// CloseIdleConnections closes the connections kept open for reuse (see WithConnectionReuse).
+// Sends may still open and keep connections afterward.
+func (m *Mailer) CloseIdleConnections() {
+	if m == nil || m.connCache == nil {
+		return
+	}
+	for _, conn := range m.connCache.drain() {
+		_ = conn.Close()
+	}
+}
+
+// acquireSender returns a live idle connection when connection reuse is configured, or connects and authenticates
+// a new one. It returns the time the connection was established along with it, zero when it is not reused.
+func (m *Mailer) acquireSender(ctx context.Context) (*mailSender, time.Time, error) {
+	if m != nil && m.connCache != nil && !requireTLS(ctx) {
+		for conn := m.connCache.get(); conn != nil; conn = m.connCache.get() {
+			if err := conn.probe(ctx); err == nil {
+				return conn.mailSender, conn.connected, nil
+			}
+			// the server closed the connection, e.g. after its idle timeout.
+			_ = conn.Client.Close()
+		}
+	}
+	sender, err := m.connectAndAuthenticate(ctx)
+	if err != nil || m.connCache == nil {
+		return sender, time.Time{}, err
+	}
+	return sender, m.now(), nil
+}
+
+// releaseSender keeps the connection open for reuse when connection reuse is configured and the send over it
+// succeeded, or closes it.
+func (m *Mailer) releaseSender(sender *mailSender, connected time.Time, err error) {
+	if m.connCache == nil || err != nil || sender.aborted.Load() ||
+		!m.connCache.put(&cachedConn{mailSender: sender, connected: connected, idleSince: m.now()}) {
+		_ = sender.Close()
+	}
+}
+
+// requireTLS reports whether the send with ctx requires TLS (see SendOptions.RequireTLS).
+func requireTLS(ctx context.Context) bool {
+	opts, _ := SendOptionsFromContext(ctx)
+	return opts.RequireTLS
+}
+
+// cachedConn is an idle connection kept by connCache.
+type cachedConn struct {
+	*mailSender
+	// connected is the time the connection was established.
+	connected time.Time
+	// idleSince is the time the connection was last used.
+	idleSince time.Time
+}
+
+// probe checks the connection is still alive with RSET, applying the timeouts and the deadline of ctx to it.
+func (c *cachedConn) probe(ctx context.Context) error {
+	if dc, ok := c.Client.(deadlineClient); ok {
+		deadline, _ := ctx.Deadline()
+		dc.setTimeouts(c.mailer.commandTimeout, c.mailer.dataTimeout, deadline)
+	}
+	return c.Reset()
+}
+
+// connCache keeps the idle connections of a Mailer, see WithConnectionReuse.
+type connCache struct {
+	ConnectionReuse
+	// now returns the current time of the Mailer clock.
+	now func() time.Time
+
+	mu sync.Mutex
+	// idle are the idle connections, the most recently used last.
+	idle []*cachedConn
+}
+
+// expired reports whether the connection exceeded its idle timeout or its lifetime at now.
+func (c *connCache) expired(conn *cachedConn, now time.Time) bool {
+	return (c.IdleTimeout > 0 && now.Sub(conn.idleSince) >= c.IdleTimeout) ||
+		(c.MaxLifetime > 0 && now.Sub(conn.connected) >= c.MaxLifetime)
+}
+
+// get returns the most recently used idle connection, nil when none is left. Expired connections are closed.
+func (c *connCache) get() *cachedConn {
+	now := c.now()
+	c.mu.Lock()
+	var expired []*cachedConn
+	var conn *cachedConn
+	for len(c.idle) > 0 {
+		last := c.idle[len(c.idle)-1]
+		c.idle = c.idle[:len(c.idle)-1]
+		if !c.expired(last, now) {
+			conn = last
+			break
+		}
+		expired = append(expired, last)
+	}
+	c.mu.Unlock()
+	for _, e := range expired {
+		_ = e.Close()
+	}
+	return conn
+}
+
+// put keeps the connection for reuse, it returns false when the cache is full or the connection exceeded its lifetime.
+func (c *connCache) put(conn *cachedConn) bool {
+	if c.expired(conn, conn.idleSince) {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.idle) >= c.MaxIdle {
+		return false
+	}
+	c.idle = append(c.idle, conn)
+	return true
+}
+
+// drain removes and returns every idle connection.
+func (c *connCache) drain() []*cachedConn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	idle := c.idle
+	c.idle = nil
+	return idle
+}
@@ -0,0 +1,90 @@
+package gomailer
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMailer_WithConnectionReuse(t *testing.T) {
+	replies := map[string]string{"MAIL": "250 ok", "RCPT": "250 ok", "RSET": "250 ok"}
+	msg := message.Message{From: testFromEmail, Recipients: testRecipient, Body: "hi"}
+	tests := map[string]struct {
+		reuse ConnectionReuse
+		// closeIdle closes the server side of the idle connection before the second send.
+		closeIdle        bool
+		expectedDials    int
+		expectedCommands [][]string
+	}{
+		"should send the next message over the idle connection": {
+			expectedDials: 1,
+			expectedCommands: [][]string{{
+				"EHLO localhost", "MAIL FROM:<test@gomailer.com> BODY=8BITMIME", "RCPT TO:<test@gomailer.com>", "DATA",
+				"RSET", "MAIL FROM:<test@gomailer.com> BODY=8BITMIME", "RCPT TO:<test@gomailer.com>", "DATA", "QUIT",
+			}},
+		},
+		"should connect again when the server closed the idle connection": {
+			closeIdle:     true,
+			expectedDials: 2,
+			expectedCommands: [][]string{
+				{"EHLO localhost", "MAIL FROM:<test@gomailer.com> BODY=8BITMIME", "RCPT TO:<test@gomailer.com>", "DATA"},
+				{"EHLO localhost", "MAIL FROM:<test@gomailer.com> BODY=8BITMIME", "RCPT TO:<test@gomailer.com>", "DATA", "QUIT"},
+			},
+		},
+		"should close the connection exceeding its lifetime": {
+			reuse:         ConnectionReuse{MaxLifetime: time.Minute},
+			expectedDials: 2,
+			expectedCommands: [][]string{
+				{"EHLO localhost", "MAIL FROM:<test@gomailer.com> BODY=8BITMIME", "RCPT TO:<test@gomailer.com>", "DATA", "QUIT"},
+				{"EHLO localhost", "MAIL FROM:<test@gomailer.com> BODY=8BITMIME", "RCPT TO:<test@gomailer.com>", "DATA", "QUIT"},
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// stub functions, the clock advances a minute every time it is read.
+			now := time.Date(2024, time.March, 5, 10, 30, 0, 0, time.UTC)
+			clock := func() time.Time {
+				now = now.Add(time.Minute)
+				return now
+			}
+			var (
+				connections []chan string
+				servers     []net.Conn
+			)
+			netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+				clientConn, serverConn := net.Pipe()
+				commands := make(chan string, 20)
+				connections = append(connections, commands)
+				servers = append(servers, serverConn)
+				go serveSMTP(serverConn, "8BITMIME", replies, commands)
+				return clientConn, nil
+			}
+
+			mailer := NewMailer("localhost", testPort, "", "", withNetDial(netDial), withClock(clock), WithEncryption(EncryptionNone), WithConnectionReuse(tc.reuse))
+			require.Nil(t, mailer.Send(context.Background(), msg))
+			if tc.closeIdle {
+				require.Nil(t, servers[0].Close())
+			}
+			require.Nil(t, mailer.Send(context.Background(), msg))
+			mailer.CloseIdleConnections()
+
+			assert.Len(t, connections, tc.expectedDials)
+			var got [][]string
+			for _, commands := range connections {
+				got = append(got, receive(commands))
+			}
+			assert.Equal(t, tc.expectedCommands, got)
+		})
+	}
+	t.Run("should reject negative settings", func(t *testing.T) {
+		_, err := NewMailerE(testHost, testPort, testUser, testPassword, WithConnectionReuse(ConnectionReuse{IdleTimeout: -time.Second}))
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, "connection reuse max idle 1, idle timeout -1s and max lifetime 0s cannot be negative")
+	})
+}
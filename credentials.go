@@ -0,0 +1,37 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+)
+
+// CredentialsProvider returns the username and password to authenticate to the SMTP server with.
+// It receives the context given to Send or SendBatch, a background one for ConnectAndAuthenticate.
+type CredentialsProvider func(ctx context.Context) (username, password string, err error)
+
+// WithCredentialsProvider configures Mailer to authenticate with the credentials returned by p, fetched for every
+// connection authenticating to the server, in place of the username and password given to NewMailer. Rotating
+// secrets, e.g. read from Vault or AWS Secrets Manager, are then used as soon as they change without recreating the
+// Mailer. The password is used for CRAM-MD5 as well. Connections fail when p returns an error.
+func WithCredentialsProvider(p CredentialsProvider) func(*Mailer) {
+	return func(mailer *Mailer) {
+		if p == nil {
+			mailer.invalidOption("credentials provider cannot be nil")
+			return
+		}
+		mailer.credentials = p
+	}
+}
+
+// credentialsOf returns the username, password and CRAM-MD5 secrets to authenticate with,
+// the ones of the Mailer or the ones returned by its CredentialsProvider.
+func (m *Mailer) credentialsOf(ctx context.Context) (username, password, secrets string, err error) {
+	if m.credentials == nil {
+		return m.Username, m.Password, m.secrets, nil
+	}
+	username, password, err = m.credentials(ctx)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to get credentials: %w", err)
+	}
+	return username, password, password, nil
+}
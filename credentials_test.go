@@ -0,0 +1,78 @@
+package gomailer
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"testing"
+	"time"
+
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+type tenantKey struct{}
+
+func TestMailer_WithCredentialsProvider(t *testing.T) {
+	t.Run("should fetch the credentials for every connection", func(t *testing.T) {
+		// stub functions
+		plainAuth := func(identity, username, password, host string) auth {
+			return smtp.PlainAuth(identity, username, password, host)
+		}
+		var connections []chan string
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			clientConn, serverConn := net.Pipe()
+			commands := make(chan string, 10)
+			connections = append(connections, commands)
+			go serveSMTP(serverConn, "AUTH PLAIN", map[string]string{"AUTH": "235 2.7.0 authenticated", "MAIL": "250 ok", "RCPT": "250 ok"}, commands)
+			return clientConn, nil
+		}
+
+		var fetches int
+		mailer := NewMailer("localhost", testPort, "", "", withNetDial(netDial), withPlainAuth(plainAuth), WithEncryption(EncryptionNone),
+			WithCredentialsProvider(func(ctx context.Context) (string, string, error) {
+				fetches++
+				return ctx.Value(tenantKey{}).(string), fmt.Sprintf("rotated-%d", fetches), nil
+			}))
+		ctx := context.WithValue(context.Background(), tenantKey{}, "tenant@example.com")
+		for range 2 {
+			assert.Nil(t, mailer.Send(ctx, message.Message{From: testFromEmail, Recipients: testRecipient, Body: "hi"}))
+		}
+		assert.Equal(t, 2, fetches)
+		for i, commands := range connections {
+			password := fmt.Sprintf("rotated-%d", i+1)
+			assert.Contains(t, receive(commands), "AUTH PLAIN "+base64.StdEncoding.EncodeToString([]byte("\x00tenant@example.com\x00"+password)))
+		}
+	})
+	t.Run("should fail the connection when the credentials cannot be fetched", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		go serveSMTP(serverConn, "AUTH PLAIN", nil, make(chan string, 10))
+
+		// stub functions
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return clientConn, nil
+		}
+
+		errSealed := errors.New("vault is sealed")
+		mailer := NewMailer("localhost", testPort, "", "", withNetDial(netDial), WithEncryption(EncryptionNone),
+			WithCredentialsProvider(func(context.Context) (string, string, error) { return "", "", errSealed }))
+		err := mailer.Send(context.Background(), message.Message{From: testFromEmail, Recipients: testRecipient, Body: "hi"})
+		assert.ErrorIs(t, err, errSealed)
+		assert.ErrorContains(t, err, "failed to authenticate with smtp server: failed to get credentials")
+	})
+	t.Run("should reject conflicting configurations", func(t *testing.T) {
+		provider := func(context.Context) (string, string, error) { return testUser, testPassword, nil }
+		_, err := NewMailerE(testHost, testPort, testUser, testPassword, WithCredentialsProvider(provider))
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, "static credentials are given along with a credentials provider")
+
+		_, err = NewMailerE(testHost, testPort, "", "", WithCredentialsProvider(provider), WithAuthMechanisms(AuthLogin(testUser, testPassword)))
+		assert.ErrorContains(t, err, "credentials provider is not used when auth or auth mechanisms are given")
+
+		_, err = NewMailerE(testHost, testPort, "", "", WithCredentialsProvider(nil))
+		assert.ErrorContains(t, err, "credentials provider cannot be nil")
+	})
+}
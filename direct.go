@@ -0,0 +1,184 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nawafswe/gomailer/internal/punycode"
+	"github.com/nawafswe/gomailer/message"
+	"github.com/nawafswe/gomailer/smtpcode"
+)
+
+// DirectOptions to configure DirectTransport.
+type DirectOptions func(*DirectTransport)
+
+// WithDirectLocalName configures DirectTransport with the hostname sent with EHLO, it should resolve back to the sending IP
+// as mail servers often reject clients with a mismatching name. The domain of the envelope sender is sent when empty.
+func WithDirectLocalName(l string) func(*DirectTransport) {
+	return func(transport *DirectTransport) {
+		transport.localName = l
+	}
+}
+
+// WithDirectMailerOptions configures DirectTransport with options applied to the Mailer delivering to every mail server,
+// e.g. WithHooks, WithTLSConfig or WithCommandTimeout. The host, port, credentials and encryption are set by DirectTransport.
+func WithDirectMailerOptions(opts ...Options) func(*DirectTransport) {
+	return func(transport *DirectTransport) {
+		transport.opts = append(transport.opts, opts...)
+	}
+}
+
+// DirectTransport is a Transport delivering messages to the mail servers of the recipient domains, found by their MX records,
+// without a relay, for senders running their own IPs. Recipients are grouped by domain and every domain is delivered to once,
+// trying its mail servers by MX preference: the next one is tried when a server is unreachable or replies with a temporary failure.
+// STARTTLS is used when the server advertises it. Every domain is delivered to the same message, with one Message-ID and Date,
+// and the delivery to a domain is retried along the retry policy given to WithDirectMailerOptions (see WithRetryPolicy).
+type DirectTransport struct {
+	// localName is the hostname sent with EHLO.
+	localName string
+	// opts are applied to the Mailer delivering to every mail server.
+	opts []Options
+}
+
+// DirectTransport must implement Transport.
+var _ Transport = (*DirectTransport)(nil)
+
+// NewDirectTransport creates a new Transport delivering messages directly to the mail servers of the recipients.
+func NewDirectTransport(opts ...DirectOptions) *DirectTransport {
+	transport := &DirectTransport{}
+	for _, opt := range opts {
+		opt(transport)
+	}
+	return transport
+}
+
+// DeliveryError is returned by DirectTransport.Send for every domain the message could not be delivered to.
+type DeliveryError struct {
+	// Domain the message was delivered to.
+	Domain string
+	// Recipients of the domain.
+	Recipients []string
+
+	err       error
+	temporary bool
+}
+
+// Error returns the domain along with the failure of its mail servers.
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("failed to deliver to %s: %v", e.Domain, e.err)
+}
+
+// Unwrap returns the underlying error.
+func (e *DeliveryError) Unwrap() error {
+	return e.err
+}
+
+// Temporary reports whether no mail server of the domain rejected the message permanently, e.g. all of them were unreachable
+// or replied with a temporary failure, so delivering the message to the recipients of the domain may be retried later.
+func (e *DeliveryError) Temporary() bool {
+	return e.temporary
+}
+
+// Send delivers the message to the mail servers of every recipient domain, it returns the joined *DeliveryError
+// of the domains the message could not be delivered to, the message was delivered to the other domains.
+func (d *DirectTransport) Send(ctx context.Context, msg message.Message) error {
+	var (
+		domains    []string
+		recipients = make(map[string][]string)
+	)
+	for _, r := range msg.Recipients {
+		addr := message.EnvelopeAddress(r)
+		domain := strings.ToLower(addr[strings.LastIndexByte(addr, '@')+1:])
+		if ascii, err := punycode.ToASCII(domain); err == nil {
+			// internationalized domains are looked up by their punycode form.
+			domain = ascii
+		}
+		if _, ok := recipients[domain]; !ok {
+			domains = append(domains, domain)
+		}
+		recipients[domain] = append(recipients[domain], r)
+	}
+	localName, err := d.localNameOf(ctx, msg)
+	if err != nil {
+		return err
+	}
+	// the domains are delivered to the same message, stamped once for all of them.
+	ctx, err = d.newMailer("", localName).withSendState(ctx, msg)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, domain := range domains {
+		mailer := d.newMailer(domain, localName)
+		_, err := mailer.withRetries(ctx, func() error {
+			return d.deliver(ctx, msg, localName, domain, recipients[domain])
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// localNameOf returns the hostname sent with EHLO for the message sent with ctx, the one configured with
+// WithDirectLocalName or the domain of the envelope sender.
+func (d *DirectTransport) localNameOf(ctx context.Context, msg message.Message) (string, error) {
+	if d.localName != "" {
+		return d.localName, nil
+	}
+	from := message.EnvelopeAddress(envelopeFrom(ctx, msg))
+	at := strings.LastIndexByte(from, '@')
+	if at < 0 || at == len(from)-1 {
+		return "", fmt.Errorf("%w: local name cannot be derived from the envelope sender %q, see WithDirectLocalName", ErrInvalidConfig, from)
+	}
+	domain := strings.ToLower(from[at+1:])
+	if ascii, err := punycode.ToASCII(domain); err == nil {
+		domain = ascii
+	}
+	return domain, nil
+}
+
+// newMailer creates the Mailer delivering to the mail server host, greeting it with localName.
+func (d *DirectTransport) newMailer(host, localName string) *Mailer {
+	opts := append([]Options{WithLocalName(localName)}, d.opts...)
+	mailer := NewMailer(host, smtpPort, "", "", append(opts, WithEncryption(EncryptionOpportunistic))...)
+	mailer.direct = true
+	return mailer
+}
+
+// deliver delivers the message to the recipients of domain, trying the mail servers of the domain by MX preference.
+func (d *DirectTransport) deliver(ctx context.Context, msg message.Message, localName, domain string, recipients []string) error {
+	hosts, err := mailServers(ctx, domain)
+	if err != nil {
+		return &DeliveryError{Domain: domain, Recipients: recipients, err: err, temporary: !errors.Is(err, ErrNoMailServer)}
+	}
+	var errs []error
+	for _, host := range hosts {
+		err := d.deliverTo(ctx, msg, localName, host, recipients)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", host, err))
+		if code, ok := replyCode(err); ok && smtpcode.Code(code).Permanent() {
+			// the domain rejected the message, other servers of the domain are expected to reject it as well.
+			return &DeliveryError{Domain: domain, Recipients: recipients, err: errors.Join(errs...)}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return &DeliveryError{Domain: domain, Recipients: recipients, err: errors.Join(errs...), temporary: true}
+}
+
+// deliverTo connects to the mail server host and sends the message to the recipients.
+func (d *DirectTransport) deliverTo(ctx context.Context, msg message.Message, localName, host string, recipients []string) error {
+	sender, err := d.newMailer(host, localName).connectAndAuthenticate(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer sender.Close()
+
+	return sender.sendTo(ctx, msg, recipients)
+}
@@ -0,0 +1,191 @@
+package gomailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nawafswe/gomailer/internal/mx"
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectTransport_Send(t *testing.T) {
+	accepted := map[string]string{"MAIL": "250 ok", "RCPT": "250 ok"}
+	tests := map[string]struct {
+		recipients []string
+		mxs        map[string][]*net.MX
+		// replies are the replies of the mail servers by address, unknown servers are unreachable.
+		replies           map[string]map[string]string
+		expectedRcpts     map[string][]string
+		expectedErr       bool
+		expectedTemporary bool
+	}{
+		"should deliver to the mail server of every recipient domain": {
+			recipients: []string{"a@example.com", "B <b@Example.org>", "c@example.com"},
+			mxs:        map[string][]*net.MX{"example.com": {{Host: "mx.example.com.", Pref: 10}}},
+			replies:    map[string]map[string]string{"mx.example.com:25": accepted, "example.org:25": accepted},
+			expectedRcpts: map[string][]string{
+				"mx.example.com:25": {"RCPT TO:<a@example.com>", "RCPT TO:<c@example.com>"},
+				"example.org:25":    {"RCPT TO:<b@Example.org>"},
+			},
+		},
+		"should try the next mail server on a temporary failure": {
+			recipients: []string{"a@example.com"},
+			mxs:        map[string][]*net.MX{"example.com": {{Host: "mx1.example.com.", Pref: 10}, {Host: "mx2.example.com.", Pref: 20}}},
+			replies: map[string]map[string]string{
+				"mx1.example.com:25": {"MAIL": "451 4.3.0 try again later"},
+				"mx2.example.com:25": accepted,
+			},
+			expectedRcpts: map[string][]string{"mx2.example.com:25": {"RCPT TO:<a@example.com>"}},
+		},
+		"should not try the next mail server when the message is rejected": {
+			recipients: []string{"a@example.com"},
+			mxs:        map[string][]*net.MX{"example.com": {{Host: "mx1.example.com.", Pref: 10}, {Host: "mx2.example.com.", Pref: 20}}},
+			replies: map[string]map[string]string{
+				"mx1.example.com:25": {"MAIL": "250 ok", "RCPT": "550 5.1.1 user unknown"},
+				"mx2.example.com:25": accepted,
+			},
+			expectedRcpts: map[string][]string{"mx1.example.com:25": {"RCPT TO:<a@example.com>"}},
+			expectedErr:   true,
+		},
+		"should report a temporary failure when no mail server is reachable": {
+			recipients:        []string{"a@example.com"},
+			mxs:               map[string][]*net.MX{"example.com": {{Host: "mx1.example.com.", Pref: 10}, {Host: "mx2.example.com.", Pref: 20}}},
+			expectedRcpts:     map[string][]string{},
+			expectedErr:       true,
+			expectedTemporary: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// stub functions
+			mx.LookupMX = func(ctx context.Context, name string) ([]*net.MX, error) {
+				return tc.mxs[name], nil
+			}
+			defer func() { mx.LookupMX = net.DefaultResolver.LookupMX }()
+			var (
+				mu    sync.Mutex
+				wg    sync.WaitGroup
+				rcpts = make(map[string][]string)
+			)
+			netDial := func(network string, addr string, t time.Duration) (net.Conn, error) {
+				replies, ok := tc.replies[addr]
+				if !ok {
+					return nil, errors.New("connection refused")
+				}
+				clientConn, serverConn := net.Pipe()
+				commands := make(chan string, 10)
+				go serveSMTP(serverConn, "8BITMIME", replies, commands)
+				wg.Go(func() {
+					for command := range commands {
+						if len(command) > 4 && command[:4] == "RCPT" {
+							mu.Lock()
+							rcpts[addr] = append(rcpts[addr], command)
+							mu.Unlock()
+						}
+					}
+				})
+				return clientConn, nil
+			}
+
+			transport := NewDirectTransport(WithDirectLocalName("mta.example.net"), WithDirectMailerOptions(withNetDial(netDial)))
+			err := transport.Send(context.Background(), message.Message{From: testFromEmail, Recipients: tc.recipients, Body: "dummy body"})
+			wg.Wait()
+			assert.Equal(t, tc.expectedRcpts, rcpts)
+			if !tc.expectedErr {
+				assert.Nil(t, err)
+				return
+			}
+			var deliveryErr *DeliveryError
+			assert.ErrorAs(t, err, &deliveryErr)
+			assert.Equal(t, "example.com", deliveryErr.Domain)
+			assert.Equal(t, tc.recipients, deliveryErr.Recipients)
+			assert.Equal(t, tc.expectedTemporary, IsTemporary(err))
+		})
+	}
+	t.Run("should deliver every domain the same message with the local name derived from the envelope sender", func(t *testing.T) {
+		// stub functions
+		mx.LookupMX = func(ctx context.Context, name string) ([]*net.MX, error) {
+			return nil, nil
+		}
+		defer func() { mx.LookupMX = net.DefaultResolver.LookupMX }()
+		var (
+			mu      sync.Mutex
+			wg      sync.WaitGroup
+			greeted []string
+			sent    []message.Message
+		)
+		netDial := func(network string, addr string, t time.Duration) (net.Conn, error) {
+			clientConn, serverConn := net.Pipe()
+			commands := make(chan string, 10)
+			go serveSMTP(serverConn, "8BITMIME", map[string]string{"MAIL": "250 ok", "RCPT": "250 ok"}, commands)
+			wg.Go(func() {
+				for command := range commands {
+					if strings.HasPrefix(command, "EHLO") {
+						mu.Lock()
+						greeted = append(greeted, command)
+						mu.Unlock()
+					}
+				}
+			})
+			return clientConn, nil
+		}
+
+		transport := NewDirectTransport(WithDirectMailerOptions(withNetDial(netDial),
+			WithHooks(Hooks{BeforeSend: func(ctx context.Context, msg message.Message, encoded []byte) error {
+				parsed, err := message.Parse(bytes.NewReader(encoded))
+				require.Nil(t, err)
+				sent = append(sent, parsed)
+				return nil
+			}})))
+		err := transport.Send(context.Background(), message.Message{From: "Alerts <alerts@Example.net>", Recipients: []string{"a@example.com", "b@example.org"}, Body: "dummy body"})
+		wg.Wait()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"EHLO example.net", "EHLO example.net"}, greeted)
+		require.Len(t, sent, 2)
+		assert.True(t, strings.HasSuffix(headerValue(sent[0], "Message-ID"), "@example.net>"))
+		assert.Equal(t, headerValue(sent[0], "Message-ID"), headerValue(sent[1], "Message-ID"))
+		assert.Equal(t, headerValue(sent[0], "Date"), headerValue(sent[1], "Date"))
+	})
+	t.Run("should refuse to deliver without a local name", func(t *testing.T) {
+		err := NewDirectTransport().Send(context.Background(), message.Message{From: "alerts", Recipients: []string{"a@example.com"}, Body: "dummy body"})
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+	})
+	t.Run("should retry the delivery to a domain along the retry policy", func(t *testing.T) {
+		// stub functions
+		mx.LookupMX = func(ctx context.Context, name string) ([]*net.MX, error) {
+			return []*net.MX{{Host: "mx.example.com.", Pref: 10}}, nil
+		}
+		defer func() { mx.LookupMX = net.DefaultResolver.LookupMX }()
+		var (
+			wg    sync.WaitGroup
+			dials int
+		)
+		netDial := func(network string, addr string, t time.Duration) (net.Conn, error) {
+			dials++
+			replies := map[string]string{"MAIL": "250 ok", "RCPT": "250 ok"}
+			if dials == 1 {
+				replies["RCPT"] = "450 4.2.0 greylisted"
+			}
+			clientConn, serverConn := net.Pipe()
+			commands := make(chan string, 10)
+			go serveSMTP(serverConn, "8BITMIME", replies, commands)
+			wg.Go(func() { receive(commands) })
+			return clientConn, nil
+		}
+
+		transport := NewDirectTransport(WithDirectLocalName("mta.example.net"), WithDirectMailerOptions(withNetDial(netDial),
+			WithRetryPolicy(Backoff{MaxRetries: 2, BaseDelay: time.Millisecond, Retryable: IsTemporary})))
+		err := transport.Send(context.Background(), message.Message{From: testFromEmail, Recipients: []string{"a@example.com"}, Body: "dummy body"})
+		wg.Wait()
+		assert.Nil(t, err)
+		assert.Equal(t, 2, dials)
+	})
+}
@@ -0,0 +1,40 @@
+package gomailer
+
+import (
+	"context"
+	"net"
+	"strconv"
+)
+
+// Endpoint is the SMTP server a message is sent to.
+type Endpoint struct {
+	// Host of the SMTP server.
+	Host string
+	// Port of the SMTP server.
+	Port int
+}
+
+// String returns the endpoint in the "host:port" form.
+func (e Endpoint) String() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+// endpointKey is the context key of the Endpoint.
+type endpointKey struct{}
+
+// EndpointFromContext returns the SMTP server the message is sent to, from the context given to hooks,
+// so events and metrics can be labeled by the target host and port.
+func EndpointFromContext(ctx context.Context) (Endpoint, bool) {
+	e, ok := ctx.Value(endpointKey{}).(Endpoint)
+	return e, ok
+}
+
+// contextWithEndpoint returns a copy of ctx carrying the endpoint.
+func contextWithEndpoint(ctx context.Context, e Endpoint) context.Context {
+	return context.WithValue(ctx, endpointKey{}, e)
+}
+
+// endpoint returns the SMTP server Mailer connects to.
+func (m *Mailer) endpoint() Endpoint {
+	return Endpoint{Host: m.Host, Port: m.Port}
+}
@@ -0,0 +1,142 @@
+package gomailer
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strings"
+
+	"github.com/nawafswe/gomailer/smtpcode"
+)
+
+// BounceType classifies a rejection reported by the SMTP server.
+type BounceType int
+
+const (
+	// BounceNone indicates the rejection is not related to the recipient, e.g. a rejected sender.
+	BounceNone BounceType = iota
+	// BounceSoft indicates a temporary failure, e.g. a full mailbox or a greylisting server, the message may be retried later.
+	BounceSoft
+	// BounceHard indicates a permanent failure, e.g. an unknown recipient, the recipient should not be retried.
+	BounceHard
+)
+
+// String returns the name of the bounce type.
+func (b BounceType) String() string {
+	switch b {
+	case BounceSoft:
+		return "soft"
+	case BounceHard:
+		return "hard"
+	default:
+		return "none"
+	}
+}
+
+// SMTPError is returned when the SMTP server rejects a command with an error reply.
+// Use errors.As to retrieve it from errors returned by Mailer and SendCloser, the codes are named by the smtpcode package.
+type SMTPError struct {
+	// Command is the rejected SMTP command, e.g. MAIL, RCPT or DATA.
+	Command string
+	// Recipient is the rejected recipient address for RCPT rejections.
+	Recipient string
+	// Code is the three digits reply code, e.g. 550.
+	Code int
+	// EnhancedCode is the RFC 3463 enhanced status code if the server sent one, e.g. "5.1.1".
+	EnhancedCode string
+	// Message is the reply text following the enhanced status code.
+	Message string
+
+	err error
+}
+
+// newSMTPError wraps err in SMTPError when it is an SMTP error reply, other errors are returned as is.
+func newSMTPError(command, recipient string, err error) error {
+	var protoErr *textproto.Error
+	if !errors.As(err, &protoErr) {
+		return err
+	}
+	smtpErr := &SMTPError{
+		Command:   command,
+		Recipient: recipient,
+		Code:      protoErr.Code,
+		Message:   protoErr.Msg,
+		err:       err,
+	}
+	if code, msg, ok := smtpcode.ParseEnhancedCode(protoErr.Msg); ok {
+		smtpErr.EnhancedCode = code.String()
+		smtpErr.Message = strings.TrimSpace(msg)
+	}
+	return smtpErr
+}
+
+// replyCode returns the reply code of err when it is an SMTP error reply.
+func replyCode(err error) (int, bool) {
+	var smtpErr *SMTPError
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code, true
+	}
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code, true
+	}
+	return 0, false
+}
+
+// Error returns the reply as sent by the SMTP server along with the rejected command.
+func (e *SMTPError) Error() string {
+	reply := fmt.Sprintf("%d %s", e.Code, e.Message)
+	if e.EnhancedCode != "" {
+		reply = fmt.Sprintf("%d %s %s", e.Code, e.EnhancedCode, e.Message)
+	}
+	return fmt.Sprintf("smtp server rejected %s: %s", e.Command, reply)
+}
+
+// Unwrap returns the underlying error.
+func (e *SMTPError) Unwrap() error {
+	return e.err
+}
+
+// Temporary reports whether the rejection is transient (4xx reply code).
+func (e *SMTPError) Temporary() bool {
+	return smtpcode.Code(e.Code).Temporary()
+}
+
+// Description returns the human-readable meaning of the rejection, the one of its enhanced status code
+// when the server sent one as it is more specific, e.g. "mailbox full" rather than "mailbox unavailable".
+func (e *SMTPError) Description() string {
+	if code, _, ok := smtpcode.ParseEnhancedCode(e.EnhancedCode); ok {
+		return code.Description()
+	}
+	return smtpcode.Code(e.Code).Description()
+}
+
+// Bounce classifies RCPT and DATA rejections into soft and hard bounces:
+//   - 4xx replies are soft bounces.
+//   - 5xx replies with an addressing (X.1.X) or disabled mailbox (X.2.1) enhanced code are hard bounces.
+//   - other 5xx replies with an enhanced code (mailbox full, system, network, content or policy issues) are soft bounces.
+//   - 5xx replies without an enhanced code are hard bounces.
+//
+// Rejections of other commands, such as MAIL, are not bounces.
+func (e *SMTPError) Bounce() BounceType {
+	if e.Command != "RCPT" && e.Command != "DATA" {
+		return BounceNone
+	}
+	if e.Temporary() {
+		return BounceSoft
+	}
+	if !smtpcode.Code(e.Code).Permanent() {
+		return BounceNone
+	}
+	code, _, ok := smtpcode.ParseEnhancedCode(e.EnhancedCode)
+	if !ok {
+		return BounceHard
+	}
+	if code.Temporary() {
+		return BounceSoft
+	}
+	if code.Subject == 1 || code.Status == smtpcode.MailboxDisabled {
+		return BounceHard
+	}
+	return BounceSoft
+}
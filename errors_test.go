@@ -0,0 +1,124 @@
+package gomailer
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSMTPError_Bounce(t *testing.T) {
+	tests := map[string]struct {
+		command       string
+		reply         *textproto.Error
+		expectedCode  string
+		expectedMsg   string
+		expectedType  BounceType
+		expectedTemp  bool
+		expectedError string
+	}{
+		"should classify unknown recipient as hard bounce": {
+			command:       "RCPT",
+			reply:         &textproto.Error{Code: 550, Msg: "5.1.1 User unknown"},
+			expectedCode:  "5.1.1",
+			expectedMsg:   "User unknown",
+			expectedType:  BounceHard,
+			expectedError: "smtp server rejected RCPT: 550 5.1.1 User unknown",
+		},
+		"should classify disabled mailbox as hard bounce": {
+			command:       "RCPT",
+			reply:         &textproto.Error{Code: 550, Msg: "5.2.1 Mailbox disabled"},
+			expectedCode:  "5.2.1",
+			expectedMsg:   "Mailbox disabled",
+			expectedType:  BounceHard,
+			expectedError: "smtp server rejected RCPT: 550 5.2.1 Mailbox disabled",
+		},
+		"should classify full mailbox as soft bounce": {
+			command:       "RCPT",
+			reply:         &textproto.Error{Code: 552, Msg: "5.2.2 Mailbox full"},
+			expectedCode:  "5.2.2",
+			expectedMsg:   "Mailbox full",
+			expectedType:  BounceSoft,
+			expectedError: "smtp server rejected RCPT: 552 5.2.2 Mailbox full",
+		},
+		"should classify policy rejection of data as soft bounce": {
+			command:       "DATA",
+			reply:         &textproto.Error{Code: 554, Msg: "5.7.1 Message rejected as spam"},
+			expectedCode:  "5.7.1",
+			expectedMsg:   "Message rejected as spam",
+			expectedType:  BounceSoft,
+			expectedError: "smtp server rejected DATA: 554 5.7.1 Message rejected as spam",
+		},
+		"should classify greylisting as soft bounce": {
+			command:       "RCPT",
+			reply:         &textproto.Error{Code: 451, Msg: "4.7.1 Greylisted, try again later"},
+			expectedCode:  "4.7.1",
+			expectedMsg:   "Greylisted, try again later",
+			expectedType:  BounceSoft,
+			expectedTemp:  true,
+			expectedError: "smtp server rejected RCPT: 451 4.7.1 Greylisted, try again later",
+		},
+		"should classify permanent rejection without enhanced code as hard bounce": {
+			command:       "RCPT",
+			reply:         &textproto.Error{Code: 550, Msg: "No such user here"},
+			expectedMsg:   "No such user here",
+			expectedType:  BounceHard,
+			expectedError: "smtp server rejected RCPT: 550 No such user here",
+		},
+		"should not classify rejected sender as bounce": {
+			command:       "MAIL",
+			reply:         &textproto.Error{Code: 553, Msg: "5.1.8 Bad sender address"},
+			expectedCode:  "5.1.8",
+			expectedMsg:   "Bad sender address",
+			expectedType:  BounceNone,
+			expectedError: "smtp server rejected MAIL: 553 5.1.8 Bad sender address",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := newSMTPError(tc.command, testFromEmail, tc.reply)
+
+			var smtpErr *SMTPError
+			assert.True(t, errors.As(err, &smtpErr))
+			assert.Equal(t, tc.reply.Code, smtpErr.Code)
+			assert.Equal(t, tc.expectedCode, smtpErr.EnhancedCode)
+			assert.Equal(t, tc.expectedMsg, smtpErr.Message)
+			assert.Equal(t, tc.expectedType, smtpErr.Bounce())
+			assert.Equal(t, tc.expectedTemp, smtpErr.Temporary())
+			assert.Equal(t, tc.expectedError, err.Error())
+			assert.ErrorIs(t, err, tc.reply)
+		})
+	}
+
+	t.Run("should return non smtp errors as is", func(t *testing.T) {
+		t.Parallel()
+		dummyErr := fmt.Errorf("dummy error")
+		assert.Equal(t, dummyErr, newSMTPError("RCPT", testFromEmail, dummyErr))
+	})
+}
+
+func TestSMTPError_Description(t *testing.T) {
+	tests := map[string]struct {
+		reply    *textproto.Error
+		expected string
+	}{
+		"should describe the enhanced status code": {
+			reply:    &textproto.Error{Code: 552, Msg: "5.2.2 mailbox full"},
+			expected: "mailbox full",
+		},
+		"should describe the reply code without enhanced status code": {
+			reply:    &textproto.Error{Code: 550, Msg: "no such user"},
+			expected: "mailbox unavailable",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var smtpErr *SMTPError
+			assert.True(t, errors.As(newSMTPError("RCPT", "rcpt@example.com", tc.reply), &smtpErr))
+			assert.Equal(t, tc.expected, smtpErr.Description())
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/nawafswe/gomailer/smtpcode"
+)
+
+// FailoverOrder is the order the SMTP servers of a Mailer with fallback hosts are tried in (see WithFallbackHosts).
+type FailoverOrder int
+
+const (
+	// FailoverPriority tries the primary host first, then the fallback hosts in the order they were given.
+	FailoverPriority FailoverOrder = iota
+	// FailoverRoundRobin spreads the connections over the primary and the fallback hosts, every connection
+	// starts with the host following the one the previous connection started with.
+	FailoverRoundRobin
+)
+
+// String returns the name of the order.
+func (o FailoverOrder) String() string {
+	switch o {
+	case FailoverPriority:
+		return "priority"
+	case FailoverRoundRobin:
+		return "round-robin"
+	default:
+		return fmt.Sprintf("FailoverOrder(%d)", int(o))
+	}
+}
+
+// WithFallbackHosts configures Mailer to connect to the given SMTP servers when the primary one is unreachable
+// or replies 421 (service not available) while the connection is set up, trying them in the order
+// of WithFailoverOrder. The servers share the credentials, encryption and timeouts of the Mailer; mind that
+// smtp.PlainAuth given to WithAuth only authenticates to the host it was created for, the mechanism
+// detected from the username and password authenticates to every host.
+// Failures of an established connection are not failed over, they are retried according to WithRetryPolicy.
+//
+// The OnWarning hooks are invoked with the error of every server failed over, the returned error joins
+// the errors of every server tried when none could be connected to.
+func WithFallbackHosts(endpoints ...Endpoint) func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.fallbackHosts = append(mailer.fallbackHosts, endpoints...)
+	}
+}
+
+// WithFailoverOrder configures the order the primary and the fallback hosts are tried in, FailoverPriority by default.
+func WithFailoverOrder(order FailoverOrder) func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.failoverOrder = order
+	}
+}
+
+// endpoints returns the SMTP servers to try for a new connection, in order, the unhealthy ones last (see WithHealthPolicy).
+func (m *Mailer) endpoints() []Endpoint {
+	endpoints := append([]Endpoint{m.endpoint()}, m.fallbackHosts...)
+	if m.failoverOrder == FailoverRoundRobin && len(endpoints) > 1 {
+		i := int((m.nextEndpoint.Add(1) - 1) % uint64(len(endpoints)))
+		endpoints = slices.Concat(endpoints[i:], endpoints[:i])
+	}
+	return m.hostHealth.order(endpoints)
+}
+
+// canFailover reports whether the connection failed with an error the next server may not fail with:
+// unreachable servers and 421 replies, as opposed to rejected credentials or a done context.
+func canFailover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	code, ok := replyCode(err)
+	return !ok || code == int(smtpcode.ServiceNotAvailable)
+}
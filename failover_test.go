@@ -0,0 +1,144 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mailerMock "github.com/nawafswe/gomailer/internal/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMailer_Failover(t *testing.T) {
+	fallback := Endpoint{Host: "fallback.smtp.com", Port: 2525}
+	primaryAddr := Endpoint{Host: testHost, Port: testPort}.String()
+	tests := map[string]struct {
+		// failures are the errors of dialing the hosts by address, the dial succeeds for other hosts.
+		failures map[string]error
+		// greetings are the errors of greeting the hosts by name.
+		greetings        map[string]error
+		authErr          error
+		expectedEndpoint Endpoint
+		expectedDials    []string
+		expectedWarnings int
+		expectErr        bool
+	}{
+		"should connect to the primary host when it is reachable": {
+			expectedEndpoint: Endpoint{Host: testHost, Port: testPort},
+			expectedDials:    []string{primaryAddr},
+		},
+		"should fail over when the primary host is unreachable": {
+			failures:         map[string]error{primaryAddr: fmt.Errorf("connection refused")},
+			expectedEndpoint: fallback,
+			expectedDials:    []string{primaryAddr, fallback.String()},
+			expectedWarnings: 1,
+		},
+		"should fail over when the primary host replies 421": {
+			greetings:        map[string]error{testHost: &textproto.Error{Code: 421, Msg: "4.3.2 service not available"}},
+			expectedEndpoint: fallback,
+			expectedDials:    []string{primaryAddr, fallback.String()},
+			expectedWarnings: 1,
+		},
+		"should not fail over when the credentials are rejected": {
+			authErr:       &textproto.Error{Code: 535, Msg: "5.7.8 authentication failed"},
+			expectedDials: []string{primaryAddr},
+			expectErr:     true,
+		},
+		"should fail when every host is unreachable": {
+			failures:         map[string]error{primaryAddr: fmt.Errorf("connection refused"), fallback.String(): fmt.Errorf("no route to host")},
+			expectedDials:    []string{primaryAddr, fallback.String()},
+			expectedWarnings: 1,
+			expectErr:        true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			// prepare mocks
+			smtpMock := mailerMock.NewMockClient(ctrl)
+			netConnMock := mailerMock.NewMockconn(ctrl)
+			// stub functions
+			var dials []string
+			netDial := func(network string, addr string, t time.Duration) (net.Conn, error) {
+				dials = append(dials, addr)
+				return netConnMock, tc.failures[addr]
+			}
+			newClient := func(conn net.Conn, host string) (Client, error) {
+				return smtpMock, tc.greetings[host]
+			}
+
+			var warnings int
+			mailer := NewMailer(testHost, testPort, testUser, testPassword, WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone),
+				WithAuth(smtp.PlainAuth("", testUser, testPassword, testHost)),
+				WithFallbackHosts(fallback),
+				WithHooks(Hooks{
+					OnWarning: func(ctx context.Context, err error) {
+						warnings++
+					},
+				}),
+			)
+
+			// expect on mocks
+			smtpMock.EXPECT().Auth(gomock.Any()).Return(tc.authErr).AnyTimes()
+			smtpMock.EXPECT().Close().Return(nil).AnyTimes()
+
+			sender, err := mailer.connectAndAuthenticate(context.Background())
+			assert.Equal(t, tc.expectedDials, dials)
+			assert.Equal(t, tc.expectedWarnings, warnings)
+			if tc.expectErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedEndpoint, sender.endpoint)
+		})
+	}
+	t.Run("should join the errors of every host tried", func(t *testing.T) {
+		// stub functions
+		netDial := func(network string, addr string, t time.Duration) (net.Conn, error) {
+			return nil, fmt.Errorf("dummy error")
+		}
+
+		mailer := NewMailer(testHost, testPort, "", "", withNetDial(netDial), WithFallbackHosts(fallback))
+		_, err := mailer.ConnectAndAuthenticate()
+		assert.ErrorContains(t, err, primaryAddr)
+		assert.ErrorContains(t, err, fallback.String())
+	})
+	t.Run("should start every connection with the next host in round-robin order", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		// stub functions
+		var dials []string
+		netDial := func(network string, addr string, t time.Duration) (net.Conn, error) {
+			dials = append(dials, addr)
+			return netConnMock, nil
+		}
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone),
+			WithFallbackHosts(fallback), WithFailoverOrder(FailoverRoundRobin),
+		)
+		for range 3 {
+			_, err := mailer.ConnectAndAuthenticate()
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, []string{primaryAddr, fallback.String(), primaryAddr}, dials)
+	})
+	t.Run("should refuse invalid fallback hosts", func(t *testing.T) {
+		_, err := NewMailerE(testHost, testPort, testUser, testPassword, WithFallbackHosts(Endpoint{Host: "fallback.smtp.com"}))
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+		_, err = NewMailerE(testHost, testPort, testUser, testPassword, WithFailoverOrder(FailoverOrder(7)))
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+		assert.True(t, strings.Contains(err.Error(), "FailoverOrder(7)"))
+	})
+}
@@ -0,0 +1,130 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nawafswe/gomailer/message"
+)
+
+// ErrFrequencyCapped is returned when a message is not sent because a recipient reached the frequency cap (see WithFrequencyCap).
+var ErrFrequencyCapped = errors.New("recipient reached the frequency cap")
+
+// FrequencyStore counts the messages sent to recipients within time windows. Implement it over a shared store
+// (e.g. Redis INCR with EXPIRE) so services sharing the cap count each other's messages.
+type FrequencyStore interface {
+	// Increment counts a message for key and returns the number of messages counted for key in the current window,
+	// including this one. Windows start with the first message counted for key.
+	Increment(ctx context.Context, key string, window time.Duration) (int, error)
+}
+
+// FrequencyCap limits the number of messages sent to every recipient within a time window.
+type FrequencyCap struct {
+	// Max is the number of messages a recipient may receive within Window.
+	Max int
+	// Window is the duration messages are counted over.
+	Window time.Duration
+	// Store counts the messages, an in-memory store local to the Mailer is used when nil.
+	Store FrequencyStore
+	// Scope returns the scope recipients are capped in, e.g. a campaign ID carried by ctx, so every campaign has its own cap.
+	// Recipients are capped across all messages when nil or when it returns an empty scope.
+	Scope func(ctx context.Context, msg message.Message) string
+}
+
+// WithFrequencyCap configures Mailer to refuse messages to recipients who already received cap.Max messages within cap.Window,
+// so several services sharing the mailer cannot overload a single inbox. Capped messages fail with ErrFrequencyCapped.
+// Messages are counted once before they are sent, however many times they are retried, messages failing afterward still count.
+func WithFrequencyCap(cap FrequencyCap) func(*Mailer) {
+	return func(mailer *Mailer) {
+		if cap.Store == nil {
+			cap.Store = &memoryFrequencyStore{now: mailer.now, windows: make(map[string]frequencyWindow)}
+		}
+		mailer.frequencyCap = &cap
+	}
+}
+
+// checkFrequencyCap counts the message for each of its recipients and returns an error wrapping ErrFrequencyCapped
+// listing the recipients that exceeded the cap, if any.
+func (m *Mailer) checkFrequencyCap(ctx context.Context, msg message.Message) error {
+	fc := m.frequencyCap
+	if fc == nil {
+		return nil
+	}
+	// the attempts of a send are counted once.
+	state := sendStateFrom(ctx)
+	if state.counted {
+		return state.capErr
+	}
+	var scope string
+	if fc.Scope != nil {
+		scope = fc.Scope(ctx, msg)
+	}
+	var capped []string
+	for _, r := range msg.Recipients {
+		key := strings.ToLower(message.EnvelopeAddress(r))
+		if scope != "" {
+			key = scope + ":" + key
+		}
+		n, err := fc.Store.Increment(ctx, key, fc.Window)
+		if err != nil {
+			return fmt.Errorf("failed to check frequency cap: %w", err)
+		}
+		if n > fc.Max {
+			capped = append(capped, r)
+		}
+	}
+	state.counted = true
+	if len(capped) > 0 {
+		state.capErr = fmt.Errorf("%w: %s", ErrFrequencyCapped, strings.Join(capped, ", "))
+	}
+	return state.capErr
+}
+
+// NewMemoryFrequencyStore returns a FrequencyStore keeping the counts in memory, they are not shared with other processes.
+func NewMemoryFrequencyStore() FrequencyStore {
+	return &memoryFrequencyStore{now: time.Now, windows: make(map[string]frequencyWindow)}
+}
+
+// memoryFrequencyStore is a FrequencyStore counting messages in fixed windows held in memory.
+type memoryFrequencyStore struct {
+	// now returns the current time, of the Mailer clock for the store created by WithFrequencyCap.
+	now func() time.Time
+
+	mu      sync.Mutex
+	windows map[string]frequencyWindow
+	// swept is when expired windows were last dropped.
+	swept time.Time
+}
+
+// frequencyWindow is the count of messages of a key since the window started.
+type frequencyWindow struct {
+	start time.Time
+	count int
+}
+
+// Increment implements FrequencyStore.
+func (s *memoryFrequencyStore) Increment(_ context.Context, key string, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	w, ok := s.windows[key]
+	if !ok || now.Sub(w.start) >= window {
+		w = frequencyWindow{start: now}
+	}
+	if now.Sub(s.swept) >= window {
+		// drop expired windows so the store does not grow with every recipient ever seen.
+		for k, other := range s.windows {
+			if now.Sub(other.start) >= window {
+				delete(s.windows, k)
+			}
+		}
+		s.swept = now
+	}
+	w.count++
+	s.windows[key] = w
+	return w.count, nil
+}
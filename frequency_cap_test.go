@@ -0,0 +1,82 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+// campaignKey is the context key of the campaign ID in tests.
+type campaignKey struct{}
+
+func TestMemoryFrequencyStore_Increment(t *testing.T) {
+	t.Run("should count messages within the window and start over once it elapsed", func(t *testing.T) {
+		now := time.Date(2024, time.March, 5, 10, 30, 0, 0, time.UTC)
+		store := &memoryFrequencyStore{now: func() time.Time { return now }, windows: make(map[string]frequencyWindow)}
+		for want := 1; want <= 3; want++ {
+			n, err := store.Increment(context.Background(), "a@example.com", time.Hour)
+			assert.Nil(t, err)
+			assert.Equal(t, want, n)
+		}
+		n, _ := store.Increment(context.Background(), "b@example.com", time.Hour)
+		assert.Equal(t, 1, n)
+
+		now = now.Add(time.Hour)
+		n, _ = store.Increment(context.Background(), "a@example.com", time.Hour)
+		assert.Equal(t, 1, n)
+	})
+}
+
+func TestMailer_FrequencyCap(t *testing.T) {
+	scope := func(ctx context.Context, msg message.Message) string {
+		id, _ := ctx.Value(campaignKey{}).(string)
+		return id
+	}
+	msg := message.Message{
+		From:       testFromEmail,
+		Recipients: []string{"A <a@example.com>", "b@example.com"},
+	}
+	tests := map[string]struct {
+		sent        []string
+		campaign    string
+		expectedErr error
+	}{
+		"should allow recipients below the cap": {
+			sent: []string{""},
+		},
+		"should refuse recipients who reached the cap": {
+			sent:        []string{"", "A@Example.com"},
+			expectedErr: fmt.Errorf("%w: %s", ErrFrequencyCapped, "A <a@example.com>"),
+		},
+		"should cap recipients per campaign": {
+			sent:     []string{"", "a@example.com", "b@example.com"},
+			campaign: "spring-sale",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mailer, err := NewMailerE(testHost, testPort, testUser, testPassword, WithFrequencyCap(FrequencyCap{Max: 2, Window: time.Hour, Scope: scope}))
+			assert.Nil(t, err)
+			// messages previously sent outside of any campaign, "" sends msg.
+			for _, to := range tc.sent {
+				m := msg
+				if to != "" {
+					m.Recipients = []string{to}
+				}
+				_ = mailer.checkFrequencyCap(context.Background(), m)
+			}
+
+			ctx := context.WithValue(context.Background(), campaignKey{}, tc.campaign)
+			assert.Equal(t, tc.expectedErr, mailer.checkFrequencyCap(ctx, msg))
+		})
+	}
+
+	t.Run("should refuse caps allowing no message", func(t *testing.T) {
+		_, err := NewMailerE(testHost, testPort, testUser, testPassword, WithFrequencyCap(FrequencyCap{Window: time.Hour}))
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+	})
+}
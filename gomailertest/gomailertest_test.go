@@ -0,0 +1,242 @@
+package gomailertest
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/textproto"
+	"testing"
+
+	"github.com/nawafswe/gomailer"
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testUser     = "user@example.com"
+	testPassword = "secret"
+)
+
+func testMessage() message.Message {
+	return message.Message{
+		From:       "from@example.com",
+		Recipients: []string{"to@example.com"},
+		Subject:    "hello",
+		Body:       "hello from gomailertest",
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	t.Run("should record the messages sent", func(t *testing.T) {
+		r := NewRecorder()
+		assert.Nil(t, r.Send(testMessage()))
+		assert.Nil(t, r.Transport().Send(context.Background(), testMessage()))
+		assert.Equal(t, []message.Message{testMessage(), testMessage()}, r.Messages())
+
+		r.Reset()
+		assert.Empty(t, r.Messages())
+	})
+	t.Run("should refuse invalid messages", func(t *testing.T) {
+		r := NewRecorder()
+		assert.NotNil(t, r.Send(message.Message{From: "from@example.com"}))
+		assert.Empty(t, r.Messages())
+	})
+	t.Run("should return the configured error", func(t *testing.T) {
+		sendErr := errors.New("dummy error")
+		r := &Recorder{Err: sendErr}
+		assert.ErrorIs(t, r.Send(testMessage()), sendErr)
+		assert.Empty(t, r.Messages())
+	})
+	t.Run("should refuse sending once closed", func(t *testing.T) {
+		r := NewRecorder()
+		assert.Nil(t, r.Close())
+		assert.True(t, r.Closed())
+		assert.ErrorIs(t, r.Send(testMessage()), ErrClosed)
+	})
+	t.Run("should fail when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.ErrorIs(t, NewRecorder().SendContext(ctx, testMessage()), context.Canceled)
+	})
+}
+
+func TestServer(t *testing.T) {
+	tests := map[string]struct {
+		username, password string
+		secrets            string
+		mechanisms         []string
+		encryption         gomailer.Encryption
+		expectedTLS        bool
+		expectedMechanism  string
+		expectErr          bool
+	}{
+		"should receive messages over STARTTLS with PLAIN authentication": {
+			username: testUser, password: testPassword,
+			encryption:        gomailer.EncryptionSTARTTLS,
+			expectedTLS:       true,
+			expectedMechanism: "PLAIN",
+		},
+		"should receive messages with LOGIN authentication": {
+			username: testUser, password: testPassword,
+			mechanisms:        []string{"LOGIN"},
+			encryption:        gomailer.EncryptionSTARTTLS,
+			expectedTLS:       true,
+			expectedMechanism: "LOGIN",
+		},
+		"should select CRAM-MD5 over PLAIN and LOGIN with the secrets": {
+			username: testUser, password: "unused", secrets: testPassword,
+			mechanisms:        []string{"LOGIN", "PLAIN", "CRAM-MD5"},
+			encryption:        gomailer.EncryptionSTARTTLS,
+			expectedTLS:       true,
+			expectedMechanism: "CRAM-MD5",
+		},
+		"should reject a CRAM-MD5 digest keyed with another secret": {
+			username: testUser, secrets: "wrong",
+			mechanisms: []string{"CRAM-MD5"},
+			encryption: gomailer.EncryptionNone,
+			expectErr:  true,
+		},
+		"should reject LOGIN authentication with invalid credentials": {
+			username: testUser, password: "wrong",
+			mechanisms: []string{"LOGIN"},
+			encryption: gomailer.EncryptionSTARTTLS,
+			expectErr:  true,
+		},
+		"should receive messages without encryption nor authentication": {
+			encryption: gomailer.EncryptionNone,
+		},
+		"should reject invalid credentials": {
+			username: testUser, password: "wrong",
+			encryption: gomailer.EncryptionSTARTTLS,
+			expectErr:  true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := NewUnstartedServer()
+			s.Username, s.Password = testUser, testPassword
+			if tc.username == "" {
+				s.Username, s.Password = "", ""
+			}
+			s.AuthMechanisms = tc.mechanisms
+			s.Start()
+			defer s.Close()
+
+			mailer := gomailer.NewMailer(s.Host(), s.Port(), tc.username, tc.password,
+				gomailer.WithEncryption(tc.encryption), gomailer.WithTLSConfig(s.TLSConfig()), gomailer.WithSecrets(tc.secrets))
+			err := mailer.Send(context.Background(), testMessage())
+			if tc.expectErr {
+				assert.NotNil(t, err)
+				assert.Empty(t, s.Messages())
+				return
+			}
+			assert.Nil(t, err)
+			messages := s.Messages()
+			if assert.Len(t, messages, 1) {
+				assert.Equal(t, "from@example.com", messages[0].From)
+				assert.Equal(t, []string{"to@example.com"}, messages[0].Recipients)
+				assert.Equal(t, tc.username, messages[0].Username)
+				assert.Equal(t, tc.expectedMechanism, messages[0].AuthMechanism)
+				assert.Equal(t, tc.expectedTLS, messages[0].TLS)
+				assert.Contains(t, string(messages[0].Data), "From: from@example.com\r\n")
+				assert.Contains(t, string(messages[0].Data), "\r\n\r\nhello from gomailertest\r\n")
+			}
+		})
+	}
+	t.Run("should require authentication when credentials are set", func(t *testing.T) {
+		s := NewUnstartedServer()
+		s.Username, s.Password = testUser, testPassword
+		s.Start()
+		defer s.Close()
+
+		mailer := gomailer.NewMailer(s.Host(), s.Port(), "", "", gomailer.WithEncryption(gomailer.EncryptionNone))
+		err := mailer.Send(context.Background(), testMessage())
+		var smtpErr *gomailer.SMTPError
+		if assert.ErrorAs(t, err, &smtpErr) {
+			assert.Equal(t, 530, smtpErr.Code)
+		}
+	})
+	t.Run("should report insecure setups only without TLS", func(t *testing.T) {
+		s := NewUnstartedServer()
+		s.Username, s.Password = testUser, testPassword
+		s.AuthMechanisms = []string{"LOGIN"}
+		s.Start()
+		defer s.Close()
+
+		for encryption, expected := range map[gomailer.Encryption][]gomailer.Insecurity{
+			gomailer.EncryptionSTARTTLS: nil,
+			gomailer.EncryptionNone:     {gomailer.InsecurePlaintextAuth},
+		} {
+			var got []gomailer.Insecurity
+			mailer := gomailer.NewMailer(s.Host(), s.Port(), testUser, testPassword, gomailer.WithEncryption(encryption),
+				gomailer.WithTLSConfig(s.TLSConfig()), gomailer.WithHooks(gomailer.Hooks{
+					OnInsecure: func(_ context.Context, setup gomailer.InsecureSetup) {
+						got = append(got, setup.Insecurity)
+					},
+				}))
+			assert.Nil(t, mailer.Send(context.Background(), testMessage()))
+			assert.Equal(t, expected, got, encryption.String())
+		}
+	})
+	t.Run("should verify the TLS connection with the callback", func(t *testing.T) {
+		s := NewServer()
+		defer s.Close()
+
+		errUnpinned := errors.New("certificate is not pinned")
+		tests := map[string]struct {
+			verifyErr error
+		}{
+			"should send when the callback accepts the connection": {},
+			"should fail when the callback rejects the connection": {verifyErr: errUnpinned},
+		}
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				var verifiedHost string
+				var peerCertificates int
+				mailer := gomailer.NewMailer(s.Host(), s.Port(), "", "", gomailer.WithEncryption(gomailer.EncryptionSTARTTLS),
+					gomailer.WithTLSConfig(s.TLSConfig()), gomailer.WithVerifyConnection(func(host string, state tls.ConnectionState) error {
+						verifiedHost, peerCertificates = host, len(state.PeerCertificates)
+						return tc.verifyErr
+					}))
+				err := mailer.Send(context.Background(), testMessage())
+				assert.Equal(t, s.Host(), verifiedHost)
+				assert.Equal(t, 1, peerCertificates)
+				if tc.verifyErr == nil {
+					assert.Nil(t, err)
+					return
+				}
+				var verificationErr *gomailer.TLSVerificationError
+				if assert.ErrorAs(t, err, &verificationErr) {
+					assert.Equal(t, s.Host(), verificationErr.Host)
+					assert.Len(t, verificationErr.Certificates, 1)
+				}
+				assert.ErrorIs(t, err, errUnpinned)
+			})
+		}
+	})
+	t.Run("should reply to canceled and malformed authentication exchanges and go on", func(t *testing.T) {
+		s := NewUnstartedServer()
+		s.Username, s.Password = testUser, testPassword
+		s.AuthMechanisms = []string{"LOGIN", "CRAM-MD5"}
+		s.Start()
+		defer s.Close()
+
+		conn, err := textproto.Dial("tcp", s.Addr)
+		require.Nil(t, err)
+		defer conn.Close()
+		_, _, err = conn.ReadResponse(220)
+		require.Nil(t, err)
+		// cmd sends the command line and returns the reply code.
+		cmd := func(line string) int {
+			require.Nil(t, conn.PrintfLine("%s", line))
+			code, _, _ := conn.ReadResponse(0)
+			return code
+		}
+		assert.Equal(t, 334, cmd("AUTH CRAM-MD5"))
+		assert.Equal(t, 501, cmd("*"))
+		assert.Equal(t, 501, cmd("AUTH LOGIN !!!"))
+		assert.Equal(t, 504, cmd("AUTH PLAIN"))
+		assert.Equal(t, 250, cmd("NOOP"))
+	})
+}
@@ -0,0 +1,95 @@
+// Package gomailertest provides utilities for testing code sending mail with gomailer,
+// an in-memory SendCloser and a local SMTP server, so it can be tested without mocking gomailer.
+package gomailertest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/nawafswe/gomailer"
+	"github.com/nawafswe/gomailer/message"
+)
+
+// ErrClosed is returned by Recorder when sending after it was closed.
+var ErrClosed = errors.New("gomailertest: recorder is closed")
+
+// Recorder is an in-memory gomailer.SendCloser recording the messages sent instead of delivering them.
+// It is safe for concurrent use.
+type Recorder struct {
+	// Err is returned by the sends while set, the messages are not recorded.
+	Err error
+
+	mu       sync.Mutex
+	messages []message.Message
+	closed   bool
+}
+
+// Recorder must implement gomailer.SendCloser.
+var _ gomailer.SendCloser = (*Recorder)(nil)
+
+// NewRecorder returns a new Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Send records msg once it was encoded successfully, as gomailer would refuse to send it otherwise.
+func (r *Recorder) Send(msg message.Message) error {
+	return r.SendContext(context.Background(), msg)
+}
+
+// SendContext records msg once it was encoded successfully, it fails when ctx is done.
+func (r *Recorder) SendContext(ctx context.Context, msg message.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := msg.Encode(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrClosed
+	}
+	if r.Err != nil {
+		return r.Err
+	}
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+// Close closes the recorder, the messages sent afterward fail with ErrClosed.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+// Closed reports whether the recorder was closed.
+func (r *Recorder) Closed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.closed
+}
+
+// Messages returns the messages recorded, in the order they were sent.
+func (r *Recorder) Messages() []message.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.messages)
+}
+
+// Reset forgets the messages recorded and reopens the recorder.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = nil
+	r.closed = false
+}
+
+// Transport returns a gomailer.Transport recording the messages sent with r, for code depending on gomailer.Transport.
+func (r *Recorder) Transport() gomailer.Transport {
+	return gomailer.TransportFunc(r.SendContext)
+}
@@ -0,0 +1,463 @@
+package gomailertest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Message is a message received by Server.
+type Message struct {
+	// From is the envelope sender given to MAIL FROM.
+	From string
+	// Recipients are the envelope recipients given to RCPT TO.
+	Recipients []string
+	// Data is the message as transmitted, with CRLF line endings and without the dot-stuffing.
+	Data []byte
+	// Username is the user the client authenticated as, empty when it did not authenticate.
+	Username string
+	// AuthMechanism is the authentication mechanism the client authenticated with, e.g. PLAIN.
+	AuthMechanism string
+	// TLS indicates whether the message was received over STARTTLS.
+	TLS bool
+}
+
+// Server is a local SMTP server for tests, receiving messages instead of delivering them.
+// It supports STARTTLS with a self-signed certificate and the PLAIN, LOGIN and CRAM-MD5 authentication mechanisms.
+type Server struct {
+	// Username and Password are the credentials the server accepts, clients must authenticate
+	// before sending when set. Password is the shared secret of CRAM-MD5 (see gomailer.WithSecrets).
+	// They must be set before Start.
+	Username, Password string
+	// AuthMechanisms are the authentication mechanisms advertised, PLAIN and LOGIN when empty.
+	// They must be set before Start.
+	AuthMechanisms []string
+	// Addr is the address the server listens on, in the "host:port" form, once started.
+	Addr string
+
+	listener  net.Listener
+	tlsConfig *tls.Config
+	certPool  *x509.CertPool
+	wg        sync.WaitGroup
+
+	// nextChallenge numbers the CRAM-MD5 challenges, so they are unique.
+	nextChallenge atomic.Uint64
+
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	messages []Message
+	closed   bool
+}
+
+// NewServer starts and returns a new Server listening on a random port of the loopback interface.
+// The caller should call Close when finished, to shut it down.
+func NewServer() *Server {
+	s := NewUnstartedServer()
+	s.Start()
+	return s
+}
+
+// NewUnstartedServer returns a new Server that is not started, so it can be configured before calling Start.
+func NewUnstartedServer() *Server {
+	return &Server{conns: make(map[net.Conn]struct{})}
+}
+
+// Start starts the server, it panics when it cannot listen or generate its certificate.
+func (s *Server) Start() {
+	if s.listener != nil {
+		panic("gomailertest: server already started")
+	}
+	cert, err := selfSignedCertificate()
+	if err != nil {
+		panic(fmt.Sprintf("gomailertest: failed to generate certificate: %v", err))
+	}
+	s.tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	s.certPool = x509.NewCertPool()
+	s.certPool.AddCert(cert.Leaf)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("gomailertest: failed to listen: %v", err))
+	}
+	s.listener = l
+	s.Addr = l.Addr().String()
+	s.wg.Add(1)
+	go s.serve()
+}
+
+// Host returns the host the server listens on.
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Addr)
+	return host
+}
+
+// Port returns the port the server listens on.
+func (s *Server) Port() int {
+	_, port, _ := net.SplitHostPort(s.Addr)
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+// TLSConfig returns a client configuration trusting the certificate of the server, e.g. for gomailer.WithTLSConfig.
+func (s *Server) TLSConfig() *tls.Config {
+	return &tls.Config{RootCAs: s.certPool, ServerName: s.Host(), MinVersion: tls.VersionTLS12}
+}
+
+// Messages returns the messages received, in the order they were received.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.messages)
+}
+
+// Close shuts down the server, closing the open connections, and waits for them to be done.
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	s.wg.Wait()
+}
+
+// serve accepts the connections until the server is closed.
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			c.Close()
+			return
+		}
+		s.conns[c] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go func() {
+			defer s.wg.Done()
+			sess := &session{server: s, conn: c}
+			sess.serve()
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+			sess.conn.Close()
+		}()
+	}
+}
+
+// receive records a message received.
+func (s *Server) receive(msg Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, msg)
+}
+
+// session is an SMTP session of a client connected to Server.
+type session struct {
+	server *Server
+	conn   net.Conn
+	tc     *textproto.Conn
+	tls    bool
+	// username the client authenticated as.
+	username string
+	// mechanism the client authenticated with.
+	mechanism string
+	// from and recipients of the current transaction, from is nil when none was started.
+	from       *string
+	recipients []string
+}
+
+// serve replies to the commands of the client until it quits or disconnects.
+func (sess *session) serve() {
+	sess.tc = textproto.NewConn(sess.conn)
+	sess.reply("220 localhost ESMTP gomailertest")
+	for {
+		line, err := sess.tc.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			sess.reset()
+			sess.reply("250-localhost")
+			sess.reply("250-8BITMIME")
+			sess.reply("250-SMTPUTF8")
+			if !sess.tls {
+				sess.reply("250-STARTTLS")
+			}
+			sess.reply("250 AUTH " + strings.Join(sess.server.authMechanisms(), " "))
+		case "HELO":
+			sess.reset()
+			sess.reply("250 localhost")
+		case "STARTTLS":
+			if sess.tls {
+				sess.reply("503 5.5.1 TLS already active")
+				continue
+			}
+			sess.reply("220 2.0.0 ready to start TLS")
+			tlsConn := tls.Server(sess.conn, sess.server.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			sess.server.mu.Lock()
+			delete(sess.server.conns, sess.conn)
+			sess.server.conns[tlsConn] = struct{}{}
+			sess.server.mu.Unlock()
+			sess.conn, sess.tls, sess.username = tlsConn, true, ""
+			sess.tc = textproto.NewConn(tlsConn)
+			sess.reset()
+		case "AUTH":
+			if !sess.auth(arg) {
+				return
+			}
+		case "MAIL":
+			switch {
+			case sess.server.Username != "" && sess.username == "":
+				sess.reply("530 5.7.0 authentication required")
+			case sess.from != nil:
+				sess.reply("503 5.5.1 nested MAIL command")
+			default:
+				from, ok := path(arg, "FROM:")
+				if !ok {
+					sess.reply("501 5.5.4 syntax error in MAIL FROM")
+					continue
+				}
+				sess.from = &from
+				sess.reply("250 2.1.0 ok")
+			}
+		case "RCPT":
+			if sess.from == nil {
+				sess.reply("503 5.5.1 need MAIL before RCPT")
+				continue
+			}
+			to, ok := path(arg, "TO:")
+			if !ok || to == "" {
+				sess.reply("501 5.5.4 syntax error in RCPT TO")
+				continue
+			}
+			sess.recipients = append(sess.recipients, to)
+			sess.reply("250 2.1.5 ok")
+		case "DATA":
+			if len(sess.recipients) == 0 {
+				sess.reply("503 5.5.1 need RCPT before DATA")
+				continue
+			}
+			sess.reply("354 end data with <CR><LF>.<CR><LF>")
+			data, err := sess.tc.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			sess.server.receive(Message{
+				From:          *sess.from,
+				Recipients:    sess.recipients,
+				Data:          bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n")),
+				Username:      sess.username,
+				AuthMechanism: sess.mechanism,
+				TLS:           sess.tls,
+			})
+			sess.reset()
+			sess.reply("250 2.0.0 queued")
+		case "RSET":
+			sess.reset()
+			sess.reply("250 2.0.0 ok")
+		case "NOOP":
+			sess.reply("250 2.0.0 ok")
+		case "QUIT":
+			sess.reply("221 2.0.0 bye")
+			return
+		default:
+			sess.reply("502 5.5.2 command not implemented")
+		}
+	}
+}
+
+// auth authenticates the client with the mechanism and initial response of arg, it reports whether the session goes on.
+func (sess *session) auth(arg string) bool {
+	if sess.username != "" {
+		sess.reply("503 5.5.1 already authenticated")
+		return true
+	}
+	mechanism, initial, _ := strings.Cut(arg, " ")
+	mechanism = strings.ToUpper(mechanism)
+	if !slices.Contains(sess.server.authMechanisms(), mechanism) {
+		sess.reply("504 5.5.4 unrecognized authentication mechanism")
+		return true
+	}
+	var (
+		username string
+		valid    bool
+	)
+	switch mechanism {
+	case "PLAIN":
+		response, err := sess.challenge(initial, "")
+		if err != nil {
+			return sess.authFailed(err)
+		}
+		// the response is the authorization identity, the username and the password separated by NUL.
+		parts := strings.Split(response, "\x00")
+		if len(parts) != 3 {
+			sess.reply("501 5.5.2 malformed authentication response")
+			return true
+		}
+		username, valid = parts[1], parts[1] == sess.server.Username && parts[2] == sess.server.Password
+	case "LOGIN":
+		var (
+			password string
+			err      error
+		)
+		if username, err = sess.challenge(initial, "Username:"); err != nil {
+			return sess.authFailed(err)
+		}
+		if password, err = sess.challenge("", "Password:"); err != nil {
+			return sess.authFailed(err)
+		}
+		valid = username == sess.server.Username && password == sess.server.Password
+	case "CRAM-MD5":
+		challenge := fmt.Sprintf("<%d.%d@gomailertest>", time.Now().UnixNano(), sess.server.nextChallenge.Add(1))
+		response, err := sess.challenge("", challenge)
+		if err != nil {
+			return sess.authFailed(err)
+		}
+		// the response is the username and the hex HMAC-MD5 digest of the challenge keyed with the shared secret (RFC 2195).
+		var digest string
+		username, digest, _ = strings.Cut(response, " ")
+		mac := hmac.New(md5.New, []byte(sess.server.Password))
+		mac.Write([]byte(challenge))
+		valid = username == sess.server.Username && hmac.Equal([]byte(digest), []byte(hex.EncodeToString(mac.Sum(nil))))
+	}
+	if !valid {
+		sess.reply("535 5.7.8 authentication credentials invalid")
+		return true
+	}
+	sess.username, sess.mechanism = username, mechanism
+	sess.reply("235 2.7.0 authentication successful")
+	return true
+}
+
+// errAuthCanceled and errAuthMalformed end an authentication exchange the client canceled or answered undecodably.
+var (
+	errAuthCanceled  = errors.New("authentication canceled")
+	errAuthMalformed = errors.New("cannot decode authentication response")
+)
+
+// authFailed ends the authentication exchange that failed with err, it reports whether the session goes on.
+func (sess *session) authFailed(err error) bool {
+	switch {
+	case errors.Is(err, errAuthCanceled):
+		sess.reply("501 5.7.0 authentication canceled")
+	case errors.Is(err, errAuthMalformed):
+		sess.reply("501 5.5.2 cannot decode authentication response")
+	default:
+		// the connection failed.
+		return false
+	}
+	return true
+}
+
+// challenge returns the decoded initial response, or sends the challenge and returns the decoded response
+// of the client when there is none.
+func (sess *session) challenge(initial, challenge string) (string, error) {
+	response := initial
+	if response == "" || response == "=" {
+		sess.reply("334 " + base64.StdEncoding.EncodeToString([]byte(challenge)))
+		line, err := sess.tc.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		if line == "*" {
+			return "", errAuthCanceled
+		}
+		response = line
+	}
+	decoded, err := base64.StdEncoding.DecodeString(response)
+	if err != nil {
+		return "", errAuthMalformed
+	}
+	return string(decoded), nil
+}
+
+// reset aborts the current transaction.
+func (sess *session) reset() {
+	sess.from, sess.recipients = nil, nil
+}
+
+// reply sends a reply line to the client.
+func (sess *session) reply(line string) {
+	_ = sess.tc.PrintfLine("%s", line)
+}
+
+// authMechanisms returns the authentication mechanisms advertised.
+func (s *Server) authMechanisms() []string {
+	if len(s.AuthMechanisms) == 0 {
+		return []string{"PLAIN", "LOGIN"}
+	}
+	return s.AuthMechanisms
+}
+
+// path returns the address of a MAIL FROM or RCPT TO argument, ignoring its parameters.
+func path(arg, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	addr, _, _ := strings.Cut(strings.TrimSpace(arg[len(prefix):]), " ")
+	if !strings.HasPrefix(addr, "<") || !strings.HasSuffix(addr, ">") {
+		return "", false
+	}
+	return addr[1 : len(addr)-1], true
+}
+
+// selfSignedCertificate returns a certificate for the loopback addresses and localhost, valid for a day.
+func selfSignedCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"gomailertest"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
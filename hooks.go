@@ -0,0 +1,124 @@
+package gomailer
+
+import (
+	"context"
+
+	"github.com/nawafswe/gomailer/message"
+)
+
+// Hooks are invoked along the send lifecycle of Mailer.Send and SendCloser.Send,
+// to inject logging, metrics or header mutation, or to veto messages, without wrapping the Mailer.
+// Every hook is optional and receives the context given to Mailer.Send, Mailer.SendBatch or SendCloser.SendContext,
+// so request-scoped values (e.g. tenant, trace) are available to them, along with the target SMTP server (see EndpointFromContext).
+type Hooks struct {
+	// BeforeEncode is invoked before the message is encoded, it may mutate the message (e.g. add a Message-ID header).
+	// Returning an error vetoes the message, nothing is sent to the SMTP server.
+	BeforeEncode func(ctx context.Context, msg *message.Message) error
+	// BeforeSend is invoked with the encoded message right before the SMTP transaction starts.
+	// Returning an error vetoes the message, nothing is sent to the SMTP server. Without BeforeSend hooks, the message
+	// is encoded while it is written to the server instead of being held in memory.
+	BeforeSend func(ctx context.Context, msg message.Message, encoded []byte) error
+	// AfterSend is invoked once the SMTP server accepted the message.
+	AfterSend func(ctx context.Context, msg message.Message)
+	// OnError is invoked when the message could not be sent, including vetoes by the other hooks.
+	OnError func(ctx context.Context, msg message.Message, err error)
+	// OnAbort is invoked, before OnError, when the context is done while the message is being sent, with the stage reached,
+	// so callers can reconcile messages that may have been sent (see SendStage.MaybeSent). Once a transaction was started,
+	// the connection is closed rather than left in an unknown state, further sends over it fail with ErrConnectionAborted.
+	OnAbort func(ctx context.Context, msg message.Message, stage SendStage, err error)
+	// OnWarning is invoked on conditions that do not prevent sending but may need attention,
+	// e.g. the SMTP server not advertising STARTTLS or AUTH (see ErrExtensionNotAdvertised).
+	OnWarning func(ctx context.Context, warning error)
+	// OnInsecure is invoked when the Mailer continues connecting in a weak setup, e.g. sending credentials without TLS or
+	// relaying without authentication, so insecure configurations can be inventoried and migrated (see Insecurity).
+	OnInsecure func(ctx context.Context, setup InsecureSetup)
+}
+
+// WithHooks configures Mailer with Hooks, hooks given by several WithHooks options are invoked in the order they were given.
+func WithHooks(h Hooks) func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.hooks = append(mailer.hooks, h)
+	}
+}
+
+// hookChain invokes the configured Hooks in order.
+type hookChain []Hooks
+
+// beforeEncode invokes the BeforeEncode hooks, stopping at the first veto.
+func (hc hookChain) beforeEncode(ctx context.Context, msg *message.Message) error {
+	for _, h := range hc {
+		if h.BeforeEncode != nil {
+			if err := h.BeforeEncode(ctx, msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hasBeforeSend reports whether a BeforeSend hook is set, which needs the encoded message.
+func (hc hookChain) hasBeforeSend() bool {
+	for _, h := range hc {
+		if h.BeforeSend != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// beforeSend invokes the BeforeSend hooks, stopping at the first veto.
+func (hc hookChain) beforeSend(ctx context.Context, msg message.Message, encoded []byte) error {
+	for _, h := range hc {
+		if h.BeforeSend != nil {
+			if err := h.BeforeSend(ctx, msg, encoded); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// afterSend invokes the AfterSend hooks.
+func (hc hookChain) afterSend(ctx context.Context, msg message.Message) {
+	for _, h := range hc {
+		if h.AfterSend != nil {
+			h.AfterSend(ctx, msg)
+		}
+	}
+}
+
+// onError invokes the OnError hooks.
+func (hc hookChain) onError(ctx context.Context, msg message.Message, err error) {
+	for _, h := range hc {
+		if h.OnError != nil {
+			h.OnError(ctx, msg, err)
+		}
+	}
+}
+
+// onAbort invokes the OnAbort hooks.
+func (hc hookChain) onAbort(ctx context.Context, msg message.Message, stage SendStage, err error) {
+	for _, h := range hc {
+		if h.OnAbort != nil {
+			h.OnAbort(ctx, msg, stage, err)
+		}
+	}
+}
+
+// onInsecure invokes the OnInsecure hooks.
+func (hc hookChain) onInsecure(ctx context.Context, setup InsecureSetup) {
+	for _, h := range hc {
+		if h.OnInsecure != nil {
+			h.OnInsecure(ctx, setup)
+		}
+	}
+}
+
+// onWarning invokes the OnWarning hooks.
+func (hc hookChain) onWarning(ctx context.Context, warning error) {
+	for _, h := range hc {
+		if h.OnWarning != nil {
+			h.OnWarning(ctx, warning)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mailerMock "github.com/nawafswe/gomailer/internal/mock"
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMailer_Hooks(t *testing.T) {
+	dummyErr := fmt.Errorf("dummy error")
+	msg := message.Message{
+		From:       testFromEmail,
+		Recipients: testRecipient,
+		Body:       "dummy body",
+	}
+	t.Run("should invoke hooks in order along the send lifecycle", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		var calls []string
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone),
+			WithHooks(Hooks{
+				BeforeEncode: func(ctx context.Context, msg *message.Message) error {
+					calls = append(calls, "first.BeforeEncode")
+					*msg = msg.WithHeader("Message-ID", "<1@gomailer>")
+					return nil
+				},
+				BeforeSend: func(ctx context.Context, msg message.Message, encoded []byte) error {
+					calls = append(calls, "first.BeforeSend")
+					assert.Contains(t, string(encoded), "Message-ID: <1@gomailer>\r\n")
+					return nil
+				},
+				AfterSend: func(ctx context.Context, msg message.Message) {
+					calls = append(calls, "first.AfterSend")
+					assert.Equal(t, []string{"<1@gomailer>"}, msg.Headers["Message-ID"])
+				},
+				OnError: func(ctx context.Context, msg message.Message, err error) {
+					calls = append(calls, "first.OnError")
+				},
+			}),
+			WithHooks(Hooks{
+				BeforeEncode: func(ctx context.Context, msg *message.Message) error {
+					calls = append(calls, "second.BeforeEncode")
+					return nil
+				},
+				AfterSend: func(ctx context.Context, msg message.Message) {
+					calls = append(calls, "second.AfterSend")
+				},
+			}),
+		)
+
+		// expect on mocks
+		smtpMock.EXPECT().Mail(msg.From).Return(nil)
+		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
+		smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+		smtpMock.EXPECT().Quit().Return(nil)
+		writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			return len(b), nil
+		})
+		writeCloserMock.EXPECT().Close().Return(nil)
+
+		err := mailer.Send(context.Background(), msg)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"first.BeforeEncode", "second.BeforeEncode", "first.BeforeSend", "first.AfterSend", "second.AfterSend"}, calls)
+	})
+	t.Run("should pass the caller context to every hook", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		type tenantKey struct{}
+		ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+		var tenants []any
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone),
+			WithHooks(Hooks{
+				BeforeEncode: func(ctx context.Context, msg *message.Message) error {
+					tenants = append(tenants, ctx.Value(tenantKey{}))
+					return nil
+				},
+				BeforeSend: func(ctx context.Context, msg message.Message, encoded []byte) error {
+					tenants = append(tenants, ctx.Value(tenantKey{}))
+					return nil
+				},
+				AfterSend: func(ctx context.Context, msg message.Message) {
+					tenants = append(tenants, ctx.Value(tenantKey{}))
+				},
+				OnError: func(ctx context.Context, msg message.Message, err error) {
+					tenants = append(tenants, ctx.Value(tenantKey{}))
+					endpoint, ok := EndpointFromContext(ctx)
+					assert.True(t, ok)
+					assert.Equal(t, Endpoint{Host: testHost, Port: testPort}, endpoint)
+				},
+			}),
+		)
+
+		// expect on mocks
+		smtpMock.EXPECT().Mail(msg.From).Return(nil).Times(2)
+		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
+		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(dummyErr)
+		smtpMock.EXPECT().Reset().Return(nil)
+		smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+		smtpMock.EXPECT().Quit().Return(nil)
+		writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			return len(b), nil
+		})
+		writeCloserMock.EXPECT().Close().Return(nil)
+
+		err := mailer.SendBatch(ctx, msg, []message.Personalization{{}, {}})
+		assert.NotNil(t, err)
+		assert.Equal(t, []any{"acme", "acme", "acme", "acme", "acme", "acme"}, tenants)
+	})
+	t.Run("should veto the message without issuing any command when a hook fails", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		var hookErr error
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone),
+			WithHooks(Hooks{
+				BeforeSend: func(ctx context.Context, msg message.Message, encoded []byte) error {
+					return dummyErr
+				},
+				AfterSend: func(ctx context.Context, msg message.Message) {
+					t.Error("AfterSend must not be invoked for vetoed messages")
+				},
+				OnError: func(ctx context.Context, msg message.Message, err error) {
+					hookErr = err
+				},
+			}),
+		)
+
+		// expect on mocks
+		smtpMock.EXPECT().Quit().Return(nil)
+
+		err := mailer.Send(context.Background(), msg)
+		assert.Equal(t, fmt.Errorf("failed to send message: %w", fmt.Errorf("message vetoed before sending: %w", dummyErr)), err)
+		assert.Equal(t, fmt.Errorf("message vetoed before sending: %w", dummyErr), hookErr)
+	})
+	t.Run("should invoke OnError on the sender when a hook vetoes before encoding", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		var hookErr error
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone),
+			WithHooks(Hooks{
+				BeforeEncode: func(ctx context.Context, msg *message.Message) error {
+					return dummyErr
+				},
+				OnError: func(ctx context.Context, msg message.Message, err error) {
+					hookErr = err
+				},
+			}),
+		)
+
+		smtpSender, err := mailer.ConnectAndAuthenticate()
+		assert.Nil(t, err)
+
+		err = smtpSender.Send(msg)
+		assert.Equal(t, fmt.Errorf("message vetoed before encoding: %w", dummyErr), err)
+		assert.Equal(t, err, hookErr)
+	})
+	t.Run("should invoke OnError when failed to connect", func(t *testing.T) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return nil, dummyErr
+		}
+
+		var hookErr error
+		mailer := NewMailer(testHost, testPort, testUser, testPassword, withNetDial(netDial), WithHooks(Hooks{
+			OnError: func(ctx context.Context, msg message.Message, err error) {
+				hookErr = err
+				endpoint, _ := EndpointFromContext(ctx)
+				assert.Equal(t, "localhost.smtp.com:587", endpoint.String())
+			},
+		}))
+
+		err := mailer.Send(context.Background(), msg)
+		assert.NotNil(t, err)
+		assert.Equal(t, err, hookErr)
+	})
+}
@@ -0,0 +1,189 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// HealthPolicy tracks the health of the primary and the fallback hosts of a Mailer (see WithFallbackHosts),
+// so connections skip the hosts that keep failing and fail back to them once they recover.
+type HealthPolicy struct {
+	// FailureThreshold is the number of consecutive connection failures marking a host unhealthy.
+	FailureThreshold int
+	// ProbeInterval is how often an unhealthy host is probed: a single connection tries it in its place
+	// every interval, the other ones try it after the healthy hosts.
+	ProbeInterval time.Duration
+	// RecoveryThreshold is the number of consecutive successful probes marking an unhealthy host healthy again,
+	// so a flapping host does not get the connections back on its first success. It is 1 when zero.
+	RecoveryThreshold int
+}
+
+// WithHealthPolicy configures Mailer to track the health of its hosts: a host failing p.FailureThreshold consecutive
+// connections, unreachable or replying 421 like the failures failed over, is marked unhealthy and tried after the healthy
+// hosts. A single connection probes it every p.ProbeInterval, after p.RecoveryThreshold consecutive successful probes
+// it is healthy again and the connections fail back to it in the order of WithFailoverOrder. Canceled connections
+// are not counted, nor are rejected credentials as the host is up.
+//
+// The OnWarning hooks are invoked with the error of the connection marking a host unhealthy, see Mailer.HostHealth.
+func WithHealthPolicy(p HealthPolicy) func(*Mailer) {
+	return func(mailer *Mailer) {
+		if p.FailureThreshold < 1 {
+			mailer.invalidOption("health failure threshold %d must be positive", p.FailureThreshold)
+			return
+		}
+		if p.ProbeInterval <= 0 {
+			mailer.invalidOption("health probe interval %s must be positive", p.ProbeInterval)
+			return
+		}
+		if p.RecoveryThreshold < 0 {
+			mailer.invalidOption("health recovery threshold %d cannot be negative", p.RecoveryThreshold)
+			return
+		}
+		mailer.hostHealth = &hostHealth{HealthPolicy: p, now: mailer.now, hosts: make(map[Endpoint]*hostState)}
+	}
+}
+
+// HostHealth is the health of a host of a Mailer, see WithHealthPolicy.
+type HostHealth struct {
+	// Endpoint is the host.
+	Endpoint Endpoint
+	// Healthy indicates whether connections try the host in its place.
+	Healthy bool
+	// ConsecutiveFailures is the number of connections to the host that failed in a row.
+	ConsecutiveFailures int
+	// Connections is the number of connections tried to the host.
+	Connections uint64
+	// Failures is the number of connections to the host that failed.
+	Failures uint64
+}
+
+// ErrorRate returns the ratio of the connections to the host that failed, 0 when none were tried.
+func (h HostHealth) ErrorRate() float64 {
+	if h.Connections == 0 {
+		return 0
+	}
+	return float64(h.Failures) / float64(h.Connections)
+}
+
+// HostHealth returns the health of the primary and the fallback hosts, in the order they were configured.
+// It returns nil when no health policy is configured (see WithHealthPolicy).
+func (m *Mailer) HostHealth() []HostHealth {
+	hh := m.hostHealth
+	if hh == nil {
+		return nil
+	}
+	hh.mu.Lock()
+	defer hh.mu.Unlock()
+	endpoints := append([]Endpoint{m.endpoint()}, m.fallbackHosts...)
+	health := make([]HostHealth, 0, len(endpoints))
+	for _, e := range endpoints {
+		h := HostHealth{Endpoint: e, Healthy: true}
+		if s, ok := hh.hosts[e]; ok {
+			h.Healthy = !s.unhealthy
+			h.ConsecutiveFailures = s.failures
+			h.Connections = s.connections
+			h.Failures = s.totalFailures
+		}
+		health = append(health, h)
+	}
+	return health
+}
+
+// hostHealth tracks the connections to the hosts of a Mailer.
+type hostHealth struct {
+	HealthPolicy
+	// now returns the current time of the Mailer clock.
+	now func() time.Time
+
+	mu    sync.Mutex
+	hosts map[Endpoint]*hostState
+}
+
+// hostState is the connection record of a host.
+type hostState struct {
+	unhealthy bool
+	// failures and successes are the consecutive failed and, while unhealthy, successful connections.
+	failures, successes int
+	// nextProbe is when a connection may try the unhealthy host in its place again.
+	nextProbe                  time.Time
+	connections, totalFailures uint64
+}
+
+// state returns the record of the host at e, creating it on first use.
+func (hh *hostHealth) state(e Endpoint) *hostState {
+	s, ok := hh.hosts[e]
+	if !ok {
+		s = &hostState{}
+		hh.hosts[e] = s
+	}
+	return s
+}
+
+// order returns the endpoints with the unhealthy hosts moved after the healthy ones, keeping the hosts due
+// for a probe in place, it returns them as given when hh is nil.
+func (hh *hostHealth) order(endpoints []Endpoint) []Endpoint {
+	if hh == nil {
+		return endpoints
+	}
+	hh.mu.Lock()
+	defer hh.mu.Unlock()
+	now := hh.now()
+	healthy := make([]Endpoint, 0, len(endpoints))
+	var unhealthy []Endpoint
+	for _, e := range endpoints {
+		s := hh.state(e)
+		if !s.unhealthy {
+			healthy = append(healthy, e)
+			continue
+		}
+		if now.Before(s.nextProbe) {
+			unhealthy = append(unhealthy, e)
+			continue
+		}
+		// probe the host with this connection only.
+		s.nextProbe = now.Add(hh.ProbeInterval)
+		healthy = append(healthy, e)
+	}
+	return slices.Concat(healthy, unhealthy)
+}
+
+// record counts the result of a connection to the host at e, it reports whether it marked the host unhealthy.
+func (hh *hostHealth) record(ctx context.Context, e Endpoint, err error) bool {
+	if hh == nil || ctx.Err() != nil {
+		// the connection was canceled, which says nothing about the host.
+		return false
+	}
+	hh.mu.Lock()
+	defer hh.mu.Unlock()
+	s := hh.state(e)
+	s.connections++
+	if err == nil || !canFailover(ctx, err) {
+		s.failures = 0
+		if s.unhealthy {
+			s.successes++
+			s.unhealthy = s.successes < max(hh.RecoveryThreshold, 1)
+		}
+		return false
+	}
+	s.totalFailures++
+	s.failures++
+	s.successes = 0
+	if s.unhealthy || s.failures < hh.FailureThreshold {
+		return false
+	}
+	s.unhealthy = true
+	s.nextProbe = hh.now().Add(hh.ProbeInterval)
+	return true
+}
+
+// recordConnection records the result of a connection to the host at e, warning when it marked the host unhealthy.
+func (m *Mailer) recordConnection(ctx context.Context, e Endpoint, err error) {
+	if m.hostHealth.record(ctx, e, err) {
+		m.hooks.onWarning(contextWithEndpoint(ctx, e),
+			fmt.Errorf("%s is unhealthy after %d consecutive failures, probing it every %s: %w",
+				e, m.hostHealth.FailureThreshold, m.hostHealth.ProbeInterval, err))
+	}
+}
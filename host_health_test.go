@@ -0,0 +1,124 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mailerMock "github.com/nawafswe/gomailer/internal/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMailer_HealthPolicy(t *testing.T) {
+	fallback := Endpoint{Host: "fallback.smtp.com", Port: 2525}
+	primary := Endpoint{Host: testHost, Port: testPort}
+	t.Run("should skip the unhealthy primary host and fail back once it recovered", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		// stub functions
+		now := time.Date(2024, time.March, 5, 10, 30, 0, 0, time.UTC)
+		clock := func() time.Time { return now }
+		var (
+			dials       []string
+			primaryDown = true
+		)
+		netDial := func(network string, addr string, t time.Duration) (net.Conn, error) {
+			dials = append(dials, addr)
+			if primaryDown && addr == primary.String() {
+				return nil, fmt.Errorf("connection refused")
+			}
+			return netConnMock, nil
+		}
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		smtpMock.EXPECT().Close().Return(nil).AnyTimes()
+
+		var warnings []error
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), withClock(clock), WithEncryption(EncryptionNone),
+			WithFallbackHosts(fallback),
+			WithHealthPolicy(HealthPolicy{FailureThreshold: 2, ProbeInterval: time.Minute, RecoveryThreshold: 2}),
+			WithHooks(Hooks{
+				OnWarning: func(ctx context.Context, err error) {
+					warnings = append(warnings, err)
+				},
+			}),
+		)
+		connect := func(expectedEndpoint Endpoint, expectedDials ...Endpoint) {
+			t.Helper()
+			dials = nil
+			sender, err := mailer.connectAndAuthenticate(context.Background())
+			assert.Nil(t, err)
+			assert.Equal(t, expectedEndpoint, sender.endpoint)
+			expected := make([]string, 0, len(expectedDials))
+			for _, e := range expectedDials {
+				expected = append(expected, e.String())
+			}
+			assert.Equal(t, expected, dials)
+		}
+
+		// the primary host fails over until it is marked unhealthy.
+		connect(fallback, primary, fallback)
+		connect(fallback, primary, fallback)
+		assert.Len(t, warnings, 3)
+		assert.ErrorContains(t, warnings[1], primary.String()+" is unhealthy after 2 consecutive failures")
+		connect(fallback, fallback)
+		assert.Equal(t, []HostHealth{
+			{Endpoint: primary, ConsecutiveFailures: 2, Connections: 2, Failures: 2},
+			{Endpoint: fallback, Healthy: true, Connections: 3},
+		}, mailer.HostHealth())
+
+		// a failed probe keeps the host unhealthy.
+		now = now.Add(time.Minute)
+		connect(fallback, primary, fallback)
+		connect(fallback, fallback)
+
+		// the recovered host gets the connections back after two successful probes.
+		primaryDown = false
+		now = now.Add(time.Minute)
+		connect(primary, primary)
+		connect(fallback, fallback)
+		now = now.Add(time.Minute)
+		connect(primary, primary)
+		connect(primary, primary)
+		assert.Len(t, warnings, 4)
+		health := mailer.HostHealth()
+		assert.True(t, health[0].Healthy)
+		assert.Equal(t, 0.5, health[0].ErrorRate())
+	})
+	t.Run("should not track the health of hosts without policy", func(t *testing.T) {
+		mailer := NewMailer(testHost, testPort, "", "", WithFallbackHosts(fallback))
+		assert.Nil(t, mailer.HostHealth())
+	})
+	t.Run("should reject invalid policies", func(t *testing.T) {
+		tests := map[string]struct {
+			policy      HealthPolicy
+			expectedErr string
+		}{
+			"should reject a non-positive failure threshold": {
+				policy:      HealthPolicy{ProbeInterval: time.Minute},
+				expectedErr: "health failure threshold 0 must be positive",
+			},
+			"should reject a non-positive probe interval": {
+				policy:      HealthPolicy{FailureThreshold: 1},
+				expectedErr: "health probe interval 0s must be positive",
+			},
+			"should reject a negative recovery threshold": {
+				policy:      HealthPolicy{FailureThreshold: 1, ProbeInterval: time.Minute, RecoveryThreshold: -1},
+				expectedErr: "health recovery threshold -1 cannot be negative",
+			},
+		}
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				_, err := NewMailerE(testHost, testPort, "", "", WithHealthPolicy(tc.policy))
+				assert.ErrorIs(t, err, ErrInvalidConfig)
+				assert.ErrorContains(t, err, tc.expectedErr)
+			})
+		}
+	})
+}
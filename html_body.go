@@ -0,0 +1,31 @@
+package gomailer
+
+import "github.com/nawafswe/gomailer/message"
+
+// WithTextFromHTML configures Mailer to generate the body of messages sent with only an HTML body from it,
+// see message.Message.WithTextFromHTML. Messages lacking a plain text alternative get worse spam scores.
+func WithTextFromHTML() func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.textFromHTML = true
+	}
+}
+
+// WithHTMLPolicy configures Mailer to sanitize the HTML body of sent messages against the policy,
+// see message.SanitizeHTML and message.DefaultHTMLPolicy. With WithTextFromHTML, the body is generated
+// from the sanitized HTML body.
+func WithHTMLPolicy(p message.HTMLPolicy) func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.htmlPolicy = &p
+	}
+}
+
+// prepareHTML returns the message with its HTML body sanitized and its body generated from it, as configured.
+func (m *Mailer) prepareHTML(msg message.Message) message.Message {
+	if m.htmlPolicy != nil {
+		msg = msg.WithSanitizedHTML(*m.htmlPolicy)
+	}
+	if m.textFromHTML {
+		msg = msg.WithTextFromHTML()
+	}
+	return msg
+}
@@ -0,0 +1,80 @@
+package gomailer
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMailer_prepareHTML(t *testing.T) {
+	html := `<p onclick="track()">Hello <b>world</b></p><script>track()</script>`
+	tests := map[string]struct {
+		opts     []Options
+		msg      message.Message
+		expected message.Message
+	}{
+		"should leave the message as is by default": {
+			msg:      message.Message{HTMLBody: html},
+			expected: message.Message{HTMLBody: html},
+		},
+		"should generate the body from the HTML body": {
+			opts:     []Options{WithTextFromHTML()},
+			msg:      message.Message{HTMLBody: html},
+			expected: message.Message{Body: "Hello world", HTMLBody: html},
+		},
+		"should keep the body given": {
+			opts:     []Options{WithTextFromHTML()},
+			msg:      message.Message{Body: "text", HTMLBody: html},
+			expected: message.Message{Body: "text", HTMLBody: html},
+		},
+		"should sanitize the HTML body": {
+			opts:     []Options{WithHTMLPolicy(message.DefaultHTMLPolicy())},
+			msg:      message.Message{HTMLBody: html},
+			expected: message.Message{HTMLBody: "<p>Hello <b>world</b></p>"},
+		},
+		"should generate the body from the sanitized HTML body": {
+			opts:     []Options{WithTextFromHTML(), WithHTMLPolicy(message.HTMLPolicy{Elements: map[string][]string{"p": nil}})},
+			msg:      message.Message{HTMLBody: `<p>Hello <b>world</b></p><style>p { color: red }</style>`},
+			expected: message.Message{Body: "Hello world", HTMLBody: "<p>Hello world</p>"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mailer := NewMailer(testHost, testPort, testUser, testPassword, tc.opts...)
+			assert.Equal(t, tc.expected, mailer.prepareHTML(tc.msg))
+		})
+	}
+}
+
+func TestMailer_TextFromHTML(t *testing.T) {
+	t.Run("should send the generated body quoted-printable to servers without 8BITMIME", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		commands := make(chan string, 10)
+		go serveSMTP(serverConn, "", map[string]string{"MAIL": "250 ok", "RCPT": "250 ok"}, commands)
+
+		// stub functions
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return clientConn, nil
+		}
+
+		var sent []byte
+		mailer := NewMailer("localhost", testPort, "", "", withNetDial(netDial), WithLocalName("localhost"), WithTextFromHTML(),
+			WithHooks(Hooks{BeforeSend: func(ctx context.Context, msg message.Message, encoded []byte) error {
+				sent = encoded
+				return nil
+			}}))
+		// the HTML body is ASCII, its text is not.
+		err := mailer.Send(context.Background(), message.Message{From: testFromEmail, Recipients: testRecipient, HTMLBody: "<p>Caf&eacute;</p>"})
+		require.Nil(t, err)
+		for range commands {
+		}
+
+		assert.Contains(t, string(sent), "Content-Type: text/plain; charset=UTF-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\nCaf=C3=A9")
+		assert.Contains(t, string(sent), "<p>Caf&eacute;</p>")
+	})
+}
@@ -0,0 +1,85 @@
+package gomailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+)
+
+// Insecurity classifies a weak setup detected by the Mailer while connecting, see Hooks.OnInsecure.
+type Insecurity int
+
+const (
+	// InsecurePlaintextAuth indicates the credentials were sent over a connection without TLS.
+	InsecurePlaintextAuth Insecurity = iota + 1
+	// InsecureTLSVersion indicates a TLS version older than TLS 1.2 was negotiated.
+	InsecureTLSVersion
+	// InsecureMissingSTARTTLS indicates the connection continued without TLS, as the server did not advertise STARTTLS
+	// or the handshake failed with EncryptionOpportunistic.
+	InsecureMissingSTARTTLS
+	// InsecureAnonymousRelay indicates the message is relayed without authentication, as no credentials are configured
+	// or the server did not advertise AUTH. Deliveries of the DirectTransport to the recipient servers are not reported.
+	InsecureAnonymousRelay
+)
+
+// String returns the name of the insecurity.
+func (i Insecurity) String() string {
+	switch i {
+	case InsecurePlaintextAuth:
+		return "plaintext auth"
+	case InsecureTLSVersion:
+		return "weak tls version"
+	case InsecureMissingSTARTTLS:
+		return "missing starttls"
+	case InsecureAnonymousRelay:
+		return "anonymous relay"
+	default:
+		return fmt.Sprintf("insecurity(%d)", int(i))
+	}
+}
+
+// InsecureSetup describes a weak setup the Mailer detected and continued with, reported to the OnInsecure hooks.
+type InsecureSetup struct {
+	// Insecurity classifies the setup.
+	Insecurity Insecurity
+	// Endpoint is the SMTP server the setup was detected with.
+	Endpoint Endpoint
+	// Detail describes the setup, e.g. "TLS 1.0 negotiated".
+	Detail string
+}
+
+// tlsStateClient is implemented by smtp clients reporting the state of their TLS connection (see protocolClient.tlsState).
+type tlsStateClient interface {
+	tlsState() (tls.ConnectionState, bool)
+}
+
+// diagnoseTLS reports the weak TLS setups of the connection c to the SMTP server at e to the OnInsecure hooks,
+// and returns whether the connection is secured with TLS.
+func (m *Mailer) diagnoseTLS(ctx context.Context, e Endpoint, c Client, encryption Encryption) bool {
+	var (
+		state   tls.ConnectionState
+		secured bool
+	)
+	if tc, ok := c.(tlsStateClient); ok {
+		state, secured = tc.tlsState()
+	}
+	ctx = contextWithEndpoint(ctx, e)
+	if !secured && encryption != EncryptionNone {
+		m.hooks.onInsecure(ctx, InsecureSetup{Insecurity: InsecureMissingSTARTTLS, Endpoint: e, Detail: "connection continued without TLS"})
+	}
+	if secured && state.Version < tls.VersionTLS12 {
+		m.hooks.onInsecure(ctx, InsecureSetup{Insecurity: InsecureTLSVersion, Endpoint: e, Detail: tls.VersionName(state.Version) + " negotiated"})
+	}
+	return secured
+}
+
+// diagnoseAuth reports the weak authentication setups of the connection to the SMTP server at e to the OnInsecure hooks.
+func (m *Mailer) diagnoseAuth(ctx context.Context, e Endpoint, authenticated, secured bool) {
+	ctx = contextWithEndpoint(ctx, e)
+	if authenticated && !secured {
+		m.hooks.onInsecure(ctx, InsecureSetup{Insecurity: InsecurePlaintextAuth, Endpoint: e, Detail: "credentials sent without TLS"})
+	}
+	if !authenticated && !m.direct {
+		m.hooks.onInsecure(ctx, InsecureSetup{Insecurity: InsecureAnonymousRelay, Endpoint: e, Detail: "message relayed without authentication"})
+	}
+}
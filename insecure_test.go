@@ -0,0 +1,74 @@
+package gomailer
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMailer_OnInsecure(t *testing.T) {
+	endpoint := Endpoint{Host: "localhost", Port: testPort}
+	tests := map[string]struct {
+		username, password string
+		encryption         Encryption
+		advertised         string
+		direct             bool
+		expected           []InsecureSetup
+	}{
+		"should report credentials sent without STARTTLS": {
+			username: testUser, password: testPassword,
+			encryption: EncryptionSTARTTLS,
+			advertised: "AUTH LOGIN",
+			expected: []InsecureSetup{
+				{Insecurity: InsecureMissingSTARTTLS, Endpoint: endpoint, Detail: "connection continued without TLS"},
+				{Insecurity: InsecurePlaintextAuth, Endpoint: endpoint, Detail: "credentials sent without TLS"},
+			},
+		},
+		"should report an anonymous relay": {
+			encryption: EncryptionNone,
+			advertised: "8BITMIME",
+			expected: []InsecureSetup{
+				{Insecurity: InsecureAnonymousRelay, Endpoint: endpoint, Detail: "message relayed without authentication"},
+			},
+		},
+		"should not report direct deliveries without authentication": {
+			encryption: EncryptionNone,
+			advertised: "8BITMIME",
+			direct:     true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			go serveSMTP(serverConn, tc.advertised, map[string]string{"AUTH": "235 2.7.0 authenticated", "MAIL": "250 ok", "RCPT": "250 ok"}, make(chan string, 10))
+
+			// stub functions
+			netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+				return clientConn, nil
+			}
+
+			var got []InsecureSetup
+			mailer := NewMailer("localhost", testPort, tc.username, tc.password, withNetDial(netDial), WithEncryption(tc.encryption), WithHooks(Hooks{
+				OnInsecure: func(ctx context.Context, setup InsecureSetup) {
+					e, _ := EndpointFromContext(ctx)
+					assert.Equal(t, endpoint, e)
+					got = append(got, setup)
+				},
+			}))
+			mailer.direct = tc.direct
+			err := mailer.Send(context.Background(), message.Message{From: testFromEmail, Recipients: testRecipient, Body: "hi"})
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestInsecurity_String(t *testing.T) {
+	assert.Equal(t, "plaintext auth", InsecurePlaintextAuth.String())
+	assert.Equal(t, "anonymous relay", InsecureAnonymousRelay.String())
+	assert.Equal(t, "insecurity(9)", Insecurity(9).String())
+}
@@ -5,6 +5,7 @@
 package mock
 
 import (
+	context "context"
 	tls "crypto/tls"
 	io "io"
 	net "net"
@@ -70,31 +71,31 @@ func (mr *MockauthMockRecorder) Start(server interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*Mockauth)(nil).Start), server)
 }
 
-// MocksmtpClient is a mock of smtpClient interface.
-type MocksmtpClient struct {
+// MockClient is a mock of Client interface.
+type MockClient struct {
 	ctrl     *gomock.Controller
-	recorder *MocksmtpClientMockRecorder
+	recorder *MockClientMockRecorder
 }
 
-// MocksmtpClientMockRecorder is the mock recorder for MocksmtpClient.
-type MocksmtpClientMockRecorder struct {
-	mock *MocksmtpClient
+// MockClientMockRecorder is the mock recorder for MockClient.
+type MockClientMockRecorder struct {
+	mock *MockClient
 }
 
-// NewMocksmtpClient creates a new mock instance.
-func NewMocksmtpClient(ctrl *gomock.Controller) *MocksmtpClient {
-	mock := &MocksmtpClient{ctrl: ctrl}
-	mock.recorder = &MocksmtpClientMockRecorder{mock}
+// NewMockClient creates a new mock instance.
+func NewMockClient(ctrl *gomock.Controller) *MockClient {
+	mock := &MockClient{ctrl: ctrl}
+	mock.recorder = &MockClientMockRecorder{mock}
 	return mock
 }
 
 // EXPECT returns an object that allows the caller to indicate expected use.
-func (m *MocksmtpClient) EXPECT() *MocksmtpClientMockRecorder {
+func (m *MockClient) EXPECT() *MockClientMockRecorder {
 	return m.recorder
 }
 
 // Auth mocks base method.
-func (m *MocksmtpClient) Auth(arg0 smtp.Auth) error {
+func (m *MockClient) Auth(arg0 smtp.Auth) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Auth", arg0)
 	ret0, _ := ret[0].(error)
@@ -102,13 +103,13 @@ func (m *MocksmtpClient) Auth(arg0 smtp.Auth) error {
 }
 
 // Auth indicates an expected call of Auth.
-func (mr *MocksmtpClientMockRecorder) Auth(arg0 interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) Auth(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Auth", reflect.TypeOf((*MocksmtpClient)(nil).Auth), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Auth", reflect.TypeOf((*MockClient)(nil).Auth), arg0)
 }
 
 // Close mocks base method.
-func (m *MocksmtpClient) Close() error {
+func (m *MockClient) Close() error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Close")
 	ret0, _ := ret[0].(error)
@@ -116,13 +117,13 @@ func (m *MocksmtpClient) Close() error {
 }
 
 // Close indicates an expected call of Close.
-func (mr *MocksmtpClientMockRecorder) Close() *gomock.Call {
+func (mr *MockClientMockRecorder) Close() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MocksmtpClient)(nil).Close))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockClient)(nil).Close))
 }
 
 // Data mocks base method.
-func (m *MocksmtpClient) Data() (io.WriteCloser, error) {
+func (m *MockClient) Data() (io.WriteCloser, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Data")
 	ret0, _ := ret[0].(io.WriteCloser)
@@ -131,13 +132,13 @@ func (m *MocksmtpClient) Data() (io.WriteCloser, error) {
 }
 
 // Data indicates an expected call of Data.
-func (mr *MocksmtpClientMockRecorder) Data() *gomock.Call {
+func (mr *MockClientMockRecorder) Data() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Data", reflect.TypeOf((*MocksmtpClient)(nil).Data))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Data", reflect.TypeOf((*MockClient)(nil).Data))
 }
 
 // Extension mocks base method.
-func (m *MocksmtpClient) Extension(arg0 string) (bool, string) {
+func (m *MockClient) Extension(arg0 string) (bool, string) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Extension", arg0)
 	ret0, _ := ret[0].(bool)
@@ -146,13 +147,13 @@ func (m *MocksmtpClient) Extension(arg0 string) (bool, string) {
 }
 
 // Extension indicates an expected call of Extension.
-func (mr *MocksmtpClientMockRecorder) Extension(arg0 interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) Extension(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Extension", reflect.TypeOf((*MocksmtpClient)(nil).Extension), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Extension", reflect.TypeOf((*MockClient)(nil).Extension), arg0)
 }
 
 // Hello mocks base method.
-func (m *MocksmtpClient) Hello(arg0 string) error {
+func (m *MockClient) Hello(arg0 string) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Hello", arg0)
 	ret0, _ := ret[0].(error)
@@ -160,27 +161,32 @@ func (m *MocksmtpClient) Hello(arg0 string) error {
 }
 
 // Hello indicates an expected call of Hello.
-func (mr *MocksmtpClientMockRecorder) Hello(arg0 interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) Hello(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Hello", reflect.TypeOf((*MocksmtpClient)(nil).Hello), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Hello", reflect.TypeOf((*MockClient)(nil).Hello), arg0)
 }
 
 // Mail mocks base method.
-func (m *MocksmtpClient) Mail(arg0 string) error {
+func (m *MockClient) Mail(from string, params ...string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Mail", arg0)
+	varargs := []interface{}{from}
+	for _, a := range params {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Mail", varargs...)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Mail indicates an expected call of Mail.
-func (mr *MocksmtpClientMockRecorder) Mail(arg0 interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) Mail(from interface{}, params ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Mail", reflect.TypeOf((*MocksmtpClient)(nil).Mail), arg0)
+	varargs := append([]interface{}{from}, params...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Mail", reflect.TypeOf((*MockClient)(nil).Mail), varargs...)
 }
 
 // Quit mocks base method.
-func (m *MocksmtpClient) Quit() error {
+func (m *MockClient) Quit() error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Quit")
 	ret0, _ := ret[0].(error)
@@ -188,27 +194,46 @@ func (m *MocksmtpClient) Quit() error {
 }
 
 // Quit indicates an expected call of Quit.
-func (mr *MocksmtpClientMockRecorder) Quit() *gomock.Call {
+func (mr *MockClientMockRecorder) Quit() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Quit", reflect.TypeOf((*MocksmtpClient)(nil).Quit))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Quit", reflect.TypeOf((*MockClient)(nil).Quit))
 }
 
 // Rcpt mocks base method.
-func (m *MocksmtpClient) Rcpt(arg0 string) error {
+func (m *MockClient) Rcpt(to string, params ...string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Rcpt", arg0)
+	varargs := []interface{}{to}
+	for _, a := range params {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Rcpt", varargs...)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Rcpt indicates an expected call of Rcpt.
-func (mr *MocksmtpClientMockRecorder) Rcpt(arg0 interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) Rcpt(to interface{}, params ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rcpt", reflect.TypeOf((*MocksmtpClient)(nil).Rcpt), arg0)
+	varargs := append([]interface{}{to}, params...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rcpt", reflect.TypeOf((*MockClient)(nil).Rcpt), varargs...)
+}
+
+// Reset mocks base method.
+func (m *MockClient) Reset() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reset")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockClientMockRecorder) Reset() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockClient)(nil).Reset))
 }
 
 // StartTLS mocks base method.
-func (m *MocksmtpClient) StartTLS(arg0 *tls.Config) error {
+func (m *MockClient) StartTLS(arg0 *tls.Config) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "StartTLS", arg0)
 	ret0, _ := ret[0].(error)
@@ -216,9 +241,9 @@ func (m *MocksmtpClient) StartTLS(arg0 *tls.Config) error {
 }
 
 // StartTLS indicates an expected call of StartTLS.
-func (mr *MocksmtpClientMockRecorder) StartTLS(arg0 interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) StartTLS(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartTLS", reflect.TypeOf((*MocksmtpClient)(nil).StartTLS), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartTLS", reflect.TypeOf((*MockClient)(nil).StartTLS), arg0)
 }
 
 // MockSendCloser is a mock of SendCloser interface.
@@ -272,6 +297,58 @@ func (mr *MockSendCloserMockRecorder) Send(message interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockSendCloser)(nil).Send), message)
 }
 
+// SendContext mocks base method.
+func (m *MockSendCloser) SendContext(ctx context.Context, message message.Message) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendContext", ctx, message)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendContext indicates an expected call of SendContext.
+func (mr *MockSendCloserMockRecorder) SendContext(ctx, message interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendContext", reflect.TypeOf((*MockSendCloser)(nil).SendContext), ctx, message)
+}
+
+// MockDialer is a mock of Dialer interface.
+type MockDialer struct {
+	ctrl     *gomock.Controller
+	recorder *MockDialerMockRecorder
+}
+
+// MockDialerMockRecorder is the mock recorder for MockDialer.
+type MockDialerMockRecorder struct {
+	mock *MockDialer
+}
+
+// NewMockDialer creates a new mock instance.
+func NewMockDialer(ctrl *gomock.Controller) *MockDialer {
+	mock := &MockDialer{ctrl: ctrl}
+	mock.recorder = &MockDialerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDialer) EXPECT() *MockDialerMockRecorder {
+	return m.recorder
+}
+
+// DialContext mocks base method.
+func (m *MockDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DialContext", ctx, network, address)
+	ret0, _ := ret[0].(net.Conn)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DialContext indicates an expected call of DialContext.
+func (mr *MockDialerMockRecorder) DialContext(ctx, network, address interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DialContext", reflect.TypeOf((*MockDialer)(nil).DialContext), ctx, network, address)
+}
+
 // Mockconn is a mock of conn interface.
 type Mockconn struct {
 	ctrl     *gomock.Controller
@@ -0,0 +1,47 @@
+// Package mx resolves the mail servers of domains from their MX records (RFC 5321 section 5.1, RFC 7505).
+package mx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// ErrNoMailServer is returned when the domain has no mail server.
+var ErrNoMailServer = errors.New("domain has no mail server")
+
+// LookupMX returns the MX records of a domain, extracted to be stubbed during testing.
+var LookupMX = net.DefaultResolver.LookupMX
+
+// Hosts returns the hosts of the MX records of domain by preference, none when it has no MX records, so its mail
+// is delivered to the domain itself. A null MX is reported as ErrNoMailServer. The records are looked up with
+// lookup, or LookupMX when nil.
+func Hosts(ctx context.Context, domain string, lookup func(ctx context.Context, name string) ([]*net.MX, error)) ([]string, error) {
+	if lookup == nil {
+		lookup = LookupMX
+	}
+	mxs, err := lookup(ctx, domain)
+	if err != nil && !IsNotFound(err) {
+		return nil, fmt.Errorf("failed to look up MX records of %s: %w", domain, err)
+	}
+	sort.SliceStable(mxs, func(i, j int) bool { return mxs[i].Pref < mxs[j].Pref })
+	hosts := make([]string, 0, len(mxs))
+	for _, mx := range mxs {
+		host := strings.TrimSuffix(mx.Host, ".")
+		if host == "" {
+			// a null MX (RFC 7505) declares the domain does not accept mail.
+			return nil, fmt.Errorf("%w: %s declares a null MX", ErrNoMailServer, domain)
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+// IsNotFound reports whether err is a DNS error telling the name has no records.
+func IsNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
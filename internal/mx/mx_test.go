@@ -0,0 +1,52 @@
+package mx
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHosts(t *testing.T) {
+	timeout := &net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}
+	tests := map[string]struct {
+		mxs         []*net.MX
+		err         error
+		expected    []string
+		expectedErr error
+	}{
+		"should return the hosts by preference": {
+			mxs:      []*net.MX{{Host: "mx2.example.com.", Pref: 20}, {Host: "mx1.example.com.", Pref: 10}},
+			expected: []string{"mx1.example.com", "mx2.example.com"},
+		},
+		"should return no hosts for domains without MX records": {
+			err:      &net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true},
+			expected: []string{},
+		},
+		"should refuse domains declaring a null MX": {
+			mxs:         []*net.MX{{Host: ".", Pref: 0}},
+			expectedErr: ErrNoMailServer,
+		},
+		"should report failing lookups": {
+			err:         timeout,
+			expectedErr: timeout,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			hosts, err := Hosts(context.Background(), "example.com", func(ctx context.Context, name string) ([]*net.MX, error) {
+				return tc.mxs, tc.err
+			})
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr))
+				assert.Nil(t, hosts)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, hosts)
+		})
+	}
+}
@@ -0,0 +1,220 @@
+// Package punycode converts internationalized domain names to their ASCII form and back (RFC 3492, RFC 5891).
+package punycode
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	base        = 36
+	tMin        = 1
+	tMax        = 26
+	skew        = 38
+	damp        = 700
+	initialBias = 72
+	initialN    = 128
+
+	// acePrefix marks a label encoded with punycode.
+	acePrefix = "xn--"
+)
+
+// ToASCII converts every non-ASCII label of the domain to its "xn--" punycode form.
+// Labels are lower-cased before encoding, the full IDNA2008 mapping is not applied.
+func ToASCII(domain string) (string, error) {
+	if !utf8.ValidString(domain) {
+		return "", fmt.Errorf("invalid utf-8 domain %q", domain)
+	}
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		encoded, err := Encode(strings.ToLower(label))
+		if err != nil {
+			return "", fmt.Errorf("failed to convert domain %s: %w", domain, err)
+		}
+		labels[i] = acePrefix + encoded
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// ToUnicode converts every "xn--" punycode label of the domain back to Unicode.
+func ToUnicode(domain string) (string, error) {
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if len(label) < len(acePrefix) || !strings.EqualFold(label[:len(acePrefix)], acePrefix) {
+			continue
+		}
+		decoded, err := Decode(strings.ToLower(label[len(acePrefix):]))
+		if err != nil {
+			return "", fmt.Errorf("failed to convert domain %s: %w", domain, err)
+		}
+		labels[i] = decoded
+	}
+	return strings.Join(labels, "."), nil
+}
+
+// Encode returns the punycode encoding of s, without the "xn--" prefix.
+func Encode(s string) (string, error) {
+	if !utf8.ValidString(s) {
+		return "", fmt.Errorf("invalid utf-8 label %q", s)
+	}
+	var out strings.Builder
+	runes := []rune(s)
+	for _, r := range runes {
+		if r < initialN {
+			out.WriteRune(r)
+		}
+	}
+	basic := out.Len()
+	handled := basic
+	if basic > 0 {
+		out.WriteByte('-')
+	}
+
+	n, delta, bias := rune(initialN), 0, initialBias
+	for handled < len(runes) {
+		m := rune(utf8.MaxRune)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		delta += int(m-n) * (handled + 1)
+		n = m
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := base; ; k += base {
+				t := threshold(k, bias)
+				if q < t {
+					break
+				}
+				out.WriteByte(digit(t + (q-t)%(base-t)))
+				q = (q - t) / (base - t)
+			}
+			out.WriteByte(digit(q))
+			bias = adapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+	return out.String(), nil
+}
+
+// Decode returns the string encoded by the punycode s, given without the "xn--" prefix.
+func Decode(s string) (string, error) {
+	var output []rune
+	pos := 0
+	if i := strings.LastIndexByte(s, '-'); i >= 0 {
+		if !isASCII(s[:i]) {
+			return "", fmt.Errorf("invalid punycode %q", s)
+		}
+		output = []rune(s[:i])
+		pos = i + 1
+	}
+	n, i, bias := initialN, 0, initialBias
+	for pos < len(s) {
+		oldI, w := i, 1
+		for k := base; ; k += base {
+			if pos == len(s) {
+				return "", fmt.Errorf("truncated punycode %q", s)
+			}
+			d := digitValue(s[pos])
+			pos++
+			if d < 0 {
+				return "", fmt.Errorf("invalid punycode %q", s)
+			}
+			if d > (utf8.MaxRune-i)/w {
+				return "", fmt.Errorf("punycode %q overflows", s)
+			}
+			i += d * w
+			t := threshold(k, bias)
+			if d < t {
+				break
+			}
+			w *= base - t
+		}
+		x := len(output) + 1
+		bias = adapt(i-oldI, x, oldI == 0)
+		n += i / x
+		i %= x
+		if n > utf8.MaxRune || (n >= 0xd800 && n <= 0xdfff) {
+			return "", fmt.Errorf("punycode %q decodes to an invalid code point", s)
+		}
+		output = append(output, 0)
+		copy(output[i+1:], output[i:])
+		output[i] = rune(n)
+		i++
+	}
+	return string(output), nil
+}
+
+// digitValue returns the digit of the basic code point c, -1 when it is not a digit.
+func digitValue(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c-'0') + 26
+	case c >= 'a' && c <= 'z':
+		return int(c - 'a')
+	case c >= 'A' && c <= 'Z':
+		return int(c - 'A')
+	default:
+		return -1
+	}
+}
+
+// threshold returns the digit threshold for position k.
+func threshold(k, bias int) int {
+	switch {
+	case k <= bias:
+		return tMin
+	case k >= bias+tMax:
+		return tMax
+	default:
+		return k - bias
+	}
+}
+
+// adapt returns the bias after encoding a code point, see RFC 3492 section 6.1.
+func adapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= damp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((base-tMin)*tMax)/2 {
+		delta /= base - tMin
+		k += base
+	}
+	return k + (base-tMin+1)*delta/(delta+skew)
+}
+
+// digit returns the basic code point of the digit d.
+func digit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+// isASCII reports whether s only contains ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
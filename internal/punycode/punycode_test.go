@@ -0,0 +1,68 @@
+package punycode
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToASCII(t *testing.T) {
+	tests := map[string]struct {
+		domain   string
+		expected string
+	}{
+		"should keep ascii domain":               {domain: "example.com", expected: "example.com"},
+		"should encode label with basic runes":   {domain: "bücher.example", expected: "xn--bcher-kva.example"},
+		"should encode label without basic rune": {domain: "例え.jp", expected: "xn--r8jz45g.jp"},
+		"should lower-case label before encode":  {domain: "BÜCHER.de", expected: "xn--bcher-kva.de"},
+		"should encode arabic label":             {domain: "مثال.إختبار", expected: "xn--mgbh0fb.xn--kgbechtv"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ToASCII(tc.domain)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+
+	t.Run("should fail on invalid utf-8", func(t *testing.T) {
+		t.Parallel()
+		_, err := ToASCII("\xffexample.com")
+		assert.NotNil(t, err)
+	})
+}
+
+func TestToUnicode(t *testing.T) {
+	tests := map[string]struct {
+		domain   string
+		expected string
+	}{
+		"should keep ascii domain":               {domain: "example.com", expected: "example.com"},
+		"should decode label with basic runes":   {domain: "xn--bcher-kva.example", expected: "bücher.example"},
+		"should decode label without basic rune": {domain: "xn--r8jz45g.jp", expected: "例え.jp"},
+		"should decode upper-case prefix":        {domain: "XN--BCHER-KVA.de", expected: "bücher.de"},
+		"should decode arabic label":             {domain: "xn--mgbh0fb.xn--kgbechtv", expected: "مثال.إختبار"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := ToUnicode(tc.domain)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, got)
+
+			ascii, err := ToASCII(got)
+			assert.Nil(t, err)
+			assert.Equal(t, strings.ToLower(tc.domain), ascii)
+		})
+	}
+
+	t.Run("should fail on invalid punycode", func(t *testing.T) {
+		t.Parallel()
+		for _, domain := range []string{"xn--bcher-kv!.de", "xn--bcher-kv.de", "xn--ü-kva.de", "xn--99999999999.de"} {
+			_, err := ToUnicode(domain)
+			assert.NotNil(t, err, domain)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package gomailer
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/nawafswe/gomailer/message"
+)
+
+// loggingClient is implemented by smtp clients able to log the SMTP protocol exchange (see protocolClient.setLogger).
+type loggingClient interface {
+	setLogger(logger *slog.Logger)
+}
+
+// WithLogger configures Mailer to log sent messages, failures and warnings to logger, labeled with the SMTP server.
+// At the debug level, every SMTP command is logged with the reply of the server and how long it took,
+// credentials exchanged during authentication are redacted and message contents are never logged.
+func WithLogger(logger *slog.Logger) func(*Mailer) {
+	return func(mailer *Mailer) {
+		if logger == nil {
+			return
+		}
+		mailer.logger = logger
+		mailer.hooks = append(mailer.hooks, loggingHooks(logger))
+	}
+}
+
+// loggingHooks returns the Hooks logging the send lifecycle to logger.
+func loggingHooks(logger *slog.Logger) Hooks {
+	return Hooks{
+		AfterSend: func(ctx context.Context, msg message.Message) {
+			logger.InfoContext(ctx, "message sent", append(endpointAttrs(ctx), messageAttrs(ctx, msg)...)...)
+		},
+		OnError: func(ctx context.Context, msg message.Message, err error) {
+			attrs := append(endpointAttrs(ctx), messageAttrs(ctx, msg)...)
+			logger.ErrorContext(ctx, "failed to send message", append(attrs, slog.Any("error", err))...)
+		},
+		OnWarning: func(ctx context.Context, warning error) {
+			logger.WarnContext(ctx, warning.Error(), endpointAttrs(ctx)...)
+		},
+	}
+}
+
+// endpointAttrs returns the log attributes of the SMTP server carried by ctx.
+func endpointAttrs(ctx context.Context) []any {
+	e, ok := EndpointFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return []any{slog.String("host", e.Host), slog.Int("port", e.Port)}
+}
+
+// messageAttrs returns the log attributes identifying msg, by the Message-ID it was sent with when ctx carries its Result.
+func messageAttrs(ctx context.Context, msg message.Message) []any {
+	attrs := []any{slog.Int("recipients", len(msg.Recipients))}
+	id := headerValue(msg, "Message-ID")
+	if r, ok := resultFromContext(ctx); ok && r.MessageID != "" {
+		id = r.MessageID
+	}
+	if id != "" {
+		attrs = append(attrs, slog.String("message_id", id))
+	}
+	return attrs
+}
+
+// redactAuth returns the line sent during authentication with the credentials redacted,
+// only the mechanism of the AUTH command is kept.
+func redactAuth(line string) string {
+	if fields := strings.Fields(line); len(fields) >= 2 && strings.EqualFold(fields[0], "AUTH") {
+		if len(fields) == 2 {
+			return line
+		}
+		return fields[0] + " " + fields[1] + " [redacted]"
+	}
+	if line == "*" {
+		// the cancellation of an authentication exchange.
+		return line
+	}
+	return "[redacted]"
+}
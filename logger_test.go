@@ -0,0 +1,111 @@
+package gomailer
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMailer_WithLogger(t *testing.T) {
+	t.Run("should log the protocol exchange with credentials redacted and the sent message", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		commands := make(chan string, 10)
+		go serveSMTP(serverConn, "AUTH PLAIN", map[string]string{"AUTH": "235 2.7.0 authenticated", "MAIL": "250 ok", "RCPT": "250 ok"}, commands)
+
+		// stub functions
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return clientConn, nil
+		}
+
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+			Level: slog.LevelDebug,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey || a.Key == "duration" {
+					return slog.Attr{}
+				}
+				return a
+			},
+		}))
+		mailer := NewMailer("localhost", testPort, "user", "pass", withNetDial(netDial), WithLocalName("localhost"), WithLogger(logger))
+		msg := message.Message{
+			From:       testFromEmail,
+			Recipients: testRecipient,
+			Body:       "dummy body",
+			Headers:    map[string][]string{"Message-ID": {"<1@localhost>"}},
+		}
+
+		assert.Nil(t, mailer.Send(context.Background(), msg))
+		// wait for the session to end.
+		for range commands {
+		}
+
+		expected := `level=DEBUG msg="smtp command" host=localhost port=587 command="EHLO localhost" code=250 reply="localhost\nAUTH PLAIN"
+level=WARN msg="smtp server does not advertise STARTTLS, continuing without TLS" host=localhost port=587
+level=DEBUG msg="smtp command" host=localhost port=587 command="AUTH PLAIN [redacted]" code=235 reply="2.7.0 authenticated"
+level=DEBUG msg="smtp command" host=localhost port=587 command="MAIL FROM:<test@gomailer.com>" code=250 reply=ok
+level=DEBUG msg="smtp command" host=localhost port=587 command="RCPT TO:<test@gomailer.com>" code=250 reply=ok
+level=DEBUG msg="smtp command" host=localhost port=587 command=DATA code=354 reply="go ahead"
+level=DEBUG msg="smtp command" host=localhost port=587 command=. code=250 reply=queued
+level=INFO msg="message sent" host=localhost port=587 recipients=1 message_id=<1@localhost>
+level=DEBUG msg="smtp command" host=localhost port=587 command=QUIT code=221 reply=bye
+`
+		assert.Equal(t, expected, buf.String())
+		assert.NotContains(t, buf.String(), "AHVzZXIAcGFzcw==")
+	})
+}
+
+func TestMessageAttrs(t *testing.T) {
+	msg := message.Message{Recipients: testRecipient, Headers: map[string][]string{"Message-ID": {"<1@localhost>"}}}
+	tests := map[string]struct {
+		ctx      context.Context
+		msg      message.Message
+		expected []any
+	}{
+		"should identify the message by its Message-ID header": {
+			ctx:      context.Background(),
+			msg:      msg,
+			expected: []any{slog.Int("recipients", 1), slog.String("message_id", "<1@localhost>")},
+		},
+		"should identify the message by the Message-ID it was sent with": {
+			ctx:      contextWithResult(context.Background(), Result{MessageID: "<2@localhost>"}),
+			msg:      message.Message{Recipients: testRecipient},
+			expected: []any{slog.Int("recipients", 1), slog.String("message_id", "<2@localhost>")},
+		},
+		"should omit the Message-ID of messages lacking one": {
+			ctx:      context.Background(),
+			msg:      message.Message{Recipients: testRecipient},
+			expected: []any{slog.Int("recipients", 1)},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, messageAttrs(tc.ctx, tc.msg))
+		})
+	}
+}
+
+func TestRedactAuth(t *testing.T) {
+	tests := map[string]struct {
+		line string
+		want string
+	}{
+		"should redact the initial response":      {line: "AUTH PLAIN AHVzZXIAcGFzcw==", want: "AUTH PLAIN [redacted]"},
+		"should keep commands without a response": {line: "AUTH LOGIN", want: "AUTH LOGIN"},
+		"should redact responses to challenges":   {line: "dXNlcg==", want: "[redacted]"},
+		"should keep cancellations":               {line: "*", want: "*"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, redactAuth(tc.line))
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package gomailer
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nawafswe/gomailer/message"
+)
+
+// ErrMailLoop is returned when a message is not sent because it already passed through the Mailer (see WithLoopPrevention).
+var ErrMailLoop = errors.New("message already passed through the mailer")
+
+// loopHeader is the header marking the messages sent by a Mailer with loop prevention, as mailing list managers do.
+const loopHeader = "X-Loop"
+
+// WithLoopPrevention configures Mailer to mark every sent message with an X-Loop header carrying marker (e.g. the
+// address of the service sending it), and to refuse with ErrMailLoop the messages already carrying that marker,
+// or a Message-ID or Resent-Message-ID generated by the Mailer.
+// It stops redistribution loops of pipelines resending or forwarding received messages, e.g. read with message.Parse,
+// which keeps these headers, when a message comes back to the pipeline that sent it. Other X-Loop markers are kept.
+func WithLoopPrevention(marker string) func(*Mailer) {
+	return func(mailer *Mailer) {
+		if strings.TrimSpace(marker) == "" {
+			mailer.invalidOption("loop prevention marker cannot be empty")
+			return
+		}
+		mailer.loopMarker = strings.TrimSpace(marker)
+	}
+}
+
+// markLoop returns the message marked with the loop marker of the Mailer, or an error wrapping ErrMailLoop
+// when it is already marked or carries a message identifier generated by the Mailer.
+// The message is returned as is when no loop prevention is configured.
+func (m *Mailer) markLoop(msg message.Message) (message.Message, error) {
+	if m.loopMarker == "" {
+		return msg, nil
+	}
+	markers := headerValues(msg, loopHeader)
+	for _, marker := range markers {
+		if strings.EqualFold(strings.TrimSpace(marker), m.loopMarker) {
+			return msg, fmt.Errorf("%w: %s %s", ErrMailLoop, loopHeader, m.loopMarker)
+		}
+	}
+	for _, key := range []string{"Message-ID", "Resent-Message-ID"} {
+		for _, id := range headerValues(msg, key) {
+			if m.generatedMessageID(id) {
+				return msg, fmt.Errorf("%w: %s %s", ErrMailLoop, key, strings.TrimSpace(id))
+			}
+		}
+	}
+	return msg.WithHeader(loopHeader, append(markers, m.loopMarker)...), nil
+}
+
+// generatedMessageID reports whether id has the form of the message identifiers generated by the Mailer
+// for its domain, see newMessageID.
+func (m *Mailer) generatedMessageID(id string) bool {
+	id = strings.TrimSpace(id)
+	if !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, ">") {
+		return false
+	}
+	local, domain, ok := strings.Cut(id[1:len(id)-1], "@")
+	if !ok || !strings.EqualFold(domain, m.messageIDDomain()) {
+		return false
+	}
+	nanos, token, ok := strings.Cut(local, ".")
+	if !ok || len(token) != 32 {
+		return false
+	}
+	if _, err := strconv.ParseInt(nanos, 10, 64); err != nil {
+		return false
+	}
+	_, err := hex.DecodeString(token)
+	return err == nil
+}
+
+// markResent returns the message with the Resent-From, Resent-Date and Resent-Message-ID fields of a resend on behalf
+// of the ResentFrom mailbox of the SendOptions carried by ctx, as is when there is none. The fields are added as a block
+// above the trace fields of earlier resends and relays, the Resent-Message-ID and Resent-Date of the send are reused from state.
+func (m *Mailer) markResent(ctx context.Context, msg message.Message, state *sendState) (message.Message, error) {
+	opts, _ := SendOptionsFromContext(ctx)
+	if opts.ResentFrom == "" {
+		return msg, nil
+	}
+	date := state.date
+	if date.IsZero() {
+		date = m.now()
+	}
+	block := []message.Field{
+		{Key: "Resent-From", Value: opts.ResentFrom},
+		{Key: "Resent-Date", Value: date.In(m.dateLocationOf(msg)).Format(time.RFC1123Z)},
+	}
+	if m.messageID {
+		id := state.resentID
+		if id == "" {
+			var err error
+			if id, err = m.newMessageID(); err != nil {
+				return msg, err
+			}
+		}
+		block = append(block, message.Field{Key: "Resent-Message-ID", Value: id})
+	}
+	return msg.WithTrace(block...), nil
+}
@@ -0,0 +1,261 @@
+package gomailer
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMailer_markLoop(t *testing.T) {
+	tests := map[string]struct {
+		headers     mail.Header
+		expected    mail.Header
+		expectedErr error
+	}{
+		"should mark an unmarked message": {
+			expected: mail.Header{"X-Loop": {"alerts@example.com"}},
+		},
+		"should keep the markers of other mailers": {
+			headers:  mail.Header{"x-loop": {"list@example.org"}, "Subject": {"hi"}},
+			expected: mail.Header{"X-Loop": {"list@example.org", "alerts@example.com"}, "Subject": {"hi"}},
+		},
+		"should refuse a message already marked, case-insensitively": {
+			headers:     mail.Header{"X-Loop": {"list@example.org", " Alerts@Example.com"}},
+			expected:    mail.Header{"X-Loop": {"list@example.org", " Alerts@Example.com"}},
+			expectedErr: ErrMailLoop,
+		},
+		"should refuse a message carrying a Message-ID generated by the mailer": {
+			headers:     mail.Header{"Message-Id": {"<1700000000000000000.00112233445566778899aabbccddeeff@" + testHost + ">"}},
+			expected:    mail.Header{"Message-Id": {"<1700000000000000000.00112233445566778899aabbccddeeff@" + testHost + ">"}},
+			expectedErr: ErrMailLoop,
+		},
+		"should refuse a message carrying a Resent-Message-ID generated by the mailer": {
+			headers: mail.Header{
+				"Message-ID":        {"<abc@example.org>"},
+				"Resent-Message-ID": {"<1700000000000000000.00112233445566778899aabbccddeeff@LOCALHOST.smtp.com>"},
+			},
+			expected: mail.Header{
+				"Message-ID":        {"<abc@example.org>"},
+				"Resent-Message-ID": {"<1700000000000000000.00112233445566778899aabbccddeeff@LOCALHOST.smtp.com>"},
+			},
+			expectedErr: ErrMailLoop,
+		},
+		"should mark a message carrying a Message-ID generated elsewhere": {
+			headers: mail.Header{"Message-ID": {"<1700000000000000000.00112233445566778899aabbccddeeff@example.org>"}},
+			expected: mail.Header{
+				"Message-ID": {"<1700000000000000000.00112233445566778899aabbccddeeff@example.org>"},
+				"X-Loop":     {"alerts@example.com"},
+			},
+		},
+		"should mark a message carrying a Message-ID of the mailer domain not generated by it": {
+			headers:  mail.Header{"Message-ID": {"<order-42@" + testHost + ">"}},
+			expected: mail.Header{"Message-ID": {"<order-42@" + testHost + ">"}, "X-Loop": {"alerts@example.com"}},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			mailer := NewMailer(testHost, testPort, testUser, testPassword, WithLoopPrevention("alerts@example.com"))
+			got, err := mailer.markLoop(message.Message{Headers: tc.headers})
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Equal(t, tc.expected, got.Headers)
+		})
+	}
+	t.Run("should leave the message as is without loop prevention", func(t *testing.T) {
+		msg := message.Message{Headers: mail.Header{"X-Loop": {"alerts@example.com"}}}
+		got, err := NewMailer(testHost, testPort, testUser, testPassword).markLoop(msg)
+		assert.Nil(t, err)
+		assert.Equal(t, msg, got)
+	})
+	t.Run("should reject an empty marker", func(t *testing.T) {
+		_, err := NewMailerE(testHost, testPort, testUser, testPassword, WithLoopPrevention(" "))
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, "loop prevention marker cannot be empty")
+	})
+}
+
+func TestMailer_LoopPrevention(t *testing.T) {
+	t.Run("should refuse a parsed message sent by the mailer before starting the transaction", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		commands := make(chan string, 10)
+		go serveSMTP(serverConn, "8BITMIME", map[string]string{"MAIL": "250 ok", "RCPT": "250 ok"}, commands)
+
+		// stub functions
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return clientConn, nil
+		}
+
+		var sent []byte
+		mailer := NewMailer("localhost", testPort, "", "", withNetDial(netDial), WithLocalName("localhost"), WithLoopPrevention("alerts@example.com"),
+			WithHooks(Hooks{BeforeSend: func(ctx context.Context, msg message.Message, encoded []byte) error {
+				sent = encoded
+				return nil
+			}}))
+		sender, err := mailer.ConnectAndAuthenticate()
+		require.Nil(t, err)
+		require.Nil(t, sender.Send(message.Message{From: testFromEmail, Recipients: testRecipient, Body: "alert",
+			Headers: mail.Header{"X-Loop": {"list@example.org"}}}))
+		assert.Contains(t, string(sent), "X-Loop: list@example.org\r\nX-Loop: alerts@example.com\r\n")
+
+		received, err := message.Parse(bytes.NewReader(sent))
+		require.Nil(t, err)
+		err = sender.Send(received)
+		assert.ErrorIs(t, err, ErrMailLoop)
+		require.Nil(t, sender.Close())
+
+		var mails int
+		for command := range commands {
+			if strings.HasPrefix(command, "MAIL FROM") {
+				mails++
+			}
+		}
+		assert.Equal(t, 1, mails)
+	})
+	t.Run("should refuse a parsed message carrying a Message-ID generated by the mailer without its marker", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		commands := make(chan string, 10)
+		go serveSMTP(serverConn, "8BITMIME", map[string]string{"MAIL": "250 ok", "RCPT": "250 ok"}, commands)
+
+		// stub functions
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return clientConn, nil
+		}
+
+		var sent []byte
+		mailer := NewMailer("localhost", testPort, "", "", withNetDial(netDial), WithLocalName("localhost"), WithLoopPrevention("alerts@example.com"),
+			WithHooks(Hooks{BeforeSend: func(ctx context.Context, msg message.Message, encoded []byte) error {
+				sent = encoded
+				return nil
+			}}))
+		sender, err := mailer.ConnectAndAuthenticate()
+		require.Nil(t, err)
+		require.Nil(t, sender.Send(message.Message{From: testFromEmail, Recipients: testRecipient, Body: "alert"}))
+
+		received, err := message.Parse(bytes.NewReader(sent))
+		require.Nil(t, err)
+		// relays forwarding the message may drop the X-Loop header.
+		delete(received.Headers, "X-Loop")
+		err = sender.Send(received)
+		assert.ErrorIs(t, err, ErrMailLoop)
+		assert.ErrorContains(t, err, "Message-ID <")
+		require.Nil(t, sender.Close())
+		assert.Len(t, receive(commands), 5)
+	})
+}
+
+func TestMailer_Resent(t *testing.T) {
+	var commands chan string
+	dial := withNetDial(func(network string, host string, t time.Duration) (net.Conn, error) {
+		clientConn, serverConn := net.Pipe()
+		commands = make(chan string, 10)
+		go serveSMTP(serverConn, "8BITMIME", map[string]string{"MAIL": "250 ok", "RCPT": "250 ok"}, commands)
+		return clientConn, nil
+	})
+	original := "From: Alerts <alerts@example.org>\r\n" +
+		"To: ops@example.org\r\n" +
+		"Subject: disk full\r\n" +
+		"Date: Mon, 02 Jan 2006 15:04:05 +0000\r\n" +
+		"Message-ID: <abc@example.org>\r\n" +
+		"Resent-From: relay@example.net\r\n" +
+		"\r\n" +
+		"alert\r\n"
+	clock := func() time.Time {
+		return time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	}
+
+	t.Run("should stamp the Resent fields above those of earlier resends and keep the original ones", func(t *testing.T) {
+		var sent []byte
+		mailer := NewMailer("localhost", testPort, "", "", dial, WithLocalName("localhost"), withClock(clock),
+			WithHooks(Hooks{BeforeSend: func(ctx context.Context, msg message.Message, encoded []byte) error {
+				sent = encoded
+				return nil
+			}}))
+		received, err := message.Parse(strings.NewReader(original))
+		require.Nil(t, err)
+		received.Recipients = []string{"oncall@example.com"}
+
+		ctx := WithSendOptions(context.Background(), SendOptions{ResentFrom: "Pipeline <pipeline@example.com>"})
+		result, err := mailer.SendResult(ctx, received)
+		require.Nil(t, err)
+		assert.Equal(t, "<abc@example.org>", result.MessageID)
+		assert.Contains(t, receive(commands), "MAIL FROM:<pipeline@example.com> BODY=8BITMIME")
+
+		resent, err := message.Parse(bytes.NewReader(sent))
+		require.Nil(t, err)
+		assert.Equal(t, "<abc@example.org>", headerValue(resent, "Message-ID"))
+		assert.Equal(t, "Mon, 02 Jan 2006 15:04:05 +0000", headerValue(resent, "Date"))
+		assert.Equal(t, []string{"Pipeline <pipeline@example.com>", "relay@example.net"}, headerValues(resent, "Resent-From"))
+		assert.Equal(t, []string{"Fri, 16 Oct 2026 12:00:00 +0000"}, headerValues(resent, "Resent-Date"))
+		ids := headerValues(resent, "Resent-Message-ID")
+		require.Len(t, ids, 1)
+		assert.True(t, mailer.generatedMessageID(ids[0]))
+	})
+	t.Run("should keep the Resent blocks of a message resent twice together, the newest first", func(t *testing.T) {
+		var sent []byte
+		hooks := WithHooks(Hooks{BeforeSend: func(ctx context.Context, msg message.Message, encoded []byte) error {
+			sent = encoded
+			return nil
+		}})
+		first := NewMailer("localhost", testPort, "", "", dial, WithLocalName("localhost"), withClock(clock), hooks)
+		second := NewMailer("localhost", testPort, "", "", dial, WithLocalName("localhost"), hooks, withClock(func() time.Time {
+			return time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+		}))
+		received, err := message.Parse(strings.NewReader("Received: from mx.example.org\r\n" + original))
+		require.Nil(t, err)
+
+		require.Nil(t, first.Send(WithSendOptions(context.Background(), SendOptions{ResentFrom: "first@example.com"}), received))
+		receive(commands)
+		once, err := message.Parse(bytes.NewReader(sent))
+		require.Nil(t, err)
+		require.Nil(t, second.Send(WithSendOptions(context.Background(), SendOptions{ResentFrom: "second@example.com"}), once))
+		receive(commands)
+
+		twice, err := message.Parse(bytes.NewReader(sent))
+		require.Nil(t, err)
+		require.Len(t, twice.Trace, 8)
+		assert.Equal(t, []message.Field{
+			{Key: "Resent-From", Value: "second@example.com"},
+			{Key: "Resent-Date", Value: "Sat, 17 Oct 2026 12:00:00 +0000"},
+		}, twice.Trace[:2])
+		assert.Equal(t, "Resent-Message-ID", twice.Trace[2].Key)
+		assert.Equal(t, []message.Field{
+			{Key: "Resent-From", Value: "first@example.com"},
+			{Key: "Resent-Date", Value: "Fri, 16 Oct 2026 12:00:00 +0000"},
+		}, twice.Trace[3:5])
+		assert.Equal(t, "Resent-Message-ID", twice.Trace[5].Key)
+		assert.NotEqual(t, twice.Trace[2].Value, twice.Trace[5].Value)
+		assert.Equal(t, []message.Field{
+			{Key: "Received", Value: "from mx.example.org"},
+			{Key: "Resent-From", Value: "relay@example.net"},
+		}, twice.Trace[6:])
+		assert.True(t, strings.HasPrefix(string(sent), "Resent-From: second@example.com\r\n"), string(sent))
+	})
+	t.Run("should refuse a resent message coming back with loop prevention", func(t *testing.T) {
+		var sent []byte
+		mailer := NewMailer("localhost", testPort, "", "", dial, WithLocalName("localhost"), WithLoopPrevention("pipeline@example.com"),
+			WithHooks(Hooks{BeforeSend: func(ctx context.Context, msg message.Message, encoded []byte) error {
+				sent = encoded
+				return nil
+			}}))
+		received, err := message.Parse(strings.NewReader(original))
+		require.Nil(t, err)
+
+		ctx := WithSendOptions(context.Background(), SendOptions{ResentFrom: "pipeline@example.com"})
+		require.Nil(t, mailer.Send(ctx, received))
+		receive(commands)
+
+		resent, err := message.Parse(bytes.NewReader(sent))
+		require.Nil(t, err)
+		delete(resent.Headers, "X-Loop")
+		err = mailer.Send(ctx, resent)
+		assert.ErrorIs(t, err, ErrMailLoop)
+		assert.ErrorContains(t, err, "Resent-Message-ID <")
+	})
+}
@@ -1,24 +1,82 @@
 package gomailer
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
 	"crypto/tls"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"maps"
 	"net"
 	"net/smtp"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nawafswe/gomailer/message"
 )
 
 const (
+	smtpPort           = 25
 	sslPort            = 465
+	submissionPort     = 587
 	crmAuthMechanism   = "CRAM-MD5"
 	plainAuthMechanism = "PLAIN"
 	loginAuthMechanism = "LOGIN"
+	// tolerantGreetingTimeout is the initial 220 message timeout recommended by RFC 5321 section 4.5.3.2.1.
+	tolerantGreetingTimeout = 5 * time.Minute
+	// dataBufferSize is the size of the buffer of the message data written to the server.
+	dataBufferSize = 32 << 10
 )
 
+// ErrSTARTTLSRequired is returned when STARTTLS is required but the SMTP server does not advertise it.
+var ErrSTARTTLSRequired = errors.New("smtp server does not advertise STARTTLS")
+
+// ErrExtensionNotAdvertised is reported to the OnWarning hooks when the SMTP server does not advertise an extension
+// Mailer would use, e.g. STARTTLS or AUTH on minimal servers only supporting HELO. Mailer continues without it.
+var ErrExtensionNotAdvertised = errors.New("smtp server does not advertise")
+
+// ErrInvalidConfig is returned when Mailer is used with an invalid configuration.
+var ErrInvalidConfig = errors.New("invalid mailer configuration")
+
+// Encryption describes how the connection to the SMTP server is secured.
+type Encryption int
+
+const (
+	// EncryptionSTARTTLS upgrades the plaintext connection with STARTTLS when the server advertises it.
+	EncryptionSTARTTLS Encryption = iota
+	// EncryptionSSLTLS wraps the connection with TLS right after dialing (implicit TLS), regardless of the port.
+	EncryptionSSLTLS
+	// EncryptionNone never secures the connection, STARTTLS is not issued even when the server advertises it.
+	EncryptionNone
+	// EncryptionOpportunistic behaves like EncryptionSTARTTLS, but when the TLS handshake fails
+	// it reconnects and continues over plaintext instead of failing.
+	EncryptionOpportunistic
+)
+
+// String returns the name of the encryption mode.
+func (e Encryption) String() string {
+	switch e {
+	case EncryptionSTARTTLS:
+		return "STARTTLS"
+	case EncryptionSSLTLS:
+		return "SSL/TLS"
+	case EncryptionNone:
+		return "None"
+	case EncryptionOpportunistic:
+		return "Opportunistic"
+	default:
+		return fmt.Sprintf("Encryption(%d)", int(e))
+	}
+}
+
 //go:generate mockgen -source=mailer.go -destination=internal/mock/mailer.go -package=mock
 type (
 	// Options to configure Mailer.
@@ -43,26 +101,39 @@ type (
 		Next(fromServer []byte, more bool) (toServer []byte, err error)
 	}
 
-	// smtpClient for implementing smtpClient
-	smtpClient interface {
+	// Client is the SMTP client the Mailer runs the SMTP protocol exchange with, see WithClientFactory.
+	// Its methods follow those of smtp.Client, MAIL and RCPT accept parameters of the advertised extensions.
+	Client interface {
 		Hello(string) error
 		Extension(string) (bool, string)
 		StartTLS(*tls.Config) error
 		Auth(smtp.Auth) error
-		Mail(string) error
-		Rcpt(string) error
+		Mail(from string, params ...string) error
+		Rcpt(to string, params ...string) error
 		Data() (io.WriteCloser, error)
+		Reset() error
 		Quit() error
 		Close() error
 	}
 
 	// SendCloser is an interface that encapsulates the functionality of sending a message and closing the connection to the SMTP server.
 	// It provides methods to send an email message and to terminate the SMTP server session.
+	// The SendCloser returned by Mailer.ConnectAndAuthenticate is safe for concurrent use, the messages of concurrent
+	// callers are sent one after the other over its single connection; use Mailer.Send to send them in parallel.
 	SendCloser interface {
 		// Close terminate the smtp server session.
 		Close() error
 		// Send sends message.Message.
 		Send(message message.Message) error
+		// SendContext sends message.Message, ctx is passed to the hooks so request-scoped values (e.g. tenant, trace) are available to them.
+		SendContext(ctx context.Context, message message.Message) error
+	}
+
+	// Dialer dials the connection to the SMTP server, e.g. through a SOCKS5 or HTTP CONNECT proxy or a custom network stack.
+	// *net.Dialer and the dialers returned by golang.org/x/net/proxy implement it.
+	Dialer interface {
+		// DialContext connects to the address on the named network using the provided context.
+		DialContext(ctx context.Context, network, address string) (net.Conn, error)
 	}
 
 	// conn is a generic stream-oriented network connection.
@@ -146,6 +217,14 @@ func defaultDialTimeout() time.Duration {
 	return time.Second * 5
 }
 
+// defaultEncryption returns implicit TLS for the well-known SMTPS port and STARTTLS otherwise.
+func defaultEncryption(port int) Encryption {
+	if port == sslPort {
+		return EncryptionSSLTLS
+	}
+	return EncryptionSTARTTLS
+}
+
 // WithLocalName configures Mailer with localName.
 func WithLocalName(l string) func(mailer *Mailer) {
 	return func(mailer *Mailer) {
@@ -158,27 +237,114 @@ func WithLocalName(l string) func(mailer *Mailer) {
 // WithTLSConfig configures Mailer with tls.Config.
 func WithTLSConfig(cfg *tls.Config) func(*Mailer) {
 	return func(mailer *Mailer) {
-		if cfg != nil {
-			mailer.tlsConfig = cfg
+		if cfg == nil {
+			mailer.invalidOption("tls config cannot be nil")
+			return
 		}
+		mailer.tlsConfig = cfg
+	}
+}
+
+// WithHostTLSConfig configures Mailer with a tls.Config used only when connecting to the given host,
+// taking precedence over WithTLSConfig, e.g. to pin certificates for the primary relay while
+// other hosts use the system roots. The host is matched case-insensitively.
+func WithHostTLSConfig(host string, cfg *tls.Config) func(*Mailer) {
+	return func(mailer *Mailer) {
+		if cfg == nil || host == "" {
+			mailer.invalidOption("tls config of host %q must be given for a non-empty host", host)
+			return
+		}
+		if mailer.hostTLSConfigs == nil {
+			mailer.hostTLSConfigs = make(map[string]*tls.Config)
+		}
+		mailer.hostTLSConfigs[strings.ToLower(host)] = cfg
 	}
 }
 
 // WithDialTimeout configures Mailer with time.Duration for dial timeout.
 func WithDialTimeout(t time.Duration) func(*Mailer) {
 	return func(mailer *Mailer) {
-		if t.Seconds() > 0 {
-			mailer.dialTimeout = t
+		if t <= 0 {
+			mailer.invalidOption("dial timeout %s must be positive", t)
+			return
+		}
+		mailer.dialTimeout = t
+	}
+}
+
+// WithCommandTimeout configures Mailer to fail when the SMTP server does not complete a command
+// (e.g. EHLO, AUTH, MAIL, RCPT) within t, so a stalled server cannot hang a send forever.
+func WithCommandTimeout(t time.Duration) func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.commandTimeout = t
+	}
+}
+
+// WithGreetingTimeout configures Mailer to fail when the SMTP server does not send its greeting banner within t
+// of connecting, instead of the command timeout. Some relays, such as greylisting appliances, delay the banner on purpose,
+// a longer greeting timeout lets them through without raising the command or dial timeouts (see WithGreetingTolerance).
+func WithGreetingTimeout(t time.Duration) func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.greetingTimeout = t
+	}
+}
+
+// WithGreetingTolerance configures Mailer to wait for the greeting banner as long as RFC 5321 section 4.5.3.2.1
+// recommends (5 minutes), tolerating servers delaying it, while the other timeouts still apply to the commands.
+// The context given to Send and WithSendTimeout still bound the wait.
+func WithGreetingTolerance() func(*Mailer) {
+	return WithGreetingTimeout(tolerantGreetingTimeout)
+}
+
+// WithDataTimeout configures Mailer to fail when the transfer of a message and the reply of the SMTP server to it
+// do not complete within t. It is usually longer than the command timeout, as messages may be large.
+func WithDataTimeout(t time.Duration) func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.dataTimeout = t
+	}
+}
+
+// WithSendTimeout configures Mailer.Send to fail when a send, from dialing to the reply to the message, does not complete within t.
+// Every retry (see WithRetryPolicy) is given t again.
+func WithSendTimeout(t time.Duration) func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.sendTimeout = t
+	}
+}
+
+// WithDialer configures Mailer with a Dialer used to connect to the SMTP server instead of a direct TCP connection.
+// The dial timeout still applies through the context given to the Dialer.
+func WithDialer(d Dialer) func(*Mailer) {
+	return func(mailer *Mailer) {
+		if d == nil {
+			mailer.invalidOption("dialer cannot be nil")
+			return
+		}
+		mailer.dialer = d
+	}
+}
+
+// WithClientFactory configures Mailer with the function creating the Client over every connection to the SMTP server,
+// e.g. to record or replay the SMTP protocol exchange. The connection is already secured when implicit TLS is used,
+// the Client is expected to have read the greeting of the server when returned.
+func WithClientFactory(f func(conn net.Conn, host string) (Client, error)) func(*Mailer) {
+	return func(mailer *Mailer) {
+		if f == nil {
+			mailer.invalidOption("client factory cannot be nil")
+			return
 		}
+		mailer.newClient = f
 	}
 }
 
 // WithAuth configures Mailer with smtp.Auth mechanism.
 func WithAuth(auth smtp.Auth) func(*Mailer) {
 	return func(mailer *Mailer) {
-		if auth != nil {
-			mailer.auth = auth
+		if auth == nil {
+			mailer.invalidOption("auth cannot be nil")
+			return
 		}
+		mailer.auth = auth
 	}
 }
 
@@ -192,16 +358,110 @@ func WithSecrets(s string) func(*Mailer) {
 }
 
 // WithSSLEnabled configures Mailer with ssl option.
+//
+// Deprecated: use WithEncryption(EncryptionSSLTLS) instead.
 func WithSSLEnabled(s bool) func(*Mailer) {
 	return func(mailer *Mailer) {
 		if s {
-			mailer.sslEnabled = s
+			mailer.encryption = EncryptionSSLTLS
 		}
 	}
 }
 
+// WithRequireSTARTTLS configures Mailer to refuse the connection instead of falling back to plaintext
+// when the SMTP server does not advertise STARTTLS or the TLS handshake fails, preventing downgrades to cleartext.
+// It applies to EncryptionSTARTTLS and EncryptionOpportunistic.
+func WithRequireSTARTTLS(r bool) func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.requireSTARTTLS = r
+	}
+}
+
+// WithContentHash configures Mailer to add the X-Content-Hash header to every sent message,
+// carrying the canonical hash of the encoded body (see message.Message.ContentHash) for downstream deduplication.
+func WithContentHash(enabled bool) func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.contentHash = enabled
+	}
+}
+
+// WithMessageID configures whether Mailer generates a Message-ID header for messages that do not carry one.
+// It is enabled by default, the identifier is made of a random token and the local name, or the host when no local name is set.
+func WithMessageID(enabled bool) func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.messageID = enabled
+	}
+}
+
+// WithDate configures whether Mailer adds a Date header with the current time to messages that do not carry one.
+// It is enabled by default.
+func WithDate(enabled bool) func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.date = enabled
+	}
+}
+
+// WithDateLocation configures the time zone of the Date header added by Mailer, UTC by default,
+// e.g. for organizations archiving messages with local time headers. message.Message.DateLocation takes precedence.
+func WithDateLocation(loc *time.Location) func(*Mailer) {
+	return func(mailer *Mailer) {
+		if loc == nil {
+			mailer.invalidOption("date location cannot be nil")
+			return
+		}
+		mailer.dateLocation = loc
+	}
+}
+
+// WithEncodeOptions configures Mailer with options applied when encoding every sent message,
+// e.g. message.WithEntityWrapper to sign or encrypt messages with the openpgp package.
+func WithEncodeOptions(opts ...message.EncodeOption) func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.encodeOptions = append(mailer.encodeOptions, opts...)
+	}
+}
+
+// WithMaxMessageSize configures Mailer to refuse messages whose encoded size exceeds size bytes, regardless of the SIZE
+// advertised by the SMTP server, to protect relays misreporting their limit. Encoding is aborted as soon as the limit
+// is exceeded and the send fails with message.ErrMessageTooLarge.
+func WithMaxMessageSize(size int64) func(*Mailer) {
+	return func(mailer *Mailer) {
+		if size <= 0 {
+			mailer.invalidOption("max message size %d must be positive", size)
+			return
+		}
+		mailer.encodeOptions = append(mailer.encodeOptions, message.WithMaxSize(size))
+	}
+}
+
+// WithMaxAttachmentSize configures Mailer to refuse messages with an attachment larger than size bytes, e.g. the
+// 25 MB limit of a provider, with a *message.AttachmentSizeError wrapping message.ErrAttachmentTooLarge.
+// Mailer.Send and Mailer.SendBatch check it, and the content size against WithMaxMessageSize, before connecting.
+func WithMaxAttachmentSize(size int64) func(*Mailer) {
+	return func(mailer *Mailer) {
+		if size <= 0 {
+			mailer.invalidOption("max attachment size %d must be positive", size)
+			return
+		}
+		mailer.encodeOptions = append(mailer.encodeOptions, message.WithMaxAttachmentSize(size))
+	}
+}
+
+// WithEncryption configures how Mailer secures the connection to the SMTP server.
+// When not given, port 465 uses EncryptionSSLTLS and any other port uses EncryptionSTARTTLS.
+func WithEncryption(e Encryption) func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.encryption = e
+	}
+}
+
 // Mailer encapsulates the connection overhead and holds the email functionality.
 // It provides methods to send emails with and without TLS.
+//
+// A Mailer is safe for concurrent use by multiple goroutines once configured: every Send opens its own connection
+// and negotiates its own authentication mechanism, and the state shared between sends (e.g. the rate limits, the
+// circuit breaker or the host health) is synchronized. Its exported fields must not be modified while it is in use,
+// and the hooks, recipient rewriters and other implementations given to its options must be safe for concurrent use.
 type Mailer struct {
 	// Port represents the port of the SMTP server.
 	Port int
@@ -215,17 +475,141 @@ type Mailer struct {
 	localName string
 	// auth represents the way of authentication to a given SMTP server.
 	auth smtp.Auth
+	// authProviders are the authentication mechanisms negotiated in order, see WithAuthMechanisms.
+	authProviders []AuthProvider
 	// tlsConfig represents the TLS configuration used.
 	tlsConfig *tls.Config
+	// hostTLSConfigs holds TLS configurations by lower-cased host, overriding tlsConfig for that host.
+	hostTLSConfigs map[string]*tls.Config
+	// verifyConnection verifies the TLS connections once their handshake completed, none when nil.
+	verifyConnection VerifyConnectionFunc
+
+	// encryption represents how the connection to the SMTP server is secured.
+	encryption Encryption
 
-	// sslEnabled indicates whether SSL is enabled.
-	sslEnabled bool
+	// requireSTARTTLS indicates whether the connection is refused when STARTTLS cannot be negotiated.
+	requireSTARTTLS bool
 
 	// secrets used for CRAM-MD5 authentication.
 	secrets string
 
+	// verpAddress is the bounce address the envelope senders of SendBatch are derived from, see WithVERP.
+	verpAddress string
+
+	// connCache keeps the idle connections reused by Send, connections are closed after every send when nil.
+	connCache *connCache
+
+	// direct indicates the Mailer delivers to the recipient servers for the DirectTransport, which accept mail without authentication.
+	direct bool
+
+	// credentials provides the username and password of every connection in place of the static ones, none when nil.
+	credentials CredentialsProvider
+
 	// dialTimeout represents a timeout configuration for connecting to smtp server.
 	dialTimeout time.Duration
+
+	// commandTimeout bounds every SMTP command and its reply, no timeout applies when zero.
+	commandTimeout time.Duration
+
+	// greetingTimeout bounds the wait for the greeting banner, the commandTimeout applies when zero.
+	greetingTimeout time.Duration
+
+	// dataTimeout bounds the transfer of a message and the reply to it, no timeout applies when zero.
+	dataTimeout time.Duration
+
+	// sendTimeout bounds every attempt of Send, no timeout applies when zero.
+	sendTimeout time.Duration
+
+	// hooks invoked along the send lifecycle.
+	hooks hookChain
+
+	// recipientRewriters rewrite the envelope recipients, in order.
+	recipientRewriters []RecipientRewriter
+
+	// contentHash indicates whether the X-Content-Hash header is added to sent messages.
+	contentHash bool
+
+	// messageID indicates whether a Message-ID header is generated for messages lacking one.
+	messageID bool
+
+	// date indicates whether a Date header is added to messages lacking one.
+	date bool
+	// dateLocation is the time zone of the added Date header, UTC when nil.
+	dateLocation *time.Location
+
+	// loopMarker marks the sent messages with an X-Loop header to refuse them once they come back, none when empty.
+	loopMarker string
+
+	// htmlPolicy sanitizes the HTML body of sent messages, none when nil.
+	htmlPolicy *message.HTMLPolicy
+	// textFromHTML indicates whether the body of messages with only an HTML body is generated from it.
+	textFromHTML bool
+	// addressValidation validates the envelope recipients before the transaction starts, none when nil.
+	addressValidation *addressValidation
+
+	// sentFolder sent messages are appended to, none when nil.
+	sentFolder *sentFolder
+
+	// frequencyCap limits the messages sent to every recipient, none when nil.
+	frequencyCap *FrequencyCap
+
+	// rateLimit limits the messages sent by the Mailer, none when nil.
+	rateLimit *rateLimiter
+	// domainRateLimits limit the messages sent to the recipients of a domain, by lowercase domain.
+	domainRateLimits map[string]*rateLimiter
+
+	// fallbackHosts are connected to when the primary host cannot be, see WithFallbackHosts.
+	fallbackHosts []Endpoint
+	// failoverOrder is the order the primary and the fallback hosts are tried in.
+	failoverOrder FailoverOrder
+	// nextEndpoint counts the connections to start them with the next host in FailoverRoundRobin order.
+	nextEndpoint atomic.Uint64
+	// hostHealth tracks the health of the hosts to skip the failing ones, none when nil.
+	hostHealth *hostHealth
+
+	// concurrency limits the concurrent sends to an adaptive limit, none when nil.
+	concurrency *concurrencyLimiter
+
+	// optionErrs are the invalid values given to options, which ignored them, reported by NewMailerE.
+	optionErrs []error
+
+	// circuitBreaker stops connecting to a failing SMTP server, none when nil.
+	circuitBreaker *circuitBreaker
+
+	// retryPolicy decides whether Send retries failed messages, they are not retried when nil.
+	retryPolicy RetryPolicy
+
+	// encodeOptions applied when encoding sent messages.
+	encodeOptions []message.EncodeOption
+
+	// dialer used to connect to smtp server, a direct TCP connection is used when nil.
+	dialer Dialer
+
+	// proxyProtocol is the version of the PROXY protocol header sent after connecting, none when zero.
+	proxyProtocol ProxyProtocolVersion
+
+	// logger the sends and the SMTP protocol exchange are logged to, nothing is logged when nil.
+	logger *slog.Logger
+
+	// metrics the sends are measured with, nothing is recorded when nil.
+	metrics *mailerMetrics
+
+	// tracer the phases of the sends are traced with, nothing is traced when nil.
+	tracer Tracer
+
+	// netDial connects to the SMTP server when no dialer is configured, net.DialTimeout by default.
+	netDial func(network, address string, timeout time.Duration) (net.Conn, error)
+	// newClient creates the Client over the connection to the SMTP server, see WithClientFactory.
+	newClient func(conn net.Conn, host string) (Client, error)
+	// tlsClient secures the connection to the SMTP server with implicit TLS, tls.Client by default.
+	tlsClient func(conn net.Conn, config *tls.Config) *tls.Conn
+	// plainAuth and cramMD5Auth create the PLAIN and CRAM-MD5 mechanisms selected from the credentials.
+	plainAuth   func(identity, username, password, host string) auth
+	cramMD5Auth func(username, secret string) smtp.Auth
+	// clock returns the current time, used for the Date and Message-ID headers, deadlines and durations.
+	clock func() time.Time
+	// randRead fills a byte slice with random bytes, used for the Message-ID header.
+	randRead func(b []byte) (int, error)
 }
 
 // NewMailer creates a new mailer to send emails via smtp.
@@ -237,6 +621,20 @@ func NewMailer(host string, port int, username, password string, opts ...Options
 		Host:        host,
 		tlsConfig:   defaultTLSCfg(host),
 		dialTimeout: defaultDialTimeout(),
+		encryption:  defaultEncryption(port),
+		messageID:   true,
+		date:        true,
+		netDial:     net.DialTimeout,
+		newClient: func(conn net.Conn, host string) (Client, error) {
+			return newProtocolClient(conn, host)
+		},
+		tlsClient: tls.Client,
+		plainAuth: func(identity, username, password, host string) auth {
+			return smtp.PlainAuth(identity, username, password, host)
+		},
+		cramMD5Auth: smtp.CRAMMD5Auth,
+		clock:       time.Now,
+		randRead:    rand.Read,
 	}
 	if opts != nil {
 		// Applying options.
@@ -247,6 +645,21 @@ func NewMailer(host string, port int, username, password string, opts ...Options
 	return mailer
 }
 
+// NewMailerE creates a new mailer like NewMailer, but validates the configuration and the combination
+// of the given options, so misconfigurations surface at construction instead of when sending.
+// Invalid values given to options, such as a nil tls.Config or a negative timeout, are reported as well,
+// where NewMailer ignores them. Every detected problem is reported, each wrapping ErrInvalidConfig.
+func NewMailerE(host string, port int, username, password string, opts ...Options) (*Mailer, error) {
+	mailer := NewMailer(host, port, username, password, opts...)
+	if err := mailer.validate(); err != nil {
+		return nil, err
+	}
+	if err := mailer.validateOptions(); err != nil {
+		return nil, err
+	}
+	return mailer, nil
+}
+
 // ConnectAndAuthenticate connects and authenticates the Mailer to an SMTP server and saves the connection internally.
 // To terminate the connection, the consumer must issue a Mailer.Close call after they finish sending emails.
 //
@@ -256,75 +669,232 @@ func NewMailer(host string, port int, username, password string, opts ...Options
 //	error: An error if the connection or authentication fails, or nil if successful.
 //
 // The function performs the following steps:
-// 1. Establishes a TLS connection to the SMTP server using the provided host and port.
-// 2. If SSL is enabled (port is 465), it wraps the connection with TLS.
+// 1. Establishes a TCP connection to the SMTP server using the provided host and port.
+// 2. If the encryption mode is EncryptionSSLTLS, it wraps the connection with TLS.
 // 3. Creates a new SMTP client using the established connection.
 // 4. If a local name is provided, it sends a HELO/EHLO command with the local name.
-// 5. If the port is not 465, it checks for the STARTTLS extension and starts TLS if supported.
+// 5. If the encryption mode is EncryptionSTARTTLS or EncryptionOpportunistic, it checks for the STARTTLS extension and starts TLS if supported,
+// or refuses the connection when STARTTLS is required but not supported.
 // 6. Checks for supported authentication mechanisms and sets the appropriate authentication method.
 // 7. Authenticates with the SMTP server using the selected authentication method.
 // 8. Returns a mailSender instance that implements the SendCloser interface.
 func (m *Mailer) ConnectAndAuthenticate() (SendCloser, error) {
-	netConn, err := netDialTimeout("tcp", m.addr(), m.dialTimeout)
+	sender, err := m.connectAndAuthenticate(context.Background())
 	if err != nil {
-		return nil, fmt.Errorf("failed to dial to smtp server: %w", err)
+		return nil, err
 	}
-	// check if ssl is enabled.
-	if m.Port == sslPort {
-		netConn = tlsClient(netConn, m.tlsConfig)
+	return sender, nil
+}
+
+// connectAndAuthenticate implements ConnectAndAuthenticate and returns the concrete mailSender,
+// connecting through the fallback of the circuit breaker while it is open.
+func (m *Mailer) connectAndAuthenticate(ctx context.Context) (*mailSender, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
 	}
-	c, err := newSmtpClient(netConn, m.Host)
-	if err != nil {
-		return nil, fmt.Errorf("failed to dial smtp server: %w", err)
+	cb := m.circuitBreaker
+	if !cb.allow() {
+		if cb.Fallback != nil {
+			return cb.Fallback.connectAndAuthenticate(ctx)
+		}
+		return nil, ErrCircuitOpen
 	}
-	if m.localName != "" {
-		if err := c.Hello(m.localName); err != nil {
-			return nil, fmt.Errorf("failed to dial smtp server: %w", err)
+	sender, err := m.connect(ctx)
+	if opened, failures := cb.record(ctx, err); opened {
+		m.hooks.onWarning(contextWithEndpoint(ctx, m.endpoint()),
+			fmt.Errorf("%w after %d consecutive failures, retrying in %s: %w", ErrCircuitOpen, failures, cb.Cooldown, err))
+	}
+	return sender, err
+}
+
+// connect connects and authenticates to the primary SMTP server, failing over to the fallback hosts if any.
+func (m *Mailer) connect(ctx context.Context) (*mailSender, error) {
+	endpoints := m.endpoints()
+	var errs []error
+	for i, e := range endpoints {
+		sender, err := m.connectTo(ctx, e)
+		m.recordConnection(ctx, e, err)
+		if err == nil || len(endpoints) == 1 {
+			return sender, err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", e, err))
+		if i == len(endpoints)-1 || !canFailover(ctx, err) {
+			break
 		}
+		m.hooks.onWarning(contextWithEndpoint(ctx, e), fmt.Errorf("failing over to %s: %w", endpoints[i+1], err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// connectTo connects and authenticates to the SMTP server at e.
+func (m *Mailer) connectTo(ctx context.Context, e Endpoint) (*mailSender, error) {
+	start := m.now()
+	_, span := m.startEndpointSpan(ctx, SpanDial, e)
+	c, err := m.dial(ctx, e, m.encryption == EncryptionSSLTLS)
+	endSpan(span, err)
+	if err != nil {
+		return nil, err
 	}
 
-	if !m.sslEnabled {
+	encryption, requireSTARTTLS := m.tlsPolicyOf(ctx)
+	if encryption == EncryptionSTARTTLS || encryption == EncryptionOpportunistic {
 		// check if conn starts with tls
 		// if starts apply tls config.
 		if ok, _ := c.Extension("STARTTLS"); ok {
-			if err := c.StartTLS(m.tlsConfig); err != nil {
+			_, span := m.startEndpointSpan(ctx, SpanStartTLS, e)
+			err := c.StartTLS(m.tlsCfg(e.Host))
+			endSpan(span, err)
+			if err != nil {
 				c.Close()
-				return nil, fmt.Errorf("failed to StartTLS: %w", err)
+				if encryption != EncryptionOpportunistic || requireSTARTTLS {
+					return nil, fmt.Errorf("failed to StartTLS: %w", err)
+				}
+				// the handshake failed, continue over a fresh plaintext connection.
+				m.hooks.onWarning(contextWithEndpoint(ctx, e), fmt.Errorf("STARTTLS failed, continuing without TLS: %w", err))
+				_, span := m.startEndpointSpan(ctx, SpanDial, e)
+				c, err = m.dial(ctx, e, false)
+				endSpan(span, err)
+				if err != nil {
+					return nil, err
+				}
 			}
+		} else if requireSTARTTLS {
+			c.Close()
+			return nil, fmt.Errorf("failed to StartTLS: %w", ErrSTARTTLSRequired)
+		} else {
+			m.hooks.onWarning(contextWithEndpoint(ctx, e), fmt.Errorf("%w STARTTLS, continuing without TLS", ErrExtensionNotAdvertised))
 		}
 	}
+	secured := m.diagnoseTLS(ctx, e, c, encryption)
 	// check if auth is given or determine which auth mechanism to use.
-	if m.auth == nil && m.Username != "" {
-		m.authenticationMechanism(c)
+	auth := m.auth
+	if auth == nil && (m.Username != "" || len(m.authProviders) > 0 || m.credentials != nil) {
+		auth, err = m.authenticationMechanism(ctx, c, e.Host)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to authenticate with smtp server: %w", err)
+		}
+		if auth == nil {
+			m.hooks.onWarning(contextWithEndpoint(ctx, e), fmt.Errorf("%w AUTH, continuing without authentication", ErrExtensionNotAdvertised))
+		}
 	}
 	// authenticate
-	if m.auth != nil {
-		if err = c.Auth(m.auth); err != nil {
+	if auth != nil {
+		_, span := m.startEndpointSpan(ctx, SpanAuth, e)
+		err = c.Auth(auth)
+		endSpan(span, err)
+		if err != nil {
 			c.Close()
 			return nil, fmt.Errorf("failed to authenticate with smtp server: %w", err)
 		}
 	}
-	return &mailSender{m, c}, nil
+	m.diagnoseAuth(ctx, e, auth != nil, secured)
+	m.metrics.observeConnectionSetup(ctx, e.Host, m.now().Sub(start))
+	return &mailSender{mailer: m, Client: c, endpoint: e}, nil
 }
 
-// authenticationMechanism function set the authentication mechanism for smtp server.
-func (m *Mailer) authenticationMechanism(smtpClient smtpClient) {
-	if ok, auths := smtpClient.Extension("AUTH"); ok {
-		if strings.Contains(auths, crmAuthMechanism) {
-			m.auth = smtpCRAMMD5Auth(m.Username, m.secrets)
-		} else if strings.Contains(auths, plainAuthMechanism) {
-			m.auth = smtpPlainAuth("", m.Username, m.Password, m.Host)
-		} else {
-			m.auth = newSmtpLoginAuth(m.Username, m.Password)
+// dial connects to the SMTP server at e, wraps the connection with TLS when implicitTLS is set,
+// and greets the server with the local name if one is configured.
+func (m *Mailer) dial(ctx context.Context, e Endpoint, implicitTLS bool) (Client, error) {
+	dialTimeout := m.dialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = defaultDialTimeout()
+	}
+	var (
+		netConn net.Conn
+		err     error
+	)
+	if m.dialer != nil {
+		dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+		defer cancel()
+		netConn, err = m.dialer.DialContext(dialCtx, "tcp", e.String())
+	} else if err = ctx.Err(); err == nil {
+		netConn, err = m.netDial("tcp", e.String(), dialTimeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial to smtp server: %w", err)
+	}
+	if err := m.writeProxyHeader(netConn); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	if implicitTLS {
+		netConn = m.tlsClient(netConn, m.tlsCfg(e.Host))
+	}
+	deadline, _ := ctx.Deadline()
+	greetingTimeout := m.commandTimeout
+	if m.greetingTimeout > 0 {
+		greetingTimeout = m.greetingTimeout
+	}
+	greetingBounded := greetingTimeout > 0 || !deadline.IsZero()
+	if greetingBounded {
+		// bound the greeting, the client takes over the deadlines afterward.
+		greetingDeadline := deadline
+		if d := m.now().Add(greetingTimeout); greetingTimeout > 0 && (deadline.IsZero() || d.Before(deadline)) {
+			greetingDeadline = d
+		}
+		if err := netConn.SetDeadline(greetingDeadline); err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("failed to dial smtp server: %w", err)
+		}
+	}
+	c, err := m.newClient(netConn, e.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial smtp server: %w", err)
+	}
+	if greetingBounded {
+		// the greeting timeout must not outlive the banner, the client only re-arms the deadline
+		// for the timeouts it is given, leaving the session deadline in place otherwise.
+		if err := netConn.SetDeadline(deadline); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to dial smtp server: %w", err)
+		}
+	}
+	if dc, ok := c.(deadlineClient); ok {
+		dc.setTimeouts(m.commandTimeout, m.dataTimeout, deadline)
+		dc.setClock(m.now)
+	}
+	if lc, ok := c.(loggingClient); ok && m.logger != nil {
+		lc.setLogger(m.logger.With(slog.String("host", e.Host), slog.Int("port", e.Port)))
+	}
+	if m.localName != "" {
+		if err := c.Hello(m.localName); err != nil {
+			return nil, fmt.Errorf("failed to dial smtp server: %w", err)
 		}
 	}
+	return c, nil
+}
+
+// authenticationMechanism returns the authentication mechanism for the smtp server at host, nil when it does not advertise AUTH.
+// The mechanisms given to WithAuthMechanisms are negotiated in their order, others are selected from the credentials,
+// fetched from the CredentialsProvider when one is configured.
+func (m *Mailer) authenticationMechanism(ctx context.Context, c Client, host string) (auth, error) {
+	ok, auths := c.Extension("AUTH")
+	if !ok {
+		return nil, nil
+	}
+	if len(m.authProviders) > 0 {
+		return m.negotiateAuth(auths, host)
+	}
+	username, password, secrets, err := m.credentialsOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(auths, crmAuthMechanism) {
+		return m.cramMD5Auth(username, secrets), nil
+	} else if strings.Contains(auths, plainAuthMechanism) {
+		return m.plainAuth("", username, password, host), nil
+	}
+	return newSmtpLoginAuth(username, password), nil
 }
 
 // Send dials the SMTP server with the proper authentication and sends an email.
+// It implements the Transport interface.
 //
 // Parameters:
 //
-//   - message (message.Message): The message to be sent.
+//   - ctx (context.Context): The context bounding the connection to the SMTP server.
+//   - msg (message.Message): The message to be sent.
 //
 // Returns:
 //
@@ -334,90 +904,551 @@ func (m *Mailer) authenticationMechanism(smtpClient smtpClient) {
 // 1. Connects and authenticates to the SMTP server using the `ConnectAndAuthenticate` method of the `Mailer` struct.
 // 2. Sends the email using the `Send` method of the `SendCloser` interface.
 // 3. Closes the connection to the SMTP server.
+// 4. When the send failed and a retry policy is configured (see WithRetryPolicy), waits and starts over.
 //
 // Example usage:
 //
 //	mailer := NewMailer("smtp.example.com", 465, "user@example.com", "password")
-//	message := message.Message{
+//	msg := message.Message{
 //	    From:       "sender@example.com",
 //	    Recipients: []string{"recipient@example.com"},
 //	    Body:       "This is a test email.",
 //	}
-//	err := mailer.Send(message)
+//	err := mailer.Send(context.Background(), msg)
 //	if err != nil {
 //	    log.Fatalf("Failed to send email: %v", err)
 //	}
-func (m *Mailer) Send(message message.Message) error {
-	sender, err := m.ConnectAndAuthenticate()
+func (m *Mailer) Send(ctx context.Context, msg message.Message) error {
+	_, err := m.SendResult(ctx, msg)
+	return err
+}
+
+// sendOnce connects to the SMTP server and sends the message over a new connection.
+func (m *Mailer) sendOnce(ctx context.Context, msg message.Message) (_ *Result, err error) {
+	if timeout := m.sendTimeoutOf(ctx); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	ctx, span := m.startSpan(ctx, SpanSend)
+	defer func() { endSpan(span, err) }()
+	var concurrency *concurrencyLimiter
+	if m != nil {
+		concurrency = m.concurrency
+		// oversized messages are refused before connecting.
+		if err := msg.CheckSize(m.encodeOptions...); err != nil {
+			err = fmt.Errorf("failed to send message: %w", err)
+			m.metrics.incFailures(ctx, m.Host, err)
+			m.hooks.onError(ctx, msg, err)
+			return nil, err
+		}
+	}
+	release, err := concurrency.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { release(err) }()
+	start := m.now()
+	sender, connected, err := m.acquireSender(ctx)
+	if err != nil {
+		err = fmt.Errorf("failed to connect and authenticate: %w", err)
+		if m != nil {
+			ctx := contextWithEndpoint(ctx, m.endpoint())
+			if m.doneErr(ctx) != nil {
+				m.hooks.onAbort(ctx, msg, StageConnect, err)
+			}
+			m.metrics.incFailures(ctx, m.Host, err)
+			m.hooks.onError(ctx, msg, err)
+		}
+		return nil, err
+	}
+	defer func() { m.releaseSender(sender, connected, err) }()
+	connectDuration := m.now().Sub(start)
+
+	// hooks are invoked by the sender.
+	if err := sender.SendContext(ctx, msg); err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
+	}
+	sent := sender.result
+	sent.ConnectDuration = connectDuration
+	return &sent, nil
+}
+
+// SendBatch sends a personalized copy of the template message for every personalization over a single connection.
+//
+// Parameters:
+//
+//   - ctx (context.Context): The context bounding the connection to the SMTP server.
+//   - tmpl (message.Message): The template message every copy is derived from.
+//   - personalizations ([]message.Personalization): The recipients and per-recipient header overrides,
+//     merged over the template headers when the copy is encoded (see message.Message.Personalize).
+//
+// Returns:
+//
+//   - error: An error if the connection could not be established, or the joined errors of every copy that could not be sent.
+//
+// A failing copy does not abort the batch, the session is reset and the remaining copies are still sent.
+// With WithVERP, every copy is sent once per recipient with the VERP address of the recipient as envelope sender.
+func (m *Mailer) SendBatch(ctx context.Context, tmpl message.Message, personalizations []message.Personalization) error {
+	if m != nil {
+		if err := tmpl.CheckSize(m.encodeOptions...); err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+	}
+	sender, err := m.connectAndAuthenticate(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to connect and authenticate: %w", err)
 	}
 	defer sender.Close()
 
-	if err := sender.Send(message); err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+	var errs []error
+	for _, p := range personalizations {
+		for _, t := range m.batchTransactions(tmpl.Personalize(p)) {
+			if err := sender.sendTo(ctx, t.msg, t.recipients); err != nil {
+				errs = append(errs, fmt.Errorf("failed to send message to %s: %w", strings.Join(t.recipients, ", "), err))
+				// abort the failed transaction so the next copy starts with a clean session.
+				_ = sender.Reset()
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validate checks that Mailer is configured enough to connect to an SMTP server,
+// so misuse such as a zero-value Mailer fails fast with a descriptive error instead of a cryptic dial error.
+func (m *Mailer) validate() error {
+	if m == nil {
+		return fmt.Errorf("%w: mailer is nil, use NewMailer to create one", ErrInvalidConfig)
+	}
+	if m.Host == "" {
+		return fmt.Errorf("%w: host cannot be empty", ErrInvalidConfig)
+	}
+	if m.Port <= 0 || m.Port > 65535 {
+		return fmt.Errorf("%w: port %d is out of range 1-65535", ErrInvalidConfig, m.Port)
+	}
+	if m.encryption < EncryptionSTARTTLS || m.encryption > EncryptionOpportunistic {
+		return fmt.Errorf("%w: unknown encryption mode %s", ErrInvalidConfig, m.encryption)
 	}
 	return nil
 }
 
-// addr returns full adders.
-func (m *Mailer) addr() string {
-	return fmt.Sprintf("%s:%d", m.Host, m.Port)
+// invalidOption records an invalid value given to an option, reported by validateOptions.
+func (m *Mailer) invalidOption(format string, args ...any) {
+	m.optionErrs = append(m.optionErrs, fmt.Errorf("%w: "+format, append([]any{ErrInvalidConfig}, args...)...))
+}
+
+// validateOptions detects invalid option values and option combinations that contradict each other or cannot take effect.
+func (m *Mailer) validateOptions() error {
+	errs := slices.Clone(m.optionErrs)
+	timeouts := []struct {
+		name string
+		t    time.Duration
+	}{{"command", m.commandTimeout}, {"greeting", m.greetingTimeout}, {"data", m.dataTimeout}, {"send", m.sendTimeout}}
+	for _, timeout := range timeouts {
+		if timeout.t < 0 {
+			errs = append(errs, fmt.Errorf("%w: %s timeout %s cannot be negative", ErrInvalidConfig, timeout.name, timeout.t))
+		}
+	}
+	if err := validateTLSConfig("", m.tlsConfig); err != nil {
+		errs = append(errs, err)
+	}
+	for _, host := range slices.Sorted(maps.Keys(m.hostTLSConfigs)) {
+		if err := validateTLSConfig(host, m.hostTLSConfigs[host]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if m.secrets != "" {
+		if m.auth != nil {
+			errs = append(errs, fmt.Errorf("%w: secrets are only used for CRAM-MD5 and are ignored when auth is given", ErrInvalidConfig))
+		}
+		if m.Username == "" {
+			errs = append(errs, fmt.Errorf("%w: secrets are given without a username to authenticate with CRAM-MD5", ErrInvalidConfig))
+		}
+	}
+	if m.auth != nil && len(m.authProviders) > 0 {
+		errs = append(errs, fmt.Errorf("%w: auth mechanisms are not negotiated when auth is given", ErrInvalidConfig))
+	}
+	if m.credentials != nil {
+		switch {
+		case m.auth != nil || len(m.authProviders) > 0:
+			errs = append(errs, fmt.Errorf("%w: credentials provider is not used when auth or auth mechanisms are given", ErrInvalidConfig))
+		case m.Username != "" || m.Password != "" || m.secrets != "":
+			errs = append(errs, fmt.Errorf("%w: static credentials are given along with a credentials provider", ErrInvalidConfig))
+		}
+	}
+	switch m.encryption {
+	case EncryptionSSLTLS:
+		if m.Port == submissionPort || m.Port == smtpPort {
+			errs = append(errs, fmt.Errorf("%w: port %d expects STARTTLS, not implicit SSL/TLS", ErrInvalidConfig, m.Port))
+		}
+	case EncryptionSTARTTLS, EncryptionOpportunistic:
+		if m.Port == sslPort {
+			errs = append(errs, fmt.Errorf("%w: port %d expects implicit SSL/TLS, not %s", ErrInvalidConfig, m.Port, m.encryption))
+		}
+	}
+	if m.requireSTARTTLS && (m.encryption == EncryptionSSLTLS || m.encryption == EncryptionNone) {
+		errs = append(errs, fmt.Errorf("%w: STARTTLS cannot be required with %s encryption", ErrInvalidConfig, m.encryption))
+	}
+	if fc := m.frequencyCap; fc != nil && (fc.Max < 1 || fc.Window <= 0) {
+		errs = append(errs, fmt.Errorf("%w: frequency cap must allow at least one message within a positive window", ErrInvalidConfig))
+	}
+	if m.rateLimit != nil && !m.rateLimit.valid() {
+		errs = append(errs, fmt.Errorf("%w: rate limit must allow at least one message within a positive period", ErrInvalidConfig))
+	}
+	for domain, l := range m.domainRateLimits {
+		if !l.valid() {
+			errs = append(errs, fmt.Errorf("%w: rate limit of domain %s must allow at least one message within a positive period", ErrInvalidConfig, domain))
+		}
+	}
+	if cb := m.circuitBreaker; cb != nil {
+		if cb.Threshold < 1 || cb.Cooldown <= 0 {
+			errs = append(errs, fmt.Errorf("%w: circuit breaker must open after at least one failure for a positive cooldown", ErrInvalidConfig))
+		}
+		if cb.Fallback == m {
+			errs = append(errs, fmt.Errorf("%w: circuit breaker cannot fall back to its own mailer", ErrInvalidConfig))
+		}
+	}
+	for _, e := range m.fallbackHosts {
+		if e.Host == "" || e.Port <= 0 || e.Port > 65535 {
+			errs = append(errs, fmt.Errorf("%w: fallback host %s must have a host and a port in range 1-65535", ErrInvalidConfig, e))
+		}
+	}
+	if m.failoverOrder != FailoverPriority && m.failoverOrder != FailoverRoundRobin {
+		errs = append(errs, fmt.Errorf("%w: unknown failover order %s", ErrInvalidConfig, m.failoverOrder))
+	}
+	return errors.Join(errs...)
 }
 
-// mailSender is a data struct that promotes the functionality of smtp.Client and supports features of Mailer.
+// validateTLSConfig detects a TLS configuration no connection can be made with, given for host or for every host when empty.
+func validateTLSConfig(host string, cfg *tls.Config) error {
+	if cfg == nil || cfg.MaxVersion == 0 || cfg.MinVersion <= cfg.MaxVersion {
+		return nil
+	}
+	if host != "" {
+		host = " of host " + host
+	}
+	return fmt.Errorf("%w: tls config%s has a min version %s above its max version %s", ErrInvalidConfig, host,
+		tls.VersionName(cfg.MinVersion), tls.VersionName(cfg.MaxVersion))
+}
+
+// tlsCfg returns the tls.Config of the connections to host, verified with the VerifyConnectionFunc when one is configured.
+func (m *Mailer) tlsCfg(host string) *tls.Config {
+	cfg := m.configuredTLSCfg(host)
+	if m.verifyConnection == nil {
+		return cfg
+	}
+	return m.withVerifyConnection(host, cfg)
+}
+
+// configuredTLSCfg returns the tls.Config configured for host, falling back to the one configured for every host,
+// or the default one when Mailer was not created by NewMailer.
+func (m *Mailer) configuredTLSCfg(host string) *tls.Config {
+	if cfg, ok := m.hostTLSConfigs[strings.ToLower(host)]; ok {
+		return cfg
+	}
+	if m.tlsConfig == nil {
+		return defaultTLSCfg(host)
+	}
+	if !strings.EqualFold(host, m.Host) && strings.EqualFold(m.tlsConfig.ServerName, m.Host) {
+		// the configuration names the primary host, fallback hosts are verified against their own name.
+		cfg := m.tlsConfig.Clone()
+		cfg.ServerName = host
+		return cfg
+	}
+	return m.tlsConfig
+}
+
+// dateLocationOf returns the time zone of the Date header of msg: the one of the message, the one configured, or UTC.
+func (m *Mailer) dateLocationOf(msg message.Message) *time.Location {
+	if msg.DateLocation != nil {
+		return msg.DateLocation
+	}
+	if m.dateLocation != nil {
+		return m.dateLocation
+	}
+	return time.UTC
+}
+
+// mailSender is a data struct that promotes the functionality of Client and supports features of Mailer.
 type mailSender struct {
 	// mailer is a reference to the Mailer instance that created this mailSender.
 	mailer *Mailer
-	// smtpClient is the SMTP client used to send emails.
-	smtpClient
+	// Client is the SMTP client used to send emails.
+	Client
+	// endpoint is the SMTP server the client is connected to.
+	endpoint Endpoint
+	// stage is the stage reached by the message being sent.
+	stage SendStage
+	// aborted indicates whether the connection was closed because a send was aborted.
+	aborted atomic.Bool
+	// mu serializes the SMTP transactions and the QUIT of concurrent callers over the connection.
+	mu sync.Mutex
+	// result describes the last message sent.
+	result Result
 }
 
 // Send sends the provided message using the SMTP client.
 //
 // Parameters:
-//   - message (message.Message): The message to be sent.
+//   - msg (message.Message): The message to be sent.
 //
 // Returns:
 //   - error: An error if the message could not be sent, or nil if the message was sent successfully.
 //
 // The function performs the following steps:
-// 1. Sends the MAIL command with the sender's address.
-// 2. Sends the RCPT command for each recipient's address.
-// 3. Initiates the DATA command to start the message data transfer.
-// 4. Encodes the message and writes it to the SMTP client's data writer.
-// 5. Closes the data writer.
+// 1. Invokes the BeforeEncode hooks, which may mutate or veto the message.
+// 2. Counts the message against the frequency cap of its recipients, if one is configured.
+// 3. Waits for the rate limits of the Mailer and of the recipient domains, if any are configured.
+// 4. Encodes the message and invokes the BeforeSend hooks, which may veto the message.
+// 5. Sends the MAIL command with the sender's address.
+// 6. Sends the RCPT command for each recipient's address.
+// 7. Initiates the DATA command to start the message data transfer.
+// 8. Writes the encoded message to the SMTP client's data writer.
+// 9. Closes the data writer and invokes the AfterSend hooks.
 //
-// If any step fails, an appropriate error is returned.
-func (m *mailSender) Send(message message.Message) error {
-	if err := m.Mail(message.From); err != nil {
-		return fmt.Errorf("mailer failed to send MAIL command for address %s: %w", message.From, err)
-	}
+// If any step fails, an appropriate error is returned and the OnError hooks are invoked. Rejections by the SMTP server are reported as *SMTPError,
+// classifying rejected recipients and messages into soft and hard bounces (see SMTPError.Bounce).
+func (m *mailSender) Send(msg message.Message) error {
+	return m.SendContext(context.Background(), msg)
+}
 
-	for _, t := range message.Recipients {
-		if err := m.Rcpt(t); err != nil {
-			return fmt.Errorf("mailer failed to send rcpt command for address %s: %w", t, err)
+// SendContext sends the message like Send, passing ctx to the hooks along with the Endpoint (see EndpointFromContext).
+func (m *mailSender) SendContext(ctx context.Context, msg message.Message) error {
+	return m.sendTo(ctx, msg, nil)
+}
+
+// sendTo sends the message like SendContext to the envelope recipients in place of its Recipients when not nil,
+// e.g. the recipients of a copy of SendBatch or of a single domain for DirectTransport.
+func (m *mailSender) sendTo(ctx context.Context, msg message.Message, recipients []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ctx = contextWithEndpoint(ctx, m.endpoint)
+	hooks := m.mailer.hooks
+	metrics := m.mailer.metrics
+	if m.aborted.Load() {
+		metrics.incFailures(ctx, m.endpoint.Host, ErrConnectionAborted)
+		hooks.onError(ctx, msg, ErrConnectionAborted)
+		return ErrConnectionAborted
+	}
+	if err := hooks.beforeEncode(ctx, &msg); err != nil {
+		err = fmt.Errorf("message vetoed before encoding: %w", err)
+		metrics.incFailures(ctx, m.endpoint.Host, err)
+		hooks.onError(ctx, msg, err)
+		return err
+	}
+	if err := m.mailer.checkFrequencyCap(ctx, msg); err != nil {
+		if errors.Is(err, ErrFrequencyCapped) {
+			metrics.incFrequencyCapped(ctx, m.endpoint.Host)
 		}
+		metrics.incFailures(ctx, m.endpoint.Host, err)
+		hooks.onError(ctx, msg, err)
+		return err
 	}
-	w, err := m.Data()
+	if err := m.mailer.waitRateLimit(ctx, msg); err != nil {
+		metrics.incFailures(ctx, m.endpoint.Host, err)
+		hooks.onError(ctx, msg, err)
+		return err
+	}
+	if err := m.send(ctx, msg, recipients); err != nil {
+		if m.mailer.doneErr(ctx) != nil {
+			err = m.abort(ctx, msg, err)
+		}
+		metrics.incFailures(ctx, m.endpoint.Host, err)
+		hooks.onError(contextWithResult(ctx, m.result), msg, err)
+		return err
+	}
+	metrics.incSent(ctx, m.endpoint.Host)
+	hooks.afterSend(contextWithResult(ctx, m.result), msg)
+	return nil
+}
+
+// send encodes the message and runs the SMTP transaction with the envelope recipients, see envelopeRecipients.
+func (m *mailSender) send(ctx context.Context, msg message.Message, recipients []string) error {
+	m.stage = StageEncode
+	m.result = Result{Endpoint: m.endpoint}
+	msg, err := m.mailer.markLoop(msg)
 	if err != nil {
-		return fmt.Errorf("mailer failed to get data writer: %w", err)
+		return fmt.Errorf("failed to send message: %w", err)
 	}
-	encodedMsg, err := message.Encode()
+	msg = m.mailer.prepareHTML(msg)
+	msg, recipients, err = m.internationalize(msg, recipients)
 	if err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
 	}
-	_, err = w.Write(encodedMsg)
-	defer func() {
-		_ = w.Close()
-	}()
+	// retries of Mailer.SendResult reuse the Message-ID and Date of the send.
+	state := sendStateFrom(ctx)
+	if m.mailer.messageID && !msg.HasHeader("Message-ID") {
+		id := state.messageID
+		if id == "" {
+			if id, err = m.mailer.newMessageID(); err != nil {
+				return fmt.Errorf("failed to send message: %w", err)
+			}
+		}
+		msg = msg.WithHeader("Message-ID", id)
+	}
+	if m.mailer.date && !msg.HasHeader("Date") {
+		date := state.date
+		if date.IsZero() {
+			date = m.mailer.now()
+		}
+		msg = msg.WithHeader("Date", date.In(m.mailer.dateLocationOf(msg)).Format(time.RFC1123Z))
+	}
+	if msg, err = m.mailer.markResent(ctx, msg, state); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	m.result.MessageID = headerValue(msg, "Message-ID")
+	if m.mailer.contentHash {
+		hash, err := msg.ContentHash()
+		if err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+		msg = msg.WithHeader(message.ContentHashHeader, hash)
+	}
+	encodeOptions := m.mailer.encodeOptions
+	if hasNonASCIIAddress(msg) {
+		// internationalized addresses are left as is by internationalize when the server advertises SMTPUTF8.
+		encodeOptions = append(encodeOptions[:len(encodeOptions):len(encodeOptions)], message.WithSMTPUTF8())
+	}
+	if msg.Requires8BitMIME() {
+		// servers advertising 8BITMIME receive BODY=8BITMIME along with MAIL FROM, others quoted-printable content.
+		if ok, _ := m.Extension("8BITMIME"); !ok {
+			encodeOptions = append(encodeOptions[:len(encodeOptions):len(encodeOptions)], message.With7BitTransport())
+		}
+	}
+	// the message is encoded while it is written to the server, unless the hooks or the sent folder need its bytes,
+	// which are then encoded once, the maximum size applying as they are.
+	var src io.WriterTo
+	var encodedMsg []byte
+	if m.mailer.hooks.hasBeforeSend() || m.mailer.sentFolder != nil {
+		if encodedMsg, err = msg.Encode(encodeOptions...); err != nil {
+			return fmt.Errorf("failed to send message: %w", err)
+		}
+		src = bytes.NewReader(encodedMsg)
+	} else if src, err = msg.Prepare(encodeOptions...); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if err := m.mailer.hooks.beforeSend(ctx, msg, encodedMsg); err != nil {
+		return fmt.Errorf("message vetoed before sending: %w", err)
+	}
+	recipients, err = m.rewriteRecipients(ctx, msg, recipients)
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if err := m.mailer.validateRecipients(ctx, recipients); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// from now on the transaction is interrupted when ctx is done, leaving the connection closed rather than dirty.
+	stop := m.interruptOnDone(ctx)
+	defer stop()
+	start := m.mailer.now()
+	m.stage = StageEnvelope
+	_, span := m.mailer.startEndpointSpan(ctx, SpanEnvelope, m.endpoint)
+	span.SetAttribute("smtp.recipients", len(recipients))
+	err = m.mailRcpt(msg, envelopeFrom(ctx, msg), recipients)
+	endSpan(span, err)
+	if err != nil {
+		return err
+	}
+	m.stage = StageData
+	_, span = m.mailer.startEndpointSpan(ctx, SpanData, m.endpoint)
+	err = m.data(ctx, src)
+	span.SetAttribute("smtp.data.bytes", m.result.Bytes)
+	endSpan(span, err)
+	if err != nil {
+		return err
+	}
+	m.result.Recipients = recipients
+	m.result.TransactionDuration = m.mailer.now().Sub(start)
+	m.mailer.appendSent(ctx, msg, encodedMsg)
+
+	return nil
+}
+
+// data transfers the encoded message written by src with the DATA command.
+func (m *mailSender) data(ctx context.Context, src io.WriterTo) error {
+	w, err := m.Data()
+	if err != nil {
+		return fmt.Errorf("mailer failed to get data writer: %w", newSMTPError("DATA", "", err))
+	}
+	// the encoder writes in small pieces, they are buffered so the server receives full segments.
+	bw := bufio.NewWriterSize(w, dataBufferSize)
+	n, err := src.WriteTo(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	m.result.Bytes = int(n)
+	m.mailer.metrics.observeDataBytes(ctx, m.endpoint.Host, int(n))
 	if err != nil {
-		return fmt.Errorf("failed writing data: %w", err)
+		_ = w.Close()
+		return fmt.Errorf("failed writing data: %w", newSMTPError("DATA", "", err))
+	}
+	m.stage = StageAwaitingReply
+	// closing the writer ends the DATA command, this is where the server accepts or rejects the message.
+	if err := w.Close(); err != nil {
+		if _, ok := replyCode(err); !ok {
+			// the reply was not received, the server may have accepted the message.
+			err = &maybeSentError{err: err}
+		}
+		return fmt.Errorf("mailer failed to complete data transfer: %w", newSMTPError("DATA", "", err))
+	}
+	if c, ok := m.Client.(dataReplyClient); ok {
+		m.result.Reply = c.dataReply()
+		m.result.QueueID = parseQueueID(m.result.Reply)
+	}
+	return nil
+}
+
+// mailRcpt sends the MAIL command with the envelope sender and the RCPT command for each envelope recipient of the message.
+// When the server advertises PIPELINING, the commands are sent at once instead of waiting for each reply,
+// DATA is still sent afterward so no message is transferred when a recipient is rejected.
+func (m *mailSender) mailRcpt(msg message.Message, from string, recipients []string) error {
+	mailParams, rcptParams := m.dsnParams(msg)
+	if requiresSMTPUTF8(msg, recipients) {
+		mailParams = append([]string{"SMTPUTF8"}, mailParams...)
+	}
+	if p, ok := m.Client.(pipeliningClient); ok {
+		if ok, _ := m.Extension("PIPELINING"); ok {
+			to := make([]string, len(recipients))
+			for i, t := range recipients {
+				to[i] = message.EnvelopeAddress(t)
+			}
+			mailErr, rcptErrs := p.MailRcpt(message.EnvelopeAddress(from), mailParams, to, rcptParams)
+			if mailErr != nil {
+				return fmt.Errorf("mailer failed to send MAIL command for address %s: %w", from, newSMTPError("MAIL", "", mailErr))
+			}
+			for i, err := range rcptErrs {
+				if err != nil {
+					t := recipients[i]
+					return fmt.Errorf("mailer failed to send rcpt command for address %s: %w", t, newSMTPError("RCPT", t, err))
+				}
+			}
+			return nil
+		}
 	}
 
+	if err := m.Mail(message.EnvelopeAddress(from), mailParams...); err != nil {
+		return fmt.Errorf("mailer failed to send MAIL command for address %s: %w", from, newSMTPError("MAIL", "", err))
+	}
+	for _, t := range recipients {
+		if err := m.Rcpt(message.EnvelopeAddress(t), rcptParams...); err != nil {
+			return fmt.Errorf("mailer failed to send rcpt command for address %s: %w", t, newSMTPError("RCPT", t, err))
+		}
+	}
 	return nil
 }
 
+// envelopeRecipients returns the recipients the message is sent to: recipients when not nil, or its Recipients.
+func envelopeRecipients(msg message.Message, recipients []string) []string {
+	if recipients != nil {
+		return recipients
+	}
+	return msg.Recipients
+}
+
 // Close closes the connection between the client and the SMTP server.
 //
 // Returns:
@@ -428,30 +1459,73 @@ func (m *mailSender) Send(message message.Message) error {
 // 2. If the QUIT command fails, it returns an error indicating the failure.
 // 3. If the QUIT command succeeds, it returns nil.
 func (m *mailSender) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.aborted.Load() {
+		// the connection is already closed.
+		return nil
+	}
 	if err := m.Quit(); err != nil {
 		return fmt.Errorf("failed to close connection to smtp server: %w", err)
 	}
 	return nil
 }
 
-// Extracted functions to be stubbed during testing to avoid dialing a real server.
-// These functions are used to create mock implementations for unit tests,
-// ensuring that the tests do not make actual network connections.
-var (
-	// newSmtpClient returns smtpClient interface.
-	newSmtpClient = func(conn net.Conn, host string) (smtpClient, error) {
-		return smtp.NewClient(conn, host)
+// dsnParams returns the MAIL and RCPT parameters requesting delivery status notifications for the message,
+// none are returned when the message does not request them or the server does not advertise the DSN extension.
+func (m *mailSender) dsnParams(msg message.Message) (mailParams, rcptParams []string) {
+	if msg.DSN == nil {
+		return nil, nil
+	}
+	if ok, _ := m.Extension("DSN"); !ok {
+		return nil, nil
+	}
+	if msg.DSN.Return != "" {
+		mailParams = append(mailParams, "RET="+string(msg.DSN.Return))
+	}
+	if msg.DSN.EnvelopeID != "" {
+		mailParams = append(mailParams, "ENVID="+xtext(msg.DSN.EnvelopeID))
 	}
+	if notify := msg.DSN.NotifyParam(); notify != "" {
+		rcptParams = append(rcptParams, "NOTIFY="+notify)
+	}
+	return mailParams, rcptParams
+}
 
-	// smtpPlainAuth returns smtp.PlainAuth.
-	smtpPlainAuth = func(identity, username, password, host string) auth {
-		return smtp.PlainAuth(identity, username, password, host)
+// xtext encodes s as xtext (RFC 3461 section 4), hex-escaping '+', '=' and characters outside printable ASCII.
+func xtext(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < '!' || c > '~' || c == '+' || c == '=' {
+			fmt.Fprintf(&b, "+%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
 	}
-	// tlsClient returns tlsClient.
-	tlsClient = tls.Client
+	return b.String()
+}
 
-	// smtpCRAMMD5Auth returns smtp.smtpCRAMMD5Auth.
-	smtpCRAMMD5Auth = smtp.CRAMMD5Auth
-	// netDialTimeout returns net.DialTimeout func.
-	netDialTimeout = net.DialTimeout
-)
+// messageIDDomain returns the right-hand side of generated Message-ID headers.
+func (m *Mailer) messageIDDomain() string {
+	if m.localName != "" {
+		return m.localName
+	}
+	return m.Host
+}
+
+// newMessageID generates a unique RFC 5322 msg-id for the domain of the Mailer, see messageIDDomain.
+func (m *Mailer) newMessageID() (string, error) {
+	token := make([]byte, 16)
+	if _, err := m.randRead(token); err != nil {
+		return "", fmt.Errorf("failed to generate Message-ID: %w", err)
+	}
+	return fmt.Sprintf("<%d.%s@%s>", m.now().UnixNano(), hex.EncodeToString(token), m.messageIDDomain()), nil
+}
+
+// now returns the current time of the Mailer clock, the system clock for mailers not created by NewMailer.
+func (m *Mailer) now() time.Time {
+	if m == nil || m.clock == nil {
+		return time.Now()
+	}
+	return m.clock()
+}
@@ -1,10 +1,18 @@
 package gomailer
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
+	"net/mail"
 	"net/smtp"
+	"net/textproto"
+	"os"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -12,6 +20,7 @@ import (
 	mailerMock "github.com/nawafswe/gomailer/internal/mock"
 	"github.com/nawafswe/gomailer/message"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // test variables.
@@ -75,29 +84,259 @@ func TestMailer_NewMailer(t *testing.T) {
 	})
 }
 
+func TestMailer_NewMailerE(t *testing.T) {
+	tests := map[string]struct {
+		port        int
+		host        string
+		username    string
+		options     []Options
+		expectedErr error
+	}{
+		"should successfully create mailer with consistent options": {
+			port:     testPort,
+			host:     testHost,
+			username: testUser,
+			options:  []Options{WithRequireSTARTTLS(true), WithSecrets(testPassword)},
+		},
+		"should successfully create mailer with implicit tls on a non standard port": {
+			port:    2465,
+			host:    testHost,
+			options: []Options{WithEncryption(EncryptionSSLTLS)},
+		},
+		"should fail to create mailer when host is empty": {
+			port:        testPort,
+			expectedErr: fmt.Errorf("%w: host cannot be empty", ErrInvalidConfig),
+		},
+		"should fail to create mailer when secrets and auth are both given": {
+			port:     testPort,
+			host:     testHost,
+			username: testUser,
+			options:  []Options{WithSecrets(testPassword), WithAuth(smtp.PlainAuth("", testUser, testPassword, testHost))},
+			expectedErr: errors.Join(
+				fmt.Errorf("%w: secrets are only used for CRAM-MD5 and are ignored when auth is given", ErrInvalidConfig),
+			),
+		},
+		"should fail to create mailer when secrets are given without username": {
+			port:    testPort,
+			host:    testHost,
+			options: []Options{WithSecrets(testPassword)},
+			expectedErr: errors.Join(
+				fmt.Errorf("%w: secrets are given without a username to authenticate with CRAM-MD5", ErrInvalidConfig),
+			),
+		},
+		"should fail to create mailer when ssl is enabled on the submission port": {
+			port:    testPort,
+			host:    testHost,
+			options: []Options{WithSSLEnabled(true)},
+			expectedErr: errors.Join(
+				fmt.Errorf("%w: port 587 expects STARTTLS, not implicit SSL/TLS", ErrInvalidConfig),
+			),
+		},
+		"should fail to create mailer when STARTTLS is used on the ssl port": {
+			port:    testSSLPort,
+			host:    testHost,
+			options: []Options{WithEncryption(EncryptionSTARTTLS)},
+			expectedErr: errors.Join(
+				fmt.Errorf("%w: port 465 expects implicit SSL/TLS, not STARTTLS", ErrInvalidConfig),
+			),
+		},
+		"should report every conflict": {
+			port:    testSSLPort,
+			host:    testHost,
+			options: []Options{WithRequireSTARTTLS(true), WithSecrets(testPassword)},
+			expectedErr: errors.Join(
+				fmt.Errorf("%w: secrets are given without a username to authenticate with CRAM-MD5", ErrInvalidConfig),
+				fmt.Errorf("%w: STARTTLS cannot be required with SSL/TLS encryption", ErrInvalidConfig),
+			),
+		},
+		"should fail to create mailer when options are given invalid values": {
+			port:     testPort,
+			host:     testHost,
+			username: testUser,
+			options: []Options{
+				WithTLSConfig(nil), WithDialTimeout(0), WithAuth(nil), WithClientFactory(nil), WithMaxMessageSize(-1), WithMaxAttachmentSize(0),
+				WithDateLocation(nil), WithCommandTimeout(-time.Second), WithSendTimeout(-time.Minute),
+				WithAddressValidation(message.ValidationLevel(3)),
+			},
+			expectedErr: errors.Join(
+				fmt.Errorf("%w: tls config cannot be nil", ErrInvalidConfig),
+				fmt.Errorf("%w: dial timeout 0s must be positive", ErrInvalidConfig),
+				fmt.Errorf("%w: auth cannot be nil", ErrInvalidConfig),
+				fmt.Errorf("%w: client factory cannot be nil", ErrInvalidConfig),
+				fmt.Errorf("%w: max message size -1 must be positive", ErrInvalidConfig),
+				fmt.Errorf("%w: max attachment size 0 must be positive", ErrInvalidConfig),
+				fmt.Errorf("%w: date location cannot be nil", ErrInvalidConfig),
+				fmt.Errorf("%w: address validation level 3 is invalid", ErrInvalidConfig),
+				fmt.Errorf("%w: command timeout -1s cannot be negative", ErrInvalidConfig),
+				fmt.Errorf("%w: send timeout -1m0s cannot be negative", ErrInvalidConfig),
+			),
+		},
+		"should fail to create mailer when a tls config cannot connect": {
+			port:     testPort,
+			host:     testHost,
+			username: testUser,
+			options: []Options{
+				WithHostTLSConfig("relay.smtp.com", &tls.Config{MinVersion: tls.VersionTLS13, MaxVersion: tls.VersionTLS12}),
+			},
+			expectedErr: errors.Join(
+				fmt.Errorf("%w: tls config of host relay.smtp.com has a min version TLS 1.3 above its max version TLS 1.2", ErrInvalidConfig),
+			),
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			mailer, err := NewMailerE(tc.host, tc.port, tc.username, testPassword, tc.options...)
+			assert.Equal(t, tc.expectedErr, err)
+			if tc.expectedErr != nil {
+				assert.Nil(t, mailer)
+				assert.ErrorIs(t, err, ErrInvalidConfig)
+			} else {
+				assert.NotNil(t, mailer)
+			}
+		})
+	}
+}
+
+func TestMailer_Validate(t *testing.T) {
+	tests := map[string]struct {
+		mailer      *Mailer
+		expectedErr error
+	}{
+		"should pass validation for mailer created by NewMailer": {
+			mailer: NewMailer(testHost, testPort, testUser, testPassword),
+		},
+		"should pass validation for zero-value mailer with host and port": {
+			mailer: &Mailer{Host: testHost, Port: testPort},
+		},
+		"should fail validation for nil mailer": {
+			expectedErr: fmt.Errorf("%w: mailer is nil, use NewMailer to create one", ErrInvalidConfig),
+		},
+		"should fail validation for zero-value mailer": {
+			mailer:      &Mailer{},
+			expectedErr: fmt.Errorf("%w: host cannot be empty", ErrInvalidConfig),
+		},
+		"should fail validation when port is zero": {
+			mailer:      NewMailer(testHost, 0, testUser, testPassword),
+			expectedErr: fmt.Errorf("%w: port 0 is out of range 1-65535", ErrInvalidConfig),
+		},
+		"should fail validation when port is out of range": {
+			mailer:      NewMailer(testHost, 70000, testUser, testPassword),
+			expectedErr: fmt.Errorf("%w: port 70000 is out of range 1-65535", ErrInvalidConfig),
+		},
+		"should fail validation when encryption mode is unknown": {
+			mailer:      NewMailer(testHost, testPort, testUser, testPassword, WithEncryption(Encryption(42))),
+			expectedErr: fmt.Errorf("%w: unknown encryption mode Encryption(42)", ErrInvalidConfig),
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expectedErr, tc.mailer.validate())
+		})
+	}
+
+	t.Run("should fail fast on first use when mailer is misconfigured", func(t *testing.T) {
+		var mailer *Mailer
+		err := mailer.Send(context.Background(), message.Message{})
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+
+		smtpSender, err := NewMailer("", testPort, testUser, testPassword).ConnectAndAuthenticate()
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+		assert.Nil(t, smtpSender)
+	})
+}
+
+func TestMailer_TLSConfig(t *testing.T) {
+	pinnedCfg := &tls.Config{ServerName: testHost, MinVersion: tls.VersionTLS13}
+	sharedCfg := &tls.Config{ServerName: "shared"}
+	tests := map[string]struct {
+		mailer      *Mailer
+		host        string
+		expectedCfg *tls.Config
+	}{
+		"should use the default config when none is configured": {
+			mailer:      &Mailer{Host: testHost, Port: testPort},
+			host:        testHost,
+			expectedCfg: &tls.Config{ServerName: testHost},
+		},
+		"should use the config configured for every host": {
+			mailer:      NewMailer(testHost, testPort, testUser, testPassword, WithTLSConfig(sharedCfg)),
+			host:        testHost,
+			expectedCfg: sharedCfg,
+		},
+		"should prefer the config configured for the host": {
+			mailer:      NewMailer(testHost, testPort, testUser, testPassword, WithTLSConfig(sharedCfg), WithHostTLSConfig("LOCALHOST.smtp.com", pinnedCfg)),
+			host:        testHost,
+			expectedCfg: pinnedCfg,
+		},
+		"should fall back for other hosts": {
+			mailer:      NewMailer(testHost, testPort, testUser, testPassword, WithTLSConfig(sharedCfg), WithHostTLSConfig(testHost, pinnedCfg)),
+			host:        "fallback.smtp.com",
+			expectedCfg: sharedCfg,
+		},
+		"should verify fallback hosts against their own name": {
+			mailer:      NewMailer(testHost, testPort, testUser, testPassword, WithFallbackHosts(Endpoint{Host: "fallback.smtp.com", Port: testPort})),
+			host:        "fallback.smtp.com",
+			expectedCfg: &tls.Config{ServerName: "fallback.smtp.com"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expectedCfg, tc.mailer.tlsCfg(tc.host))
+		})
+	}
+
+	t.Run("should upgrade the connection with the config configured for the host", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithHostTLSConfig(testHost, pinnedCfg))
+
+		// expect on mocks
+		smtpMock.EXPECT().Extension("STARTTLS").Return(true, "STARTTLS")
+		smtpMock.EXPECT().StartTLS(pinnedCfg).Return(nil)
+
+		smtpSender, err := mailer.ConnectAndAuthenticate()
+		assert.Nil(t, err)
+		assert.NotNil(t, smtpSender)
+	})
+}
+
 func TestMailer_ConnectAndAuthenticate(t *testing.T) {
 	dummyErr := fmt.Errorf("dummy error")
 	t.Run("should connect and authenticate to smtp server via mailer without tls config using plain auth", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		// prepare mocks
-		smtpMock := mailerMock.NewMocksmtpClient(ctrl)
+		smtpMock := mailerMock.NewMockClient(ctrl)
 		netConnMock := mailerMock.NewMockconn(ctrl)
 		authMock := mailerMock.NewMockauth(ctrl)
 
 		// stub functions
-		newSmtpClient = func(conn net.Conn, host string) (smtpClient, error) {
+		newClient := func(conn net.Conn, host string) (Client, error) {
 			return smtpMock, nil
 		}
-		netDialTimeout = func(network string, host string, t time.Duration) (net.Conn, error) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
 			return netConnMock, nil
 		}
 
-		smtpPlainAuth = func(identity, username, password, host string) auth {
+		plainAuth := func(identity, username, password, host string) auth {
 			return authMock
 		}
 
 		// init mailer
-		mailer := NewMailer(testHost, testPort, testUser, testPassword)
+		mailer := NewMailer(testHost, testPort, testUser, testPassword, WithClientFactory(newClient), withNetDial(netDial), withPlainAuth(plainAuth))
 		assert.NotNil(t, mailer)
 
 		// expect on mocks
@@ -114,19 +353,19 @@ func TestMailer_ConnectAndAuthenticate(t *testing.T) {
 	t.Run("should connect and authenticate to smtp server via mailer without tls config using login auth", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		// prepare mocks
-		smtpMock := mailerMock.NewMocksmtpClient(ctrl)
+		smtpMock := mailerMock.NewMockClient(ctrl)
 		netConnMock := mailerMock.NewMockconn(ctrl)
 
 		// stub functions
-		newSmtpClient = func(conn net.Conn, host string) (smtpClient, error) {
+		newClient := func(conn net.Conn, host string) (Client, error) {
 			return smtpMock, nil
 		}
-		netDialTimeout = func(network string, host string, t time.Duration) (net.Conn, error) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
 			return netConnMock, nil
 		}
 
 		// init mailer
-		mailer := NewMailer(testHost, testPort, testUser, testPassword)
+		mailer := NewMailer(testHost, testPort, testUser, testPassword, WithClientFactory(newClient), withNetDial(netDial))
 		assert.NotNil(t, mailer)
 
 		// expect on mocks
@@ -143,27 +382,27 @@ func TestMailer_ConnectAndAuthenticate(t *testing.T) {
 	t.Run("should connect and authenticate to smtp server using ssl connection with CRAM-MD5 auth mechanism", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		// prepare mocks
-		smtpMock := mailerMock.NewMocksmtpClient(ctrl)
+		smtpMock := mailerMock.NewMockClient(ctrl)
 		netConnMock := mailerMock.NewMockconn(ctrl)
 		authMock := mailerMock.NewMockauth(ctrl)
 
 		// stub functions
-		newSmtpClient = func(conn net.Conn, host string) (smtpClient, error) {
+		newClient := func(conn net.Conn, host string) (Client, error) {
 			return smtpMock, nil
 		}
-		tlsClient = func(conn net.Conn, config *tls.Config) *tls.Conn {
+		tlsClient := func(conn net.Conn, config *tls.Config) *tls.Conn {
 			return &tls.Conn{}
 		}
-		netDialTimeout = func(network string, host string, t time.Duration) (net.Conn, error) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
 			return netConnMock, nil
 		}
 
-		smtpCRAMMD5Auth = func(username, secret string) smtp.Auth {
+		cramMD5Auth := func(username, secret string) smtp.Auth {
 			return authMock
 		}
 
 		// init mailer
-		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithSSLEnabled(true), WithSecrets(testPassword))
+		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithClientFactory(newClient), withNetDial(netDial), withTLSClient(tlsClient), withCRAMMD5Auth(cramMD5Auth), WithSSLEnabled(true), WithSecrets(testPassword))
 		assert.NotNil(t, mailer)
 
 		// expect on mocks
@@ -179,25 +418,25 @@ func TestMailer_ConnectAndAuthenticate(t *testing.T) {
 	t.Run("should connect and authenticate to smtp server with STARTTLS and plain auth when localName is specified", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		// prepare mocks
-		smtpMock := mailerMock.NewMocksmtpClient(ctrl)
+		smtpMock := mailerMock.NewMockClient(ctrl)
 		netConnMock := mailerMock.NewMockconn(ctrl)
 		authMock := mailerMock.NewMockauth(ctrl)
 
 		// stub functions
-		newSmtpClient = func(conn net.Conn, host string) (smtpClient, error) {
+		newClient := func(conn net.Conn, host string) (Client, error) {
 			return smtpMock, nil
 		}
 
-		netDialTimeout = func(network string, host string, t time.Duration) (net.Conn, error) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
 			return netConnMock, nil
 		}
 
-		smtpPlainAuth = func(identity, username, password, host string) auth {
+		plainAuth := func(identity, username, password, host string) auth {
 			return authMock
 		}
 
 		// init mailer
-		mailer := NewMailer(testHost, testPort, testUser, testPassword, WithLocalName(testLocalName))
+		mailer := NewMailer(testHost, testPort, testUser, testPassword, WithClientFactory(newClient), withNetDial(netDial), withPlainAuth(plainAuth), WithLocalName(testLocalName))
 		assert.NotNil(t, mailer)
 
 		// expect on mocks
@@ -213,12 +452,208 @@ func TestMailer_ConnectAndAuthenticate(t *testing.T) {
 		assert.Nil(t, err)
 		assert.NotNil(t, smtpSender)
 	})
+	t.Run("should connect using implicit tls on a non standard port when ssl/tls encryption is configured", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		tlsConn := &tls.Conn{}
+		var wrapped bool
+
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			assert.Equal(t, tlsConn, conn)
+			return smtpMock, nil
+		}
+		tlsClient := func(conn net.Conn, config *tls.Config) *tls.Conn {
+			wrapped = true
+			return tlsConn
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		// init mailer
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), withTLSClient(tlsClient), WithEncryption(EncryptionSSLTLS))
+		assert.NotNil(t, mailer)
+
+		// dial smtp server and obtain sender, STARTTLS must not be probed.
+		smtpSender, err := mailer.ConnectAndAuthenticate()
+
+		assert.Nil(t, err)
+		assert.NotNil(t, smtpSender)
+		assert.True(t, wrapped)
+	})
+	t.Run("should not issue STARTTLS when encryption is disabled", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		// init mailer
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone))
+		assert.NotNil(t, mailer)
+
+		// dial smtp server and obtain sender.
+		smtpSender, err := mailer.ConnectAndAuthenticate()
+
+		assert.Nil(t, err)
+		assert.NotNil(t, smtpSender)
+	})
+	t.Run("should fall back to plaintext when STARTTLS fails in opportunistic mode", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		firstSmtpMock := mailerMock.NewMockClient(ctrl)
+		secondSmtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		authMock := mailerMock.NewMockauth(ctrl)
+		clients := []Client{firstSmtpMock, secondSmtpMock}
+
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			c := clients[0]
+			clients = clients[1:]
+			return c, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+		plainAuth := func(identity, username, password, host string) auth {
+			return authMock
+		}
+
+		// init mailer
+		mailer := NewMailer(testHost, testPort, testUser, testPassword, WithClientFactory(newClient), withNetDial(netDial), withPlainAuth(plainAuth), WithEncryption(EncryptionOpportunistic))
+		assert.NotNil(t, mailer)
+
+		// expect on mocks
+		firstSmtpMock.EXPECT().Extension("STARTTLS").Return(true, "")
+		firstSmtpMock.EXPECT().StartTLS(mailer.tlsConfig).Return(dummyErr)
+		firstSmtpMock.EXPECT().Close().Return(nil)
+		secondSmtpMock.EXPECT().Extension("AUTH").Return(true, plainAuthMechanism)
+		secondSmtpMock.EXPECT().Auth(authMock).Return(nil)
+
+		// dial smtp server and obtain sender.
+		smtpSender, err := mailer.ConnectAndAuthenticate()
+
+		assert.Nil(t, err)
+		assert.NotNil(t, smtpSender)
+	})
+	t.Run("should refuse the connection when STARTTLS is required but not advertised", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		// init mailer
+		mailer := NewMailer(testHost, testPort, testUser, testPassword, WithClientFactory(newClient), withNetDial(netDial), WithRequireSTARTTLS(true))
+		assert.NotNil(t, mailer)
+
+		// expect on mocks
+		smtpMock.EXPECT().Extension("STARTTLS").Return(false, "")
+		smtpMock.EXPECT().Close().Return(nil)
+
+		// dial smtp server and obtain sender.
+		smtpSender, err := mailer.ConnectAndAuthenticate()
+
+		assert.ErrorIs(t, err, ErrSTARTTLSRequired)
+		assert.Nil(t, smtpSender)
+	})
+	t.Run("should not fall back to plaintext in opportunistic mode when STARTTLS is required", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		// init mailer
+		mailer := NewMailer(testHost, testPort, testUser, testPassword, WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionOpportunistic), WithRequireSTARTTLS(true))
+		assert.NotNil(t, mailer)
+
+		// expect on mocks
+		smtpMock.EXPECT().Extension("STARTTLS").Return(true, "")
+		smtpMock.EXPECT().StartTLS(mailer.tlsConfig).Return(dummyErr)
+		smtpMock.EXPECT().Close().Return(nil)
+
+		// dial smtp server and obtain sender.
+		smtpSender, err := mailer.ConnectAndAuthenticate()
+
+		assert.Equal(t, fmt.Errorf("failed to StartTLS: %w", dummyErr), err)
+		assert.Nil(t, smtpSender)
+	})
+	t.Run("should connect through the configured dialer", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		dialerMock := mailerMock.NewMockDialer(ctrl)
+
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			assert.Equal(t, netConnMock, conn)
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return nil, fmt.Errorf("direct dial must not be used")
+		}
+
+		// init mailer
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithDialer(dialerMock), WithEncryption(EncryptionNone))
+		assert.NotNil(t, mailer)
+
+		// expect on mocks
+		dialerMock.EXPECT().DialContext(gomock.Any(), "tcp", fmt.Sprintf("%s:%d", testHost, testPort)).
+			DoAndReturn(func(ctx context.Context, network, address string) (net.Conn, error) {
+				_, ok := ctx.Deadline()
+				assert.True(t, ok)
+				return netConnMock, nil
+			})
+
+		// dial smtp server and obtain sender.
+		smtpSender, err := mailer.ConnectAndAuthenticate()
+
+		assert.Nil(t, err)
+		assert.NotNil(t, smtpSender)
+	})
+	t.Run("should fail to connect when the configured dialer fails", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		dialerMock := mailerMock.NewMockDialer(ctrl)
+		dialerMock.EXPECT().DialContext(gomock.Any(), "tcp", gomock.Any()).Return(nil, dummyErr)
+
+		mailer := NewMailer(testHost, testPort, testUser, testPassword, WithDialer(dialerMock))
+		smtpSender, err := mailer.ConnectAndAuthenticate()
+		assert.Equal(t, fmt.Errorf("failed to dial to smtp server: %w", dummyErr), err)
+		assert.Nil(t, smtpSender)
+	})
 	t.Run("should fail to connect and authenticate to smtp server when failed to establish a tcp connection", func(t *testing.T) {
-		netDialTimeout = func(network string, host string, t time.Duration) (net.Conn, error) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
 			return nil, dummyErr
 		}
 
-		mailer := NewMailer(testHost, testPort, testUser, testPassword)
+		mailer := NewMailer(testHost, testPort, testUser, testPassword, withNetDial(netDial))
 		smtpSender, err := mailer.ConnectAndAuthenticate()
 		assert.NotNil(t, err)
 		assert.Equal(t, fmt.Errorf("failed to dial to smtp server: %w", dummyErr), err)
@@ -229,15 +664,15 @@ func TestMailer_ConnectAndAuthenticate(t *testing.T) {
 		netConnMock := mailerMock.NewMockconn(ctrl)
 
 		// stub functions
-		newSmtpClient = func(conn net.Conn, host string) (smtpClient, error) {
+		newClient := func(conn net.Conn, host string) (Client, error) {
 			return nil, dummyErr
 		}
 
-		netDialTimeout = func(network string, host string, t time.Duration) (net.Conn, error) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
 			return netConnMock, nil
 		}
 
-		mailer := NewMailer(testHost, testPort, testUser, testPassword)
+		mailer := NewMailer(testHost, testPort, testUser, testPassword, WithClientFactory(newClient), withNetDial(netDial))
 		smtpSender, err := mailer.ConnectAndAuthenticate()
 		assert.NotNil(t, err)
 		assert.Equal(t, fmt.Errorf("failed to dial smtp server: %w", dummyErr), err)
@@ -246,20 +681,20 @@ func TestMailer_ConnectAndAuthenticate(t *testing.T) {
 	t.Run("should fail to connect and authenticate to SMTP server when issuing HELLO command fails", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		// prepare mocks
-		smtpMock := mailerMock.NewMocksmtpClient(ctrl)
+		smtpMock := mailerMock.NewMockClient(ctrl)
 		netConnMock := mailerMock.NewMockconn(ctrl)
 
 		// stub functions
-		newSmtpClient = func(conn net.Conn, host string) (smtpClient, error) {
+		newClient := func(conn net.Conn, host string) (Client, error) {
 			return smtpMock, nil
 		}
 
-		netDialTimeout = func(network string, host string, t time.Duration) (net.Conn, error) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
 			return netConnMock, nil
 		}
 
 		// init mailer
-		mailer := NewMailer(testHost, testPort, testUser, testPassword, WithLocalName(testLocalName))
+		mailer := NewMailer(testHost, testPort, testUser, testPassword, WithClientFactory(newClient), withNetDial(netDial), WithLocalName(testLocalName))
 		assert.NotNil(t, mailer)
 
 		// expect on mocks
@@ -273,20 +708,20 @@ func TestMailer_ConnectAndAuthenticate(t *testing.T) {
 	t.Run("should fail to connect and authenticate to SMTP server when issuing STARTTLS command fails", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		// prepare mocks
-		smtpMock := mailerMock.NewMocksmtpClient(ctrl)
+		smtpMock := mailerMock.NewMockClient(ctrl)
 		netConnMock := mailerMock.NewMockconn(ctrl)
 
 		// stub functions
-		newSmtpClient = func(conn net.Conn, host string) (smtpClient, error) {
+		newClient := func(conn net.Conn, host string) (Client, error) {
 			return smtpMock, nil
 		}
 
-		netDialTimeout = func(network string, host string, t time.Duration) (net.Conn, error) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
 			return netConnMock, nil
 		}
 
 		// init mailer
-		mailer := NewMailer(testHost, testPort, testUser, testPassword, WithLocalName(testLocalName))
+		mailer := NewMailer(testHost, testPort, testUser, testPassword, WithClientFactory(newClient), withNetDial(netDial), WithLocalName(testLocalName))
 		assert.NotNil(t, mailer)
 
 		// expect on mocks
@@ -305,24 +740,24 @@ func TestMailer_ConnectAndAuthenticate(t *testing.T) {
 	t.Run("should fail connect and authenticate to smtp server via mailer using tls config when smtp failed to authenticate with smtp server", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		// prepare mocks
-		smtpMock := mailerMock.NewMocksmtpClient(ctrl)
+		smtpMock := mailerMock.NewMockClient(ctrl)
 		netConnMock := mailerMock.NewMockconn(ctrl)
 		authMock := mailerMock.NewMockauth(ctrl)
 
 		// stub functions
-		newSmtpClient = func(conn net.Conn, host string) (smtpClient, error) {
+		newClient := func(conn net.Conn, host string) (Client, error) {
 			return smtpMock, nil
 		}
-		netDialTimeout = func(network string, host string, t time.Duration) (net.Conn, error) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
 			return netConnMock, nil
 		}
 
-		smtpPlainAuth = func(identity, username, password, host string) auth {
+		plainAuth := func(identity, username, password, host string) auth {
 			return authMock
 		}
 
 		// init mailer
-		mailer := NewMailer(testHost, testPort, testUser, testPassword)
+		mailer := NewMailer(testHost, testPort, testUser, testPassword, WithClientFactory(newClient), withNetDial(netDial), withPlainAuth(plainAuth))
 		assert.NotNil(t, mailer)
 
 		// expect on mocks
@@ -345,27 +780,27 @@ func TestMailer_Send(t *testing.T) {
 	t.Run("should send message successfully", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		// prepare mocks
-		smtpMock := mailerMock.NewMocksmtpClient(ctrl)
+		smtpMock := mailerMock.NewMockClient(ctrl)
 		netConnMock := mailerMock.NewMockconn(ctrl)
 		authMock := mailerMock.NewMockauth(ctrl)
 		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
 		// stub functions
-		newSmtpClient = func(conn net.Conn, host string) (smtpClient, error) {
+		newClient := func(conn net.Conn, host string) (Client, error) {
 			return smtpMock, nil
 		}
-		tlsClient = func(conn net.Conn, config *tls.Config) *tls.Conn {
+		tlsClient := func(conn net.Conn, config *tls.Config) *tls.Conn {
 			return &tls.Conn{}
 		}
-		netDialTimeout = func(network string, host string, t time.Duration) (net.Conn, error) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
 			return netConnMock, nil
 		}
 
-		smtpCRAMMD5Auth = func(username, secret string) smtp.Auth {
+		cramMD5Auth := func(username, secret string) smtp.Auth {
 			return authMock
 		}
 
 		// init mailer
-		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithSSLEnabled(true), WithSecrets(testPassword))
+		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithClientFactory(newClient), withNetDial(netDial), withTLSClient(tlsClient), withCRAMMD5Auth(cramMD5Auth), WithSSLEnabled(true), WithSecrets(testPassword))
 		assert.NotNil(t, mailer)
 
 		msg := message.Message{
@@ -380,7 +815,9 @@ func TestMailer_Send(t *testing.T) {
 		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
 		smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
 		smtpMock.EXPECT().Quit().Return(nil)
-		writeCloserMock.EXPECT().Write(gomock.Any()).Return(0, nil)
+		writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			return len(b), nil
+		})
 		writeCloserMock.EXPECT().Close().Return(nil)
 
 		// dial smtp server and obtain sender.
@@ -396,27 +833,27 @@ func TestMailer_Send(t *testing.T) {
 	t.Run("should success send message without using mailSender implementation", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		// prepare mocks
-		smtpMock := mailerMock.NewMocksmtpClient(ctrl)
+		smtpMock := mailerMock.NewMockClient(ctrl)
 		netConnMock := mailerMock.NewMockconn(ctrl)
 		authMock := mailerMock.NewMockauth(ctrl)
 		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
 		// stub functions
-		newSmtpClient = func(conn net.Conn, host string) (smtpClient, error) {
+		newClient := func(conn net.Conn, host string) (Client, error) {
 			return smtpMock, nil
 		}
-		tlsClient = func(conn net.Conn, config *tls.Config) *tls.Conn {
+		tlsClient := func(conn net.Conn, config *tls.Config) *tls.Conn {
 			return &tls.Conn{}
 		}
-		netDialTimeout = func(network string, host string, t time.Duration) (net.Conn, error) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
 			return netConnMock, nil
 		}
 
-		smtpCRAMMD5Auth = func(username, secret string) smtp.Auth {
+		cramMD5Auth := func(username, secret string) smtp.Auth {
 			return authMock
 		}
 
 		// init mailer
-		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithSSLEnabled(true), WithSecrets(testPassword))
+		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithClientFactory(newClient), withNetDial(netDial), withTLSClient(tlsClient), withCRAMMD5Auth(cramMD5Auth), WithSSLEnabled(true), WithSecrets(testPassword))
 		assert.NotNil(t, mailer)
 
 		msg := message.Message{
@@ -431,37 +868,342 @@ func TestMailer_Send(t *testing.T) {
 		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
 		smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
 		smtpMock.EXPECT().Quit().Return(nil)
-		writeCloserMock.EXPECT().Write(gomock.Any()).Return(0, nil)
+		writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			return len(b), nil
+		})
 		writeCloserMock.EXPECT().Close().Return(nil)
 
 		// dial smtp server and obtain sender.
-		err := mailer.Send(msg)
+		err := mailer.Send(context.Background(), msg)
+		assert.Nil(t, err)
+	})
+	t.Run("should send message encoded with the configured encode options", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		wrapper := message.EntityWrapperFunc(func(entity []byte) ([]byte, error) {
+			return []byte("Content-Type: text/plain\r\n\r\nwrapped\r\n"), nil
+		})
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone), WithEncodeOptions(message.WithEntityWrapper(wrapper)))
+		msg := message.Message{
+			From:       testFromEmail,
+			Recipients: testRecipient,
+			Body:       "dummy body",
+		}
+		// expect on mocks
+		smtpMock.EXPECT().Mail(msg.From).Return(nil)
+		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
+		smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+		smtpMock.EXPECT().Quit().Return(nil)
+		writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			assert.True(t, strings.HasSuffix(string(b), "Content-Type: text/plain\r\n\r\nwrapped\r\n"))
+			assert.NotContains(t, string(b), "dummy body")
+			return len(b), nil
+		})
+		writeCloserMock.EXPECT().Close().Return(nil)
+
+		err := mailer.Send(context.Background(), msg)
+		assert.Nil(t, err)
+	})
+	t.Run("should send message with content hash header when enabled", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		// init mailer
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone), WithContentHash(true))
+		assert.NotNil(t, mailer)
+
+		msg := message.Message{
+			From:       testFromEmail,
+			Recipients: testRecipient,
+			Body:       "dummy body",
+		}
+		hash, err := msg.ContentHash()
+		assert.Nil(t, err)
+		// expect on mocks
+		smtpMock.EXPECT().Mail(msg.From).Return(nil)
+		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
+		smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+		smtpMock.EXPECT().Quit().Return(nil)
+		writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			assert.Contains(t, string(b), fmt.Sprintf("%s: %s\r\n", message.ContentHashHeader, hash))
+			return len(b), nil
+		})
+		writeCloserMock.EXPECT().Close().Return(nil)
+
+		err = mailer.Send(context.Background(), msg)
+		assert.Nil(t, err)
+		assert.Nil(t, msg.Headers)
+	})
+	t.Run("should generate Message-ID and Date headers only when enabled and absent", func(t *testing.T) {
+		now := time.Date(2024, time.March, 5, 10, 30, 0, 0, time.UTC)
+		clock := func() time.Time { return now }
+		randRead := func(b []byte) (int, error) {
+			for i := range b {
+				b[i] = 0xab
+			}
+			return len(b), nil
+		}
+		generatedID := fmt.Sprintf("<%d.%s@%s>", now.UnixNano(), strings.Repeat("ab", 16), testLocalName)
+		tests := map[string]struct {
+			options      []Options
+			headers      mail.Header
+			dateLocation *time.Location
+			contains     []string
+			notContains  []string
+		}{
+			"should generate both headers by default": {
+				options:  []Options{WithLocalName(testLocalName)},
+				contains: []string{"Message-ID: " + generatedID + "\r\n", "Date: Tue, 05 Mar 2024 10:30:00 +0000\r\n"},
+			},
+			"should keep the headers set on the message": {
+				options:     []Options{WithLocalName(testLocalName)},
+				headers:     mail.Header{"Message-Id": {"<own@example.com>"}, "date": {"Mon, 04 Mar 2024 09:00:00 +0000"}},
+				contains:    []string{"Message-Id: <own@example.com>\r\n", "date: Mon, 04 Mar 2024 09:00:00 +0000\r\n"},
+				notContains: []string{generatedID, "Tue, 05 Mar 2024"},
+			},
+			"should not generate headers when disabled": {
+				options:     []Options{WithLocalName(testLocalName), WithMessageID(false), WithDate(false)},
+				notContains: []string{"Message-ID:", "Date:"},
+			},
+			"should generate the Date header in the configured location": {
+				options:  []Options{WithLocalName(testLocalName), WithDateLocation(time.FixedZone("AST", 3*60*60))},
+				contains: []string{"Date: Tue, 05 Mar 2024 13:30:00 +0300\r\n"},
+			},
+			"should generate the Date header in the location of the message over the configured one": {
+				options:      []Options{WithLocalName(testLocalName), WithDateLocation(time.FixedZone("AST", 3*60*60))},
+				dateLocation: time.FixedZone("EST", -5*60*60),
+				contains:     []string{"Date: Tue, 05 Mar 2024 05:30:00 -0500\r\n"},
+			},
+		}
+		for name, tt := range tests {
+			t.Run(name, func(t *testing.T) {
+				ctrl := gomock.NewController(t)
+				// prepare mocks
+				smtpMock := mailerMock.NewMockClient(ctrl)
+				netConnMock := mailerMock.NewMockconn(ctrl)
+				writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+				// stub functions
+				newClient := func(conn net.Conn, host string) (Client, error) {
+					return smtpMock, nil
+				}
+				netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+					return netConnMock, nil
+				}
+
+				mailer := NewMailer(testHost, testPort, "", "", append(tt.options, WithEncryption(EncryptionNone), WithClientFactory(newClient), withNetDial(netDial), withClock(clock), withRandRead(randRead))...)
+				msg := message.Message{
+					From:         testFromEmail,
+					Recipients:   testRecipient,
+					Body:         "dummy body",
+					Headers:      tt.headers,
+					DateLocation: tt.dateLocation,
+				}
+				// expect on mocks
+				smtpMock.EXPECT().Hello(testLocalName).Return(nil)
+				smtpMock.EXPECT().Mail(msg.From).Return(nil)
+				smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
+				smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+				smtpMock.EXPECT().Quit().Return(nil)
+				writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+					for _, s := range tt.contains {
+						assert.Contains(t, string(b), s)
+					}
+					for _, s := range tt.notContains {
+						assert.NotContains(t, string(b), s)
+					}
+					return len(b), nil
+				})
+				writeCloserMock.EXPECT().Close().Return(nil)
+
+				err := mailer.Send(context.Background(), msg)
+				assert.Nil(t, err)
+			})
+		}
+	})
+	t.Run("should request delivery status notifications only when the server advertises DSN", func(t *testing.T) {
+		tests := map[string]struct {
+			advertised         bool
+			expectedMailParams []any
+			expectedRcptParams []any
+		}{
+			"should append DSN parameters when advertised": {
+				advertised:         true,
+				expectedMailParams: []any{"RET=HDRS", "ENVID=order+2B42+3Dx"},
+				expectedRcptParams: []any{"NOTIFY=FAILURE,DELAY"},
+			},
+			"should omit DSN parameters when not advertised": {},
+		}
+		for name, tt := range tests {
+			t.Run(name, func(t *testing.T) {
+				ctrl := gomock.NewController(t)
+				// prepare mocks
+				smtpMock := mailerMock.NewMockClient(ctrl)
+				netConnMock := mailerMock.NewMockconn(ctrl)
+				writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+				// stub functions
+				newClient := func(conn net.Conn, host string) (Client, error) {
+					return smtpMock, nil
+				}
+				netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+					return netConnMock, nil
+				}
+
+				mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone))
+				msg := message.Message{
+					From:       testFromEmail,
+					Recipients: testRecipient,
+					Body:       "dummy body",
+					DSN: &message.DSN{
+						Notify:     []message.DSNNotify{message.DSNNotifyFailure, message.DSNNotifyDelay},
+						Return:     message.DSNReturnHeaders,
+						EnvelopeID: "order+42=x",
+					},
+				}
+				// expect on mocks
+				smtpMock.EXPECT().Extension("DSN").Return(tt.advertised, "")
+				smtpMock.EXPECT().Mail(msg.From, tt.expectedMailParams...).Return(nil)
+				smtpMock.EXPECT().Rcpt(msg.Recipients[0], tt.expectedRcptParams...).Return(nil)
+				smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+				smtpMock.EXPECT().Quit().Return(nil)
+				writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+					return len(b), nil
+				})
+				writeCloserMock.EXPECT().Close().Return(nil)
+
+				err := mailer.Send(context.Background(), msg)
+				assert.Nil(t, err)
+			})
+		}
+	})
+	t.Run("should negotiate 8BITMIME for messages with 8bit content", func(t *testing.T) {
+		tests := map[string]struct {
+			advertised       bool
+			expectedEncoding string
+		}{
+			"should send 8bit content when 8BITMIME is advertised": {
+				advertised:       true,
+				expectedEncoding: "Content-Transfer-Encoding: 8bit\r\n\r\nمرحبا\r\n",
+			},
+			"should quoted-printable encode 8bit content when 8BITMIME is not advertised": {
+				expectedEncoding: "Content-Transfer-Encoding: quoted-printable\r\n\r\n=D9=85=D8=B1=D8=AD=D8=A8=D8=A7\r\n",
+			},
+		}
+		for name, tt := range tests {
+			t.Run(name, func(t *testing.T) {
+				ctrl := gomock.NewController(t)
+				// prepare mocks
+				smtpMock := mailerMock.NewMockClient(ctrl)
+				netConnMock := mailerMock.NewMockconn(ctrl)
+				writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+				// stub functions
+				newClient := func(conn net.Conn, host string) (Client, error) {
+					return smtpMock, nil
+				}
+				netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+					return netConnMock, nil
+				}
+
+				mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone))
+				msg := message.Message{
+					From:       testFromEmail,
+					Recipients: testRecipient,
+					Body:       "مرحبا",
+				}
+				// expect on mocks
+				smtpMock.EXPECT().Extension("8BITMIME").Return(tt.advertised, "")
+				smtpMock.EXPECT().Mail(msg.From).Return(nil)
+				smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
+				smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+				smtpMock.EXPECT().Quit().Return(nil)
+				writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+					assert.True(t, strings.HasSuffix(string(b), tt.expectedEncoding))
+					return len(b), nil
+				})
+				writeCloserMock.EXPECT().Close().Return(nil)
+
+				err := mailer.Send(context.Background(), msg)
+				assert.Nil(t, err)
+			})
+		}
+	})
+	t.Run("should use bare addresses in the envelope when display names are given", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone))
+		msg := message.Message{
+			From:       message.Address{Name: "Go Mailer", Email: testFromEmail}.String(),
+			Recipients: message.Addresses(message.Address{Name: "Recipient", Email: testRecipient[0]}),
+			Body:       "dummy body",
+		}
+		// expect on mocks
+		smtpMock.EXPECT().Mail(testFromEmail).Return(nil)
+		smtpMock.EXPECT().Rcpt(testRecipient[0]).Return(nil)
+		smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+		smtpMock.EXPECT().Quit().Return(nil)
+		writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			return len(b), nil
+		})
+		writeCloserMock.EXPECT().Close().Return(nil)
+
+		err := mailer.Send(context.Background(), msg)
 		assert.Nil(t, err)
 	})
 	t.Run("should send message successfully and failed in terminating the session", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		// prepare mocks
-		smtpMock := mailerMock.NewMocksmtpClient(ctrl)
+		smtpMock := mailerMock.NewMockClient(ctrl)
 		netConnMock := mailerMock.NewMockconn(ctrl)
 		authMock := mailerMock.NewMockauth(ctrl)
 		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
 		// stub functions
-		newSmtpClient = func(conn net.Conn, host string) (smtpClient, error) {
+		newClient := func(conn net.Conn, host string) (Client, error) {
 			return smtpMock, nil
 		}
-		tlsClient = func(conn net.Conn, config *tls.Config) *tls.Conn {
+		tlsClient := func(conn net.Conn, config *tls.Config) *tls.Conn {
 			return &tls.Conn{}
 		}
-		netDialTimeout = func(network string, host string, t time.Duration) (net.Conn, error) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
 			return netConnMock, nil
 		}
 
-		smtpCRAMMD5Auth = func(username, secret string) smtp.Auth {
+		cramMD5Auth := func(username, secret string) smtp.Auth {
 			return authMock
 		}
 
 		// init mailer
-		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithSSLEnabled(true), WithSecrets(testPassword))
+		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithClientFactory(newClient), withNetDial(netDial), withTLSClient(tlsClient), withCRAMMD5Auth(cramMD5Auth), WithSSLEnabled(true), WithSecrets(testPassword))
 		assert.NotNil(t, mailer)
 
 		msg := message.Message{
@@ -476,7 +1218,9 @@ func TestMailer_Send(t *testing.T) {
 		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
 		smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
 		smtpMock.EXPECT().Quit().Return(dummyErr)
-		writeCloserMock.EXPECT().Write(gomock.Any()).Return(0, nil)
+		writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			return len(b), nil
+		})
 		writeCloserMock.EXPECT().Close().Return(nil)
 
 		// dial smtp server and obtain sender.
@@ -493,27 +1237,27 @@ func TestMailer_Send(t *testing.T) {
 	t.Run("should fail to send message when issuing MAIL command fails", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		// prepare mocks
-		smtpMock := mailerMock.NewMocksmtpClient(ctrl)
+		smtpMock := mailerMock.NewMockClient(ctrl)
 		netConnMock := mailerMock.NewMockconn(ctrl)
 		authMock := mailerMock.NewMockauth(ctrl)
 
 		// stub functions
-		newSmtpClient = func(conn net.Conn, host string) (smtpClient, error) {
+		newClient := func(conn net.Conn, host string) (Client, error) {
 			return smtpMock, nil
 		}
-		tlsClient = func(conn net.Conn, config *tls.Config) *tls.Conn {
+		tlsClient := func(conn net.Conn, config *tls.Config) *tls.Conn {
 			return &tls.Conn{}
 		}
-		netDialTimeout = func(network string, host string, t time.Duration) (net.Conn, error) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
 			return netConnMock, nil
 		}
 
-		smtpCRAMMD5Auth = func(username, secret string) smtp.Auth {
+		cramMD5Auth := func(username, secret string) smtp.Auth {
 			return authMock
 		}
 
 		// init mailer
-		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithSSLEnabled(true), WithSecrets(testPassword))
+		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithClientFactory(newClient), withNetDial(netDial), withTLSClient(tlsClient), withCRAMMD5Auth(cramMD5Auth), WithSSLEnabled(true), WithSecrets(testPassword))
 		assert.NotNil(t, mailer)
 		msg := message.Message{
 			From:       testFromEmail,
@@ -538,27 +1282,27 @@ func TestMailer_Send(t *testing.T) {
 	t.Run("should fail to send message when issuing RCPT command fails", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		// prepare mocks
-		smtpMock := mailerMock.NewMocksmtpClient(ctrl)
+		smtpMock := mailerMock.NewMockClient(ctrl)
 		netConnMock := mailerMock.NewMockconn(ctrl)
 		authMock := mailerMock.NewMockauth(ctrl)
 
 		// stub functions
-		newSmtpClient = func(conn net.Conn, host string) (smtpClient, error) {
+		newClient := func(conn net.Conn, host string) (Client, error) {
 			return smtpMock, nil
 		}
-		tlsClient = func(conn net.Conn, config *tls.Config) *tls.Conn {
+		tlsClient := func(conn net.Conn, config *tls.Config) *tls.Conn {
 			return &tls.Conn{}
 		}
-		netDialTimeout = func(network string, host string, t time.Duration) (net.Conn, error) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
 			return netConnMock, nil
 		}
 
-		smtpCRAMMD5Auth = func(username, secret string) smtp.Auth {
+		cramMD5Auth := func(username, secret string) smtp.Auth {
 			return authMock
 		}
 
 		// init mailer
-		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithSSLEnabled(true), WithSecrets(testPassword))
+		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithClientFactory(newClient), withNetDial(netDial), withTLSClient(tlsClient), withCRAMMD5Auth(cramMD5Auth), WithSSLEnabled(true), WithSecrets(testPassword))
 		assert.NotNil(t, mailer)
 		msg := message.Message{
 			From:       testFromEmail,
@@ -581,31 +1325,106 @@ func TestMailer_Send(t *testing.T) {
 		assert.NotNil(t, err)
 		assert.Equal(t, fmt.Errorf("mailer failed to send rcpt command for address %s: %w", msg.Recipients[0], dummyErr), err)
 	})
+	t.Run("should fail to send message with a classified bounce when the server rejects the recipient", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		// init mailer
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone))
+		assert.NotNil(t, mailer)
+		msg := message.Message{
+			From:       testFromEmail,
+			Recipients: testRecipient,
+			Body:       "dummy body",
+		}
+		// expect on mocks
+		smtpMock.EXPECT().Mail(msg.From).Return(nil)
+		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(&textproto.Error{Code: 550, Msg: "5.1.1 User unknown"})
+		smtpMock.EXPECT().Quit().Return(nil)
+
+		err := mailer.Send(context.Background(), msg)
+
+		var smtpErr *SMTPError
+		assert.True(t, errors.As(err, &smtpErr))
+		assert.Equal(t, msg.Recipients[0], smtpErr.Recipient)
+		assert.Equal(t, BounceHard, smtpErr.Bounce())
+	})
+	t.Run("should fail to send message when the server rejects the message data", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		// init mailer
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone))
+		assert.NotNil(t, mailer)
+		msg := message.Message{
+			From:       testFromEmail,
+			Recipients: testRecipient,
+			Body:       "dummy body",
+		}
+		reply := &textproto.Error{Code: 552, Msg: "5.3.4 Message too big"}
+		// expect on mocks
+		smtpMock.EXPECT().Mail(msg.From).Return(nil)
+		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
+		smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+		smtpMock.EXPECT().Quit().Return(nil)
+		writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			return len(b), nil
+		})
+		writeCloserMock.EXPECT().Close().Return(reply)
+
+		err := mailer.Send(context.Background(), msg)
+		assert.Equal(t, fmt.Errorf("failed to send message: %w", fmt.Errorf("mailer failed to complete data transfer: %w", newSMTPError("DATA", "", reply))), err)
+
+		var smtpErr *SMTPError
+		assert.True(t, errors.As(err, &smtpErr))
+		assert.Equal(t, BounceSoft, smtpErr.Bounce())
+	})
 	t.Run("should fail to send message when getting writer closer from SMTP client fails", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		// prepare mocks
-		smtpMock := mailerMock.NewMocksmtpClient(ctrl)
+		smtpMock := mailerMock.NewMockClient(ctrl)
 		netConnMock := mailerMock.NewMockconn(ctrl)
 		authMock := mailerMock.NewMockauth(ctrl)
 		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
 
 		// stub functions
-		newSmtpClient = func(conn net.Conn, host string) (smtpClient, error) {
+		newClient := func(conn net.Conn, host string) (Client, error) {
 			return smtpMock, nil
 		}
-		tlsClient = func(conn net.Conn, config *tls.Config) *tls.Conn {
+		tlsClient := func(conn net.Conn, config *tls.Config) *tls.Conn {
 			return &tls.Conn{}
 		}
-		netDialTimeout = func(network string, host string, t time.Duration) (net.Conn, error) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
 			return netConnMock, nil
 		}
 
-		smtpCRAMMD5Auth = func(username, secret string) smtp.Auth {
+		cramMD5Auth := func(username, secret string) smtp.Auth {
 			return authMock
 		}
 
 		// init mailer
-		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithSSLEnabled(true), WithSecrets(testPassword))
+		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithClientFactory(newClient), withNetDial(netDial), withTLSClient(tlsClient), withCRAMMD5Auth(cramMD5Auth), WithSSLEnabled(true), WithSecrets(testPassword))
 		assert.NotNil(t, mailer)
 		msg := message.Message{
 			From:       testFromEmail,
@@ -632,28 +1451,27 @@ func TestMailer_Send(t *testing.T) {
 	t.Run("should fail to send message when encoding message fails", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		// prepare mocks
-		smtpMock := mailerMock.NewMocksmtpClient(ctrl)
+		smtpMock := mailerMock.NewMockClient(ctrl)
 		netConnMock := mailerMock.NewMockconn(ctrl)
 		authMock := mailerMock.NewMockauth(ctrl)
-		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
 
 		// stub functions
-		newSmtpClient = func(conn net.Conn, host string) (smtpClient, error) {
+		newClient := func(conn net.Conn, host string) (Client, error) {
 			return smtpMock, nil
 		}
-		tlsClient = func(conn net.Conn, config *tls.Config) *tls.Conn {
+		tlsClient := func(conn net.Conn, config *tls.Config) *tls.Conn {
 			return &tls.Conn{}
 		}
-		netDialTimeout = func(network string, host string, t time.Duration) (net.Conn, error) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
 			return netConnMock, nil
 		}
 
-		smtpCRAMMD5Auth = func(username, secret string) smtp.Auth {
+		cramMD5Auth := func(username, secret string) smtp.Auth {
 			return authMock
 		}
 
 		// init mailer
-		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithSSLEnabled(true), WithSecrets(testPassword))
+		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithClientFactory(newClient), withNetDial(netDial), withTLSClient(tlsClient), withCRAMMD5Auth(cramMD5Auth), WithSSLEnabled(true), WithSecrets(testPassword))
 		assert.NotNil(t, mailer)
 		msg := message.Message{
 			From:       "",
@@ -663,9 +1481,7 @@ func TestMailer_Send(t *testing.T) {
 		// expect on mocks
 		smtpMock.EXPECT().Extension("AUTH").Return(true, crmAuthMechanism)
 		smtpMock.EXPECT().Auth(authMock).Return(nil)
-		smtpMock.EXPECT().Mail(msg.From).Return(nil)
-		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
-		smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+		// the message is encoded before the transaction starts, so no command is issued.
 
 		// dial smtp server and obtain sender.
 		smtpSender, err := mailer.ConnectAndAuthenticate()
@@ -680,28 +1496,28 @@ func TestMailer_Send(t *testing.T) {
 	t.Run("should fail to send message when writing encoded message fails", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		// prepare mocks
-		smtpMock := mailerMock.NewMocksmtpClient(ctrl)
+		smtpMock := mailerMock.NewMockClient(ctrl)
 		netConnMock := mailerMock.NewMockconn(ctrl)
 		authMock := mailerMock.NewMockauth(ctrl)
 		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
 
 		// stub functions
-		newSmtpClient = func(conn net.Conn, host string) (smtpClient, error) {
+		newClient := func(conn net.Conn, host string) (Client, error) {
 			return smtpMock, nil
 		}
-		tlsClient = func(conn net.Conn, config *tls.Config) *tls.Conn {
+		tlsClient := func(conn net.Conn, config *tls.Config) *tls.Conn {
 			return &tls.Conn{}
 		}
-		netDialTimeout = func(network string, host string, t time.Duration) (net.Conn, error) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
 			return netConnMock, nil
 		}
 
-		smtpCRAMMD5Auth = func(username, secret string) smtp.Auth {
+		cramMD5Auth := func(username, secret string) smtp.Auth {
 			return authMock
 		}
 
 		// init mailer
-		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithSSLEnabled(true), WithSecrets(testPassword))
+		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithClientFactory(newClient), withNetDial(netDial), withTLSClient(tlsClient), withCRAMMD5Auth(cramMD5Auth), WithSSLEnabled(true), WithSecrets(testPassword))
 		assert.NotNil(t, mailer)
 		msg := message.Message{
 			From:       testFromEmail,
@@ -729,11 +1545,11 @@ func TestMailer_Send(t *testing.T) {
 	})
 	t.Run("should fail to send message due to authentication failure without using mailSender implementation", func(t *testing.T) {
 		// stub functions
-		netDialTimeout = func(network string, host string, t time.Duration) (net.Conn, error) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
 			return nil, dummyErr
 		}
 		// init mailer
-		mailer := NewMailer(testHost, testSSLPort, testUser, testPassword)
+		mailer := NewMailer(testHost, testSSLPort, testUser, testPassword, withNetDial(netDial))
 		assert.NotNil(t, mailer)
 
 		msg := message.Message{
@@ -744,35 +1560,34 @@ func TestMailer_Send(t *testing.T) {
 		// expect on mocks
 
 		// dial smtp server and obtain sender.
-		err := mailer.Send(msg)
+		err := mailer.Send(context.Background(), msg)
 		assert.NotNil(t, err)
 		assert.Equal(t, fmt.Errorf("failed to connect and authenticate: %w", fmt.Errorf("failed to dial to smtp server: %w", dummyErr)), err)
 	})
 	t.Run("should fail to send message due to message sending failure without using mailSender implementation", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		// prepare mocks
-		smtpMock := mailerMock.NewMocksmtpClient(ctrl)
+		smtpMock := mailerMock.NewMockClient(ctrl)
 		netConnMock := mailerMock.NewMockconn(ctrl)
 		authMock := mailerMock.NewMockauth(ctrl)
-		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
 
 		// stub functions
-		newSmtpClient = func(conn net.Conn, host string) (smtpClient, error) {
+		newClient := func(conn net.Conn, host string) (Client, error) {
 			return smtpMock, nil
 		}
-		tlsClient = func(conn net.Conn, config *tls.Config) *tls.Conn {
+		tlsClient := func(conn net.Conn, config *tls.Config) *tls.Conn {
 			return &tls.Conn{}
 		}
-		netDialTimeout = func(network string, host string, t time.Duration) (net.Conn, error) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
 			return netConnMock, nil
 		}
 
-		smtpCRAMMD5Auth = func(username, secret string) smtp.Auth {
+		cramMD5Auth := func(username, secret string) smtp.Auth {
 			return authMock
 		}
 
 		// init mailer
-		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithSSLEnabled(true), WithSecrets(testPassword))
+		mailer := NewMailer(testHost, testSSLPort, testUser, "", WithClientFactory(newClient), withNetDial(netDial), withTLSClient(tlsClient), withCRAMMD5Auth(cramMD5Auth), WithSSLEnabled(true), WithSecrets(testPassword))
 		assert.NotNil(t, mailer)
 		msg := message.Message{
 			From:       "",
@@ -782,13 +1597,478 @@ func TestMailer_Send(t *testing.T) {
 		// expect on mocks
 		smtpMock.EXPECT().Extension("AUTH").Return(true, crmAuthMechanism)
 		smtpMock.EXPECT().Auth(authMock).Return(nil)
-		smtpMock.EXPECT().Mail(msg.From).Return(nil)
-		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
-		smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+		// the message is encoded before the transaction starts, so no command is issued.
 		smtpMock.EXPECT().Quit().Return(nil)
 
-		err := mailer.Send(msg)
+		err := mailer.Send(context.Background(), msg)
 		assert.NotNil(t, err)
 		assert.Equal(t, "failed to send message: failed to send message: failed to encode message: from address cannot be empty", err.Error())
 	})
 }
+
+func TestMailer_SendBatch(t *testing.T) {
+	dummyErr := fmt.Errorf("dummy error")
+	t.Run("should send a personalized copy per recipient and continue after a failed copy", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		// init mailer
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone))
+		assert.NotNil(t, mailer)
+
+		tmpl := message.Message{
+			From:    testFromEmail,
+			Body:    "dummy body",
+			Headers: mail.Header{"Accept-Language": {"en"}},
+		}
+		personalizations := []message.Personalization{
+			{Recipients: []string{"first@gomailer.com"}},
+			{Recipients: []string{"second@gomailer.com"}, Headers: mail.Header{"Accept-Language": {"ar"}}},
+		}
+		// expect on mocks
+		gomock.InOrder(
+			smtpMock.EXPECT().Mail(tmpl.From).Return(nil),
+			smtpMock.EXPECT().Rcpt("first@gomailer.com").Return(dummyErr),
+			smtpMock.EXPECT().Reset().Return(nil),
+			smtpMock.EXPECT().Mail(tmpl.From).Return(nil),
+			smtpMock.EXPECT().Rcpt("second@gomailer.com").Return(nil),
+			smtpMock.EXPECT().Data().Return(writeCloserMock, nil),
+			smtpMock.EXPECT().Quit().Return(nil),
+		)
+		writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			assert.Contains(t, string(b), "Accept-Language: ar\r\n")
+			return len(b), nil
+		})
+		writeCloserMock.EXPECT().Close().Return(nil)
+
+		err := mailer.SendBatch(context.Background(), tmpl, personalizations)
+		assert.Equal(t, errors.Join(fmt.Errorf("failed to send message to first@gomailer.com: %w",
+			fmt.Errorf("mailer failed to send rcpt command for address first@gomailer.com: %w", dummyErr))), err)
+	})
+	t.Run("should fail to send batch when failed to connect", func(t *testing.T) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return nil, dummyErr
+		}
+
+		mailer := NewMailer(testHost, testPort, testUser, testPassword, withNetDial(netDial))
+		err := mailer.SendBatch(context.Background(), message.Message{}, []message.Personalization{{Recipients: testRecipient}})
+		assert.Equal(t, fmt.Errorf("failed to connect and authenticate: %w", fmt.Errorf("failed to dial to smtp server: %w", dummyErr)), err)
+	})
+}
+
+func TestMailer_MaxMessageSize(t *testing.T) {
+	t.Run("should refuse messages exceeding the maximum size before sending them", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		commands := make(chan string, 10)
+		go serveSMTP(serverConn, "SIZE 10485760", nil, commands)
+
+		// stub functions
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return clientConn, nil
+		}
+
+		mailer := NewMailer(testHost, testPort, "", "", withNetDial(netDial), WithLocalName("localhost"), WithEncryption(EncryptionNone), WithMaxMessageSize(1024))
+		msg := message.Message{
+			From:       testFromEmail,
+			Recipients: testRecipient,
+			// the body fits, the header fields make the encoded message exceed the maximum size.
+			Body: strings.Repeat("a", 1000),
+		}
+
+		err := mailer.Send(context.Background(), msg)
+		assert.ErrorIs(t, err, message.ErrMessageTooLarge)
+
+		var got []string
+		for cmd := range commands {
+			got = append(got, cmd)
+		}
+		assert.Equal(t, []string{"EHLO localhost", "QUIT"}, got)
+	})
+
+	tests := map[string]struct {
+		opts        []Options
+		expectedErr error
+	}{
+		"should refuse attachments exceeding the maximum size before connecting": {
+			opts:        []Options{WithMaxAttachmentSize(1024)},
+			expectedErr: message.ErrAttachmentTooLarge,
+		},
+		"should refuse messages whose content exceeds the maximum size before connecting": {
+			opts:        []Options{WithMaxMessageSize(1024)},
+			expectedErr: message.ErrMessageTooLarge,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+				return nil, errors.New("unexpected dial")
+			}
+
+			mailer := NewMailer(testHost, testPort, "", "", append(tc.opts, withNetDial(netDial))...)
+			msg := message.Message{
+				From:        testFromEmail,
+				Recipients:  testRecipient,
+				Body:        "body",
+				Attachments: []message.Attachment{{Filename: "large.bin", Data: make([]byte, 2048)}},
+			}
+
+			err := mailer.Send(context.Background(), msg)
+			assert.ErrorIs(t, err, tc.expectedErr)
+			err = mailer.SendBatch(context.Background(), msg, []message.Personalization{{}})
+			assert.ErrorIs(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestMailer_Timeouts(t *testing.T) {
+	tests := map[string]struct {
+		// stallOn is the command the server stops replying to.
+		stallOn string
+		opts    []Options
+		// expectedErr is the error expected in the chain, os.ErrDeadlineExceeded when nil.
+		expectedErr error
+	}{
+		"should time out commands the server does not reply to": {
+			stallOn: "EHLO",
+			opts:    []Options{WithCommandTimeout(50 * time.Millisecond)},
+		},
+		"should time out messages the server does not accept": {
+			stallOn: ".",
+			opts:    []Options{WithCommandTimeout(100 * time.Millisecond), WithDataTimeout(50 * time.Millisecond)},
+		},
+		"should time out the whole send": {
+			stallOn: "RCPT",
+			opts:    []Options{WithSendTimeout(50 * time.Millisecond)},
+			// the connection is closed as soon as the send times out.
+			expectedErr: context.DeadlineExceeded,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			defer serverConn.Close()
+			go func() {
+				server := textproto.NewConn(serverConn)
+				_ = server.PrintfLine("220 localhost ESMTP")
+				stalled := false
+				for {
+					line, err := server.ReadLine()
+					if err != nil {
+						return
+					}
+					// keep reading once stalled, so the client is not blocked writing.
+					if stalled = stalled || strings.HasPrefix(line, tc.stallOn); stalled {
+						continue
+					}
+					switch {
+					case strings.HasPrefix(line, "DATA"):
+						_ = server.PrintfLine("354 go ahead")
+					case strings.HasPrefix(line, "QUIT"):
+						_ = server.PrintfLine("221 bye")
+					case line == "." || strings.HasPrefix(line, "MAIL") || strings.HasPrefix(line, "RCPT") || strings.HasPrefix(line, "EHLO"):
+						_ = server.PrintfLine("250 ok")
+					}
+				}
+			}()
+
+			// stub functions
+			netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+				return clientConn, nil
+			}
+
+			opts := append([]Options{WithLocalName("localhost"), WithEncryption(EncryptionNone)}, tc.opts...)
+			mailer := NewMailer(testHost, testPort, "", "", append(opts, withNetDial(netDial))...)
+			msg := message.Message{
+				From:       testFromEmail,
+				Recipients: testRecipient,
+				Body:       "dummy body",
+			}
+
+			expectedErr := tc.expectedErr
+			if expectedErr == nil {
+				expectedErr = os.ErrDeadlineExceeded
+			}
+			err := mailer.Send(context.Background(), msg)
+			assert.ErrorIs(t, err, expectedErr)
+		})
+	}
+}
+
+func TestMailer_GreetingTimeout(t *testing.T) {
+	tests := map[string]struct {
+		opts        []Options
+		expectedErr error
+	}{
+		"should time out a delayed banner with the command timeout": {
+			opts:        []Options{WithCommandTimeout(50 * time.Millisecond)},
+			expectedErr: os.ErrDeadlineExceeded,
+		},
+		"should wait for a delayed banner within the greeting timeout": {
+			opts: []Options{WithCommandTimeout(50 * time.Millisecond), WithGreetingTimeout(time.Second)},
+		},
+		"should time out a banner delayed past the greeting timeout": {
+			opts:        []Options{WithCommandTimeout(time.Second), WithGreetingTimeout(50 * time.Millisecond)},
+			expectedErr: os.ErrDeadlineExceeded,
+		},
+		"should tolerate a delayed banner": {
+			opts: []Options{WithCommandTimeout(50 * time.Millisecond), WithGreetingTolerance()},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			defer serverConn.Close()
+			commands := make(chan string, 10)
+			go func() {
+				// delay the banner like a greylisting appliance.
+				time.Sleep(200 * time.Millisecond)
+				serveSMTP(serverConn, "8BITMIME", map[string]string{"MAIL": "250 ok", "RCPT": "250 ok"}, commands)
+			}()
+
+			// stub functions
+			netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+				return clientConn, nil
+			}
+
+			opts := append([]Options{WithLocalName("localhost"), WithEncryption(EncryptionNone)}, tc.opts...)
+			mailer := NewMailer(testHost, testPort, "", "", append(opts, withNetDial(netDial))...)
+			err := mailer.Send(context.Background(), message.Message{From: testFromEmail, Recipients: testRecipient, Body: "dummy body"})
+			if tc.expectedErr == nil {
+				assert.Nil(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tc.expectedErr)
+		})
+	}
+	t.Run("should clear the greeting timeout once the banner is read", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		defer serverConn.Close()
+		commands := make(chan string, 10)
+		go serveSMTP(serverConn, "8BITMIME", map[string]string{"MAIL": "250 ok", "RCPT": "250 ok"}, commands)
+
+		// stub functions
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			// the session outlives the greeting timeout by far.
+			return &slowConn{Conn: clientConn, delay: 20 * time.Millisecond}, nil
+		}
+
+		mailer := NewMailer(testHost, testPort, "", "", withNetDial(netDial), WithLocalName("localhost"), WithEncryption(EncryptionNone), WithGreetingTimeout(50*time.Millisecond))
+		err := mailer.Send(context.Background(), message.Message{From: testFromEmail, Recipients: testRecipient, Body: "dummy body"})
+		assert.Nil(t, err)
+	})
+	t.Run("should refuse a negative greeting timeout", func(t *testing.T) {
+		_, err := NewMailerE(testHost, testPort, testUser, testPassword, WithGreetingTimeout(-time.Second))
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+	})
+}
+
+// slowConn is a net.Conn delaying every write, like a slow network.
+type slowConn struct {
+	net.Conn
+	delay time.Duration
+}
+
+// Write writes p to the underlying connection after the delay.
+func (c *slowConn) Write(p []byte) (int, error) {
+	time.Sleep(c.delay)
+	return c.Conn.Write(p)
+}
+
+func TestMailer_ConcurrentSend(t *testing.T) {
+	// serve serves a scripted SMTP session over a new pipe for every dial, it returns the option dialing it
+	// and a function waiting for the sessions to end and returning their commands.
+	serve := func() (dial Options, commands func() []string) {
+		var (
+			mu       sync.Mutex
+			sessions sync.WaitGroup
+			recorded []string
+		)
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			clientConn, serverConn := net.Pipe()
+			session := make(chan string)
+			go serveSMTP(serverConn, "AUTH PLAIN", map[string]string{"AUTH": "235 2.7.0 authenticated", "MAIL": "250 ok", "RCPT": "250 ok"}, session)
+			sessions.Go(func() {
+				for command := range session {
+					mu.Lock()
+					recorded = append(recorded, command)
+					mu.Unlock()
+				}
+			})
+			return clientConn, nil
+		}
+		return withNetDial(netDial), func() []string {
+			sessions.Wait()
+			return recorded
+		}
+	}
+	msg := message.Message{From: testFromEmail, Recipients: testRecipient, Body: "dummy body"}
+	// send sends msg from 8 goroutines, returning their errors.
+	send := func(send func(context.Context, message.Message) error) []error {
+		var wg sync.WaitGroup
+		errs := make([]error, 8)
+		for i := range errs {
+			wg.Go(func() { errs[i] = send(context.Background(), msg) })
+		}
+		wg.Wait()
+		return errs
+	}
+
+	t.Run("should send concurrently over connections authenticated per send", func(t *testing.T) {
+		dial, commands := serve()
+		mailer := NewMailer("localhost", testPort, "user", "pass", dial)
+
+		assert.Equal(t, make([]error, 8), send(mailer.Send))
+		assert.Len(t, slices.DeleteFunc(commands(), func(c string) bool { return !strings.HasPrefix(c, "AUTH PLAIN") }), 8)
+	})
+	t.Run("should serialize concurrent sends over a single SendCloser", func(t *testing.T) {
+		dial, commands := serve()
+		sender, err := NewMailer("localhost", testPort, "user", "pass", dial).ConnectAndAuthenticate()
+		require.Nil(t, err)
+
+		assert.Equal(t, make([]error, 8), send(sender.SendContext))
+		assert.Nil(t, sender.Close())
+		assert.Len(t, slices.DeleteFunc(commands(), func(c string) bool { return !strings.HasPrefix(c, "MAIL FROM") }), 8)
+	})
+	t.Run("should send to the envelope recipients of every concurrent call over a single connection", func(t *testing.T) {
+		dial, commands := serve()
+		sender, err := NewMailer("localhost", testPort, "user", "pass", dial).connectAndAuthenticate(context.Background())
+		require.Nil(t, err)
+
+		var (
+			wg       sync.WaitGroup
+			expected []string
+		)
+		errs := make([]error, 8)
+		for i := range errs {
+			recipient := fmt.Sprintf("user%d@example.com", i)
+			expected = append(expected, "RCPT TO:<"+recipient+">")
+			wg.Go(func() { errs[i] = sender.sendTo(context.Background(), msg, []string{recipient}) })
+		}
+		wg.Wait()
+		assert.Equal(t, make([]error, 8), errs)
+		assert.Nil(t, sender.Close())
+		assert.ElementsMatch(t, expected, slices.DeleteFunc(commands(), func(c string) bool { return !strings.HasPrefix(c, "RCPT TO") }))
+	})
+}
+
+func TestMailer_StreamData(t *testing.T) {
+	msg := message.Message{
+		From:       testFromEmail,
+		Recipients: testRecipient,
+		Body:       "dummy body",
+		Attachments: []message.Attachment{{
+			Filename: "archive.bin",
+			Data:     make([]byte, 256<<10),
+			MIMEType: "application/octet-stream",
+		}},
+	}
+	tests := map[string]struct {
+		beforeSend bool
+	}{
+		"should encode the message while writing it to the server": {},
+		"should write the message given to the BeforeSend hooks":   {beforeSend: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			// prepare mocks
+			smtpMock := mailerMock.NewMockClient(ctrl)
+			netConnMock := mailerMock.NewMockconn(ctrl)
+			writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+
+			// stub functions
+			newClient := func(conn net.Conn, host string) (Client, error) {
+				return smtpMock, nil
+			}
+			netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+				return netConnMock, nil
+			}
+
+			var encoded []byte
+			opts := []Options{WithEncryption(EncryptionNone)}
+			if tc.beforeSend {
+				opts = append(opts, WithHooks(Hooks{
+					BeforeSend: func(ctx context.Context, msg message.Message, b []byte) error {
+						encoded = b
+						return nil
+					},
+				}))
+			}
+			mailer := NewMailer(testHost, testPort, "", "", append(opts, WithClientFactory(newClient), withNetDial(netDial))...)
+
+			// expect on mocks
+			var data []byte
+			smtpMock.EXPECT().Mail(msg.From).Return(nil)
+			smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
+			smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+			smtpMock.EXPECT().Quit().Return(nil)
+			writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+				data = append(data, b...)
+				return len(b), nil
+			}).MinTimes(1)
+			writeCloserMock.EXPECT().Close().Return(nil)
+
+			result, err := mailer.SendResult(context.Background(), msg)
+			require.Nil(t, err)
+			assert.Equal(t, len(data), result.Bytes)
+			assert.Greater(t, len(data), len(msg.Attachments[0].Data))
+			assert.True(t, strings.HasSuffix(string(data), "--\r\n"))
+			if tc.beforeSend {
+				assert.Equal(t, string(encoded), string(data))
+			}
+		})
+	}
+}
+
+// withNetDial stubs the direct TCP connection of the Mailer, so tests do not dial a real server.
+func withNetDial(dial func(network, address string, timeout time.Duration) (net.Conn, error)) Options {
+	return func(mailer *Mailer) {
+		mailer.netDial = dial
+	}
+}
+
+// withTLSClient stubs the implicit TLS handshake of the Mailer.
+func withTLSClient(tlsClient func(conn net.Conn, config *tls.Config) *tls.Conn) Options {
+	return func(mailer *Mailer) {
+		mailer.tlsClient = tlsClient
+	}
+}
+
+// withPlainAuth stubs the PLAIN mechanism selected by the Mailer.
+func withPlainAuth(plainAuth func(identity, username, password, host string) auth) Options {
+	return func(mailer *Mailer) {
+		mailer.plainAuth = plainAuth
+	}
+}
+
+// withCRAMMD5Auth stubs the CRAM-MD5 mechanism selected by the Mailer.
+func withCRAMMD5Auth(cramMD5Auth func(username, secret string) smtp.Auth) Options {
+	return func(mailer *Mailer) {
+		mailer.cramMD5Auth = cramMD5Auth
+	}
+}
+
+// withClock stubs the clock of the Mailer.
+func withClock(clock func() time.Time) Options {
+	return func(mailer *Mailer) {
+		mailer.clock = clock
+	}
+}
+
+// withRandRead stubs the random source of the Message-ID headers generated by the Mailer.
+func withRandRead(randRead func(b []byte) (int, error)) Options {
+	return func(mailer *Mailer) {
+		mailer.randRead = randRead
+	}
+}
@@ -0,0 +1,152 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// Address is a mail address with an optional display name, e.g. "Nawaf <nawaf@example.com>".
+// Message fields hold addresses as strings, use String or Addresses to fill them from Address values.
+type Address struct {
+	// Name is the display name, it may contain non-ASCII characters.
+	Name string
+	// Email is the bare address used in the SMTP envelope.
+	Email string
+}
+
+// String formats the address for use in Message fields and headers.
+// A display name is quoted or RFC 2047 encoded as needed, an address without one is returned as is.
+func (a Address) String() string {
+	if a.Name == "" {
+		return a.Email
+	}
+	return (&mail.Address{Name: a.Name, Address: a.Email}).String()
+}
+
+// AddressError reports an invalid address of a Message field.
+type AddressError struct {
+	// Field is the Message field holding the address, e.g. "recipient" or "cc".
+	Field string
+	// Address is the invalid address as given.
+	Address string
+	err     error
+}
+
+// Error implements the error interface.
+func (e *AddressError) Error() string {
+	return fmt.Sprintf("given %s is invalid %s email: %v", e.Address, e.Field, e.err)
+}
+
+// Unwrap returns the parsing error.
+func (e *AddressError) Unwrap() error {
+	return e.err
+}
+
+// ParseAddress parses a single RFC 5322 address, e.g. "Nawaf <nawaf@example.com>" or "nawaf@example.com".
+func ParseAddress(s string) (Address, error) {
+	addr, err := mail.ParseAddress(s)
+	if err != nil {
+		return Address{}, err
+	}
+	return Address{Name: addr.Name, Email: addr.Address}, nil
+}
+
+// ParseAddressList parses a comma separated list of addresses.
+// Every address is parsed on its own, so the returned error reports each invalid address as an *AddressError,
+// while the valid ones are still returned.
+func ParseAddressList(s string) ([]Address, error) {
+	var (
+		addrs []Address
+		errs  []error
+	)
+	for _, part := range splitAddressList(s) {
+		addr, err := ParseAddress(part)
+		if err != nil {
+			errs = append(errs, &AddressError{Field: "list", Address: part, err: err})
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, errors.Join(errs...)
+}
+
+// Addresses formats the given addresses, to be assigned to Message.Recipients, Message.Cc or Message.Bcc.
+func Addresses(addrs ...Address) []string {
+	list := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		list = append(list, a.String())
+	}
+	return list
+}
+
+// EnvelopeAddress returns the bare address of s as used in MAIL and RCPT commands,
+// s is returned unchanged when it cannot be parsed.
+func EnvelopeAddress(s string) string {
+	if addr, err := mail.ParseAddress(s); err == nil {
+		return addr.Address
+	}
+	return s
+}
+
+// validateAddresses validates every address of a Message field and returns an *AddressError for each invalid one.
+func validateAddresses(field string, list []string) []error {
+	var errs []error
+	for _, a := range list {
+		if _, err := mail.ParseAddress(a); err != nil {
+			errs = append(errs, &AddressError{Field: field, Address: a, err: err})
+		}
+	}
+	return errs
+}
+
+// formatAddressList formats the addresses of a header, re-encoding display names that need it.
+// Addresses that cannot be parsed are written as given.
+func formatAddressList(list []string) string {
+	formatted := make([]string, 0, len(list))
+	for _, a := range list {
+		if addr, err := ParseAddress(a); err == nil {
+			a = addr.String()
+		}
+		formatted = append(formatted, a)
+	}
+	return strings.Join(formatted, separator)
+}
+
+// splitAddressList splits a comma separated address list, ignoring commas within quoted display names or angle brackets.
+func splitAddressList(s string) []string {
+	var (
+		parts   []string
+		quoted  bool
+		angle   bool
+		escaped bool
+		start   int
+	)
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && quoted:
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == '<' && !quoted:
+			angle = true
+		case r == '>' && !quoted:
+			angle = false
+		case r == ',' && !quoted && !angle:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, s[start:])
+
+	trimmed := parts[:0]
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			trimmed = append(trimmed, p)
+		}
+	}
+	return trimmed
+}
@@ -0,0 +1,91 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddress_String(t *testing.T) {
+	tests := map[string]struct {
+		address  Address
+		expected string
+	}{
+		"should return the bare address without display name": {
+			address:  Address{Email: testEmail},
+			expected: testEmail,
+		},
+		"should quote ascii display name": {
+			address:  Address{Name: "Go Mailer", Email: testEmail},
+			expected: `"Go Mailer" <test.usr@smtp.com>`,
+		},
+		"should encode non-ascii display name": {
+			address:  Address{Name: "نواف", Email: testEmail},
+			expected: "=?utf-8?q?=D9=86=D9=88=D8=A7=D9=81?= <test.usr@smtp.com>",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, tc.address.String())
+		})
+	}
+}
+
+func TestParseAddressList(t *testing.T) {
+	tests := map[string]struct {
+		list          string
+		expected      []Address
+		expectedError error
+	}{
+		"should parse addresses with and without display names": {
+			list: `"Doe, John" <john@example.com>, ahmad@example.com,`,
+			expected: []Address{
+				{Name: "Doe, John", Email: "john@example.com"},
+				{Email: "ahmad@example.com"},
+			},
+		},
+		"should report every invalid address and keep the valid ones": {
+			list:     "invalid, ahmad@example.com, also@",
+			expected: []Address{{Email: "ahmad@example.com"}},
+			expectedError: errors.Join(
+				&AddressError{Field: "list", Address: "invalid", err: fmt.Errorf("mail: missing '@' or angle-addr")},
+				&AddressError{Field: "list", Address: "also@", err: fmt.Errorf("mail: missing '@' or angle-addr")},
+			),
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			addrs, err := ParseAddressList(tc.list)
+			assert.Equal(t, tc.expected, addrs)
+			assert.Equal(t, tc.expectedError, err)
+		})
+	}
+}
+
+func TestEnvelopeAddress(t *testing.T) {
+	t.Run("should strip display name", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, testEmail, EnvelopeAddress(Address{Name: "Go Mailer", Email: testEmail}.String()))
+		assert.Equal(t, testEmail, EnvelopeAddress(testEmail))
+		assert.Equal(t, "invalid", EnvelopeAddress("invalid"))
+	})
+}
+
+func TestMessage_EncodeAddresses(t *testing.T) {
+	t.Run("should encode display names of address headers", func(t *testing.T) {
+		t.Parallel()
+		msg := NewMessage()
+		msg.From = "Zoë <from@example.com>"
+		msg.Recipients = Addresses(Address{Name: "Go Mailer", Email: testEmail}, Address{Email: "to@example.com"})
+
+		encoded, err := msg.Encode()
+		assert.Nil(t, err)
+		assert.True(t, strings.Contains(string(encoded), "From: =?utf-8?q?Zo=C3=AB?= <from@example.com>\r\n"))
+		assert.True(t, strings.Contains(string(encoded), "To: \"Go Mailer\" <test.usr@smtp.com>, to@example.com\r\n"))
+	})
+}
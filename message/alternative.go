@@ -0,0 +1,36 @@
+package message
+
+import (
+	"fmt"
+	"mime"
+	"net/mail"
+	"net/textproto"
+)
+
+// Alternative is an additional version of the message content, sent along with the body and HTML body.
+type Alternative struct {
+	// MIMEType is the Content-Type of the part, e.g. "text/markdown; charset=UTF-8" or "application/json".
+	MIMEType string
+	// Content of the part, encoded like the bodies: as is when ASCII, quoted-printable or 8bit otherwise.
+	Content string
+	// Headers are additional header fields of the part, e.g. a schema identifier of a machine-readable payload.
+	// Content-Type and Content-Transfer-Encoding are set by the encoder and cannot be given.
+	Headers mail.Header
+}
+
+// validate validates the media type and the header field names of the alternative.
+func (a Alternative) validate() error {
+	if _, _, err := mime.ParseMediaType(a.MIMEType); err != nil {
+		return fmt.Errorf("invalid alternative media type %q: %w", a.MIMEType, err)
+	}
+	for k := range a.Headers {
+		if !validHeaderName(k) {
+			return fmt.Errorf("invalid header field name %q of alternative %s", k, a.MIMEType)
+		}
+		switch textproto.CanonicalMIMEHeaderKey(k) {
+		case "Content-Type", "Content-Transfer-Encoding":
+			return fmt.Errorf("header %s of alternative %s is set by the encoder", k, a.MIMEType)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package message
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage_EncodeAlternatives(t *testing.T) {
+	markdown := Alternative{MIMEType: "text/markdown; charset=UTF-8", Content: "# Hello", Headers: map[string][]string{"X-Variant": {"markdown"}}}
+	tests := map[string]struct {
+		input            Message
+		opts             []EncodeOption
+		expectedContains []string
+		expectErr        bool
+		expectedErr      error
+	}{
+		"should send a lone alternative as the message entity along with its headers": {
+			input:            Message{From: testEmail, Recipients: []string{testEmail}, Alternatives: []Alternative{markdown}},
+			expectedContains: []string{"Content-Type: text/markdown; charset=UTF-8\r\nTo: " + testEmail + "\r\nX-Variant: markdown\r\n\r\n# Hello\r\n"},
+		},
+		"should send the alternatives before the body": {
+			input: Message{From: testEmail, Recipients: []string{testEmail}, Body: "Hello", Alternatives: []Alternative{markdown}},
+			expectedContains: []string{
+				"Content-Type: multipart/alternative; boundary=ALT-BOUNDARY\r\n",
+				"--ALT-BOUNDARY\r\nContent-Type: text/markdown; charset=UTF-8\r\nContent-Transfer-Encoding: 7bit\r\nX-Variant: markdown\r\n\r\n# Hello\r\n\r\n--ALT-BOUNDARY\r\nContent-Type: text/plain",
+			},
+		},
+		"should refuse an invalid media type": {
+			input:     Message{From: testEmail, Recipients: []string{testEmail}, Alternatives: []Alternative{{MIMEType: "markdown;;", Content: "# Hello"}}},
+			expectErr: true,
+		},
+		"should refuse headers set by the encoder": {
+			input: Message{From: testEmail, Recipients: []string{testEmail}, Alternatives: []Alternative{
+				{MIMEType: "application/json", Content: "{}", Headers: map[string][]string{"content-transfer-encoding": {"base64"}}},
+			}},
+			expectErr: true,
+		},
+		"should refuse a bare LF of an alternative in strict mode": {
+			input:       Message{From: testEmail, Recipients: []string{testEmail}, Body: "Hello", Alternatives: []Alternative{{MIMEType: "text/markdown", Content: "# Hello\nWorld"}}},
+			opts:        []EncodeOption{WithStrictLineBreaks()},
+			expectErr:   true,
+			expectedErr: ErrBareLineBreak,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := tc.input.Encode(append([]EncodeOption{WithDeterministicBoundaries(testSeed)}, tc.opts...)...)
+			if tc.expectErr {
+				assert.NotNil(t, err)
+				if tc.expectedErr != nil {
+					assert.ErrorIs(t, err, tc.expectedErr)
+				}
+				return
+			}
+			assert.Nil(t, err)
+			for _, expected := range tc.expectedContains {
+				assert.Contains(t, string(got), withTestBoundaries(expected))
+			}
+		})
+	}
+	t.Run("should require 8bit MIME for non-ASCII alternatives", func(t *testing.T) {
+		t.Parallel()
+		msg := Message{Body: "ascii", Alternatives: []Alternative{{MIMEType: "text/markdown", Content: strings.Repeat("Grüße", 2)}}}
+		assert.True(t, msg.Requires8BitMIME())
+	})
+}
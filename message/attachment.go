@@ -0,0 +1,59 @@
+package message
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultMIMEType is the MIME type of attachments whose type cannot be detected (RFC 2046 section 4.5.1).
+const defaultMIMEType = "application/octet-stream"
+
+// AttachFile returns an attachment of the file at path, named after its base name,
+// its MIME type detected from the extension or the content.
+func AttachFile(path string) (Attachment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to attach file: %w", err)
+	}
+	return newAttachment(filepath.Base(path), data), nil
+}
+
+// AttachReader returns an attachment named name of the content read from r until EOF,
+// its MIME type detected from the extension of name or the content.
+func AttachReader(name string, r io.Reader) (Attachment, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to attach %q: %w", name, err)
+	}
+	return newAttachment(name, data), nil
+}
+
+// newAttachment returns an attachment of data named name, its MIME type detected.
+func newAttachment(name string, data []byte) Attachment {
+	return Attachment{Filename: name, Data: data, MIMEType: detectMIMEType(name, data)}
+}
+
+// detectMIMEType returns the MIME type of the file named name from its extension, sniffing the content
+// when the extension is unknown (see http.DetectContentType), application/octet-stream when neither tells.
+func detectMIMEType(name string, data []byte) string {
+	if t := mime.TypeByExtension(strings.ToLower(filepath.Ext(name))); t != "" {
+		return t
+	}
+	if len(data) == 0 {
+		return defaultMIMEType
+	}
+	return http.DetectContentType(data)
+}
+
+// mediaType returns the MIME type of the attachment, detected when empty.
+func (a Attachment) mediaType() string {
+	if a.MIMEType != "" {
+		return a.MIMEType
+	}
+	return detectMIMEType(a.Filename, a.Data)
+}
@@ -0,0 +1,77 @@
+package message
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachment_DetectMIMEType(t *testing.T) {
+	tests := map[string]struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		"should detect the type from the extension": {
+			name:     "report.PDF",
+			data:     []byte("not sniffed"),
+			expected: "application/pdf",
+		},
+		"should sniff the content of unknown extensions": {
+			name:     "logo",
+			data:     []byte("\x89PNG\r\n\x1a\n"),
+			expected: "image/png",
+		},
+		"should fall back to application/octet-stream without content": {
+			name:     "empty",
+			expected: "application/octet-stream",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, detectMIMEType(tc.name, tc.data))
+		})
+	}
+	t.Run("should encode the detected type of attachments without MIMEType", func(t *testing.T) {
+		t.Parallel()
+		msg := Message{From: testEmail, Recipients: []string{testEmail}, Body: "body",
+			Attachments: []Attachment{{Filename: "logo.png", Data: []byte("png")}}}
+		got, err := msg.Encode()
+		assert.Nil(t, err)
+		assert.Contains(t, string(got), "Content-Type: image/png; name=\"logo.png\"\r\n")
+	})
+}
+
+func TestAttachFile(t *testing.T) {
+	t.Run("should attach the file named after its base name", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "logo.png")
+		assert.Nil(t, os.WriteFile(path, []byte("png"), 0o600))
+
+		got, err := AttachFile(path)
+		assert.Nil(t, err)
+		assert.Equal(t, Attachment{Filename: "logo.png", Data: []byte("png"), MIMEType: "image/png"}, got)
+	})
+	t.Run("should fail to attach a missing file", func(t *testing.T) {
+		_, err := AttachFile(filepath.Join(t.TempDir(), "missing.png"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestAttachReader(t *testing.T) {
+	t.Run("should attach the content read", func(t *testing.T) {
+		got, err := AttachReader("report.pdf", strings.NewReader("%PDF"))
+		assert.Nil(t, err)
+		assert.Equal(t, Attachment{Filename: "report.pdf", Data: []byte("%PDF"), MIMEType: "application/pdf"}, got)
+	})
+	t.Run("should fail when the reader fails", func(t *testing.T) {
+		readErr := errors.New("dummy error")
+		_, err := AttachReader("report.pdf", iotest.ErrReader(readErr))
+		assert.ErrorIs(t, err, readErr)
+	})
+}
@@ -0,0 +1,187 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrContentTypeNotAllowed is returned by AttachURL when the content is not of a type given to WithURLContentTypes.
+var ErrContentTypeNotAllowed = errors.New("attachment content type is not allowed")
+
+const (
+	// defaultURLMaxSize is the maximum size of the content downloaded by AttachURL, the 25 MB limit of common providers.
+	defaultURLMaxSize = 25 << 20
+	// defaultURLTimeout bounds the download of AttachURL.
+	defaultURLTimeout = 30 * time.Second
+)
+
+// URLOption configures how AttachURL downloads the content of an attachment.
+type URLOption func(*urlConfig)
+
+// urlConfig holds the configuration applied by URLOption.
+type urlConfig struct {
+	client       *http.Client
+	maxSize      int64
+	timeout      time.Duration
+	contentTypes []string
+	filename     string
+}
+
+// WithURLClient downloads the content with the client, e.g. one signing requests to an object storage,
+// http.DefaultClient is used by default.
+func WithURLClient(c *http.Client) URLOption {
+	return func(cfg *urlConfig) {
+		cfg.client = c
+	}
+}
+
+// WithURLMaxSize limits the content to size bytes, 25 MB by default. The download fails with ErrAttachmentTooLarge
+// once the content exceeds it, or before it starts when the Content-Length does. A size of zero or less removes the limit.
+func WithURLMaxSize(size int64) URLOption {
+	return func(cfg *urlConfig) {
+		cfg.maxSize = size
+	}
+}
+
+// WithURLTimeout bounds the download, including reading the content, 30 seconds by default.
+// A timeout of zero or less removes the bound, the deadline of the context still applies.
+func WithURLTimeout(timeout time.Duration) URLOption {
+	return func(cfg *urlConfig) {
+		cfg.timeout = timeout
+	}
+}
+
+// WithURLContentTypes only allows content of the media types, e.g. "application/pdf" or "image/*", the download
+// fails with ErrContentTypeNotAllowed otherwise. Content of any type is allowed by default.
+func WithURLContentTypes(types ...string) URLOption {
+	return func(cfg *urlConfig) {
+		cfg.contentTypes = append(cfg.contentTypes, types...)
+	}
+}
+
+// WithURLFilename names the attachment, instead of the filename of the Content-Disposition of the response
+// or the last segment of the URL path.
+func WithURLFilename(name string) URLOption {
+	return func(cfg *urlConfig) {
+		cfg.filename = name
+	}
+}
+
+// allows reports whether the configuration allows content of the media type.
+func (cfg urlConfig) allows(mediaType string) bool {
+	if len(cfg.contentTypes) == 0 {
+		return true
+	}
+	for _, t := range cfg.contentTypes {
+		if prefix, ok := strings.CutSuffix(t, "/*"); ok {
+			if strings.HasPrefix(mediaType, strings.ToLower(prefix)+"/") {
+				return true
+			}
+		} else if strings.EqualFold(t, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// AttachURL returns an attachment of the content downloaded from the http or https URL rawURL, e.g. an invoice
+// kept in an object storage. The download is bounded in size and time, see WithURLMaxSize and WithURLTimeout.
+// The attachment is named after the Content-Disposition of the response or the URL path, its MIME type is the
+// Content-Type of the response, or detected like AttachReader when the response does not tell it.
+func AttachURL(ctx context.Context, rawURL string, opts ...URLOption) (Attachment, error) {
+	cfg := urlConfig{client: http.DefaultClient, maxSize: defaultURLMaxSize, timeout: defaultURLTimeout}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	a, err := downloadAttachment(ctx, rawURL, cfg)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to attach %q: %w", rawURL, err)
+	}
+	return a, nil
+}
+
+// downloadAttachment implements AttachURL for the configuration.
+func downloadAttachment(ctx context.Context, rawURL string, cfg urlConfig) (Attachment, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Attachment{}, fmt.Errorf("unsupported url scheme %q", u.Scheme)
+	}
+	if cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Attachment{}, err
+	}
+	resp, err := cfg.client.Do(req)
+	if err != nil {
+		return Attachment{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Attachment{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if cfg.maxSize > 0 && resp.ContentLength > cfg.maxSize {
+		return Attachment{}, fmt.Errorf("%w: content length %d exceeds %d bytes", ErrAttachmentTooLarge, resp.ContentLength, cfg.maxSize)
+	}
+
+	// application/octet-stream tells nothing about the content, its type is detected.
+	var mimeType string
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err == nil && mediaType != defaultMIMEType {
+		if !cfg.allows(mediaType) {
+			return Attachment{}, fmt.Errorf("%w: %s", ErrContentTypeNotAllowed, mediaType)
+		}
+		mimeType = mime.FormatMediaType(mediaType, params)
+	}
+
+	var body io.Reader = resp.Body
+	if cfg.maxSize > 0 {
+		body = io.LimitReader(resp.Body, cfg.maxSize+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if cfg.maxSize > 0 && int64(len(data)) > cfg.maxSize {
+		return Attachment{}, fmt.Errorf("%w: content exceeds %d bytes", ErrAttachmentTooLarge, cfg.maxSize)
+	}
+
+	name := cfg.filename
+	if name == "" {
+		name = urlFilename(u, resp.Header.Get("Content-Disposition"))
+	}
+	if mimeType == "" {
+		mimeType = detectMIMEType(name, data)
+		detected, _, _ := mime.ParseMediaType(mimeType)
+		if !cfg.allows(detected) && (mediaType != defaultMIMEType || !cfg.allows(mediaType)) {
+			return Attachment{}, fmt.Errorf("%w: %s", ErrContentTypeNotAllowed, detected)
+		}
+	}
+	return Attachment{Filename: name, Data: data, MIMEType: mimeType}, nil
+}
+
+// urlFilename returns the filename of the Content-Disposition, or the last segment of the URL path,
+// "attachment" when neither names the content.
+func urlFilename(u *url.URL, disposition string) string {
+	if _, params, err := mime.ParseMediaType(disposition); err == nil && params["filename"] != "" {
+		return path.Base(strings.ReplaceAll(params["filename"], `\`, "/"))
+	}
+	if name := path.Base(u.Path); name != "/" && name != "." {
+		return name
+	}
+	return "attachment"
+}
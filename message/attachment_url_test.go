@@ -0,0 +1,124 @@
+package message
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachURL(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/invoices/inv-1.pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		_, _ = w.Write([]byte("%PDF-1.7"))
+	})
+	mux.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="C:\reports\report.csv"`)
+		_, _ = w.Write([]byte("a,b\n1,2\n"))
+	})
+	mux.HandleFunc("/logo", func(w http.ResponseWriter, r *http.Request) {
+		w.Header()["Content-Type"] = nil
+		_, _ = w.Write([]byte("\x89PNG\r\n\x1a\n"))
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("a", 2048)))
+	})
+	mux.HandleFunc("/streamed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		for range 4 {
+			_, _ = w.Write([]byte(strings.Repeat("a", 512)))
+			w.(http.Flusher).Flush()
+		}
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	tests := map[string]struct {
+		path        string
+		opts        []URLOption
+		expected    Attachment
+		expectedErr error
+		errContains string
+	}{
+		"should attach the content named after the url path": {
+			path:     "/invoices/inv-1.pdf",
+			expected: Attachment{Filename: "inv-1.pdf", Data: []byte("%PDF-1.7"), MIMEType: "application/pdf"},
+		},
+		"should name the attachment after the content disposition and detect its type": {
+			path:     "/download",
+			expected: Attachment{Filename: "report.csv", Data: []byte("a,b\n1,2\n"), MIMEType: "text/csv; charset=utf-8"},
+		},
+		"should sniff the type of content without content type": {
+			path:     "/logo",
+			opts:     []URLOption{WithURLContentTypes("image/*")},
+			expected: Attachment{Filename: "logo", Data: []byte("\x89PNG\r\n\x1a\n"), MIMEType: "image/png"},
+		},
+		"should name the attachment as given": {
+			path:     "/invoices/inv-1.pdf",
+			opts:     []URLOption{WithURLFilename("invoice.pdf"), WithURLContentTypes("application/pdf")},
+			expected: Attachment{Filename: "invoice.pdf", Data: []byte("%PDF-1.7"), MIMEType: "application/pdf"},
+		},
+		"should refuse content of another type": {
+			path:        "/invoices/inv-1.pdf",
+			opts:        []URLOption{WithURLContentTypes("image/*", "text/plain")},
+			expectedErr: ErrContentTypeNotAllowed,
+		},
+		"should refuse sniffed content of another type": {
+			path:        "/logo",
+			opts:        []URLOption{WithURLContentTypes("application/pdf")},
+			expectedErr: ErrContentTypeNotAllowed,
+		},
+		"should refuse content whose length exceeds the maximum size": {
+			path:        "/large",
+			opts:        []URLOption{WithURLMaxSize(1024)},
+			expectedErr: ErrAttachmentTooLarge,
+			errContains: "content length 2048 exceeds 1024 bytes",
+		},
+		"should refuse streamed content exceeding the maximum size": {
+			path:        "/streamed",
+			opts:        []URLOption{WithURLMaxSize(1024)},
+			expectedErr: ErrAttachmentTooLarge,
+			errContains: "content exceeds 1024 bytes",
+		},
+		"should fail on unexpected status": {
+			path:        "/missing",
+			errContains: "unexpected status 404 Not Found",
+		},
+		"should time out slow downloads": {
+			path:        "/slow",
+			opts:        []URLOption{WithURLTimeout(50 * time.Millisecond)},
+			expectedErr: context.DeadlineExceeded,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := AttachURL(context.Background(), server.URL+tc.path, tc.opts...)
+			if tc.expectedErr == nil && tc.errContains == "" {
+				require.Nil(t, err)
+				assert.Equal(t, tc.expected, got)
+				return
+			}
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			}
+			assert.ErrorContains(t, err, tc.errContains)
+			assert.Equal(t, Attachment{}, got)
+		})
+	}
+
+	t.Run("should refuse urls of other schemes", func(t *testing.T) {
+		_, err := AttachURL(context.Background(), "file:///etc/passwd")
+		assert.ErrorContains(t, err, `failed to attach "file:///etc/passwd": unsupported url scheme "file"`)
+	})
+}
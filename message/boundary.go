@@ -0,0 +1,64 @@
+package message
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"math/rand/v2"
+)
+
+// boundaries are the boundaries of the multipart entities of an encoded message (RFC 2046 section 5.1.1).
+type boundaries struct {
+	mixed, alternative, related string
+}
+
+// WithDeterministicBoundaries derives the boundaries of the multipart entities from seed instead of generating random
+// ones for every encoding, so a message encoded twice with the same seed gives the same bytes, e.g. for golden-file
+// tests snapshotting the encoded output. Random boundaries cannot occur in the content, deterministic ones only when
+// it is crafted knowing the seed.
+func WithDeterministicBoundaries(seed int64) EncodeOption {
+	return func(cfg *encodeConfig) {
+		cfg.boundarySeed = &seed
+	}
+}
+
+// newBoundaries returns the boundaries of a message, random or derived from seed when not nil.
+func newBoundaries(seed *int64) boundaries {
+	var src io.Reader = crand.Reader
+	if seed != nil {
+		var key [32]byte
+		binary.LittleEndian.PutUint64(key[:], uint64(*seed))
+		src = rand.NewChaCha8(key)
+	}
+	return boundaries{
+		mixed:       newBoundary(src, "mixed"),
+		alternative: newBoundary(src, "alt"),
+		related:     newBoundary(src, "related"),
+	}
+}
+
+// newBoundary returns a boundary made of the kind of entity and 12 bytes read from src, hex encoded, short enough
+// for the Content-Type of the multipart/mixed and multipart/alternative entities to fit in 76 characters.
+// It only contains characters that are not tspecials, so it is not quoted in the Content-Type.
+func newBoundary(src io.Reader, kind string) string {
+	var b [12]byte
+	// neither crypto/rand nor ChaCha8 fail.
+	_, _ = io.ReadFull(src, b[:])
+	return kind + "-" + hex.EncodeToString(b[:])
+}
+
+// mixedContentType returns the Content-Type of the multipart/mixed entity.
+func (b boundaries) mixedContentType() string {
+	return "multipart/mixed; boundary=" + b.mixed
+}
+
+// alternativeContentType returns the Content-Type of the multipart/alternative entity.
+func (b boundaries) alternativeContentType() string {
+	return "multipart/alternative; boundary=" + b.alternative
+}
+
+// relatedContentType returns the Content-Type of the multipart/related entity of the HTML body (RFC 2387).
+func (b boundaries) relatedContentType() string {
+	return `multipart/related; type="text/html"; boundary=` + b.related
+}
@@ -0,0 +1,72 @@
+package message
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testSeed derives the boundaries of the messages encoded by the tests.
+const testSeed = 42
+
+// testBoundaries are the boundaries derived from testSeed.
+var testBoundaries = seededBoundaries(testSeed)
+
+// seededBoundaries returns the boundaries derived from seed.
+func seededBoundaries(seed int64) boundaries {
+	return newBoundaries(&seed)
+}
+
+// withTestBoundaries replaces the BOUNDARY, ALT-BOUNDARY and RELATED-BOUNDARY placeholders of the expected output
+// with testBoundaries.
+func withTestBoundaries(s string) string {
+	return strings.NewReplacer(
+		"RELATED-BOUNDARY", testBoundaries.related,
+		"ALT-BOUNDARY", testBoundaries.alternative,
+		"BOUNDARY", testBoundaries.mixed,
+	).Replace(s)
+}
+
+func TestWithDeterministicBoundaries(t *testing.T) {
+	msg := Message{
+		From: testEmail, Recipients: []string{testEmail}, Body: "hello", HTMLBody: "<p>hello</p>",
+		Attachments: []Attachment{{Filename: "f1", Data: []byte("data"), MIMEType: "application/pdf"}},
+	}
+
+	t.Run("should encode the same message with the same seed", func(t *testing.T) {
+		first, err := msg.Encode(WithDeterministicBoundaries(testSeed))
+		assert.Nil(t, err)
+		second, err := msg.Encode(WithDeterministicBoundaries(testSeed))
+		assert.Nil(t, err)
+		assert.Equal(t, first, second)
+		assert.Contains(t, string(first), "Content-Type: "+testBoundaries.mixedContentType()+"\r\n")
+		assert.Contains(t, string(first), "Content-Type: "+testBoundaries.alternativeContentType()+"\r\n")
+	})
+
+	t.Run("should derive other boundaries from another seed", func(t *testing.T) {
+		other := seededBoundaries(7)
+		assert.NotEqual(t, testBoundaries, other)
+		encoded, err := msg.Encode(WithDeterministicBoundaries(7))
+		assert.Nil(t, err)
+		assert.Contains(t, string(encoded), "--"+other.mixed+"--\r\n")
+	})
+
+	t.Run("should generate random boundaries by default", func(t *testing.T) {
+		first, err := msg.Encode()
+		assert.Nil(t, err)
+		second, err := msg.Encode()
+		assert.Nil(t, err)
+		assert.NotEqual(t, first, second)
+		assert.Equal(t, len(first), len(second))
+	})
+
+	t.Run("should generate distinct boundaries without tspecials", func(t *testing.T) {
+		b := newBoundaries(nil)
+		assert.Len(t, map[string]bool{b.mixed: true, b.alternative: true, b.related: true}, 3)
+		for _, boundary := range []string{b.mixed, b.alternative, b.related} {
+			assert.LessOrEqual(t, len(boundary), 70)
+			assert.False(t, strings.ContainsAny(boundary, "()<>@,;:\\\"/[]?= "), boundary)
+		}
+	})
+}
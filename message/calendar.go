@@ -0,0 +1,196 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// calendarProductID identifies gomailer as the producer of calendar objects (RFC 5545 section 3.7.3).
+	calendarProductID = "-//gomailer//gomailer//EN"
+	// calendarTimeFormat is the UTC DATE-TIME form of RFC 5545 section 3.3.5.
+	calendarTimeFormat = "20060102T150405Z"
+	// maxCalendarLineLength is the number of octets content lines are folded at (RFC 5545 section 3.1).
+	maxCalendarLineLength = 75
+)
+
+// CalendarMethod is the iTIP method of a calendar invitation (RFC 5546 section 1.4).
+type CalendarMethod string
+
+const (
+	// CalendarRequest invites the attendees to the event, or updates it.
+	CalendarRequest CalendarMethod = "REQUEST"
+	// CalendarCancel cancels the event.
+	CalendarCancel CalendarMethod = "CANCEL"
+	// CalendarPublish shares the event without asking for replies.
+	CalendarPublish CalendarMethod = "PUBLISH"
+)
+
+// CalendarEvent is a meeting invitation sent as a text/calendar alternative of the message,
+// so clients such as Outlook and Gmail render it natively with accept and decline buttons.
+type CalendarEvent struct {
+	// Method of the invitation, CalendarRequest when empty.
+	Method CalendarMethod
+	// UID identifies the event, updates and cancellations must reuse the UID of the invitation.
+	UID string
+	// Sequence is the revision of the event, it must be incremented with every update or cancellation.
+	Sequence int
+	// Stamp is when the invitation was created, the time of encoding when zero.
+	Stamp time.Time
+	// Start and End of the event, sent in UTC.
+	Start, End time.Time
+	// Summary is the title of the event.
+	Summary string
+	// Description details the event.
+	Description string
+	// Location of the event, e.g. a room or a meeting URL.
+	Location string
+	// Organizer is the address of the organizer, replies are sent to it. It defaults to the From address of the message.
+	Organizer string
+	// Attendees are the addresses of the invited attendees.
+	Attendees []string
+}
+
+// method returns the method of the invitation, CalendarRequest when empty.
+func (e CalendarEvent) method() CalendarMethod {
+	if e.Method == "" {
+		return CalendarRequest
+	}
+	return e.Method
+}
+
+// validate validates the calendar event.
+func (e CalendarEvent) validate() error {
+	var errs []error
+	switch e.method() {
+	case CalendarRequest, CalendarCancel, CalendarPublish:
+	default:
+		errs = append(errs, fmt.Errorf("unknown calendar method %q", e.Method))
+	}
+	if e.UID == "" {
+		errs = append(errs, errors.New("calendar event uid cannot be empty"))
+	}
+	if e.Start.IsZero() {
+		errs = append(errs, errors.New("calendar event start cannot be empty"))
+	} else if !e.End.IsZero() && e.End.Before(e.Start) {
+		errs = append(errs, errors.New("calendar event cannot end before it starts"))
+	}
+	if e.Organizer != "" {
+		if _, err := mail.ParseAddress(e.Organizer); err != nil {
+			errs = append(errs, fmt.Errorf("invalid calendar organizer: %w", err))
+		}
+	}
+	errs = append(errs, validateAddresses("calendar attendee", e.Attendees)...)
+	return errors.Join(errs...)
+}
+
+// ics returns the event as an iCalendar object (RFC 5545) of the from address, its lines terminated by crlf.
+func (e CalendarEvent) ics(from string) string {
+	stamp := e.Stamp
+	if stamp.IsZero() {
+		stamp = time.Now()
+	}
+	organizer := e.Organizer
+	if organizer == "" {
+		organizer = from
+	}
+	status := "CONFIRMED"
+	if e.method() == CalendarCancel {
+		status = "CANCELLED"
+	}
+
+	var b strings.Builder
+	line := func(s string) {
+		b.WriteString(foldCalendarLine(s))
+		b.WriteString(crlf)
+	}
+	line("BEGIN:VCALENDAR")
+	line("PRODID:" + calendarProductID)
+	line("VERSION:2.0")
+	line("METHOD:" + string(e.method()))
+	line("BEGIN:VEVENT")
+	line("UID:" + escapeCalendarText(e.UID))
+	line("SEQUENCE:" + strconv.Itoa(e.Sequence))
+	line("DTSTAMP:" + stamp.UTC().Format(calendarTimeFormat))
+	line("DTSTART:" + e.Start.UTC().Format(calendarTimeFormat))
+	if !e.End.IsZero() {
+		line("DTEND:" + e.End.UTC().Format(calendarTimeFormat))
+	}
+	if e.Summary != "" {
+		line("SUMMARY:" + escapeCalendarText(e.Summary))
+	}
+	if e.Description != "" {
+		line("DESCRIPTION:" + escapeCalendarText(e.Description))
+	}
+	if e.Location != "" {
+		line("LOCATION:" + escapeCalendarText(e.Location))
+	}
+	line("ORGANIZER" + calendarAddress(organizer, ""))
+	for _, a := range e.Attendees {
+		line("ATTENDEE" + calendarAddress(a, ";ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE"))
+	}
+	line("STATUS:" + status)
+	line("END:VEVENT")
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+// calendarTextReplacer escapes TEXT values (RFC 5545 section 3.3.11), line breaks are escaped as \n.
+var calendarTextReplacer = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// escapeCalendarText escapes a TEXT value.
+func escapeCalendarText(s string) string {
+	return calendarTextReplacer.Replace(s)
+}
+
+// calendarAddress returns the parameters and the mailto value of an address property,
+// the display name given as CN parameter, followed by params.
+func calendarAddress(a, params string) string {
+	name, email := "", EnvelopeAddress(a)
+	if addr, err := ParseAddress(a); err == nil {
+		name = addr.Name
+	}
+	if name != "" {
+		// parameter values are quoted and cannot contain quotes (RFC 5545 section 3.1).
+		params = `;CN="` + strings.ReplaceAll(name, `"`, "") + `"` + params
+	}
+	return params + ":mailto:" + email
+}
+
+// foldCalendarLine folds a content line into lines of at most maxCalendarLineLength octets,
+// continuation lines start with a space (RFC 5545 section 3.1). Characters are not split.
+func foldCalendarLine(s string) string {
+	if len(s) <= maxCalendarLineLength {
+		return s
+	}
+	var b strings.Builder
+	limit := maxCalendarLineLength
+	for len(s) > limit {
+		n := limit
+		for n > 0 && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		b.WriteString(s[:n])
+		b.WriteString(crlf + " ")
+		s = s[n:]
+		// the leading space counts toward the length of continuation lines.
+		limit = maxCalendarLineLength - 1
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+// is7Bit reports whether the text fields of the event are ASCII.
+func (e CalendarEvent) is7Bit() bool {
+	for _, s := range append([]string{e.UID, e.Summary, e.Description, e.Location, e.Organizer}, e.Attendees...) {
+		if !is7Bit(s) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package message
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage_EncodeCalendar(t *testing.T) {
+	start := time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC)
+	event := CalendarEvent{UID: "planning-1@example.com", Start: start, End: start.Add(time.Hour), Stamp: start}
+	tests := map[string]struct {
+		input            Message
+		expectedContains []string
+		expectedErr      bool
+	}{
+		"should send a lone invitation as the message entity": {
+			input: Message{From: testEmail, Recipients: []string{testEmail}, Calendar: &event},
+			expectedContains: []string{
+				"Content-Type: text/calendar; charset=UTF-8; method=REQUEST\r\n",
+				"METHOD:REQUEST\r\n", "ORGANIZER:mailto:" + testEmail + "\r\n", "END:VCALENDAR\r\n",
+			},
+		},
+		"should send the invitation alongside the body and the attachments": {
+			input: Message{
+				From: testEmail, Recipients: []string{testEmail}, Body: "invitation", Calendar: &event,
+				Attachments: []Attachment{{Filename: "agenda.txt", MIMEType: "text/plain", Data: []byte("agenda")}},
+			},
+			expectedContains: []string{
+				"Content-Type: multipart/mixed; boundary=BOUNDARY\r\n",
+				"Content-Type: multipart/alternative; boundary=ALT-BOUNDARY\r\n",
+				"Content-Type: text/calendar; charset=UTF-8; method=REQUEST\r\n",
+			},
+		},
+		"should cancel the event": {
+			input: Message{
+				From: testEmail, Recipients: []string{testEmail}, Body: "cancelled",
+				Calendar: &CalendarEvent{Method: CalendarCancel, UID: event.UID, Sequence: 1, Start: start},
+			},
+			expectedContains: []string{"method=CANCEL\r\n", "SEQUENCE:1\r\n", "STATUS:CANCELLED\r\n"},
+		},
+		"should refuse an event without uid": {
+			input:       Message{From: testEmail, Recipients: []string{testEmail}, Calendar: &CalendarEvent{Start: start}},
+			expectedErr: true,
+		},
+		"should refuse an event ending before it starts": {
+			input: Message{
+				From: testEmail, Recipients: []string{testEmail},
+				Calendar: &CalendarEvent{UID: event.UID, Start: start, End: start.Add(-time.Hour)},
+			},
+			expectedErr: true,
+		},
+		"should refuse an invalid attendee": {
+			input: Message{
+				From: testEmail, Recipients: []string{testEmail},
+				Calendar: &CalendarEvent{UID: event.UID, Start: start, Attendees: []string{"invalid"}},
+			},
+			expectedErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := tc.input.Encode(WithDeterministicBoundaries(testSeed))
+			if tc.expectedErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			for _, expected := range tc.expectedContains {
+				assert.Contains(t, string(got), withTestBoundaries(expected))
+			}
+		})
+	}
+}
+
+func TestCalendarEvent_ICS(t *testing.T) {
+	t.Run("should escape text values", func(t *testing.T) {
+		assert.Equal(t, `a\\b\; c\, d\ne\nf`, escapeCalendarText("a\\b; c, d\r\ne\nf"))
+	})
+	t.Run("should fold long lines without splitting characters", func(t *testing.T) {
+		line := "SUMMARY:" + strings.Repeat("Grüße ", 30)
+		folded := foldCalendarLine(line)
+		lines := strings.Split(folded, "\r\n")
+		assert.Greater(t, len(lines), 1)
+		for i, l := range lines {
+			assert.LessOrEqual(t, len(l), maxCalendarLineLength)
+			assert.True(t, utf8.ValidString(l))
+			if i > 0 {
+				assert.True(t, strings.HasPrefix(l, " "))
+			}
+		}
+		assert.Equal(t, line, strings.ReplaceAll(folded, "\r\n ", ""))
+	})
+	t.Run("should require 8bit MIME for non-ASCII events", func(t *testing.T) {
+		msg := Message{Body: "ascii", Calendar: &CalendarEvent{Summary: "Grüße"}}
+		assert.True(t, msg.Requires8BitMIME())
+	})
+}
@@ -0,0 +1,278 @@
+package message
+
+import (
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// update rewrites the golden files of the conformance suite with the current encoder output:
+//
+//	go test ./message -run TestConformance -update
+//
+// Review the diff of testdata/conformance before committing, the parsers must still accept the new output.
+var update = flag.Bool("update", false, "update the golden files of the conformance suite")
+
+// conformancePart is the structure of a MIME entity as seen by an independent parser.
+type conformancePart struct {
+	// mediaType is the media type of the entity, without parameters.
+	mediaType string
+	// charset is the charset parameter of text entities.
+	charset string
+	// filename is the filename parameter of the Content-Disposition of attachments.
+	filename string
+	// content is the decoded content of leaf entities, line breaks normalized to LF and the final one trimmed for text.
+	content string
+	// parts are the entities of multipart entities.
+	parts []conformancePart
+}
+
+// conformanceVectors are the canonical messages of the conformance suite, the encoded output of every vector
+// is stored in testdata/conformance/<name>.eml, its boundaries derived from testSeed.
+var conformanceVectors = map[string]struct {
+	msg  Message
+	opts []EncodeOption
+	want conformancePart
+}{
+	"text": {
+		msg:  Message{From: "sender@example.com", Recipients: []string{"rcpt@example.com"}, Subject: "Plain text", Body: "Hello,\nthis is plain text."},
+		want: conformancePart{mediaType: "text/plain", charset: "us-ascii", content: "Hello,\nthis is plain text."},
+	},
+	"text_utf8": {
+		msg:  Message{From: "sender@example.com", Recipients: []string{"rcpt@example.com"}, Subject: "Grüße", Body: "Grüße aus Zürich"},
+		want: conformancePart{mediaType: "text/plain", charset: "UTF-8", content: "Grüße aus Zürich"},
+	},
+	"text_7bit": {
+		msg:  Message{From: "sender@example.com", Recipients: []string{"rcpt@example.com"}, Subject: "Grüße", Body: "Grüße aus Zürich"},
+		opts: []EncodeOption{With7BitTransport()},
+		want: conformancePart{mediaType: "text/plain", charset: "UTF-8", content: "Grüße aus Zürich"},
+	},
+	"html": {
+		msg:  Message{From: "sender@example.com", Recipients: []string{"rcpt@example.com"}, Subject: "HTML", HTMLBody: `<p>Hello <a href="https://example.com">world</a></p>`},
+		want: conformancePart{mediaType: "text/html", charset: "UTF-8", content: `<p>Hello <a href="https://example.com">world</a></p>`},
+	},
+	"alternative": {
+		msg: Message{From: "sender@example.com", Recipients: []string{"rcpt@example.com"}, Subject: "Alternative", Body: "Hello", HTMLBody: "<p>Hello</p>"},
+		want: conformancePart{mediaType: "multipart/alternative", parts: []conformancePart{
+			{mediaType: "text/plain", charset: "us-ascii", content: "Hello"},
+			{mediaType: "text/html", charset: "UTF-8", content: "<p>Hello</p>"},
+		}},
+	},
+	"amp": {
+		msg: Message{
+			From: "sender@example.com", Recipients: []string{"rcpt@example.com"}, Subject: "AMP", Body: "Hello",
+			AMPBody:  `<!doctype html><html ⚡4email><body>Hello <amp-img src="https://example.com/a.png" width="1" height="1"></amp-img></body></html>`,
+			HTMLBody: "<p>Hello</p>",
+		},
+		want: conformancePart{mediaType: "multipart/alternative", parts: []conformancePart{
+			{mediaType: "text/plain", charset: "us-ascii", content: "Hello"},
+			{mediaType: "text/x-amp-html", charset: "UTF-8", content: `<!doctype html><html ⚡4email><body>Hello <amp-img src="https://example.com/a.png" width="1" height="1"></amp-img></body></html>`},
+			{mediaType: "text/html", charset: "UTF-8", content: "<p>Hello</p>"},
+		}},
+	},
+	"mixed": {
+		msg: Message{
+			From: "sender@example.com", Recipients: []string{"rcpt@example.com"}, Subject: "Mixed", Body: "See attached.",
+			Attachments: []Attachment{{Filename: "report.csv", MIMEType: "text/csv", Data: []byte("a,b\n1,2\n")}},
+		},
+		want: conformancePart{mediaType: "multipart/mixed", parts: []conformancePart{
+			{mediaType: "text/plain", charset: "us-ascii", content: "See attached."},
+			{mediaType: "text/csv", filename: "report.csv", content: "a,b\n1,2\n"},
+		}},
+	},
+	"mixed_html": {
+		msg: Message{
+			From: "sender@example.com", Recipients: []string{"rcpt@example.com"}, Subject: "Mixed HTML", HTMLBody: "<p>See attached.</p>",
+			Attachments: []Attachment{{Filename: "logo.png", MIMEType: "image/png", Data: []byte("\x89PNG\r\n\x1a\n")}},
+		},
+		want: conformancePart{mediaType: "multipart/mixed", parts: []conformancePart{
+			{mediaType: "text/html", charset: "UTF-8", content: "<p>See attached.</p>"},
+			{mediaType: "image/png", filename: "logo.png", content: "\x89PNG\r\n\x1a\n"},
+		}},
+	},
+	"nested": {
+		msg: Message{
+			From: "Sender <sender@example.com>", Recipients: []string{"Rcpt <rcpt@example.com>"}, Cc: []string{"cc@example.com"},
+			Subject: "Nested", Body: "Hello, Zoë", HTMLBody: "<p>Hello, Zoë</p>",
+			Headers: map[string][]string{"X-Campaign": {"spring"}, "Reply-To": {"reply@example.com"}},
+			Attachments: []Attachment{
+				{Filename: "a.txt", MIMEType: "text/plain", Data: []byte("first")},
+				{Filename: "b.bin", MIMEType: "application/octet-stream", Data: bytes.Repeat([]byte{0, 1, 2, 0xff}, 40)},
+			},
+		},
+		want: conformancePart{mediaType: "multipart/mixed", parts: []conformancePart{
+			{mediaType: "multipart/alternative", parts: []conformancePart{
+				{mediaType: "text/plain", charset: "UTF-8", content: "Hello, Zoë"},
+				{mediaType: "text/html", charset: "UTF-8", content: "<p>Hello, Zoë</p>"},
+			}},
+			{mediaType: "text/plain", filename: "a.txt", content: "first"},
+			{mediaType: "application/octet-stream", filename: "b.bin", content: string(bytes.Repeat([]byte{0, 1, 2, 0xff}, 40))},
+		}},
+	},
+	"max_compatibility": {
+		msg: Message{
+			From: "Zoë <zoe@example.com>", Recipients: []string{"rcpt@example.com"},
+			Subject: "Grüße aus Zürich, ein sehr langer Betreff für alte Gateways", Body: "Hello, Zoë", HTMLBody: "<p>Hello, Zoë</p>",
+			Headers:     map[string][]string{"X-Note": {"Grüße"}},
+			Attachments: []Attachment{{Filename: "Bericht für Zürich.csv", MIMEType: "text/csv", Data: []byte("a,b\n1,2\n")}},
+		},
+		opts: []EncodeOption{WithMaxCompatibility()},
+		want: conformancePart{mediaType: "multipart/mixed", parts: []conformancePart{
+			{mediaType: "multipart/alternative", parts: []conformancePart{
+				{mediaType: "text/plain", charset: "UTF-8", content: "Hello, Zoë"},
+				{mediaType: "text/html", charset: "UTF-8", content: "<p>Hello, Zoë</p>"},
+			}},
+			{mediaType: "text/csv", filename: "Bericht für Zürich.csv", content: "a,b\n1,2\n"},
+		}},
+	},
+	"related": {
+		msg: Message{
+			From: "sender@example.com", Recipients: []string{"rcpt@example.com"},
+			Subject: "Newsletter", Body: "Hello", HTMLBody: `<p>Hello</p><img src="cid:logo">`,
+			Attachments: []Attachment{
+				{Filename: "logo.png", MIMEType: "image/png", Data: []byte("\x89PNG\r\n"), ContentID: "logo"},
+				{Filename: "terms.txt", MIMEType: "text/plain", Data: []byte("terms")},
+			},
+		},
+		want: conformancePart{mediaType: "multipart/mixed", parts: []conformancePart{
+			{mediaType: "multipart/alternative", parts: []conformancePart{
+				{mediaType: "text/plain", charset: "us-ascii", content: "Hello"},
+				{mediaType: "multipart/related", parts: []conformancePart{
+					{mediaType: "text/html", charset: "UTF-8", content: `<p>Hello</p><img src="cid:logo">`},
+					{mediaType: "image/png", filename: "logo.png", content: "\x89PNG\r\n"},
+				}},
+			}},
+			{mediaType: "text/plain", filename: "terms.txt", content: "terms"},
+		}},
+	},
+	"alternatives": {
+		msg: Message{
+			From: "sender@example.com", Recipients: []string{"rcpt@example.com"},
+			Subject: "Order shipped", Body: "Your order shipped.", HTMLBody: "<p>Your order shipped.</p>",
+			Alternatives: []Alternative{{
+				MIMEType: "application/json",
+				Content:  `{"order":42,"status":"shipped"}`,
+				Headers:  map[string][]string{"X-Schema": {"https://example.com/schemas/order-status"}},
+			}},
+		},
+		want: conformancePart{mediaType: "multipart/alternative", parts: []conformancePart{
+			{mediaType: "application/json", content: `{"order":42,"status":"shipped"}`},
+			{mediaType: "text/plain", charset: "us-ascii", content: "Your order shipped."},
+			{mediaType: "text/html", charset: "UTF-8", content: "<p>Your order shipped.</p>"},
+		}},
+	},
+	"calendar": {
+		msg: Message{
+			From: "Organizer <organizer@example.com>", Recipients: []string{"rcpt@example.com"},
+			Subject: "Planning", Body: "You are invited to the planning.", HTMLBody: "<p>You are invited to the planning.</p>",
+			Calendar: &CalendarEvent{
+				UID: "planning-1@example.com", Summary: "Planning", Location: "Room 1", Attendees: []string{"Rcpt <rcpt@example.com>"},
+				Stamp: time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC),
+				Start: time.Date(2025, 1, 2, 9, 0, 0, 0, time.UTC), End: time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC),
+			},
+		},
+		want: conformancePart{mediaType: "multipart/alternative", parts: []conformancePart{
+			{mediaType: "text/plain", charset: "us-ascii", content: "You are invited to the planning."},
+			{mediaType: "text/html", charset: "UTF-8", content: "<p>You are invited to the planning.</p>"},
+			{mediaType: "text/calendar", charset: "UTF-8", content: strings.Join([]string{
+				"BEGIN:VCALENDAR", "PRODID:-//gomailer//gomailer//EN", "VERSION:2.0", "METHOD:REQUEST", "BEGIN:VEVENT",
+				"UID:planning-1@example.com", "SEQUENCE:0", "DTSTAMP:20250101T080000Z", "DTSTART:20250102T090000Z", "DTEND:20250102T100000Z",
+				"SUMMARY:Planning", "LOCATION:Room 1", `ORGANIZER;CN="Organizer":mailto:organizer@example.com`,
+				`ATTENDEE;CN="Rcpt";ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mai`, " lto:rcpt@example.com",
+				"STATUS:CONFIRMED", "END:VEVENT", "END:VCALENDAR",
+			}, "\n")},
+		}},
+	},
+}
+
+// TestConformance encodes the canonical messages and compares the output to the golden files, byte for byte,
+// then checks the golden files are understood by the net/mail and mime/multipart parsers as the message encoded.
+// Golden file changes show the exact impact of an encoder change, the parsers make sure it is still valid.
+func TestConformance(t *testing.T) {
+	for name, vector := range conformanceVectors {
+		t.Run(name, func(t *testing.T) {
+			golden := filepath.Join("testdata", "conformance", name+".eml")
+			encoded, err := vector.msg.Encode(append([]EncodeOption{WithDeterministicBoundaries(testSeed)}, vector.opts...)...)
+			require.Nil(t, err)
+			if *update {
+				require.Nil(t, os.WriteFile(golden, encoded, 0o644))
+			}
+			want, err := os.ReadFile(golden)
+			require.Nil(t, err, "run with -update to create the golden file")
+			assert.Equal(t, string(want), string(encoded))
+
+			parsed, err := mail.ReadMessage(bytes.NewReader(want))
+			require.Nil(t, err)
+			subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+			assert.Nil(t, err)
+			assert.Equal(t, vector.msg.Subject, subject)
+			assert.Equal(t, "1.0", parsed.Header.Get("MIME-Version"))
+			from, err := parsed.Header.AddressList("From")
+			assert.Nil(t, err)
+			assert.Len(t, from, 1)
+			to, err := parsed.Header.AddressList("To")
+			assert.Nil(t, err)
+			assert.Len(t, to, len(vector.msg.Recipients))
+			for key, values := range vector.msg.Headers {
+				value, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get(key))
+				assert.Nil(t, err)
+				assert.Equal(t, strings.Join(values, ", "), value)
+			}
+			assert.Equal(t, vector.want, parseConformancePart(t, parsed.Header.Get("Content-Type"), parsed.Header.Get("Content-Transfer-Encoding"), "", parsed.Body))
+		})
+	}
+}
+
+// parseConformancePart parses a MIME entity with the standard library parsers.
+func parseConformancePart(t *testing.T, contentType, transferEncoding, disposition string, body io.Reader) conformancePart {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	require.Nil(t, err)
+	part := conformancePart{mediaType: mediaType}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			require.Nil(t, err)
+			// NextPart decodes quoted-printable parts and removes their Content-Transfer-Encoding.
+			part.parts = append(part.parts, parseConformancePart(t, p.Header.Get("Content-Type"),
+				p.Header.Get("Content-Transfer-Encoding"), p.Header.Get("Content-Disposition"), p))
+		}
+		return part
+	}
+
+	switch strings.ToLower(transferEncoding) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	content, err := io.ReadAll(body)
+	require.Nil(t, err)
+	if disposition != "" {
+		_, dispositionParams, err := mime.ParseMediaType(disposition)
+		require.Nil(t, err)
+		part.filename = dispositionParams["filename"]
+		part.content = string(content)
+		return part
+	}
+	part.charset = params["charset"]
+	part.content = strings.TrimSuffix(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	return part
+}
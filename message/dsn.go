@@ -0,0 +1,76 @@
+package message
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DSNNotify is a condition under which a delivery status notification is requested, see RFC 3461 section 4.1.
+type DSNNotify string
+
+const (
+	// DSNNotifySuccess requests a notification on successful delivery.
+	DSNNotifySuccess DSNNotify = "SUCCESS"
+	// DSNNotifyFailure requests a notification on delivery failure.
+	DSNNotifyFailure DSNNotify = "FAILURE"
+	// DSNNotifyDelay requests a notification when the delivery is delayed.
+	DSNNotifyDelay DSNNotify = "DELAY"
+	// DSNNotifyNever requests no notification at all, it cannot be combined with other conditions.
+	DSNNotifyNever DSNNotify = "NEVER"
+)
+
+// DSNReturn selects how much of the message is returned in a failure notification, see RFC 3461 section 4.3.
+type DSNReturn string
+
+const (
+	// DSNReturnFull returns the full message.
+	DSNReturnFull DSNReturn = "FULL"
+	// DSNReturnHeaders returns the message headers only.
+	DSNReturnHeaders DSNReturn = "HDRS"
+)
+
+// DSN requests delivery status notifications (RFC 3461) for a message.
+// The parameters are only sent when the SMTP server advertises the DSN extension.
+type DSN struct {
+	// Notify lists the conditions a notification is requested for, the server default applies when empty.
+	Notify []DSNNotify
+	// Return selects whether failure notifications carry the full message or its headers only.
+	Return DSNReturn
+	// EnvelopeID is returned in notifications to correlate them with the sent message.
+	EnvelopeID string
+}
+
+// NotifyParam returns the NOTIFY parameter value of RCPT TO, or an empty string when no condition is given.
+func (d DSN) NotifyParam() string {
+	conditions := make([]string, 0, len(d.Notify))
+	for _, n := range d.Notify {
+		conditions = append(conditions, string(n))
+	}
+	return strings.Join(conditions, ",")
+}
+
+// validate validates the DSN parameters.
+func (d DSN) validate() error {
+	for _, n := range d.Notify {
+		switch n {
+		case DSNNotifySuccess, DSNNotifyFailure, DSNNotifyDelay:
+		case DSNNotifyNever:
+			if len(d.Notify) > 1 {
+				return fmt.Errorf("dsn notify NEVER cannot be combined with other conditions")
+			}
+		default:
+			return fmt.Errorf("unknown dsn notify condition %q", n)
+		}
+	}
+	switch d.Return {
+	case "", DSNReturnFull, DSNReturnHeaders:
+	default:
+		return fmt.Errorf("unknown dsn return %q", d.Return)
+	}
+	for _, r := range d.EnvelopeID {
+		if r < ' ' || r > '~' {
+			return fmt.Errorf("dsn envelope id must be printable ascii")
+		}
+	}
+	return nil
+}
@@ -1,140 +1,764 @@
 package message
 
 import (
+	"bytes"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"slices"
 	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+)
+
+const (
+	// transferEncoding7Bit sends ASCII content as is, split into lines of at most maxLineLength.
+	transferEncoding7Bit = "7bit"
+	// transferEncoding8Bit sends the content as is, split into lines of at most maxLineLength.
+	transferEncoding8Bit = "8bit"
+	// transferEncodingBase64 sends the content base64 encoded, wrapped into lines of maxLineLength.
+	transferEncodingBase64 = "base64"
+	// transferEncodingQuotedPrintable sends the content quoted-printable encoded, see RFC 2045 section 6.7.
+	transferEncodingQuotedPrintable = "quoted-printable"
 )
 
+// ErrMessageTooLarge is returned when the encoded message exceeds the size given to WithMaxSize.
+var ErrMessageTooLarge = errors.New("message exceeds the maximum size")
+
+// ErrBareLineBreak is returned when a body contains a CR or LF that is not part of a CRLF line break
+// and WithStrictLineBreaks is given.
+var ErrBareLineBreak = errors.New("content contains a bare CR or LF")
+
+// crlfBytes is crlf as bytes, avoiding a conversion on every inserted line break.
+var crlfBytes = []byte(crlf)
+
 // encodeBase64 Helper function to encode a string in Base64.
 func encodeBase64(input string) string {
-	return strings.TrimRight(base64.StdEncoding.EncodeToString([]byte(input)), "=")
+	return base64.StdEncoding.EncodeToString([]byte(input))
+}
+
+// maxEncodedWordText is the number of bytes a split value carries per base64 encoded-word, keeping the words
+// within the 75 characters allowed by RFC 2047 section 2, and the first line of headers such as Subject within maxLineLength.
+const maxEncodedWordText = 39
+
+// encodeWords encodes the value as a single base64 encoded-word, or split into several encoded-words
+// separated by spaces, so the header can be folded between them. Words are split between characters.
+func encodeWords(value string, split bool) string {
+	if !split {
+		return "=?UTF-8?B?" + encodeBase64(value) + "?="
+	}
+	var words []string
+	for {
+		n := len(value)
+		if n > maxEncodedWordText {
+			n = maxEncodedWordText
+			for n > 0 && !utf8.RuneStart(value[n]) {
+				n--
+			}
+		}
+		words = append(words, "=?UTF-8?B?"+encodeBase64(value[:n])+"?=")
+		if value = value[n:]; value == "" {
+			return strings.Join(words, " ")
+		}
+	}
+}
+
+// asciiHeaderValue returns the value of an additional header with its non-ASCII characters RFC 2047 encoded:
+// the display names of address lists, or the whole value otherwise.
+func asciiHeaderValue(value string) string {
+	if is7Bit(value) {
+		return value
+	}
+	if addrs, err := ParseAddressList(value); err == nil {
+		formatted := make([]string, 0, len(addrs))
+		for _, addr := range addrs {
+			formatted = append(formatted, addr.String())
+		}
+		if joined := strings.Join(formatted, separator); is7Bit(joined) {
+			return joined
+		}
+	}
+	return encodeWords(value, true)
+}
+
+// errWriter wraps an io.Writer and remembers the first error, so a sequence of writes
+// can be checked once at the end instead of after every single write.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+// Write writes p to the underlying writer unless a previous write failed.
+func (ew *errWriter) Write(p []byte) (int, error) {
+	if ew.err != nil {
+		return 0, ew.err
+	}
+	n, err := ew.w.Write(p)
+	ew.err = err
+	return n, err
 }
 
-// splitLines splits the input string into lines of a specified maximum length.
-func splitLines(input string, maxLength int) []string {
-	var lines []string
-	for len(input) > maxLength {
-		lines = append(lines, input[:maxLength])
-		input = input[maxLength:]
+// countWriter counts the bytes written to the underlying writer.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write writes p to the underlying writer, counting the bytes written.
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// limitWriter writes at most limit bytes to the underlying writer, the write exceeding the limit fails
+// with ErrMessageTooLarge so the encoding stops there.
+type limitWriter struct {
+	w     io.Writer
+	limit int64
+	n     int64
+}
+
+// Write writes p to the underlying writer, failing with ErrMessageTooLarge once the limit is exceeded.
+func (lw *limitWriter) Write(p []byte) (int, error) {
+	if remaining := lw.limit - lw.n; int64(len(p)) > remaining {
+		n, err := lw.w.Write(p[:max(remaining, 0)])
+		lw.n += int64(n)
+		if err != nil {
+			return n, err
+		}
+		return n, ErrMessageTooLarge
+	}
+	n, err := lw.w.Write(p)
+	lw.n += int64(n)
+	return n, err
+}
+
+// headerWriter writes header fields in the "Key: value" form terminated by crlf.
+type headerWriter struct {
+	w io.Writer
+	// fold indicates whether values are folded at spaces so lines do not exceed maxLineLength where possible.
+	fold bool
+	// limit counts the fields written, fields exceeding it are not written. No limit applies when nil.
+	limit *headerLimit
+}
+
+// headerValueReplacer replaces the line breaks of header values with spaces, so a value cannot inject header fields.
+var headerValueReplacer = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// writeHeader writes a single header field, line breaks within value are replaced with spaces.
+func (hw headerWriter) writeHeader(key, value string) {
+	value = headerValueReplacer.Replace(value)
+	if hw.fold {
+		value = foldHeaderValue(len(key)+len(": "), value)
+	}
+	if !hw.limit.allow(key, len(key)+len(": ")+len(value)+len(crlf)) {
+		return
+	}
+	_, _ = fmt.Fprintf(hw.w, "%s: %s%s", key, value, crlf)
+}
+
+// foldHeaderValue inserts crlf before the spaces of value where the line, starting at column start,
+// would exceed maxLineLength (RFC 5322 section 2.2.3). Words longer than a line are not broken.
+func foldHeaderValue(start int, value string) string {
+	var b strings.Builder
+	length := start
+	for i, word := range strings.Split(value, " ") {
+		if i > 0 {
+			if word != "" && length+1+len(word) > maxLineLength {
+				b.WriteString(crlf)
+				length = 0
+			}
+			b.WriteByte(' ')
+			length++
+		}
+		b.WriteString(word)
+		length += len(word)
 	}
-	lines = append(lines, input)
-	return lines
+	return b.String()
+}
+
+// end writes the empty line separating the header fields from the body.
+func (hw headerWriter) end() {
+	_, _ = io.WriteString(hw.w, crlf)
+}
+
+// lineWriter is a line-length enforcer, it breaks the content into lines that do not exceed maxLength
+// by inserting crlf, and terminates the content with crlf when closed.
+// Line breaks already present in the content reset the line length, bare CR and LF are normalized to crlf
+// so the content cannot carry line breaks SMTP servers interpret differently. Lines are not broken when maxLength is zero.
+type lineWriter struct {
+	w         io.Writer
+	maxLength int
+	length    int
+	// cr indicates whether the last written byte was a CR, so an LF following it completes the same line break.
+	cr bool
+}
+
+// newLineWriter returns a lineWriter writing lines of at most maxLength to w.
+func newLineWriter(w io.Writer, maxLength int) *lineWriter {
+	return &lineWriter{w: w, maxLength: maxLength}
+}
+
+// Write writes p to the underlying writer, breaking lines that would exceed the maximum length.
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n, err := lw.writeChunk(p)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// writeChunk writes either a single existing line break character or the bytes up to the next line break or line length limit.
+func (lw *lineWriter) writeChunk(p []byte) (int, error) {
+	if c := p[0]; c == '\r' || c == '\n' {
+		crlfDone := c == '\n' && lw.cr
+		lw.cr = c == '\r'
+		lw.length = 0
+		if crlfDone {
+			return 1, nil
+		}
+		if _, err := lw.w.Write(crlfBytes); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+	lw.cr = false
+	if lw.maxLength > 0 && lw.length == lw.maxLength {
+		if _, err := lw.w.Write(crlfBytes); err != nil {
+			return 0, err
+		}
+		lw.length = 0
+	}
+	chunk := p
+	if lw.maxLength > 0 {
+		chunk = p[:min(len(p), lw.maxLength-lw.length)]
+	}
+	if i := bytes.IndexAny(chunk, "\r\n"); i >= 0 {
+		chunk = chunk[:i]
+	}
+	n, err := lw.w.Write(chunk)
+	lw.length += n
+	return n, err
+}
+
+// Close terminates the content with crlf.
+func (lw *lineWriter) Close() error {
+	_, err := io.WriteString(lw.w, crlf)
+	return err
+}
+
+// base64Writer base64 encodes the content and wraps it into lines of maxLineLength.
+type base64Writer struct {
+	encoder io.WriteCloser
+	lines   *lineWriter
+}
+
+// newBase64Writer returns a base64Writer writing to w.
+func newBase64Writer(w io.Writer) *base64Writer {
+	lines := newLineWriter(w, maxLineLength)
+	return &base64Writer{encoder: base64.NewEncoder(base64.StdEncoding, lines), lines: lines}
+}
+
+// Write encodes p, partial 3-byte groups are kept until the next Write or Close.
+func (bw *base64Writer) Write(p []byte) (int, error) {
+	return bw.encoder.Write(p)
+}
+
+// Close flushes the remaining (padded) group and terminates the last line.
+func (bw *base64Writer) Close() error {
+	if err := bw.encoder.Close(); err != nil {
+		return err
+	}
+	return bw.lines.Close()
+}
+
+// qpWriter quoted-printable encodes the content, the encoding keeps lines within 76 characters by itself.
+type qpWriter struct {
+	encoder *quotedprintable.Writer
+	w       io.Writer
+}
+
+// newQPWriter returns a qpWriter writing to w.
+func newQPWriter(w io.Writer) *qpWriter {
+	return &qpWriter{encoder: quotedprintable.NewWriter(w), w: w}
+}
+
+// Write encodes p.
+func (qw *qpWriter) Write(p []byte) (int, error) {
+	return qw.encoder.Write(p)
+}
+
+// Close flushes the encoder and terminates the last line.
+func (qw *qpWriter) Close() error {
+	if err := qw.encoder.Close(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(qw.w, crlf)
+	return err
+}
+
+// newContentWriter returns the writer applying the given Content-Transfer-Encoding to the content written to w.
+func newContentWriter(w io.Writer, transferEncoding string) io.WriteCloser {
+	switch transferEncoding {
+	case transferEncodingBase64:
+		return newBase64Writer(w)
+	case transferEncodingQuotedPrintable:
+		return newQPWriter(w)
+	default:
+		return newLineWriter(w, maxLineLength)
+	}
+}
+
+// writeContent writes the content to w through the writer of the given Content-Transfer-Encoding.
+func writeContent(w io.Writer, transferEncoding string, content []byte) {
+	cw := newContentWriter(w, transferEncoding)
+	_, _ = cw.Write(content)
+	_ = cw.Close()
 }
 
 // encode encodes mail components into bytes to be sent.
-func encode(m Message) []byte {
-	var mailMessage strings.Builder
-	mailSubjectEncoded := "=?UTF-8?B?" + encodeBase64(m.Subject) + "?="
-	hasAttachement := len(m.Attachments) > 0
-	hasBothPlainAndHTML := m.Body != "" && m.HTMLBody != ""
-	mailMessage.WriteString(fmt.Sprintf("MIME-Version: 1.0%s", crlf))
-	mailMessage.WriteString(fmt.Sprintf("Subject: %s%s", mailSubjectEncoded, crlf))
-	mailMessage.WriteString(fmt.Sprintf("From: %s%s", m.From, crlf))
+func encode(m Message, cfg encodeConfig) ([]byte, error) {
+	p, err := prepare(m, cfg)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if cfg.maxSize <= 0 {
+		_, _ = p.WriteTo(&buf)
+		return buf.Bytes(), nil
+	}
+	// the limit applies while the message is written, so it is encoded once and the encoding stops
+	// at the first write exceeding the limit.
+	if _, err := p.WriteTo(&limitWriter{w: &buf, limit: cfg.maxSize}); err != nil {
+		return nil, fmt.Errorf("%w of %d bytes", err, cfg.maxSize)
+	}
+	return buf.Bytes(), nil
+}
+
+// prepare checks the message against the configuration and writes its header section, so only its body remains to be
+// written by Prepared.WriteTo, which then cannot fail but for the writer. The maximum size is left to the caller,
+// see Prepared.checkMaxSize.
+func prepare(m Message, cfg encodeConfig) (*Prepared, error) {
+	if cfg.sourceEncoding != nil {
+		var err error
+		if m, err = transcode(m, cfg.sourceEncoding); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.strictLineBreaks {
+		if err := checkLineBreaks(m); err != nil {
+			return nil, err
+		}
+	}
+	m = withPreviewText(m)
+	if !cfg.smtpUTF8 {
+		m = withASCIIDomains(m)
+	}
+	if err := checkSize(m, cfg); err != nil {
+		return nil, err
+	}
+	cfg.boundaries = newBoundaries(cfg.boundarySeed)
+	var buf bytes.Buffer
+	wrapped, err := writeHeader(&buf, m, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Prepared{m: m, cfg: cfg, header: buf.Bytes(), wrapped: wrapped}, nil
+}
+
+// writeHeader writes the header section of the message to w. When entity wrappers apply, the wrapped entity follows,
+// as it is encoded as a whole, and wrapped is true.
+func writeHeader(w io.Writer, m Message, cfg encodeConfig) (wrapped bool, err error) {
+	hw := headerWriter{w: w, fold: cfg.maxCompatibility, limit: newHeaderLimit(cfg)}
+	writeTrace(hw, m.Trace, cfg)
+	hw.writeHeader("MIME-Version", "1.0")
+	hw.writeHeader("Subject", encodeWords(m.Subject, cfg.maxCompatibility))
+	hw.writeHeader("From", formatAddressList([]string{m.From}))
+
+	if len(cfg.entityWrappers) == 0 {
+		hw.writeHeader("Content-Type", contentType(m, cfg))
+		writeAddressHeaders(hw, m, cfg)
+		writeEntityTransferEncoding(hw, m, cfg)
+		if err := hw.limit.error(); err != nil {
+			return false, err
+		}
+		hw.end()
+		return false, nil
+	}
+
+	// the entity is wrapped as a whole, so its Content-Type follows the top-level header fields.
+	writeAddressHeaders(hw, m, cfg)
+	if err := hw.limit.error(); err != nil {
+		return true, err
+	}
+	var buf bytes.Buffer
+	ehw := headerWriter{w: &buf}
+	ehw.writeHeader("Content-Type", contentType(m, cfg))
+	writeEntityTransferEncoding(ehw, m, cfg)
+	ehw.end()
+	writeBody(&buf, m, cfg)
+	entity := buf.Bytes()
+	for _, wrapper := range cfg.entityWrappers {
+		var err error
+		if entity, err = wrapper.WrapEntity(entity); err != nil {
+			return true, fmt.Errorf("failed to wrap MIME entity: %w", err)
+		}
+	}
+	_, _ = w.Write(entity)
+	return true, nil
+}
+
+// bodyPart is a text alternative of the message: the body, the HTML body, an additional alternative or the calendar invitation.
+type bodyPart struct {
+	contentType, content string
+	html                 bool
+	// headers are the additional header fields of the part.
+	headers mail.Header
+	// related are the inline attachments referenced by the HTML body, sent along with it as multipart/related.
+	related []Attachment
+}
+
+// bodyParts returns the text alternatives of the message, from the least to the most preferred (RFC 2046 section 5.1.4).
+// A message without content has an empty plain text part.
+func bodyParts(m Message) []bodyPart {
+	var parts []bodyPart
+	for _, a := range m.Alternatives {
+		parts = append(parts, bodyPart{contentType: a.MIMEType, content: a.Content, headers: a.Headers})
+	}
+	if m.Body != "" || (m.HTMLBody == "" && m.Calendar == nil && len(m.Alternatives) == 0) {
+		parts = append(parts, bodyPart{contentType: plainTextContentType(m.Body), content: m.Body})
+	}
+	if m.AMPBody != "" {
+		// the AMP body precedes the HTML body, which Gmail requires so clients without AMP support fall back to it.
+		parts = append(parts, bodyPart{contentType: ampContentType, content: m.AMPBody, html: true})
+	}
+	if m.HTMLBody != "" {
+		parts = append(parts, bodyPart{contentType: htmlTypeContentType, content: m.HTMLBody, html: true, related: inlineAttachments(m)})
+	}
+	if m.Calendar != nil {
+		parts = append(parts, bodyPart{
+			contentType: fmt.Sprintf("%s; method=%s", calendarContentType, m.Calendar.method()),
+			// the content writers terminate the last line.
+			content: strings.TrimSuffix(m.Calendar.ics(m.From), crlf),
+		})
+	}
+	return parts
+}
+
+// mediaType returns the Content-Type of the part entity, multipart/related when it has inline attachments.
+func (p bodyPart) mediaType(cfg encodeConfig) string {
+	if len(p.related) > 0 {
+		return cfg.boundaries.relatedContentType()
+	}
+	return p.contentType
+}
+
+// write writes the part content in the given Content-Transfer-Encoding, the closing line break is written by the caller.
+func (p bodyPart) write(w io.Writer, transferEncoding string) {
+	if p.html {
+		writeHTML(w, transferEncoding, p.content)
+		return
+	}
+	writeContent(w, transferEncoding, []byte(p.content))
+}
+
+// writeWithHeaders writes the part along with its Content-Type and Content-Transfer-Encoding, followed by a line break.
+func (p bodyPart) writeWithHeaders(w io.Writer, cfg encodeConfig) {
+	hw := headerWriter{w: w}
+	if len(p.related) > 0 {
+		hw.writeHeader("Content-Type", cfg.boundaries.relatedContentType())
+		hw.end()
+		p.writeRelated(w, cfg)
+		_, _ = io.WriteString(w, crlf)
+		return
+	}
+	encoding := cfg.textTransferEncoding(p.content)
+	hw.writeHeader("Content-Type", p.contentType)
+	hw.writeHeader("Content-Transfer-Encoding", encoding)
+	writeExtraHeaders(hw, p.headers, cfg)
+	hw.end()
+	p.write(w, encoding)
+	_, _ = io.WriteString(w, crlf)
+}
+
+// writeRelated writes the part followed by its inline attachments, as the body of a multipart/related entity (RFC 2387).
+func (p bodyPart) writeRelated(w io.Writer, cfg encodeConfig) {
+	_, _ = fmt.Fprintf(w, "--%s%s", cfg.boundaries.related, crlf)
+	root := p
+	root.related = nil
+	root.writeWithHeaders(w, cfg)
+	for _, attachment := range p.related {
+		attachment.writeTo(w, cfg.boundaries.related, cfg)
+	}
+	_, _ = fmt.Fprintf(w, "--%s--%s", cfg.boundaries.related, crlf)
+}
+
+// inlineAttachments returns the attachments of the message displayed within the HTML body, referenced by their Content-ID.
+// They are sent as regular attachments when the message has no HTML body.
+func inlineAttachments(m Message) []Attachment {
+	if m.HTMLBody == "" {
+		return nil
+	}
+	var inline []Attachment
+	for _, a := range m.Attachments {
+		if a.ContentID != "" {
+			inline = append(inline, a)
+		}
+	}
+	return inline
+}
+
+// regularAttachments returns the attachments of the message that are not displayed within the HTML body.
+func regularAttachments(m Message) []Attachment {
+	if m.HTMLBody == "" {
+		return m.Attachments
+	}
+	var attachments []Attachment
+	for _, a := range m.Attachments {
+		if a.ContentID == "" {
+			attachments = append(attachments, a)
+		}
+	}
+	return attachments
+}
 
+// contentType returns the Content-Type of the message entity.
+func contentType(m Message, cfg encodeConfig) string {
 	// If the email has attachments, set the original content type to multipart/mixed.
 	// This allows for nesting of different content types (plain text, HTML, or both) within the email.
 	// For more details on multipart/mixed, refer to: https://datatracker.ietf.org/doc/html/rfc2046#section-5.1.3
-	if hasAttachement {
-		mailMessage.WriteString(fmt.Sprintf("Content-Type: %s%s", multiPartMixedContentType, crlf))
-	} else if hasBothPlainAndHTML {
-		mailMessage.WriteString(fmt.Sprintf("Content-Type: %s%s", multiPartAlternativeContentType, crlf))
-	} else if m.HTMLBody != "" {
-		mailMessage.WriteString(fmt.Sprintf("Content-Type: %s%s", htmlTypeContentType, crlf))
-	} else {
-		mailMessage.WriteString(fmt.Sprintf("Content-Type: %s%s", plainContentType, crlf))
+	if len(regularAttachments(m)) > 0 {
+		return cfg.boundaries.mixedContentType()
 	}
+	parts := bodyParts(m)
+	if len(parts) > 1 {
+		return cfg.boundaries.alternativeContentType()
+	}
+	return parts[0].mediaType(cfg)
+}
 
+// plainTextContentType returns the Content-Type of plain text content, labeled us-ascii only when it is ASCII.
+func plainTextContentType(content string) string {
+	if is7Bit(content) {
+		return plainContentType
+	}
+	return plainUTF8ContentType
+}
+
+// writeEntityTransferEncoding writes the Content-Transfer-Encoding of a single part message,
+// omitted for 7bit content as it is the default (RFC 2045 section 6.1), followed by the headers of the part.
+// Multipart messages declare the encoding of every part instead.
+func writeEntityTransferEncoding(hw headerWriter, m Message, cfg encodeConfig) {
+	parts := bodyParts(m)
+	if len(regularAttachments(m)) > 0 || len(parts) > 1 || len(parts[0].related) > 0 {
+		return
+	}
+	if encoding := cfg.textTransferEncoding(parts[0].content); encoding != transferEncoding7Bit {
+		hw.writeHeader("Content-Transfer-Encoding", encoding)
+	}
+	writeExtraHeaders(hw, parts[0].headers, cfg)
+}
+
+// writeAddressHeaders writes the recipient header fields followed by the additional headers of the message,
+// whose values are made ASCII for maximum compatibility, and the priority, mailing list and read receipt headers.
+func writeAddressHeaders(hw headerWriter, m Message, cfg encodeConfig) {
 	if len(m.Recipients) > 0 {
-		mailMessage.WriteString(fmt.Sprintf("To: %s%s", strings.Join(m.Recipients, separator), crlf))
+		hw.writeHeader("To", formatAddressList(m.Recipients))
 	}
 	if len(m.Cc) > 0 {
-		mailMessage.WriteString(fmt.Sprintf("Cc: %s%s", strings.Join(m.Cc, separator), crlf))
+		hw.writeHeader("Cc", formatAddressList(m.Cc))
 	}
 
 	if len(m.Bcc) > 0 {
-		mailMessage.WriteString(fmt.Sprintf("Bcc: %s%s", strings.Join(m.Bcc, separator), crlf))
+		hw.writeHeader("Bcc", formatAddressList(m.Bcc))
+	}
+	writeExtraHeaders(hw, m.Headers, cfg)
+	for _, h := range slices.Concat(m.Priority.headers(), m.listHeaders(), m.receiptHeaders()) {
+		if !m.HasHeader(h[0]) {
+			hw.writeHeader(h[0], h[1])
+		}
+	}
+}
+
+// writeExtraHeaders writes additional headers, sorted so the encoded message is deterministic,
+// their values made ASCII for maximum compatibility. The values of a header are joined into one field,
+// except for the repeatable fields, see repeatableField.
+func writeExtraHeaders(hw headerWriter, headers mail.Header, cfg encodeConfig) {
+	for _, k := range slices.Sorted(maps.Keys(headers)) {
+		values := headers[k]
+		if !repeatableField(k) {
+			values = []string{strings.Join(values, ", ")}
+		}
+		for _, value := range values {
+			if cfg.maxCompatibility {
+				value = asciiHeaderValue(value)
+			}
+			hw.writeHeader(k, value)
+		}
 	}
-	// additional headers if any.
-	for k, v := range m.Headers {
-		mailMessage.WriteString(fmt.Sprintf("%s: %s%s", k, strings.Join(v, ", "), crlf))
+}
+
+// writeTrace writes the trace fields in order, above the other header fields as RFC 5322 section 3.6 requires,
+// their values made ASCII for maximum compatibility.
+func writeTrace(hw headerWriter, trace []Field, cfg encodeConfig) {
+	for _, f := range trace {
+		value := f.Value
+		if cfg.maxCompatibility {
+			value = asciiHeaderValue(value)
+		}
+		hw.writeHeader(f.Key, value)
 	}
-	mailMessage.WriteString(crlf)
+}
+
+// repeatableField reports whether the header is a trace, resent or loop field, which RFC 5322 section 3.6 lets
+// occur any number of times, so every value is written as a field of its own and read back as such.
+func repeatableField(key string) bool {
+	return traceField(key) || textproto.CanonicalMIMEHeaderKey(key) == "X-Loop"
+}
+
+// traceField reports whether the header is a trace or resent field, which Parse keeps in Message.Trace.
+func traceField(key string) bool {
+	key = textproto.CanonicalMIMEHeaderKey(key)
+	return key == "Received" || key == "Return-Path" || strings.HasPrefix(key, "Resent-")
+}
 
+// writeBody writes the message body, the attachments included. The complete structure is
+//
+//	multipart/mixed(multipart/alternative(text, multipart/related(html, inline attachments), calendar), attachments)
+//
+// where every multipart entity with a single part is replaced by the part.
+func writeBody(w io.Writer, m Message, cfg encodeConfig) {
 	// if Message has attachement
-	if hasAttachement {
-		mailMessage.WriteString(fmt.Sprintf("--%s%s", boundary, crlf))
-		mailMessage.WriteString(encodeMultiPartMixed(m))
+	if attachments := regularAttachments(m); len(attachments) > 0 {
+		_, _ = fmt.Fprintf(w, "--%s%s", cfg.boundaries.mixed, crlf)
+		writeMultiPartMixed(w, m, cfg)
 		// Add attachments
-		for _, attachment := range m.Attachments {
-			mailMessage.WriteString(attachment.encode())
+		for _, attachment := range attachments {
+			attachment.writeTo(w, cfg.boundaries.mixed, cfg)
 		}
 		// Final boundary to indicate the end of the message
-		mailMessage.WriteString(fmt.Sprintf("--%s--%s", boundary, crlf))
+		_, _ = fmt.Fprintf(w, "--%s--%s", cfg.boundaries.mixed, crlf)
 
 	} else {
 		// else just encode message bodies.
-		mailMessage.WriteString(encodeMessageContent(m))
-	}
-	return []byte(mailMessage.String())
-}
-
-// encodeMessageContent function encodes the Message.Body, and Message.HTMLBody.
-func encodeMessageContent(m Message) string {
-	var mb strings.Builder
-	// check if mail has both versions.
-	if m.Body != "" && m.HTMLBody != "" {
-		mb.WriteString(fmt.Sprintf("Content-Type: %s%s", multiPartAlternativeContentType, crlf))
-		mb.WriteString(fmt.Sprintf("--%s%s", altBoundary, crlf))
-		// Plain text content.
-		mb.WriteString(fmt.Sprintf("Content-Type: %s%s", plainContentType, crlf))
-		mb.WriteString(fmt.Sprintf("Content-Transfer-Encoding: 8bit%s", crlf))
-		mb.WriteString(crlf)
-		for _, line := range splitLines(m.Body, maxLineLength) {
-			mb.WriteString(line + crlf)
-		}
-
-		mb.WriteString(crlf)
-		// HTML content.
-
-		mb.WriteString(fmt.Sprintf("--%s%s", altBoundary, crlf))
-		mb.WriteString(fmt.Sprintf("Content-Type: %s%s", htmlTypeContentType, crlf))
-		mb.WriteString(fmt.Sprintf("Content-Transfer-Encoding: 8bit%s", crlf))
-		mb.WriteString(crlf)
-		mb.WriteString(m.HTMLBody + crlf)
+		writeMessageContent(w, m, cfg)
+	}
+}
+
+// writeMessageContent function encodes the Message.Body, Message.HTMLBody and Message.Calendar.
+func writeMessageContent(w io.Writer, m Message, cfg encodeConfig) {
+	parts := bodyParts(m)
+	// check if mail has several versions.
+	if len(parts) > 1 {
+		for _, part := range parts {
+			_, _ = fmt.Fprintf(w, "--%s%s", cfg.boundaries.alternative, crlf)
+			part.writeWithHeaders(w, cfg)
+		}
 		// Closing boundary
-		mb.WriteString(fmt.Sprintf("--%s--%s", altBoundary, crlf))
-	} else if m.HTMLBody != "" {
-		mb.WriteString(m.HTMLBody + crlf)
-	} else {
-		for _, line := range splitLines(m.Body, maxLineLength) {
-			mb.WriteString(line + crlf)
+		_, _ = fmt.Fprintf(w, "--%s--%s", cfg.boundaries.alternative, crlf)
+		return
+	}
+	part := parts[0]
+	if len(part.related) > 0 {
+		part.writeRelated(w, cfg)
+		return
+	}
+	part.write(w, cfg.textTransferEncoding(part.content))
+	if part.html {
+		_, _ = io.WriteString(w, crlf)
+	}
+}
+
+// writeMultiPartMixed function encodes multipart mixed and writeMessageContent if any.
+func writeMultiPartMixed(w io.Writer, m Message, cfg encodeConfig) {
+	parts := bodyParts(m)
+	// check if mail has content as alternative
+	if len(parts) > 1 {
+		hw := headerWriter{w: w}
+		hw.writeHeader("Content-Type", cfg.boundaries.alternativeContentType())
+		hw.end()
+		writeMessageContent(w, m, cfg)
+		_, _ = io.WriteString(w, crlf)
+		return
+	}
+	parts[0].writeWithHeaders(w, cfg)
+}
+
+// writeHTML writes the HTML content, whose lines are not broken unless it is quoted-printable encoded,
+// as breaking its lines could break attributes and URLs.
+func writeHTML(w io.Writer, transferEncoding, html string) {
+	if transferEncoding == transferEncodingQuotedPrintable {
+		qw := newQPWriter(w)
+		_, _ = io.WriteString(qw.encoder, html)
+		// the closing line break is written by the caller.
+		_ = qw.encoder.Close()
+		return
+	}
+	// only normalizes line breaks, the closing line break is written by the caller.
+	_, _ = io.WriteString(newLineWriter(w, 0), html)
+}
+
+// checkLineBreaks returns an error wrapping ErrBareLineBreak locating the first bare CR or LF of the bodies and alternatives.
+func checkLineBreaks(m Message) error {
+	bodies := []struct{ name, content string }{{"body", m.Body}, {"AMP body", m.AMPBody}, {"HTML body", m.HTMLBody}}
+	for _, a := range m.Alternatives {
+		bodies = append(bodies, struct{ name, content string }{a.MIMEType + " alternative", a.Content})
+	}
+	for _, body := range bodies {
+		if i := bareLineBreak(body.content); i >= 0 {
+			return fmt.Errorf("%w in %s at offset %d", ErrBareLineBreak, body.name, i)
 		}
 	}
+	return nil
+}
 
-	return mb.String()
+// bareLineBreak returns the offset of the first CR or LF of content that is not part of a CRLF, or -1 if there is none.
+func bareLineBreak(content string) int {
+	for i := 0; i < len(content); i++ {
+		switch content[i] {
+		case '\r':
+			if i+1 == len(content) || content[i+1] != '\n' {
+				return i
+			}
+			i++
+		case '\n':
+			return i
+		}
+	}
+	return -1
 }
 
-// encodeMultiPartMixed function encodes multipart mixed and encodeMessageContent if any.
-func encodeMultiPartMixed(m Message) string {
-	var mb strings.Builder
-	// check if mail has content as alternative
-	if m.HTMLBody != "" && m.Body != "" {
-		mb.WriteString(encodeMessageContent(m))
-	} else if m.HTMLBody != "" {
-		mb.WriteString(fmt.Sprintf("Content-Type: %s%s", htmlTypeContentType, crlf))
-		mb.WriteString(fmt.Sprintf("Content-Transfer-Encoding: 8bit%s", crlf))
-		mb.WriteString(crlf)
-		mb.WriteString(m.HTMLBody)
-	} else {
-		mb.WriteString(fmt.Sprintf("Content-Type: %s%s", plainContentType, crlf))
-		mb.WriteString(fmt.Sprintf("Content-Transfer-Encoding: 8bit%s", crlf))
-		mb.WriteString(crlf)
-		for _, line := range splitLines(m.Body, maxLineLength) {
-			mb.WriteString(line + crlf)
+// is7Bit reports whether the content only contains ASCII characters and no NUL, so it can be sent as 7bit.
+func is7Bit(content string) bool {
+	for i := 0; i < len(content); i++ {
+		if c := content[i]; c == 0 || c >= 0x80 {
+			return false
 		}
 	}
-	mb.WriteString(crlf)
+	return true
+}
 
-	return mb.String()
+// transcode returns a copy of m with the subject, bodies and preview text decoded from the source encoding to UTF-8.
+func transcode(m Message, source encoding.Encoding) (Message, error) {
+	decoder := source.NewDecoder()
+	for _, field := range []*string{&m.Subject, &m.Body, &m.AMPBody, &m.HTMLBody, &m.PreviewText} {
+		decoded, err := decoder.String(*field)
+		if err != nil {
+			return m, fmt.Errorf("failed to transcode content to UTF-8: %w", err)
+		}
+		*field = decoded
+	}
+	return m, nil
 }
@@ -1,10 +1,17 @@
 package message
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/encoding/charmap"
 )
 
 const (
@@ -15,30 +22,228 @@ func TestMessage_EncodeBase64(t *testing.T) {
 	t.Parallel()
 	t.Run("should encode message to base64", func(t *testing.T) {
 		t.Parallel()
-		expected := "aW5wdXQ"
+		expected := "aW5wdXQ="
 		msg := encodeBase64("input")
 		assert.Equal(t, expected, msg)
 	})
 }
 
-func TestMessage_SplitLines(t *testing.T) {
+func TestMessage_LineWriter(t *testing.T) {
 	input := "input"
 	t.Run("should put input into multiple lines when it is exceeding the max length", func(t *testing.T) {
-		lines := splitLines(input, 1)
-		assert.Equal(t, len(lines), len(input))
-		// assert each line.
-		for i, line := range lines {
-			assert.Equal(t, input[i:i+1], line)
-		}
+		var buf bytes.Buffer
+		lw := newLineWriter(&buf, 1)
+		_, err := lw.Write([]byte(input))
+		assert.Nil(t, err)
+		assert.Nil(t, lw.Close())
+		assert.Equal(t, "i\r\nn\r\np\r\nu\r\nt\r\n", buf.String())
 	})
 
 	t.Run("should put input in one line when it is not exceeding the max length", func(t *testing.T) {
-		lines := splitLines(input, 20)
-		assert.Equal(t, len(lines), 1)
-		assert.Equal(t, lines[0], input)
+		var buf bytes.Buffer
+		lw := newLineWriter(&buf, 20)
+		_, err := lw.Write([]byte(input))
+		assert.Nil(t, err)
+		assert.Nil(t, lw.Close())
+		assert.Equal(t, "input\r\n", buf.String())
+	})
+
+	t.Run("should keep counting the line length across writes", func(t *testing.T) {
+		var buf bytes.Buffer
+		lw := newLineWriter(&buf, 3)
+		for _, chunk := range []string{"in", "pu", "t"} {
+			_, err := lw.Write([]byte(chunk))
+			assert.Nil(t, err)
+		}
+		assert.Nil(t, lw.Close())
+		assert.Equal(t, "inp\r\nut\r\n", buf.String())
+	})
+
+	t.Run("should reset the line length on existing line breaks", func(t *testing.T) {
+		var buf bytes.Buffer
+		lw := newLineWriter(&buf, 3)
+		_, err := lw.Write([]byte("ab\r\ncdef"))
+		assert.Nil(t, err)
+		assert.Nil(t, lw.Close())
+		assert.Equal(t, "ab\r\ncde\r\nf\r\n", buf.String())
+	})
+
+	t.Run("should normalize bare CR and LF to CRLF", func(t *testing.T) {
+		var buf bytes.Buffer
+		lw := newLineWriter(&buf, 3)
+		for _, chunk := range []string{"a\nb\rc\r", "\nd\n\n.\r\n"} {
+			_, err := lw.Write([]byte(chunk))
+			assert.Nil(t, err)
+		}
+		assert.Nil(t, lw.Close())
+		assert.Equal(t, "a\r\nb\r\nc\r\nd\r\n\r\n.\r\n\r\n", buf.String())
+	})
+
+	t.Run("should not break lines when the max length is zero", func(t *testing.T) {
+		var buf bytes.Buffer
+		_, err := newLineWriter(&buf, 0).Write([]byte(strings.Repeat("a", 100) + "\n"))
+		assert.Nil(t, err)
+		assert.Equal(t, strings.Repeat("a", 100)+"\r\n", buf.String())
+	})
+}
+
+func TestMessage_HeaderWriter(t *testing.T) {
+	t.Run("should replace line breaks within values so they cannot inject header fields", func(t *testing.T) {
+		var buf bytes.Buffer
+		headerWriter{w: &buf}.writeHeader("X-Tag", "a\r\nBcc: evil@example.com\nb\rc")
+		assert.Equal(t, "X-Tag: a Bcc: evil@example.com b c\r\n", buf.String())
+	})
+	t.Run("should fold values at spaces so lines do not exceed 76 characters", func(t *testing.T) {
+		var buf bytes.Buffer
+		headerWriter{w: &buf, fold: true}.writeHeader("X-Tag", strings.Repeat("word ", 20)+strings.Repeat("x", 80))
+		assert.Equal(t, "X-Tag: "+strings.Repeat("word ", 13)+"word\r\n "+strings.Repeat("word ", 5)+"word\r\n "+strings.Repeat("x", 80)+"\r\n", buf.String())
+	})
+	t.Run("should write a field per value of the trace, resent and loop headers only", func(t *testing.T) {
+		var buf bytes.Buffer
+		writeExtraHeaders(headerWriter{w: &buf}, mail.Header{
+			"Keywords":    {"a", "b"},
+			"Resent-Date": {"Fri, 16 Oct 2026 12:00:00 +0000", "Mon, 02 Jan 2006 15:04:05 +0000"},
+			"X-Loop":      {"list@example.org", "alerts@example.com"},
+		}, encodeConfig{})
+		assert.Equal(t, "Keywords: a, b\r\n"+
+			"Resent-Date: Fri, 16 Oct 2026 12:00:00 +0000\r\n"+
+			"Resent-Date: Mon, 02 Jan 2006 15:04:05 +0000\r\n"+
+			"X-Loop: list@example.org\r\n"+
+			"X-Loop: alerts@example.com\r\n", buf.String())
+	})
+}
+
+func TestMessage_EncodeTrace(t *testing.T) {
+	t.Run("should write the trace fields in order above the other header fields", func(t *testing.T) {
+		msg := Message{From: "a@example.com", Recipients: []string{"b@example.com"}, Body: "hello", Headers: mail.Header{"Resent-Sender": {"c@example.net"}}}.
+			WithTrace(Field{Key: "Received", Value: "from mx.example.net"}).
+			WithTrace(Field{Key: "Resent-From", Value: "b@example.net"}, Field{Key: "Resent-Date", Value: "Fri, 16 Oct 2026 12:00:00 +0000"})
+		encoded, err := msg.Encode()
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(string(encoded), "Resent-From: b@example.net\r\n"+
+			"Resent-Date: Fri, 16 Oct 2026 12:00:00 +0000\r\n"+
+			"Received: from mx.example.net\r\n"+
+			"MIME-Version: 1.0\r\n"), string(encoded))
+	})
+	t.Run("should reject an invalid trace field name", func(t *testing.T) {
+		_, err := Message{From: "a@example.com", Recipients: []string{"b@example.com"}}.WithTrace(Field{Key: "Resent From", Value: "x"}).Encode()
+		assert.ErrorContains(t, err, `invalid header field name "Resent From"`)
+	})
+}
+
+func TestMessage_Base64Writer(t *testing.T) {
+	t.Run("should encode content with padding and wrap it into 76 characters lines", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		bw := newBase64Writer(&buf)
+		content := bytes.Repeat([]byte("gomailer"), 10)
+		// write byte by byte to cross the 3-byte group boundaries.
+		for _, b := range content {
+			_, err := bw.Write([]byte{b})
+			assert.Nil(t, err)
+		}
+		assert.Nil(t, bw.Close())
+
+		encoded := base64.StdEncoding.EncodeToString(content)
+		assert.Equal(t, encoded[:76]+"\r\n"+encoded[76:]+"\r\n", buf.String())
+		assert.True(t, strings.HasSuffix(encoded, "="))
+	})
+}
+
+func TestMessage_QPWriter(t *testing.T) {
+	t.Run("should encode content as quoted-printable", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		qw := newQPWriter(&buf)
+		_, err := qw.Write([]byte("caf\u00e9 = coffee"))
+		assert.Nil(t, err)
+		assert.Nil(t, qw.Close())
+		assert.Equal(t, "caf=C3=A9 =3D coffee\r\n", buf.String())
+	})
+}
+
+func TestMessage_ErrWriter(t *testing.T) {
+	t.Run("should keep the first error and skip further writes", func(t *testing.T) {
+		t.Parallel()
+		dummyErr := fmt.Errorf("dummy error")
+		ew := &errWriter{w: failingWriter{err: dummyErr}}
+		headerWriter{w: ew}.writeHeader("Subject", "hello")
+		_, err := ew.Write([]byte("body"))
+		assert.Equal(t, dummyErr, err)
+		assert.Equal(t, dummyErr, ew.err)
 	})
 }
 
+func TestMessage_EncodeMaxSize(t *testing.T) {
+	msg := Message{
+		From:        testEmail,
+		Recipients:  []string{testEmail},
+		Body:        "hello",
+		Attachments: []Attachment{{Filename: "a.bin", Data: make([]byte, 1024), MIMEType: "application/octet-stream"}},
+	}
+	encoded, err := msg.Encode(WithDeterministicBoundaries(testSeed))
+	assert.Nil(t, err)
+	size := int64(len(encoded))
+
+	tests := map[string]struct {
+		maxSize     int64
+		expectedErr error
+	}{
+		"should encode messages of exactly the maximum size": {maxSize: size},
+		"should not limit the size when zero":                {maxSize: 0},
+		"should abort encoding once the maximum size is exceeded": {
+			maxSize:     size - 1,
+			expectedErr: ErrMessageTooLarge,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := msg.Encode(WithMaxSize(tc.maxSize), WithDeterministicBoundaries(testSeed))
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Nil(t, got)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, encoded, got)
+		})
+	}
+	t.Run("should encode the message once under a limit", func(t *testing.T) {
+		unlimited := testing.AllocsPerRun(10, func() { _, _ = msg.Encode() })
+		limited := testing.AllocsPerRun(10, func() { _, _ = msg.Encode(WithMaxSize(size)) })
+		// the limit costs its writer, measuring the message beforehand would cost a second encoding.
+		assert.LessOrEqual(t, limited, unlimited+1)
+	})
+}
+
+func TestMessage_EncodeMaxSizeStopsEarly(t *testing.T) {
+	msg := Message{
+		From:        testEmail,
+		Recipients:  []string{testEmail},
+		Body:        "hello",
+		Attachments: []Attachment{{Filename: "a.bin", Data: make([]byte, 1<<20), MIMEType: "application/octet-stream"}},
+	}
+	p, err := msg.Prepare(WithDeterministicBoundaries(testSeed))
+	assert.Nil(t, err)
+
+	// every write reaching the counter is encoding work done, none may follow the write exceeding the limit.
+	cw := &countWriter{w: io.Discard}
+	lw := &limitWriter{w: cw, limit: 1024}
+	_, err = p.WriteTo(lw)
+	assert.ErrorIs(t, err, ErrMessageTooLarge)
+	assert.Equal(t, int64(1024), cw.n)
+}
+
+// failingWriter is an io.Writer failing every write.
+type failingWriter struct {
+	err error
+}
+
+func (fw failingWriter) Write([]byte) (int, error) {
+	return 0, fw.err
+}
+
 func TestMessage_Encode(t *testing.T) {
 	tests := map[string]struct {
 		input Message
@@ -53,7 +258,7 @@ func TestMessage_Encode(t *testing.T) {
 				HTMLBody:   "<p>hello</p>",
 				Subject:    "testing html body",
 			},
-			want: "MIME-Version: 1.0\r\nSubject: =?UTF-8?B?dGVzdGluZyBodG1sIGJvZHk?=\r\nFrom: gomailer@smtp.com\r\nContent-Type: text/html; charset=UTF-8\r\nTo: test.usr@smtp.com\r\nCc: test.usr@smtp.com\r\nBcc: test.usr@smtp.com\r\n\r\n<p>hello</p>\r\n",
+			want: "MIME-Version: 1.0\r\nSubject: =?UTF-8?B?dGVzdGluZyBodG1sIGJvZHk=?=\r\nFrom: gomailer@smtp.com\r\nContent-Type: text/html; charset=UTF-8\r\nTo: test.usr@smtp.com\r\nCc: test.usr@smtp.com\r\nBcc: test.usr@smtp.com\r\n\r\n<p>hello</p>\r\n",
 		},
 		"should encode message correctly with both HTML and plain text bodies, including to, cc, and bcc fields": {
 			input: Message{
@@ -65,7 +270,7 @@ func TestMessage_Encode(t *testing.T) {
 				Body:       "hello",
 				Subject:    "testing html body",
 			},
-			want: "MIME-Version: 1.0\r\nSubject: =?UTF-8?B?dGVzdGluZyBodG1sIGJvZHk?=\r\nFrom: gomailer@smtp.com\r\nContent-Type: multipart/alternative; boundary=ALT-BOUNDARY\r\nTo: test.usr@smtp.com\r\nCc: test.usr@smtp.com\r\nBcc: test.usr@smtp.com\r\n\r\nContent-Type: multipart/alternative; boundary=ALT-BOUNDARY\r\n--ALT-BOUNDARY\r\nContent-Type: text/plain; charset=us-ascii\r\nContent-Transfer-Encoding: 8bit\r\n\r\nhello\r\n\r\n--ALT-BOUNDARY\r\nContent-Type: text/html; charset=UTF-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n<p>hello</p>\r\n--ALT-BOUNDARY--\r\n",
+			want: "MIME-Version: 1.0\r\nSubject: =?UTF-8?B?dGVzdGluZyBodG1sIGJvZHk=?=\r\nFrom: gomailer@smtp.com\r\nContent-Type: multipart/alternative; boundary=ALT-BOUNDARY\r\nTo: test.usr@smtp.com\r\nCc: test.usr@smtp.com\r\nBcc: test.usr@smtp.com\r\n\r\n--ALT-BOUNDARY\r\nContent-Type: text/plain; charset=us-ascii\r\nContent-Transfer-Encoding: 7bit\r\n\r\nhello\r\n\r\n--ALT-BOUNDARY\r\nContent-Type: text/html; charset=UTF-8\r\nContent-Transfer-Encoding: 7bit\r\n\r\n<p>hello</p>\r\n--ALT-BOUNDARY--\r\n",
 		},
 		"should encode message in the expected format when message has an text body only with to,cc, and bcc": {
 			input: Message{
@@ -76,7 +281,7 @@ func TestMessage_Encode(t *testing.T) {
 				Body:       "hello",
 				Subject:    "testing txt body",
 			},
-			want: "MIME-Version: 1.0\r\nSubject: =?UTF-8?B?dGVzdGluZyB0eHQgYm9keQ?=\r\nFrom: gomailer@smtp.com\r\nContent-Type: text/plain; charset=us-ascii\r\nTo: test.usr@smtp.com\r\nCc: test.usr@smtp.com\r\nBcc: test.usr@smtp.com\r\n\r\nhello\r\n",
+			want: "MIME-Version: 1.0\r\nSubject: =?UTF-8?B?dGVzdGluZyB0eHQgYm9keQ==?=\r\nFrom: gomailer@smtp.com\r\nContent-Type: text/plain; charset=us-ascii\r\nTo: test.usr@smtp.com\r\nCc: test.usr@smtp.com\r\nBcc: test.usr@smtp.com\r\n\r\nhello\r\n",
 		},
 		"should encode message correctly with plain text body and attachments, including to, cc, and bcc fields": {
 			input: Message{
@@ -92,7 +297,7 @@ func TestMessage_Encode(t *testing.T) {
 				}},
 				Subject: "testing txt body with attachment",
 			},
-			want: "MIME-Version: 1.0\r\nSubject: =?UTF-8?B?dGVzdGluZyB0eHQgYm9keSB3aXRoIGF0dGFjaG1lbnQ?=\r\nFrom: gomailer@smtp.com\r\nContent-Type: multipart/mixed; boundary=BOUNDARY\r\nTo: test.usr@smtp.com\r\nCc: test.usr@smtp.com\r\nBcc: test.usr@smtp.com\r\n\r\n--BOUNDARY\r\nContent-Type: text/plain; charset=us-ascii\r\nContent-Transfer-Encoding: 8bit\r\n\r\nhello\r\n\r\n--BOUNDARY\r\nContent-Type: application/pdf; name=\"f1\"\r\nContent-Transfer-Encoding: base64\r\nContent-Disposition: attachment; filename=\"f1\"\r\n\r\nYnl0ZSBzdHI\r\n\r\n--BOUNDARY--\r\n",
+			want: "MIME-Version: 1.0\r\nSubject: =?UTF-8?B?dGVzdGluZyB0eHQgYm9keSB3aXRoIGF0dGFjaG1lbnQ=?=\r\nFrom: gomailer@smtp.com\r\nContent-Type: multipart/mixed; boundary=BOUNDARY\r\nTo: test.usr@smtp.com\r\nCc: test.usr@smtp.com\r\nBcc: test.usr@smtp.com\r\n\r\n--BOUNDARY\r\nContent-Type: text/plain; charset=us-ascii\r\nContent-Transfer-Encoding: 7bit\r\n\r\nhello\r\n\r\n--BOUNDARY\r\nContent-Type: application/pdf; name=\"f1\"\r\nContent-Transfer-Encoding: base64\r\nContent-Disposition: attachment; filename=\"f1\"\r\n\r\nYnl0ZSBzdHI=\r\n\r\n--BOUNDARY--\r\n",
 		},
 		"should encode message correctly with plain text and HTML bodies, including attachments, to, cc, and bcc fields": {
 			input: Message{
@@ -109,7 +314,7 @@ func TestMessage_Encode(t *testing.T) {
 				}},
 				Subject: "testing txt body with attachment",
 			},
-			want: "MIME-Version: 1.0\r\nSubject: =?UTF-8?B?dGVzdGluZyB0eHQgYm9keSB3aXRoIGF0dGFjaG1lbnQ?=\r\nFrom: gomailer@smtp.com\r\nContent-Type: multipart/mixed; boundary=BOUNDARY\r\nTo: test.usr@smtp.com\r\nCc: test.usr@smtp.com\r\nBcc: test.usr@smtp.com\r\n\r\n--BOUNDARY\r\nContent-Type: multipart/alternative; boundary=ALT-BOUNDARY\r\n--ALT-BOUNDARY\r\nContent-Type: text/plain; charset=us-ascii\r\nContent-Transfer-Encoding: 8bit\r\n\r\nhello\r\n\r\n--ALT-BOUNDARY\r\nContent-Type: text/html; charset=UTF-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n<p>hello</p>\r\n--ALT-BOUNDARY--\r\n\r\n--BOUNDARY\r\nContent-Type: application/pdf; name=\"f1\"\r\nContent-Transfer-Encoding: base64\r\nContent-Disposition: attachment; filename=\"f1\"\r\n\r\nYnl0ZSBzdHI\r\n\r\n--BOUNDARY--\r\n",
+			want: "MIME-Version: 1.0\r\nSubject: =?UTF-8?B?dGVzdGluZyB0eHQgYm9keSB3aXRoIGF0dGFjaG1lbnQ=?=\r\nFrom: gomailer@smtp.com\r\nContent-Type: multipart/mixed; boundary=BOUNDARY\r\nTo: test.usr@smtp.com\r\nCc: test.usr@smtp.com\r\nBcc: test.usr@smtp.com\r\n\r\n--BOUNDARY\r\nContent-Type: multipart/alternative; boundary=ALT-BOUNDARY\r\n\r\n--ALT-BOUNDARY\r\nContent-Type: text/plain; charset=us-ascii\r\nContent-Transfer-Encoding: 7bit\r\n\r\nhello\r\n\r\n--ALT-BOUNDARY\r\nContent-Type: text/html; charset=UTF-8\r\nContent-Transfer-Encoding: 7bit\r\n\r\n<p>hello</p>\r\n--ALT-BOUNDARY--\r\n\r\n--BOUNDARY\r\nContent-Type: application/pdf; name=\"f1\"\r\nContent-Transfer-Encoding: base64\r\nContent-Disposition: attachment; filename=\"f1\"\r\n\r\nYnl0ZSBzdHI=\r\n\r\n--BOUNDARY--\r\n",
 		},
 		"should encode message in the expected format when message has an html body and attachments with to,cc, and bcc": {
 			input: Message{
@@ -125,7 +330,7 @@ func TestMessage_Encode(t *testing.T) {
 				}},
 				Subject: "testing txt body with attachment",
 			},
-			want: "MIME-Version: 1.0\r\nSubject: =?UTF-8?B?dGVzdGluZyB0eHQgYm9keSB3aXRoIGF0dGFjaG1lbnQ?=\r\nFrom: gomailer@smtp.com\r\nContent-Type: multipart/mixed; boundary=BOUNDARY\r\nTo: test.usr@smtp.com\r\nCc: test.usr@smtp.com\r\nBcc: test.usr@smtp.com\r\n\r\n--BOUNDARY\r\nContent-Type: text/html; charset=UTF-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n<p>hello</p>\r\n--BOUNDARY\r\nContent-Type: application/pdf; name=\"f1\"\r\nContent-Transfer-Encoding: base64\r\nContent-Disposition: attachment; filename=\"f1\"\r\n\r\nYnl0ZSBzdHI\r\n\r\n--BOUNDARY--\r\n",
+			want: "MIME-Version: 1.0\r\nSubject: =?UTF-8?B?dGVzdGluZyB0eHQgYm9keSB3aXRoIGF0dGFjaG1lbnQ=?=\r\nFrom: gomailer@smtp.com\r\nContent-Type: multipart/mixed; boundary=BOUNDARY\r\nTo: test.usr@smtp.com\r\nCc: test.usr@smtp.com\r\nBcc: test.usr@smtp.com\r\n\r\n--BOUNDARY\r\nContent-Type: text/html; charset=UTF-8\r\nContent-Transfer-Encoding: 7bit\r\n\r\n<p>hello</p>\r\n--BOUNDARY\r\nContent-Type: application/pdf; name=\"f1\"\r\nContent-Transfer-Encoding: base64\r\nContent-Disposition: attachment; filename=\"f1\"\r\n\r\nYnl0ZSBzdHI=\r\n\r\n--BOUNDARY--\r\n",
 		},
 		"should encode message in the expected format when message has an html body and attachments with to,cc, and bcc and additional headers": {
 			input: Message{
@@ -142,16 +347,240 @@ func TestMessage_Encode(t *testing.T) {
 				}},
 				Subject: "testing txt body with attachment",
 			},
-			want: "MIME-Version: 1.0\r\nSubject: =?UTF-8?B?dGVzdGluZyB0eHQgYm9keSB3aXRoIGF0dGFjaG1lbnQ?=\r\nFrom: gomailer@smtp.com\r\nContent-Type: multipart/mixed; boundary=BOUNDARY\r\nTo: test.usr@smtp.com\r\nCc: test.usr@smtp.com\r\nBcc: test.usr@smtp.com\r\nmessage-id: 124\r\n\r\n--BOUNDARY\r\nContent-Type: text/html; charset=UTF-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n<p>hello</p>\r\n--BOUNDARY\r\nContent-Type: application/pdf; name=\"f1\"\r\nContent-Transfer-Encoding: base64\r\nContent-Disposition: attachment; filename=\"f1\"\r\n\r\nYnl0ZSBzdHI\r\n\r\n--BOUNDARY--\r\n",
+			want: "MIME-Version: 1.0\r\nSubject: =?UTF-8?B?dGVzdGluZyB0eHQgYm9keSB3aXRoIGF0dGFjaG1lbnQ=?=\r\nFrom: gomailer@smtp.com\r\nContent-Type: multipart/mixed; boundary=BOUNDARY\r\nTo: test.usr@smtp.com\r\nCc: test.usr@smtp.com\r\nBcc: test.usr@smtp.com\r\nmessage-id: 124\r\n\r\n--BOUNDARY\r\nContent-Type: text/html; charset=UTF-8\r\nContent-Transfer-Encoding: 7bit\r\n\r\n<p>hello</p>\r\n--BOUNDARY\r\nContent-Type: application/pdf; name=\"f1\"\r\nContent-Transfer-Encoding: base64\r\nContent-Disposition: attachment; filename=\"f1\"\r\n\r\nYnl0ZSBzdHI=\r\n\r\n--BOUNDARY--\r\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := encode(tc.input, newEncodeConfig([]EncodeOption{WithDeterministicBoundaries(testSeed)}))
+			assert.Nil(t, err)
+			assert.Equal(t, withTestBoundaries(tc.want), string(got))
+		})
+	}
+}
+
+func BenchmarkMessage_Encode(b *testing.B) {
+	msg := Message{
+		From:       "gomailer@smtp.com",
+		Recipients: []string{testEmail},
+		Subject:    "benchmark",
+		Body:       strings.Repeat("plain text body ", 1000),
+		HTMLBody:   strings.Repeat("<p>html body</p>", 1000),
+		Attachments: []Attachment{{
+			Filename: "f1",
+			Data:     bytes.Repeat([]byte{0xde, 0xad, 0xbe, 0xef}, 256*1024),
+			MIMEType: "application/octet-stream",
+		}},
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := msg.WriteTo(io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMessage_EncodeTransferEncoding(t *testing.T) {
+	const header = "MIME-Version: 1.0\r\nSubject: =?UTF-8?B??=\r\nFrom: gomailer@smtp.com\r\n"
+	tests := map[string]struct {
+		input Message
+		opts  []EncodeOption
+		want  string
+	}{
+		"should declare 8bit content of a single part message": {
+			input: Message{From: "gomailer@smtp.com", Recipients: []string{testEmail}, Body: "مرحبا"},
+			want:  header + "Content-Type: text/plain; charset=UTF-8\r\nTo: test.usr@smtp.com\r\nContent-Transfer-Encoding: 8bit\r\n\r\nمرحبا\r\n",
+		},
+		"should transcode Windows-1256 content to UTF-8": {
+			input: Message{From: "gomailer@smtp.com", Recipients: []string{testEmail}, Body: "\xe3\xd1\xcd\xc8\xc7"},
+			opts:  []EncodeOption{WithSourceEncoding(charmap.Windows1256)},
+			want:  header + "Content-Type: text/plain; charset=UTF-8\r\nTo: test.usr@smtp.com\r\nContent-Transfer-Encoding: 8bit\r\n\r\nمرحبا\r\n",
+		},
+		"should transcode ISO-8859-1 content to UTF-8 before quoted-printable encoding it for 7bit transports": {
+			input: Message{From: "gomailer@smtp.com", Recipients: []string{testEmail}, HTMLBody: "<p>Zo\xeb</p>"},
+			opts:  []EncodeOption{WithSourceEncoding(charmap.ISO8859_1), With7BitTransport()},
+			want:  header + "Content-Type: text/html; charset=UTF-8\r\nTo: test.usr@smtp.com\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n<p>Zo=C3=AB</p>\r\n",
+		},
+		"should quoted-printable encode 8bit content of a single part message for 7bit transports": {
+			input: Message{From: "gomailer@smtp.com", Recipients: []string{testEmail}, HTMLBody: "<p>Zoë</p>"},
+			opts:  []EncodeOption{With7BitTransport()},
+			want:  header + "Content-Type: text/html; charset=UTF-8\r\nTo: test.usr@smtp.com\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n<p>Zo=C3=AB</p>\r\n",
+		},
+		"should only quoted-printable encode the parts with 8bit content for 7bit transports": {
+			input: Message{From: "gomailer@smtp.com", Recipients: []string{testEmail}, Body: "hello", HTMLBody: "<p>Zoë</p>"},
+			opts:  []EncodeOption{With7BitTransport()},
+			want: header + "Content-Type: multipart/alternative; boundary=ALT-BOUNDARY\r\nTo: test.usr@smtp.com\r\n\r\n" +
+				"--ALT-BOUNDARY\r\n" +
+				"Content-Type: text/plain; charset=us-ascii\r\nContent-Transfer-Encoding: 7bit\r\n\r\nhello\r\n\r\n" +
+				"--ALT-BOUNDARY\r\nContent-Type: text/html; charset=UTF-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n<p>Zo=C3=AB</p>\r\n" +
+				"--ALT-BOUNDARY--\r\n",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := tc.input.Encode(append([]EncodeOption{WithDeterministicBoundaries(testSeed)}, tc.opts...)...)
+			assert.Nil(t, err)
+			assert.Equal(t, withTestBoundaries(tc.want), string(got))
+			assert.True(t, tc.input.Requires8BitMIME())
+		})
+	}
+}
+
+func TestMessage_EncodeMaxCompatibility(t *testing.T) {
+	t.Run("should split long subjects into encoded-words between characters", func(t *testing.T) {
+		subject := strings.Repeat("مرحبا بالعالم ", 10)
+		encoded := encodeWords(subject, true)
+		words := strings.Split(encoded, " ")
+		assert.Greater(t, len(words), 1)
+		for _, word := range words {
+			assert.LessOrEqual(t, len(word), 75)
+		}
+		decoded, err := new(mime.WordDecoder).DecodeHeader(encoded)
+		assert.Nil(t, err)
+		assert.Equal(t, subject, decoded)
+	})
+	t.Run("should make additional header values ASCII", func(t *testing.T) {
+		assert.Equal(t, "plain", asciiHeaderValue("plain"))
+		assert.Equal(t, "=?utf-8?q?Zo=C3=AB?= <zoe@example.com>", asciiHeaderValue("Zoë <zoe@example.com>"))
+		assert.Equal(t, "=?UTF-8?B?R3LDvMOfZQ==?=", asciiHeaderValue("Grüße"))
+	})
+	t.Run("should keep every line of the message ASCII and within 76 characters", func(t *testing.T) {
+		msg := Message{
+			From: "Zoë <zoe@example.com>", Recipients: []string{testEmail},
+			Subject:     strings.Repeat("Grüße aus Zürich ", 8),
+			Body:        strings.Repeat("plain ascii line without any break ", 5),
+			HTMLBody:    "<p>" + strings.Repeat("Grüße ", 30) + "</p>",
+			Headers:     map[string][]string{"X-Note": {strings.Repeat("Grüße ", 20)}},
+			Attachments: []Attachment{{Filename: "Bericht für Zürich.pdf", MIMEType: "application/pdf", Data: []byte("%PDF")}},
+		}
+		encoded, err := msg.Encode(WithMaxCompatibility())
+		assert.Nil(t, err)
+		assert.True(t, is7Bit(string(encoded)))
+		for _, line := range strings.Split(strings.TrimSuffix(string(encoded), "\r\n"), "\r\n") {
+			assert.LessOrEqual(t, len(line), 76, line)
+			assert.NotContains(t, line, "\n")
+		}
+	})
+}
+
+func TestMessage_EncodeLineBreaks(t *testing.T) {
+	tests := map[string]struct {
+		input        Message
+		opts         []EncodeOption
+		expectedBody string
+		expectedErr  error
+	}{
+		"should normalize bare LF and CR of the body to CRLF": {
+			input:        Message{From: "gomailer@smtp.com", Recipients: []string{testEmail}, Body: "first\nsecond\rthird\r\n"},
+			expectedBody: "\r\n\r\nfirst\r\nsecond\r\nthird\r\n\r\n",
+		},
+		"should normalize bare LF of the HTML body to CRLF": {
+			input:        Message{From: "gomailer@smtp.com", Recipients: []string{testEmail}, HTMLBody: "<p>first</p>\n<p>second</p>"},
+			expectedBody: "\r\n\r\n<p>first</p>\r\n<p>second</p>\r\n",
+		},
+		"should accept CRLF line breaks in strict mode": {
+			input:        Message{From: "gomailer@smtp.com", Recipients: []string{testEmail}, Body: "first\r\nsecond"},
+			opts:         []EncodeOption{WithStrictLineBreaks()},
+			expectedBody: "\r\n\r\nfirst\r\nsecond\r\n",
+		},
+		"should refuse a bare LF of the body in strict mode": {
+			input:       Message{From: "gomailer@smtp.com", Recipients: []string{testEmail}, Body: "first\r\nsecond\nthird"},
+			opts:        []EncodeOption{WithStrictLineBreaks()},
+			expectedErr: ErrBareLineBreak,
 		},
+		"should refuse a trailing bare CR of the HTML body in strict mode": {
+			input:       Message{From: "gomailer@smtp.com", Recipients: []string{testEmail}, Body: "first", HTMLBody: "<p>first</p>\r"},
+			opts:        []EncodeOption{WithStrictLineBreaks()},
+			expectedErr: ErrBareLineBreak,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := tc.input.Encode(tc.opts...)
+			assert.ErrorIs(t, err, tc.expectedErr)
+			if tc.expectedErr == nil {
+				assert.True(t, strings.HasSuffix(string(got), tc.expectedBody), string(got))
+			}
+		})
 	}
+}
 
+func TestMessage_EncodeAMP(t *testing.T) {
+	amp := `<!doctype html><html amp4email><body>Hello</body></html>`
+	t.Run("should send the AMP body before the HTML body and its inline attachments", func(t *testing.T) {
+		t.Parallel()
+		logo := Attachment{Filename: "logo.png", MIMEType: "image/png", Data: []byte("png"), ContentID: "logo"}
+		got, err := Message{From: testEmail, Recipients: []string{testEmail}, AMPBody: amp, HTMLBody: `<img src="cid:logo">`, Attachments: []Attachment{logo}}.Encode(WithDeterministicBoundaries(testSeed))
+		assert.Nil(t, err)
+		ampPart := withTestBoundaries("--ALT-BOUNDARY\r\nContent-Type: " + ampContentType + "\r\nContent-Transfer-Encoding: 7bit\r\n\r\n" + amp + "\r\n")
+		assert.Contains(t, string(got), ampPart)
+		assert.Less(t, strings.Index(string(got), ampPart), strings.Index(string(got), "Content-Type: "+testBoundaries.relatedContentType()))
+	})
+	t.Run("should refuse an AMP body without fallback", func(t *testing.T) {
+		t.Parallel()
+		_, err := Message{From: testEmail, Recipients: []string{testEmail}, AMPBody: amp}.Encode()
+		assert.ErrorContains(t, err, "AMP body requires a body or an HTML body as fallback")
+	})
+	t.Run("should refuse a bare LF of the AMP body in strict mode", func(t *testing.T) {
+		t.Parallel()
+		_, err := Message{From: testEmail, Recipients: []string{testEmail}, Body: "Hello", AMPBody: amp + "\n"}.Encode(WithStrictLineBreaks())
+		assert.ErrorIs(t, err, ErrBareLineBreak)
+		assert.ErrorContains(t, err, "in AMP body")
+	})
+}
+
+func TestMessage_EncodeRelated(t *testing.T) {
+	logo := Attachment{Filename: "logo.png", MIMEType: "image/png", Data: []byte("png"), ContentID: "logo"}
+	terms := Attachment{Filename: "terms.txt", MIMEType: "text/plain", Data: []byte("terms")}
+	tests := map[string]struct {
+		input               Message
+		expectedContentType string
+		expectedContains    []string
+		expectedErr         bool
+	}{
+		"should send the HTML body along with its inline attachments as multipart/related": {
+			input:               Message{From: testEmail, Recipients: []string{testEmail}, HTMLBody: `<img src="cid:logo">`, Attachments: []Attachment{logo}},
+			expectedContentType: testBoundaries.relatedContentType(),
+			expectedContains:    []string{"Content-Disposition: inline; filename=\"logo.png\"\r\nContent-ID: <logo>\r\n", "--RELATED-BOUNDARY--\r\n"},
+		},
+		"should nest the related entity within the alternative one": {
+			input:               Message{From: testEmail, Recipients: []string{testEmail}, Body: "logo", HTMLBody: `<img src="cid:logo">`, Attachments: []Attachment{logo}},
+			expectedContentType: testBoundaries.alternativeContentType(),
+			expectedContains:    []string{"--ALT-BOUNDARY\r\nContent-Type: " + testBoundaries.relatedContentType() + "\r\n"},
+		},
+		"should nest the related entity within the mixed one": {
+			input:               Message{From: testEmail, Recipients: []string{testEmail}, HTMLBody: `<img src="cid:logo">`, Attachments: []Attachment{logo, terms}},
+			expectedContentType: testBoundaries.mixedContentType(),
+			expectedContains:    []string{"--BOUNDARY\r\nContent-Type: " + testBoundaries.relatedContentType() + "\r\n"},
+		},
+		"should send inline attachments as regular ones without HTML body": {
+			input:               Message{From: testEmail, Recipients: []string{testEmail}, Body: "logo", Attachments: []Attachment{logo}},
+			expectedContentType: testBoundaries.mixedContentType(),
+			expectedContains:    []string{"--BOUNDARY\r\nContent-Type: image/png; name=\"logo.png\"\r\n"},
+		},
+		"should refuse an invalid content id": {
+			input:       Message{From: testEmail, Recipients: []string{testEmail}, HTMLBody: "logo", Attachments: []Attachment{{ContentID: "<logo>"}}},
+			expectedErr: true,
+		},
+	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
-			got := string(encode(tc.input))
-			fmt.Println(got)
-			assert.Equal(t, tc.want, got)
+			got, err := tc.input.Encode(WithDeterministicBoundaries(testSeed))
+			if tc.expectedErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Contains(t, string(got), "Content-Type: "+tc.expectedContentType+"\r\n")
+			assert.True(t, strings.Index(string(got), tc.expectedContentType) < strings.Index(string(got), "\r\n\r\n"))
+			for _, expected := range tc.expectedContains {
+				assert.Contains(t, string(got), withTestBoundaries(expected))
+			}
 		})
 	}
 }
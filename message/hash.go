@@ -0,0 +1,28 @@
+package message
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// ContentHashHeader is the header carrying the content hash of the message body, see Message.ContentHash.
+const ContentHashHeader = "X-Content-Hash"
+
+// ContentHash returns a canonical hash of the encoded message body in the form "sha256=<hex digest>".
+// Only the body is hashed, so identical notifications share the same hash regardless of their headers,
+// which lets downstream systems and archives deduplicate them. The body is encoded with fixed boundaries,
+// see WithDeterministicBoundaries.
+func (m Message) ContentHash() (string, error) {
+	encoded, err := m.Encode(WithDeterministicBoundaries(0))
+	if err != nil {
+		return "", fmt.Errorf("failed to compute content hash: %w", err)
+	}
+	body := encoded
+	if i := bytes.Index(encoded, []byte(crlf+crlf)); i >= 0 {
+		body = encoded[i+len(crlf+crlf):]
+	}
+	sum := sha256.Sum256(body)
+	return "sha256=" + hex.EncodeToString(sum[:]), nil
+}
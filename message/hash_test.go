@@ -0,0 +1,73 @@
+package message
+
+import (
+	"fmt"
+	"net/mail"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage_ContentHash(t *testing.T) {
+	t.Run("should hash identical bodies equally regardless of headers", func(t *testing.T) {
+		t.Parallel()
+		first := Message{From: testEmail, Recipients: []string{testEmail}, Subject: "first", Body: "hello"}
+		second := Message{
+			From:       "gomailer@smtp.com",
+			Recipients: []string{"gomailer@smtp.com"},
+			Subject:    "second",
+			Body:       "hello",
+			Headers:    mail.Header{"X-Customer-Id": {"42"}},
+		}
+
+		firstHash, err := first.ContentHash()
+		assert.Nil(t, err)
+		secondHash, err := second.ContentHash()
+		assert.Nil(t, err)
+
+		// sha256 of "hello\r\n"
+		assert.Equal(t, "sha256=cd2eca3535741f27a8ae40c31b0c41d4057a7a7b912b33b9aed86485d1c84676", firstHash)
+		assert.Equal(t, firstHash, secondHash)
+	})
+	t.Run("should hash different bodies differently", func(t *testing.T) {
+		t.Parallel()
+		first := Message{From: testEmail, Recipients: []string{testEmail}, Body: "hello"}
+		second := Message{From: testEmail, Recipients: []string{testEmail}, Body: "world"}
+
+		firstHash, err := first.ContentHash()
+		assert.Nil(t, err)
+		secondHash, err := second.ContentHash()
+		assert.Nil(t, err)
+		assert.NotEqual(t, firstHash, secondHash)
+	})
+	t.Run("should fail to hash invalid message", func(t *testing.T) {
+		t.Parallel()
+		hash, err := Message{}.ContentHash()
+		assert.Empty(t, hash)
+		assert.Equal(t, fmt.Errorf("failed to compute content hash: %w",
+			fmt.Errorf("failed to encode message: %w", fmt.Errorf("from address cannot be empty"))), err)
+	})
+}
+
+func TestMessage_WithHeader(t *testing.T) {
+	t.Run("should return a copy with the header replaced", func(t *testing.T) {
+		t.Parallel()
+		msg := Message{Headers: mail.Header{"x-content-hash": {"old"}, "X-Campaign": {"welcome"}}}
+
+		updated := msg.WithHeader(ContentHashHeader, "new")
+
+		assert.Equal(t, mail.Header{ContentHashHeader: {"new"}, "X-Campaign": {"welcome"}}, updated.Headers)
+		assert.Equal(t, mail.Header{"x-content-hash": {"old"}, "X-Campaign": {"welcome"}}, msg.Headers)
+	})
+}
+
+func TestMessage_HasHeader(t *testing.T) {
+	t.Run("should match header keys case-insensitively", func(t *testing.T) {
+		t.Parallel()
+		msg := Message{Headers: mail.Header{"message-id": {"<1@gomailer>"}}}
+
+		assert.True(t, msg.HasHeader("Message-ID"))
+		assert.False(t, msg.HasHeader("Date"))
+		assert.False(t, Message{}.HasHeader("Message-ID"))
+	})
+}
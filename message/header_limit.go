@@ -0,0 +1,75 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrHeaderLimit is returned, wrapped in a *HeaderLimitError, when the header section of the encoded message
+// exceeds the limits given to WithMaxHeaderBytes or WithMaxHeaderCount.
+var ErrHeaderLimit = errors.New("message header exceeds the limit")
+
+// HeaderLimitError reports the header field of the encoded message exceeding the limits given to
+// WithMaxHeaderBytes or WithMaxHeaderCount. Use errors.As to retrieve it.
+type HeaderLimitError struct {
+	// Field is the name of the header field exceeding the limit.
+	Field string
+	// Count and Bytes are the number and the size of the header fields up to and including Field.
+	Count, Bytes int
+	// MaxCount and MaxBytes are the limits, zero when not given.
+	MaxCount, MaxBytes int
+}
+
+// Error returns the field and the limit it exceeds.
+func (e *HeaderLimitError) Error() string {
+	if e.MaxCount > 0 && e.Count > e.MaxCount {
+		return fmt.Sprintf("%v: header field %s exceeds the maximum of %d header fields", ErrHeaderLimit, e.Field, e.MaxCount)
+	}
+	return fmt.Sprintf("%v: header field %s exceeds the maximum of %d header bytes with %d bytes", ErrHeaderLimit, e.Field, e.MaxBytes, e.Bytes)
+}
+
+// Unwrap returns ErrHeaderLimit.
+func (e *HeaderLimitError) Unwrap() error {
+	return ErrHeaderLimit
+}
+
+// headerLimit counts the header fields written by a headerWriter against the configured limits.
+type headerLimit struct {
+	maxCount, maxBytes int
+	count, bytes       int
+	// err is the error of the first field exceeding the limits, the following fields are not written.
+	err error
+}
+
+// newHeaderLimit returns the header limit of cfg, nil when no limit is configured.
+func newHeaderLimit(cfg encodeConfig) *headerLimit {
+	if cfg.maxHeaderCount <= 0 && cfg.maxHeaderBytes <= 0 {
+		return nil
+	}
+	return &headerLimit{maxCount: cfg.maxHeaderCount, maxBytes: cfg.maxHeaderBytes}
+}
+
+// allow counts the header field of the given name and encoded size, it reports whether it may be written.
+func (l *headerLimit) allow(key string, size int) bool {
+	if l == nil {
+		return true
+	}
+	if l.err != nil {
+		return false
+	}
+	l.count++
+	l.bytes += size
+	if (l.maxCount > 0 && l.count > l.maxCount) || (l.maxBytes > 0 && l.bytes > l.maxBytes) {
+		l.err = &HeaderLimitError{Field: key, Count: l.count, Bytes: l.bytes, MaxCount: l.maxCount, MaxBytes: l.maxBytes}
+		return false
+	}
+	return true
+}
+
+// error returns the error of the field exceeding the limits, nil when none did or l is nil.
+func (l *headerLimit) error() error {
+	if l == nil {
+		return nil
+	}
+	return l.err
+}
@@ -0,0 +1,70 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage_EncodeHeaderLimits(t *testing.T) {
+	manyHeaders := make(map[string][]string)
+	for i := range 10 {
+		manyHeaders[fmt.Sprintf("X-Custom-%d", i)] = []string{"value"}
+	}
+	tests := map[string]struct {
+		headers     map[string][]string
+		opts        []EncodeOption
+		expectedErr *HeaderLimitError
+	}{
+		"should encode headers within the limits": {
+			headers: manyHeaders,
+			opts:    []EncodeOption{WithMaxHeaderCount(20), WithMaxHeaderBytes(1024)},
+		},
+		"should refuse too many header fields": {
+			headers: manyHeaders,
+			opts:    []EncodeOption{WithMaxHeaderCount(8)},
+			expectedErr: &HeaderLimitError{
+				Field: "X-Custom-3", Count: 9, Bytes: 210, MaxCount: 8,
+			},
+		},
+		"should refuse a header section exceeding the size": {
+			headers: map[string][]string{"X-Huge": {strings.Repeat("a", 5000)}},
+			opts:    []EncodeOption{WithMaxHeaderBytes(4096)},
+			expectedErr: &HeaderLimitError{
+				Field: "X-Huge", Count: 6, Bytes: 5144, MaxBytes: 4096,
+			},
+		},
+		"should not limit the headers by default": {
+			headers: map[string][]string{"X-Huge": {strings.Repeat("a", 5000)}},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			msg := Message{From: testEmail, Recipients: []string{testEmail}, Body: "body", Headers: tc.headers}
+			got, err := msg.Encode(tc.opts...)
+			if tc.expectedErr == nil {
+				assert.Nil(t, err)
+				assert.NotEmpty(t, got)
+				return
+			}
+			assert.ErrorIs(t, err, ErrHeaderLimit)
+			var limitErr *HeaderLimitError
+			if assert.True(t, errors.As(err, &limitErr)) {
+				assert.Equal(t, tc.expectedErr, limitErr)
+			}
+		})
+	}
+	t.Run("should limit the top-level headers of wrapped entities", func(t *testing.T) {
+		t.Parallel()
+		msg := Message{From: testEmail, Recipients: []string{testEmail}, Body: "body", Headers: manyHeaders}
+		_, err := msg.Encode(WithMaxHeaderCount(5), WithEntityWrapper(EntityWrapperFunc(func(entity []byte) ([]byte, error) {
+			return entity, nil
+		})))
+		assert.ErrorIs(t, err, ErrHeaderLimit)
+		assert.ErrorContains(t, err, "exceeds the maximum of 5 header fields")
+	})
+}
@@ -0,0 +1,205 @@
+package message
+
+import (
+	"html"
+	"strings"
+)
+
+// htmlTokenType is the type of an htmlToken.
+type htmlTokenType int
+
+const (
+	htmlTextToken htmlTokenType = iota
+	htmlStartTagToken
+	htmlEndTagToken
+	htmlCommentToken
+	htmlDirectiveToken
+)
+
+// htmlAttr is an attribute of a start tag, its value unescaped.
+type htmlAttr struct {
+	name, value string
+}
+
+// htmlToken is a token of HTML content read by htmlTokenizer.
+type htmlToken struct {
+	typ htmlTokenType
+	// data is the raw text of text tokens, the lower case name of tags, or the raw comment or directive.
+	data  string
+	attrs []htmlAttr
+	// selfClosing indicates a start tag ending with "/>".
+	selfClosing bool
+	// raw indicates the text is the content of a raw text element, e.g. a script or a style sheet.
+	raw bool
+}
+
+// attr returns the value of the attribute of the token, empty when it is missing.
+func (t htmlToken) attr(name string) string {
+	for _, a := range t.attrs {
+		if a.name == name {
+			return a.value
+		}
+	}
+	return ""
+}
+
+// rawTextElements are the elements whose content is not markup (HTML Living Standard section 13.1.2).
+var rawTextElements = map[string]bool{
+	"script": true, "style": true, "title": true, "textarea": true, "xmp": true, "iframe": true, "noembed": true, "noframes": true,
+}
+
+// voidElements are the elements without content nor end tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true, "input": true,
+	"link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// htmlTokenizer splits HTML content into tokens. It is lenient rather than conforming: it does not build
+// the document tree, and malformed markup is read as text or as a tag ending with the content.
+type htmlTokenizer struct {
+	s string
+	// rawTag is the raw text element whose content is read next.
+	rawTag string
+}
+
+// next returns the next token, false once the content is read.
+func (z *htmlTokenizer) next() (htmlToken, bool) {
+	if z.s == "" {
+		return htmlToken{}, false
+	}
+	if z.rawTag != "" {
+		tag := z.rawTag
+		z.rawTag = ""
+		end := indexFold(z.s, "</"+tag)
+		if end < 0 {
+			end = len(z.s)
+		}
+		if end > 0 {
+			text := z.s[:end]
+			z.s = z.s[end:]
+			return htmlToken{typ: htmlTextToken, data: text, raw: true}, true
+		}
+	}
+	if z.s[0] == '<' && len(z.s) > 1 {
+		switch {
+		case strings.HasPrefix(z.s, "<!--"):
+			return htmlToken{typ: htmlCommentToken, data: z.consume(strings.Index(z.s[4:], "-->"), 4, 3)}, true
+		case z.s[1] == '!' || z.s[1] == '?':
+			return htmlToken{typ: htmlDirectiveToken, data: z.consume(strings.IndexByte(z.s, '>'), 0, 1)}, true
+		case z.s[1] == '/' && len(z.s) > 2 && isASCIILetter(z.s[2]):
+			tag := z.consume(strings.IndexByte(z.s, '>'), 0, 1)
+			name := tag[2:]
+			if end := strings.IndexAny(name, " \t\n\f\r/>"); end >= 0 {
+				name = name[:end]
+			}
+			return htmlToken{typ: htmlEndTagToken, data: strings.ToLower(name)}, true
+		case isASCIILetter(z.s[1]):
+			return z.startTag(), true
+		}
+	}
+	end := strings.IndexByte(z.s[1:], '<')
+	if end < 0 {
+		end = len(z.s)
+	} else {
+		end++
+	}
+	text := z.s[:end]
+	z.s = z.s[end:]
+	return htmlToken{typ: htmlTextToken, data: text}, true
+}
+
+// consume consumes and returns the content up to index i of the remaining content offset by off, plus n bytes,
+// or the remaining content when i is negative.
+func (z *htmlTokenizer) consume(i, off, n int) string {
+	end := len(z.s)
+	if i >= 0 {
+		end = min(off+i+n, len(z.s))
+	}
+	s := z.s[:end]
+	z.s = z.s[end:]
+	return s
+}
+
+// startTag reads a start tag and its attributes.
+func (z *htmlTokenizer) startTag() htmlToken {
+	s := z.s
+	i := 1
+	for i < len(s) && !isHTMLSpace(s[i]) && s[i] != '>' && s[i] != '/' {
+		i++
+	}
+	tok := htmlToken{typ: htmlStartTagToken, data: strings.ToLower(s[1:i])}
+	for i < len(s) {
+		for i < len(s) && (isHTMLSpace(s[i]) || s[i] == '/') {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		if s[i] == '>' {
+			tok.selfClosing = s[i-1] == '/'
+			i++
+			break
+		}
+		start := i
+		for i < len(s) && !isHTMLSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+			i++
+		}
+		if i == start {
+			// a stray "=" is read as an attribute name.
+			i++
+		}
+		attr := htmlAttr{name: strings.ToLower(s[start:i])}
+		j := i
+		for j < len(s) && isHTMLSpace(s[j]) {
+			j++
+		}
+		if j < len(s) && s[j] == '=' {
+			j++
+			for j < len(s) && isHTMLSpace(s[j]) {
+				j++
+			}
+			i = j
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				quote := s[i]
+				end := strings.IndexByte(s[i+1:], quote)
+				if end < 0 {
+					end = len(s) - i - 1
+				}
+				attr.value = s[i+1 : i+1+end]
+				i = min(i+end+2, len(s))
+			} else {
+				for i < len(s) && !isHTMLSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				attr.value = s[j:i]
+			}
+			attr.value = html.UnescapeString(attr.value)
+		}
+		tok.attrs = append(tok.attrs, attr)
+	}
+	z.s = s[i:]
+	if rawTextElements[tok.data] {
+		z.rawTag = tok.data
+	}
+	return tok
+}
+
+// isHTMLSpace reports whether c is ASCII whitespace as defined by HTML.
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
+
+// isASCIILetter reports whether c is an ASCII letter.
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// indexFold returns the index of the first ASCII case-insensitive occurrence of substr in s, -1 when it is missing.
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,167 @@
+package message
+
+import (
+	"html"
+	"slices"
+	"strings"
+)
+
+// HTMLPolicy is the policy SanitizeHTML applies to HTML content: the elements and attributes it keeps.
+type HTMLPolicy struct {
+	// Elements maps the allowed elements to their allowed attributes, in lower case, e.g. "a": {"href", "title"}.
+	// The tags of other elements are removed, their content is kept unless it is a script, a style sheet,
+	// an embedded document or a form control.
+	Elements map[string][]string
+	// GlobalAttributes are the attributes allowed on every allowed element, e.g. "style" or "class".
+	// Event handler attributes (onclick, onload, ...) are always removed.
+	GlobalAttributes []string
+	// URLSchemes are the schemes allowed in URL attributes (href, src, ...), e.g. "https". Attributes with another
+	// scheme are removed, relative URLs are always allowed.
+	URLSchemes []string
+}
+
+// DefaultHTMLPolicy returns the policy of HTML messages: the document structure, text formatting, lists, tables,
+// links and images, with the style and presentational attributes mail clients render, and http, https, mailto, tel
+// and cid URLs. Scripts, forms, embedded documents and event handlers are removed. Style sheets and style attributes
+// are kept as is, CSS is not sanitized.
+func DefaultHTMLPolicy() HTMLPolicy {
+	cell := []string{"width", "height", "align", "valign", "bgcolor", "colspan", "rowspan"}
+	elements := map[string][]string{
+		"body":       {"bgcolor"},
+		"style":      {"type", "media"},
+		"meta":       {"charset", "name", "content"},
+		"a":          {"href", "name", "target"},
+		"img":        {"src", "alt", "width", "height", "border", "align"},
+		"hr":         {"width", "size", "noshade"},
+		"blockquote": {"cite"},
+		"font":       {"color", "face", "size"},
+		"ol":         {"start", "type"},
+		"table":      {"width", "border", "cellpadding", "cellspacing", "align", "bgcolor", "role"},
+		"colgroup":   {"span", "width"},
+		"col":        {"span", "width"},
+		"tr":         {"align", "valign", "bgcolor"},
+		"td":         cell,
+		"th":         cell,
+	}
+	for _, name := range []string{"p", "div", "h1", "h2", "h3", "h4", "h5", "h6"} {
+		elements[name] = []string{"align"}
+	}
+	for _, name := range []string{
+		"html", "head", "title", "span", "br", "center", "b", "strong", "i", "em", "u", "s", "strike", "del", "ins",
+		"small", "big", "sub", "sup", "code", "pre", "abbr", "ul", "li", "dl", "dt", "dd", "caption", "thead", "tbody", "tfoot",
+	} {
+		elements[name] = nil
+	}
+	return HTMLPolicy{
+		Elements:         elements,
+		GlobalAttributes: []string{"style", "class", "id", "dir", "lang", "title"},
+		URLSchemes:       []string{"http", "https", "mailto", "tel", "cid"},
+	}
+}
+
+// droppedContentElements are the elements whose content is removed along with them when they are not allowed.
+var droppedContentElements = map[string]bool{
+	"script": true, "style": true, "title": true, "textarea": true, "xmp": true, "iframe": true, "noembed": true,
+	"noframes": true, "object": true, "applet": true, "template": true, "select": true, "svg": true, "math": true,
+}
+
+// urlAttributes are the attributes holding a URL.
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "action": true, "formaction": true, "background": true, "cite": true, "longdesc": true,
+	"poster": true, "lowsrc": true, "dynsrc": true, "xlink:href": true,
+}
+
+// SanitizeHTML returns the HTML content sanitized against the policy, e.g. to send HTML built from user input.
+// Tags of elements the policy does not allow and attributes it does not allow are removed, as are comments,
+// including the conditional comments of Outlook, and processing instructions. The doctype is kept.
+// The content is tokenized rather than parsed into a document, the elements are not balanced.
+func SanitizeHTML(content string, p HTMLPolicy) string {
+	var (
+		b     strings.Builder
+		z     = htmlTokenizer{s: content}
+		skip  string
+		depth int
+	)
+	for tok, ok := z.next(); ok; tok, ok = z.next() {
+		if skip != "" {
+			switch {
+			case tok.typ == htmlStartTagToken && tok.data == skip && !tok.selfClosing:
+				depth++
+			case tok.typ == htmlEndTagToken && tok.data == skip:
+				if depth--; depth == 0 {
+					skip = ""
+				}
+			}
+			continue
+		}
+		switch tok.typ {
+		case htmlTextToken:
+			if tok.raw {
+				// the raw text of an allowed element, e.g. a style sheet, cannot contain its end tag.
+				b.WriteString(tok.data)
+			} else {
+				b.WriteString(strings.ReplaceAll(tok.data, "<", "&lt;"))
+			}
+		case htmlStartTagToken:
+			attrs, ok := p.Elements[tok.data]
+			if !ok {
+				if droppedContentElements[tok.data] && !tok.selfClosing {
+					skip, depth = tok.data, 1
+				}
+				continue
+			}
+			b.WriteString("<" + tok.data)
+			for _, a := range tok.attrs {
+				if !p.allowsAttr(a, attrs) {
+					continue
+				}
+				b.WriteString(" " + a.name + `="` + html.EscapeString(a.value) + `"`)
+			}
+			if tok.selfClosing {
+				b.WriteString(" /")
+			}
+			b.WriteString(">")
+		case htmlEndTagToken:
+			if _, ok := p.Elements[tok.data]; ok {
+				b.WriteString("</" + tok.data + ">")
+			}
+		case htmlDirectiveToken:
+			if len(tok.data) > 9 && strings.EqualFold(tok.data[:9], "<!doctype") {
+				b.WriteString(tok.data)
+			}
+		}
+	}
+	return b.String()
+}
+
+// allowsAttr reports whether the attribute is allowed on an element allowing the attributes attrs.
+func (p HTMLPolicy) allowsAttr(a htmlAttr, attrs []string) bool {
+	if strings.HasPrefix(a.name, "on") || (!slices.Contains(attrs, a.name) && !slices.Contains(p.GlobalAttributes, a.name)) {
+		return false
+	}
+	if !urlAttributes[a.name] {
+		return true
+	}
+	// browsers ignore whitespace and control characters within the scheme, e.g. "java\tscript:".
+	url := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, a.value)
+	end := strings.IndexAny(url, ":/?#")
+	if end < 0 || url[end] != ':' {
+		return true
+	}
+	return slices.ContainsFunc(p.URLSchemes, func(scheme string) bool {
+		return strings.EqualFold(scheme, url[:end])
+	})
+}
+
+// WithSanitizedHTML returns a copy of the Message whose HTML body is sanitized against the policy with SanitizeHTML.
+func (m Message) WithSanitizedHTML(p HTMLPolicy) Message {
+	if m.HTMLBody != "" {
+		m.HTMLBody = SanitizeHTML(m.HTMLBody, p)
+	}
+	return m
+}
@@ -0,0 +1,70 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := map[string]struct {
+		html     string
+		policy   *HTMLPolicy
+		expected string
+	}{
+		"should keep the allowed elements and attributes": {
+			html:     `<p align="center" style="color: red">Hi <a href="https://example.com" target="_blank">there</a><br/></p>`,
+			expected: `<p align="center" style="color: red">Hi <a href="https://example.com" target="_blank">there</a><br /></p>`,
+		},
+		"should remove scripts and embedded documents with their content": {
+			html:     `<p>Hi</p><script>alert("</p>")</script><iframe src="https://example.com">frame</iframe><object><p>x</p></object>`,
+			expected: `<p>Hi</p>`,
+		},
+		"should remove the tags of other elements and keep their content": {
+			html:     `<form action="/login"><label>Name <input name="user"></label></form><p>Hi</p>`,
+			expected: `Name <p>Hi</p>`,
+		},
+		"should remove event handlers and unknown attributes": {
+			html:     `<img src="cid:logo" alt="Logo" onerror="alert(1)" data-track="1"><div ONCLICK=alert(1)>x</div>`,
+			expected: `<img src="cid:logo" alt="Logo"><div>x</div>`,
+		},
+		"should remove urls of disallowed schemes": {
+			html: `<a href="javascript:alert(1)">a</a><a href=" java&#x09;script:alert(1)">b</a><a href="data:text/html,x">c</a>` +
+				`<a href="/relative?q=a:b">d</a><a href="MAILTO:user@example.com">e</a>`,
+			expected: `<a>a</a><a>b</a><a>c</a><a href="/relative?q=a:b">d</a><a href="MAILTO:user@example.com">e</a>`,
+		},
+		"should escape attribute values and stray markup": {
+			html:     `<p title='a "quote" &amp; more'>1 < 2</p>`,
+			expected: `<p title="a &#34;quote&#34; &amp; more">1 &lt; 2</p>`,
+		},
+		"should keep the doctype and style sheets and remove comments": {
+			html: "<!DOCTYPE html><html><head><style>td > p { color: red; }</style></head>" +
+				"<body><!--[if mso]><table><![endif]--><p>Hi</p><?xml version=\"1.0\"?></body></html>",
+			expected: "<!DOCTYPE html><html><head><style>td > p { color: red; }</style></head><body><p>Hi</p></body></html>",
+		},
+		"should apply a custom policy": {
+			html:     `<p class="lead"><b>Bold</b> <a href="http://example.com">link</a></p>`,
+			policy:   &HTMLPolicy{Elements: map[string][]string{"a": {"href"}, "b": nil}, URLSchemes: []string{"https"}},
+			expected: `<b>Bold</b> <a>link</a>`,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			policy := DefaultHTMLPolicy()
+			if tc.policy != nil {
+				policy = *tc.policy
+			}
+			assert.Equal(t, tc.expected, SanitizeHTML(tc.html, policy))
+		})
+	}
+}
+
+func TestMessage_WithSanitizedHTML(t *testing.T) {
+	msg := Message{From: testEmail, Recipients: []string{testEmail}, HTMLBody: `<p onclick="x()">Hi</p><script>x()</script>`}
+
+	got := msg.WithSanitizedHTML(DefaultHTMLPolicy())
+
+	assert.Equal(t, "<p>Hi</p>", got.HTMLBody)
+	assert.Equal(t, `<p onclick="x()">Hi</p><script>x()</script>`, msg.HTMLBody)
+}
@@ -0,0 +1,236 @@
+package message
+
+import (
+	"html"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// hiddenTextElements are the elements whose content is not displayed as text.
+var hiddenTextElements = map[string]bool{
+	"head": true, "script": true, "style": true, "title": true, "template": true, "iframe": true, "object": true,
+	"noembed": true, "noframes": true,
+}
+
+// paragraphElements are the elements separated from the surrounding text by an empty line.
+var paragraphElements = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "ul": true, "ol": true,
+	"dl": true, "table": true, "blockquote": true, "pre": true, "hr": true,
+}
+
+// lineElements are the elements starting on a new line.
+var lineElements = map[string]bool{
+	"div": true, "tr": true, "li": true, "dt": true, "dd": true, "section": true, "article": true, "header": true,
+	"footer": true, "main": true, "nav": true, "aside": true, "address": true, "figure": true, "figcaption": true,
+	"form": true, "center": true, "caption": true,
+}
+
+// invisibleRunes are the zero-width and invisible characters removed from the text, e.g. padding of a preheader.
+var invisibleRunes = map[rune]bool{
+	'\u00ad': true, '\u034f': true, '\u200b': true, '\u200c': true, '\u200d': true, '\u2060': true, '\ufeff': true,
+}
+
+// HTMLToText returns the plain text version of the HTML content, as displayed by a mail client: the tags are stripped,
+// the entities decoded and the whitespace collapsed; paragraphs and headings are separated by an empty line, list items
+// prefixed with "* " or their number, and links followed by their URL, e.g. "pricing (https://example.com/pricing)".
+// The head, scripts, style sheets and elements hidden with display:none are omitted.
+func HTMLToText(content string) string {
+	var (
+		w     textWriter
+		z     = htmlTokenizer{s: content}
+		skip  string
+		depth int
+		pre   int
+		lists []int
+		links []textLink
+	)
+	for tok, ok := z.next(); ok; tok, ok = z.next() {
+		if skip != "" {
+			switch {
+			case tok.typ == htmlStartTagToken && skip == "head" && tok.data == "body":
+				// the end tag of the head is optional.
+				skip = ""
+			case tok.typ == htmlStartTagToken && tok.data == skip && !voidElements[tok.data] && !tok.selfClosing:
+				depth++
+				continue
+			case tok.typ == htmlEndTagToken && tok.data == skip:
+				if depth--; depth == 0 {
+					skip = ""
+				}
+				continue
+			default:
+				continue
+			}
+		}
+		switch tok.typ {
+		case htmlTextToken:
+			text := html.UnescapeString(tok.data)
+			if pre > 0 {
+				w.writePre(text)
+			} else {
+				w.writeText(text)
+			}
+		case htmlStartTagToken:
+			if (hiddenTextElements[tok.data] || hiddenStyle(tok.attr("style"))) && !voidElements[tok.data] && !tok.selfClosing {
+				skip, depth = tok.data, 1
+				continue
+			}
+			switch {
+			case tok.data == "br":
+				w.newlines++
+			case tok.data == "li":
+				w.breakLines(1)
+				if len(lists) > 0 && lists[len(lists)-1] >= 0 {
+					lists[len(lists)-1]++
+					w.writeText(strconv.Itoa(lists[len(lists)-1]) + ". ")
+				} else {
+					w.writeText("* ")
+				}
+			case tok.data == "ol" || tok.data == "ul":
+				w.breakLines(2)
+				next := 0
+				if tok.data == "ul" {
+					next = -1
+				} else if start, err := strconv.Atoi(tok.attr("start")); err == nil {
+					next = start - 1
+				}
+				lists = append(lists, next)
+			case tok.data == "td" || tok.data == "th":
+				w.space = true
+			case tok.data == "img":
+				w.writeText(tok.attr("alt"))
+			case tok.data == "a":
+				links = append(links, textLink{href: strings.TrimSpace(tok.attr("href")), start: w.len()})
+			case paragraphElements[tok.data]:
+				w.breakLines(2)
+			case lineElements[tok.data]:
+				w.breakLines(1)
+			}
+			if tok.data == "pre" {
+				pre++
+			}
+		case htmlEndTagToken:
+			switch {
+			case tok.data == "ol" || tok.data == "ul":
+				if len(lists) > 0 {
+					lists = lists[:len(lists)-1]
+				}
+				w.breakLines(2)
+			case tok.data == "a":
+				if len(links) == 0 {
+					continue
+				}
+				link := links[len(links)-1]
+				links = links[:len(links)-1]
+				w.writeLink(link)
+			case paragraphElements[tok.data]:
+				w.breakLines(2)
+			case lineElements[tok.data]:
+				w.breakLines(1)
+			}
+			if tok.data == "pre" && pre > 0 {
+				pre--
+			}
+		}
+	}
+	return w.String()
+}
+
+// hiddenStyle reports whether the style attribute hides the element with display:none.
+func hiddenStyle(style string) bool {
+	return strings.Contains(strings.ToLower(strings.Join(strings.Fields(style), "")), "display:none")
+}
+
+// textLink is a link of the HTML content being converted to text.
+type textLink struct {
+	href string
+	// start is the length of the text when the link started.
+	start int
+}
+
+// textWriter writes the text of HTML content, collapsing the whitespace.
+type textWriter struct {
+	b strings.Builder
+	// newlines are the line breaks written before the next text.
+	newlines int
+	// space indicates a space is written before the next text.
+	space bool
+}
+
+// len returns the length of the written text.
+func (w *textWriter) len() int {
+	return w.b.Len()
+}
+
+// breakLines ends the current line with at least n line breaks, n = 2 leaving an empty line.
+func (w *textWriter) breakLines(n int) {
+	w.newlines = max(w.newlines, n)
+}
+
+// flush writes the pending line breaks or space, which are dropped at the start of the text.
+func (w *textWriter) flush() {
+	if w.b.Len() > 0 {
+		if w.newlines > 0 {
+			w.b.WriteString(strings.Repeat("\n", w.newlines))
+		} else if w.space {
+			w.b.WriteByte(' ')
+		}
+	}
+	w.newlines, w.space = 0, false
+}
+
+// writeText writes text, collapsing its whitespace.
+func (w *textWriter) writeText(text string) {
+	for _, r := range text {
+		switch {
+		case invisibleRunes[r]:
+		case unicode.IsSpace(r):
+			w.space = true
+		default:
+			w.flush()
+			w.b.WriteRune(r)
+		}
+	}
+}
+
+// writePre writes preformatted text as is.
+func (w *textWriter) writePre(text string) {
+	if text == "" {
+		return
+	}
+	w.space = false
+	w.flush()
+	w.b.WriteString(strings.ReplaceAll(text, "\r\n", "\n"))
+}
+
+// writeLink writes the URL of the link after its text, unless the text is the URL or the link is not a URL.
+func (w *textWriter) writeLink(link textLink) {
+	href := link.href
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return
+	}
+	text := strings.TrimSpace(w.b.String()[link.start:])
+	address, mailto := strings.CutPrefix(href, "mailto:")
+	switch {
+	case text == "":
+		w.writeText(href)
+	case text != href && !(mailto && text == address):
+		w.writeText(" (" + href + ")")
+	}
+}
+
+// String returns the written text.
+func (w *textWriter) String() string {
+	return w.b.String()
+}
+
+// WithTextFromHTML returns a copy of the Message whose body is generated from its HTML body with HTMLToText,
+// when it has an HTML body but no body. Messages with only an HTML body get worse spam scores, the copy is sent
+// as multipart/alternative with both versions.
+func (m Message) WithTextFromHTML() Message {
+	if m.Body == "" && m.HTMLBody != "" {
+		m.Body = HTMLToText(m.HTMLBody)
+	}
+	return m
+}
@@ -0,0 +1,94 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTMLToText(t *testing.T) {
+	tests := map[string]struct {
+		html     string
+		expected string
+	}{
+		"should strip the tags and collapse the whitespace": {
+			html:     "<p>Hello   <b>world</b>,\n  welcome!</p>",
+			expected: "Hello world, welcome!",
+		},
+		"should separate paragraphs and headings by an empty line": {
+			html:     "<h1>Title</h1><p>First</p><p>Second<br>line</p><div>Block</div><div>Next</div>",
+			expected: "Title\n\nFirst\n\nSecond\nline\n\nBlock\nNext",
+		},
+		"should decode the entities": {
+			html:     "<p>Caf&eacute; &amp; cr&#232;me &lt;3&nbsp;&#x2764;</p>",
+			expected: "Café & crème <3 ❤",
+		},
+		"should follow links by their url": {
+			html: `<p>See <a href="https://example.com/pricing">pricing</a>, <a href="https://example.com">https://example.com</a>,` +
+				` <a href="mailto:support@example.com">support@example.com</a> or <a href="#top">top</a>.</p>`,
+			expected: "See pricing (https://example.com/pricing), https://example.com, support@example.com or top.",
+		},
+		"should write the url of links without text": {
+			html:     `<a href="https://example.com"><img src="cid:logo"></a>`,
+			expected: "https://example.com",
+		},
+		"should write the alternative text of images": {
+			html:     `<p><img src="cid:logo" alt="Example Inc."> News</p>`,
+			expected: "Example Inc. News",
+		},
+		"should prefix list items": {
+			html:     "<p>Steps:</p><ol><li>Sign up</li><li>Verify</li></ol><ul><li>one</li><li>two</li></ul><ol start=\"3\"><li>three</li></ol>",
+			expected: "Steps:\n\n1. Sign up\n2. Verify\n\n* one\n* two\n\n3. three",
+		},
+		"should write table rows on their own line": {
+			html:     "<table><tr><th>Item</th><th>Price</th></tr><tr><td>Book</td><td>$10</td></tr></table>",
+			expected: "Item Price\nBook $10",
+		},
+		"should keep preformatted text": {
+			html:     "<p>Code:</p><pre>if ok {\n    return\n}</pre><p>Done</p>",
+			expected: "Code:\n\nif ok {\n    return\n}\n\nDone",
+		},
+		"should omit the head, scripts, style sheets and hidden elements": {
+			html: "<!DOCTYPE html><html><head><title>Title</title><style>p { color: red; }</style></head><body>" +
+				"<!-- comment --><div style=\"display: none\">Preview <div>text</div></div><script>if (a < b) {}</script>" +
+				"<p>Body</p></body></html>",
+			expected: "Body",
+		},
+		"should read the body of a head without end tag": {
+			html:     "<html><head><title>Title</title><body><p>Body</p></body></html>",
+			expected: "Body",
+		},
+		"should remove invisible characters": {
+			html:     "<p>Hi&zwnj;&nbsp;&#847;&zwnj;&nbsp;there</p>",
+			expected: "Hi there",
+		},
+		"should read malformed markup as text": {
+			html:     "<p>1 < 2 and 3 > 2</p><p>unterminated <b",
+			expected: "1 < 2 and 3 > 2\n\nunterminated",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, HTMLToText(tc.html))
+		})
+	}
+}
+
+func TestMessage_WithTextFromHTML(t *testing.T) {
+	t.Run("should generate the body from the HTML body", func(t *testing.T) {
+		msg := Message{From: testEmail, Recipients: []string{testEmail}, HTMLBody: "<p>Hello <b>world</b></p>"}
+
+		got := msg.WithTextFromHTML()
+
+		assert.Equal(t, "Hello world", got.Body)
+		assert.Equal(t, msg.HTMLBody, got.HTMLBody)
+		assert.Empty(t, msg.Body)
+	})
+
+	t.Run("should keep the body given", func(t *testing.T) {
+		msg := Message{From: testEmail, Recipients: []string{testEmail}, Body: "text", HTMLBody: "<p>html</p>"}
+
+		assert.Equal(t, msg, msg.WithTextFromHTML())
+	})
+}
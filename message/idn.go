@@ -0,0 +1,64 @@
+package message
+
+import (
+	"strings"
+
+	"github.com/nawafswe/gomailer/internal/punycode"
+)
+
+// WithSMTPUTF8 keeps internationalized domains of the address header fields as is, for SMTP servers advertising
+// the SMTPUTF8 extension (RFC 6531). They are converted to punycode by default, so any server delivers the message.
+func WithSMTPUTF8() EncodeOption {
+	return func(cfg *encodeConfig) {
+		cfg.smtpUTF8 = true
+	}
+}
+
+// ToASCII returns the address with the internationalized domain converted to punycode, e.g. user@xn--bcher-kva.de
+// for user@bücher.de, which servers without SMTPUTF8 support accept. The local part and display name are kept as is.
+func (a Address) ToASCII() (Address, error) {
+	at := strings.LastIndexByte(a.Email, '@')
+	domain, err := punycode.ToASCII(a.Email[at+1:])
+	if err != nil {
+		return a, err
+	}
+	a.Email = a.Email[:at+1] + domain
+	return a, nil
+}
+
+// ToUnicode returns the address with the punycode domain converted back to Unicode for display, e.g. user@bücher.de
+// for user@xn--bcher-kva.de. A domain that is not valid punycode is kept as is.
+func (a Address) ToUnicode() Address {
+	at := strings.LastIndexByte(a.Email, '@')
+	if domain, err := punycode.ToUnicode(a.Email[at+1:]); err == nil {
+		a.Email = a.Email[:at+1] + domain
+	}
+	return a
+}
+
+// withASCIIDomains returns a copy of m with the internationalized domains of the addresses written in the header fields
+// converted to punycode. Addresses that cannot be converted are kept as is.
+func withASCIIDomains(m Message) Message {
+	m.From = asciiDomain(m.From)
+	m.ReturnReceiptTo = asciiDomain(m.ReturnReceiptTo)
+	for _, list := range []*[]string{&m.Recipients, &m.Cc, &m.Bcc, &m.DispositionNotificationTo} {
+		converted := make([]string, 0, len(*list))
+		for _, a := range *list {
+			converted = append(converted, asciiDomain(a))
+		}
+		*list = converted
+	}
+	return m
+}
+
+// asciiDomain converts the domain of the address to punycode, the address is returned as given when its domain is ASCII.
+func asciiDomain(a string) string {
+	addr, err := ParseAddress(a)
+	if err != nil || is7Bit(addr.Email[strings.LastIndexByte(addr.Email, '@')+1:]) {
+		return a
+	}
+	if addr, err = addr.ToASCII(); err != nil {
+		return a
+	}
+	return addr.String()
+}
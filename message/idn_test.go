@@ -0,0 +1,104 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddress_ToASCII(t *testing.T) {
+	tests := map[string]struct {
+		address  Address
+		expected Address
+	}{
+		"should keep ascii domains": {
+			address:  Address{Email: testEmail},
+			expected: Address{Email: testEmail},
+		},
+		"should convert internationalized domains to punycode": {
+			address:  Address{Name: "Bücher", Email: "user@Bücher.de"},
+			expected: Address{Name: "Bücher", Email: "user@xn--bcher-kva.de"},
+		},
+		"should keep non-ascii local parts": {
+			address:  Address{Email: "نواف@مثال.إختبار"},
+			expected: Address{Email: "نواف@xn--mgbh0fb.xn--kgbechtv"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := tc.address.ToASCII()
+			require.Nil(t, err)
+			assert.Equal(t, tc.expected, got)
+			assert.Equal(t, tc.address.Name, got.ToUnicode().Name)
+		})
+	}
+}
+
+func TestAddress_ToUnicode(t *testing.T) {
+	tests := map[string]struct {
+		address  Address
+		expected Address
+	}{
+		"should keep ascii domains": {
+			address:  Address{Email: testEmail},
+			expected: Address{Email: testEmail},
+		},
+		"should convert punycode domains to unicode": {
+			address:  Address{Name: "Books", Email: "user@xn--bcher-kva.de"},
+			expected: Address{Name: "Books", Email: "user@bücher.de"},
+		},
+		"should keep invalid punycode domains": {
+			address:  Address{Email: "user@xn--bcher-kv!.de"},
+			expected: Address{Email: "user@xn--bcher-kv!.de"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, tc.address.ToUnicode())
+		})
+	}
+}
+
+func TestMessage_EncodeInternationalizedDomains(t *testing.T) {
+	msg := Message{
+		From:                      "Bücher <shop@bücher.de>",
+		Recipients:                []string{"user@例え.jp"},
+		Cc:                        []string{testEmail},
+		DispositionNotificationTo: []string{"receipts@bücher.de"},
+		Body:                      "hello",
+	}
+	tests := map[string]struct {
+		opts     []EncodeOption
+		expected []string
+	}{
+		"should convert domains to punycode": {
+			expected: []string{
+				"From: =?utf-8?q?B=C3=BCcher?= <shop@xn--bcher-kva.de>\r\n",
+				"To: user@xn--r8jz45g.jp\r\n",
+				"Cc: " + testEmail + "\r\n",
+				"Disposition-Notification-To: receipts@xn--bcher-kva.de\r\n",
+			},
+		},
+		"should keep domains as is for SMTPUTF8": {
+			opts: []EncodeOption{WithSMTPUTF8()},
+			expected: []string{
+				"From: =?utf-8?q?B=C3=BCcher?= <shop@bücher.de>\r\n",
+				"To: user@例え.jp\r\n",
+				"Disposition-Notification-To: receipts@bücher.de\r\n",
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			encoded, err := msg.Encode(tc.opts...)
+			require.Nil(t, err)
+			for _, header := range tc.expected {
+				assert.Contains(t, string(encoded), header)
+			}
+		})
+	}
+}
@@ -1,9 +1,15 @@
 package message
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/mail"
+	"net/textproto"
+	"slices"
 	"strings"
+	"time"
 )
 
 const (
@@ -12,18 +18,14 @@ const (
 
 	// plainContentType is the default Content-Type according to RFC 2045, section 5.2
 	plainContentType = "text/plain; charset=us-ascii"
+	// plainUTF8ContentType is the Content-Type of plain text with non-ASCII content.
+	plainUTF8ContentType = "text/plain; charset=UTF-8"
 	// htmlTypeContentType to support content type with HTML.
 	htmlTypeContentType = "text/html; charset=UTF-8"
-
-	// The boundary string is used to separate different parts of a multipart email message.
-	// This is essential for correctly formatting emails with attachments or multiple content types.
-	// For more details, refer to: https://datatracker.ietf.org/doc/html/rfc2046
-	boundary = "BOUNDARY"
-
-	// The altBoundary string is used to separate different parts of a multipart email message.
-	// This is essential for correctly formatting emails with attachments or multiple content types.
-	// For more details, refer to: https://datatracker.ietf.org/doc/html/rfc2046
-	altBoundary = "ALT-BOUNDARY"
+	// ampContentType is the Content-Type of AMP for Email bodies.
+	ampContentType = "text/x-amp-html; charset=UTF-8"
+	// calendarContentType is the Content-Type of calendar invitations (RFC 6047 section 2.4), followed by their method.
+	calendarContentType = "text/calendar; charset=UTF-8"
 
 	// The crlf sequence is used to terminate lines in email messages, as specified by RFC 5322.
 	// This ensures proper formatting and compatibility with email clients and servers.
@@ -33,15 +35,13 @@ const (
 	separator = ", "
 )
 
-var (
-	multiPartMixedContentType       = fmt.Sprintf("multipart/mixed; boundary=%s", boundary)
-	multiPartAlternativeContentType = fmt.Sprintf("multipart/alternative; boundary=%s", altBoundary)
-)
-
 // Message will be sent in email.
 type Message struct {
 	// From whom is going to send that mail.
 	From string
+	// EnvelopeFrom is the envelope sender given to the MAIL command in place of From, where the receiving servers send
+	// bounces, e.g. a VERP address or the mailbox of a service sending on behalf of From. It is not written as a header.
+	EnvelopeFrom string
 	// Recipients contains the primary recipients of the email.
 	Recipients []string
 	// Cc contains the recipients who will receive a carbon copy of the email.
@@ -52,13 +52,48 @@ type Message struct {
 	// allowing email clients to choose the most suitable version to display. Ensure that the content of Body and HTMLBody is equivalent
 	// to provide a consistent user experience. For more details, refer to: https://datatracker.ietf.org/doc/html/rfc2046#section-5.1.4
 	Body, HTMLBody string
+	// AMPBody is an AMP for Email version of the content, sent as a text/x-amp-html alternative between Body and HTMLBody
+	// so clients supporting AMP (e.g. Gmail) display it interactively. It requires Body or HTMLBody as a fallback,
+	// clients without AMP support display.
+	AMPBody string
+	// PreviewText is the snippet mail clients (e.g. Gmail, Apple Mail) show next to the subject, sent as hidden preheader
+	// content at the start of HTML bodies. It is padded, so the snippet does not run into the visible content.
+	// It is ignored without HTMLBody.
+	PreviewText string
 	// Subject the subject of the email.
 	Subject string
 	// Headers Extra mail headers
 	Headers mail.Header
+	// Trace holds the trace and resent fields (RFC 5322 section 3.6.6 and 3.6.7), e.g. Received or Resent-From,
+	// written in order above the other header fields, the newest first, so the fields of a resend stay together.
+	Trace []Field
+	// DateLocation is the time zone of the Date header added by the Mailer, overriding the one of the Mailer.
+	DateLocation *time.Location
+	// Priority flags the message as important or unimportant with the headers every mail client expects,
+	// priority headers given in Headers take precedence.
+	Priority Priority
+	// Unsubscribe sends the List-Unsubscribe headers, list headers given in Headers take precedence.
+	Unsubscribe *Unsubscribe
+	// Bulk marks the message as bulk mail with the Precedence header, it requires Unsubscribe as bulk
+	// senders must let recipients unsubscribe (e.g. Gmail and Yahoo sender requirements).
+	Bulk bool
+	// DispositionNotificationTo requests a read receipt sent to the addresses when the recipient displays the message (RFC 8098),
+	// which mail clients usually ask the recipient to confirm. The header given in Headers takes precedence.
+	DispositionNotificationTo []string
+	// ReturnReceiptTo requests a read receipt with the legacy Return-Receipt-To header, still honored by some clients.
+	// The header given in Headers takes precedence.
+	ReturnReceiptTo string
 
 	// Attachments any files attached to email.
 	Attachments []Attachment
+	// Alternatives are additional versions of the content, e.g. a text/markdown copy or an application/json payload
+	// for machine processing, sent as parts of the multipart/alternative entity. They precede the bodies,
+	// as clients display the last alternative they support (RFC 2046 section 5.1.4).
+	Alternatives []Alternative
+	// Calendar is a meeting invitation sent as a text/calendar alternative of the bodies, see CalendarEvent.
+	Calendar *CalendarEvent
+	// DSN requests delivery status notifications for the message, none are requested when nil.
+	DSN *DSN
 }
 
 func NewMessage() Message {
@@ -67,6 +102,42 @@ func NewMessage() Message {
 	}
 }
 
+// WithHeader returns a copy of the Message with the header set to the given values, replacing any existing values.
+// The headers of the original Message are left untouched.
+func (m Message) WithHeader(key string, values ...string) Message {
+	headers := make(mail.Header, len(m.Headers)+1)
+	for k, v := range m.Headers {
+		if textproto.CanonicalMIMEHeaderKey(k) != textproto.CanonicalMIMEHeaderKey(key) {
+			headers[k] = v
+		}
+	}
+	headers[key] = values
+	m.Headers = headers
+	return m
+}
+
+// Field is a header field of the Message, see Message.Trace.
+type Field struct {
+	Key, Value string
+}
+
+// WithTrace returns a copy of the Message with the fields added above its Trace, e.g. the Resent fields of a resend.
+// The trace of the original Message is left untouched.
+func (m Message) WithTrace(fields ...Field) Message {
+	m.Trace = slices.Concat(fields, m.Trace)
+	return m
+}
+
+// HasHeader reports whether the Message carries the header, the key is matched case-insensitively.
+func (m Message) HasHeader(key string) bool {
+	for k := range m.Headers {
+		if textproto.CanonicalMIMEHeaderKey(k) == textproto.CanonicalMIMEHeaderKey(key) {
+			return true
+		}
+	}
+	return false
+}
+
 // validate validates message primary fields before send operation.
 func (m Message) validate() error {
 	if m.From == "" {
@@ -79,49 +150,159 @@ func (m Message) validate() error {
 		return fmt.Errorf("recipients cannot be empty slice")
 	}
 
-	for _, r := range m.Recipients {
-		if _, err := mail.ParseAddress(r); err != nil {
-			return fmt.Errorf("given %s is invalid recipient email: %w", r, err)
+	if m.DSN != nil {
+		if err := m.DSN.validate(); err != nil {
+			return err
 		}
 	}
-	return nil
+	if err := m.Priority.validate(); err != nil {
+		return err
+	}
+	if m.Unsubscribe != nil {
+		if err := m.Unsubscribe.validate(); err != nil {
+			return err
+		}
+	} else if m.Bulk {
+		return fmt.Errorf("bulk messages require an unsubscribe URL or mailto address")
+	}
+	if m.AMPBody != "" && m.Body == "" && m.HTMLBody == "" {
+		return fmt.Errorf("AMP body requires a body or an HTML body as fallback")
+	}
+	if m.Calendar != nil {
+		if err := m.Calendar.validate(); err != nil {
+			return err
+		}
+	}
+	for _, a := range m.Attachments {
+		if err := a.validate(); err != nil {
+			return err
+		}
+	}
+	for _, a := range m.Alternatives {
+		if err := a.validate(); err != nil {
+			return err
+		}
+	}
+	for k := range m.Headers {
+		if !validHeaderName(k) {
+			return fmt.Errorf("invalid header field name %q", k)
+		}
+	}
+	for _, f := range m.Trace {
+		if !validHeaderName(f.Key) {
+			return fmt.Errorf("invalid header field name %q", f.Key)
+		}
+	}
+	// every invalid address is reported, each as an *AddressError.
+	errs := validateAddresses("recipient", m.Recipients)
+	errs = append(errs, validateAddresses("cc", m.Cc)...)
+	errs = append(errs, validateAddresses("bcc", m.Bcc)...)
+	errs = append(errs, validateAddresses("disposition notification", m.DispositionNotificationTo)...)
+	if m.ReturnReceiptTo != "" {
+		errs = append(errs, validateAddresses("return receipt", []string{m.ReturnReceiptTo})...)
+	}
+	if m.EnvelopeFrom != "" {
+		errs = append(errs, validateAddresses("envelope from", []string{m.EnvelopeFrom})...)
+	}
+	return errors.Join(errs...)
+}
+
+// validHeaderName reports whether name is a valid header field name, made of printable ASCII characters except colon (RFC 5322 section 2.2).
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c < '!' || c > '~' || c == ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// Requires8BitMIME reports whether the body, AMP body, HTML body, alternatives or calendar invitation contain 8bit content, which is either sent
+// to SMTP servers advertising the 8BITMIME extension or quoted-printable encoded (see With7BitTransport).
+func (m Message) Requires8BitMIME() bool {
+	if !is7Bit(m.Body) || !is7Bit(m.AMPBody) || !is7Bit(m.HTMLBody) || (m.Calendar != nil && !m.Calendar.is7Bit()) {
+		return true
+	}
+	for _, a := range m.Alternatives {
+		if !is7Bit(a.Content) {
+			return true
+		}
+	}
+	return false
 }
 
-func (m Message) Encode() ([]byte, error) {
+// Encode validates the message and encodes it into the bytes sent to the SMTP server, see Prepare to write it instead.
+func (m Message) Encode(opts ...EncodeOption) ([]byte, error) {
 	if err := m.validate(); err != nil {
 		return nil, fmt.Errorf("failed to encode message: %w", err)
 	}
-	return encode(m), nil
+	encoded, err := encode(m, newEncodeConfig(opts))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode message: %w", err)
+	}
+	return encoded, nil
 }
 
-// Attachment attached files to Message.
+// Attachment attached files to Message, see AttachFile and AttachReader.
 type Attachment struct {
 	Filename string
 	Data     []byte
+	// MIMEType of the attachment, detected from the Filename extension or the Data when empty.
 	MIMEType string
+	// ContentID makes the attachment inline, displayed within the HTML body which references it as cid:ContentID,
+	// e.g. <img src="cid:logo">. Inline attachments are sent along with the other attachments when the message has no HTML body.
+	ContentID string
+}
+
+// dispositionType returns the Content-Disposition type of the attachment (RFC 2183).
+func (a Attachment) dispositionType() string {
+	if a.ContentID != "" {
+		return "inline"
+	}
+	return "attachment"
+}
+
+// validate validates the Content-ID of the attachment, a msg-id without its angle brackets (RFC 2392).
+func (a Attachment) validate() error {
+	if strings.ContainsAny(a.ContentID, "<>\"\\ \t\r\n") {
+		return fmt.Errorf("invalid content id %q of attachment %q", a.ContentID, a.Filename)
+	}
+	return nil
 }
 
-// encode encodes an attachment in base64 and returns the encoded string.
-func (a Attachment) encode() string {
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("--%s%s", boundary, crlf))
-	sb.WriteString(fmt.Sprintf("Content-Type: %s; name=\"%s\"%s", a.MIMEType, a.Filename, crlf))
+// writeTo writes the attachment part, encoded in base64, to w after the boundary.
+func (a Attachment) writeTo(w io.Writer, boundary string, cfg encodeConfig) {
+	hw := headerWriter{w: w, fold: cfg.maxCompatibility}
+	_, _ = fmt.Fprintf(w, "--%s%s", boundary, crlf)
+	mediaType := a.mediaType()
+	contentType := fmt.Sprintf("%s; name=\"%s\"", mediaType, a.Filename)
+	disposition := fmt.Sprintf("%s; filename=\"%s\"", a.dispositionType(), a.Filename)
+	if cfg.maxCompatibility && !is7Bit(a.Filename) {
+		// RFC 2231 encoded parameters keep the header fields ASCII, invalid media types are written as given.
+		if ct := mime.FormatMediaType(mediaType, map[string]string{"name": a.Filename}); ct != "" {
+			contentType = ct
+		}
+		disposition = mime.FormatMediaType(a.dispositionType(), map[string]string{"filename": a.Filename})
+	}
+	hw.writeHeader("Content-Type", contentType)
 
 	// This header specifies how the attachment's data is encoded for transmission, ensuring that the client can correctly decode and display the file.
 	// According to RFC 2045, this is crucial for proper email attachment handling.
 	// For more details, refer to: https://datatracker.ietf.org/doc/html/rfc2045
-	sb.WriteString(fmt.Sprintf("Content-Transfer-Encoding: base64%s", crlf))
+	hw.writeHeader("Content-Transfer-Encoding", transferEncodingBase64)
 	// Email clients needs this header to be able to render the file as attachement and display proper name when user downloading that attachement.
 	// see https://datatracker.ietf.org/doc/html/rfc2183
-	sb.WriteString(fmt.Sprintf("Content-Disposition: attachment; filename=\"%s\"%s", a.Filename, crlf))
-	sb.WriteString(crlf)
+	hw.writeHeader("Content-Disposition", disposition)
+	if a.ContentID != "" {
+		hw.writeHeader("Content-ID", "<"+a.ContentID+">")
+	}
+	hw.end()
 
 	// Encode and wrap in 76-char lines
-	base64Encoded := encodeBase64(string(a.Data))
-	for _, line := range splitLines(base64Encoded, maxLineLength) {
-		sb.WriteString(line + crlf)
-	}
+	writeContent(w, transferEncodingBase64, a.Data)
 
-	sb.WriteString(crlf)
-	return sb.String()
+	_, _ = io.WriteString(w, crlf)
 }
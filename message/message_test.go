@@ -1,6 +1,7 @@
 package message
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
@@ -49,7 +50,94 @@ func TestMessage(t *testing.T) {
 				msg.Recipients = []string{"gomailerAddr"}
 				return msg
 			},
-			expectedErr: fmt.Errorf("failed to encode message: %w", fmt.Errorf("given gomailerAddr is invalid recipient email: %w", fmt.Errorf("mail: missing '@' or angle-addr"))),
+			expectedErr: fmt.Errorf("failed to encode message: %w", errors.Join(
+				&AddressError{Field: "recipient", Address: "gomailerAddr", err: fmt.Errorf("mail: missing '@' or angle-addr")},
+			)),
+		},
+		"should fail encoding message when a header field name could inject header fields": {
+			getMessage: func() Message {
+				msg := NewMessage()
+				msg.From = testEmail
+				msg.Recipients = []string{testEmail}
+				msg.Headers = map[string][]string{"X-Tag\r\nBcc": {"evil@example.com"}}
+				return msg
+			},
+			expectedErr: fmt.Errorf("failed to encode message: %w", fmt.Errorf("invalid header field name %q", "X-Tag\r\nBcc")),
+		},
+		"should successfully encode message requesting delivery status notifications": {
+			getMessage: func() Message {
+				msg := NewMessage()
+				msg.From = testEmail
+				msg.Recipients = []string{testEmail}
+				msg.DSN = &DSN{Notify: []DSNNotify{DSNNotifyFailure, DSNNotifyDelay}, Return: DSNReturnHeaders, EnvelopeID: "order-42"}
+				return msg
+			},
+		},
+		"should fail encoding message when dsn notify NEVER is combined": {
+			getMessage: func() Message {
+				msg := NewMessage()
+				msg.From = testEmail
+				msg.Recipients = []string{testEmail}
+				msg.DSN = &DSN{Notify: []DSNNotify{DSNNotifyNever, DSNNotifyFailure}}
+				return msg
+			},
+			expectedErr: fmt.Errorf("failed to encode message: %w", fmt.Errorf("dsn notify NEVER cannot be combined with other conditions")),
+		},
+		"should fail encoding message when dsn notify condition is unknown": {
+			getMessage: func() Message {
+				msg := NewMessage()
+				msg.From = testEmail
+				msg.Recipients = []string{testEmail}
+				msg.DSN = &DSN{Notify: []DSNNotify{"ALWAYS"}}
+				return msg
+			},
+			expectedErr: fmt.Errorf("failed to encode message: %w", fmt.Errorf("unknown dsn notify condition %q", "ALWAYS")),
+		},
+		"should fail encoding message when dsn return is unknown": {
+			getMessage: func() Message {
+				msg := NewMessage()
+				msg.From = testEmail
+				msg.Recipients = []string{testEmail}
+				msg.DSN = &DSN{Return: "BODY"}
+				return msg
+			},
+			expectedErr: fmt.Errorf("failed to encode message: %w", fmt.Errorf("unknown dsn return %q", "BODY")),
+		},
+		"should fail encoding message when dsn envelope id is not printable ascii": {
+			getMessage: func() Message {
+				msg := NewMessage()
+				msg.From = testEmail
+				msg.Recipients = []string{testEmail}
+				msg.DSN = &DSN{EnvelopeID: "order\r\n"}
+				return msg
+			},
+			expectedErr: fmt.Errorf("failed to encode message: %w", fmt.Errorf("dsn envelope id must be printable ascii")),
+		},
+		"should report every invalid address of recipients, cc and bcc": {
+			getMessage: func() Message {
+				msg := NewMessage()
+				msg.From = testEmail
+				msg.Recipients = []string{testEmail, "gomailerAddr"}
+				msg.Cc = []string{"cc@"}
+				msg.Bcc = []string{testEmail}
+				return msg
+			},
+			expectedErr: fmt.Errorf("failed to encode message: %w", errors.Join(
+				&AddressError{Field: "recipient", Address: "gomailerAddr", err: fmt.Errorf("mail: missing '@' or angle-addr")},
+				&AddressError{Field: "cc", Address: "cc@", err: fmt.Errorf("mail: missing '@' or angle-addr")},
+			)),
+		},
+		"should fail encoding message when invalid envelope from address provided": {
+			getMessage: func() Message {
+				msg := NewMessage()
+				msg.From = testEmail
+				msg.EnvelopeFrom = "bounces"
+				msg.Recipients = []string{testEmail}
+				return msg
+			},
+			expectedErr: fmt.Errorf("failed to encode message: %w", errors.Join(
+				&AddressError{Field: "envelope from", Address: "bounces", err: fmt.Errorf("mail: missing '@' or angle-addr")},
+			)),
 		},
 	}
 	for name, tc := range tests {
@@ -0,0 +1,151 @@
+package message
+
+import "golang.org/x/text/encoding"
+
+// EncodeOption configures how Message.Encode encodes a message.
+type EncodeOption func(*encodeConfig)
+
+// encodeConfig holds the configuration applied by EncodeOption.
+type encodeConfig struct {
+	// entityWrappers wrap the MIME entity of the message, in order.
+	entityWrappers []EntityWrapper
+	// sevenBitTransport indicates whether 8bit content is quoted-printable encoded.
+	sevenBitTransport bool
+	// maxCompatibility indicates whether the message is encoded for strict legacy gateways, see WithMaxCompatibility.
+	maxCompatibility bool
+	// smtpUTF8 indicates whether internationalized domains are kept as is, see WithSMTPUTF8.
+	smtpUTF8 bool
+	// sourceEncoding is the charset the subject and bodies are given in, they are UTF-8 when nil.
+	sourceEncoding encoding.Encoding
+	// strictLineBreaks indicates whether bodies with bare CR or LF are refused instead of normalized.
+	strictLineBreaks bool
+	// maxSize is the maximum size of the encoded message in bytes, no limit applies when zero.
+	maxSize int64
+	// maxAttachmentSize is the maximum size of the data of every attachment in bytes, no limit applies when zero.
+	maxAttachmentSize int64
+	// maxHeaderCount and maxHeaderBytes limit the top-level header fields, no limit applies when zero.
+	maxHeaderCount, maxHeaderBytes int
+	// boundarySeed derives the boundaries of the multipart entities, they are random when nil.
+	boundarySeed *int64
+	// boundaries are the boundaries of the message being encoded.
+	boundaries boundaries
+}
+
+// textTransferEncoding returns the Content-Transfer-Encoding of a text part with the given content:
+// 7bit for ASCII content, otherwise 8bit, or quoted-printable for 7bit transports.
+// Every text part is quoted-printable encoded for maximum compatibility.
+func (cfg encodeConfig) textTransferEncoding(content string) string {
+	if cfg.maxCompatibility {
+		return transferEncodingQuotedPrintable
+	}
+	if is7Bit(content) {
+		return transferEncoding7Bit
+	}
+	if cfg.sevenBitTransport {
+		return transferEncodingQuotedPrintable
+	}
+	return transferEncoding8Bit
+}
+
+// newEncodeConfig applies the options to an empty configuration.
+func newEncodeConfig(opts []EncodeOption) encodeConfig {
+	var cfg encodeConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// EntityWrapper wraps the MIME entity of a message, e.g. to sign or encrypt it (see the openpgp package).
+// The entity is made of the Content-* header fields, an empty line and the body, all lines terminated by CRLF;
+// WrapEntity returns the wrapping entity in the same form. The top-level header fields (From, To, Subject, ...)
+// are not part of the entity and are written unchanged.
+type EntityWrapper interface {
+	WrapEntity(entity []byte) ([]byte, error)
+}
+
+// EntityWrapperFunc is an adapter to use an ordinary function as EntityWrapper.
+type EntityWrapperFunc func(entity []byte) ([]byte, error)
+
+// WrapEntity calls f(entity).
+func (f EntityWrapperFunc) WrapEntity(entity []byte) ([]byte, error) {
+	return f(entity)
+}
+
+// WithEntityWrapper wraps the MIME entity with w when encoding, wrappers given by several options
+// are applied in order, e.g. signing before encrypting.
+func WithEntityWrapper(w EntityWrapper) EncodeOption {
+	return func(cfg *encodeConfig) {
+		cfg.entityWrappers = append(cfg.entityWrappers, w)
+	}
+}
+
+// With7BitTransport quoted-printable encodes text parts with 8bit content instead of declaring them 8bit,
+// for SMTP servers that do not advertise the 8BITMIME extension.
+func With7BitTransport() EncodeOption {
+	return func(cfg *encodeConfig) {
+		cfg.sevenBitTransport = true
+	}
+}
+
+// WithMaxCompatibility encodes messages for strict legacy gateways, which reject 8bit content and long lines:
+// text parts are quoted-printable encoded, even ASCII ones, so no line exceeds 76 characters and no line break
+// is inserted into the content; the subject is split into several encoded-words and long header fields are folded;
+// non-ASCII additional header values are RFC 2047 encoded and non-ASCII attachment filenames RFC 2231 encoded.
+// Line breaks are normalized to CRLF as always. Addresses with non-ASCII local parts still require SMTPUTF8.
+func WithMaxCompatibility() EncodeOption {
+	return func(cfg *encodeConfig) {
+		cfg.maxCompatibility = true
+	}
+}
+
+// WithStrictLineBreaks refuses bodies containing a CR or LF that is not part of a CRLF line break with ErrBareLineBreak,
+// instead of normalizing them to CRLF as RFC 5321 requires, for applications that want to find the code producing them.
+func WithStrictLineBreaks() EncodeOption {
+	return func(cfg *encodeConfig) {
+		cfg.strictLineBreaks = true
+	}
+}
+
+// WithMaxSize limits the encoded message to size bytes, encoding is aborted with ErrMessageTooLarge
+// as soon as the limit is exceeded. A size of zero or less removes the limit.
+func WithMaxSize(size int64) EncodeOption {
+	return func(cfg *encodeConfig) {
+		cfg.maxSize = size
+	}
+}
+
+// WithMaxAttachmentSize limits the data of every attachment to size bytes, before it is encoded, encoding fails with
+// an *AttachmentSizeError wrapping ErrAttachmentTooLarge when an attachment exceeds it. A size of zero or less removes the limit.
+func WithMaxAttachmentSize(size int64) EncodeOption {
+	return func(cfg *encodeConfig) {
+		cfg.maxAttachmentSize = size
+	}
+}
+
+// WithMaxHeaderBytes limits the top-level header fields of the encoded message to size bytes, encoding fails
+// with a *HeaderLimitError wrapping ErrHeaderLimit before the body is written when the limit is exceeded,
+// e.g. by a pathological Headers map. A size of zero or less removes the limit.
+func WithMaxHeaderBytes(size int) EncodeOption {
+	return func(cfg *encodeConfig) {
+		cfg.maxHeaderBytes = size
+	}
+}
+
+// WithMaxHeaderCount limits the number of top-level header fields of the encoded message, encoding fails
+// with a *HeaderLimitError wrapping ErrHeaderLimit before the body is written when the limit is exceeded.
+// A count of zero or less removes the limit.
+func WithMaxHeaderCount(count int) EncodeOption {
+	return func(cfg *encodeConfig) {
+		cfg.maxHeaderCount = count
+	}
+}
+
+// WithSourceEncoding transcodes the subject and bodies from the given legacy charset (e.g. charmap.Windows1256
+// or charmap.ISO8859_6 of golang.org/x/text/encoding/charmap) to UTF-8, so content produced by legacy systems
+// is sent correctly labeled.
+func WithSourceEncoding(e encoding.Encoding) EncodeOption {
+	return func(cfg *encodeConfig) {
+		cfg.sourceEncoding = e
+	}
+}
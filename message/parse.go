@@ -0,0 +1,283 @@
+package message
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// Parse parses a message, as encoded by Encode or received from another mail client, back into a Message:
+// the From, To, Cc and Bcc addresses, the decoded Subject, the text, AMP and HTML bodies of multipart/alternative,
+// multipart/related and multipart/mixed structures, their other alternatives and the attachments, inline ones
+// carrying their ContentID. Text is decoded to UTF-8 and the line break terminating it is trimmed.
+//
+// The trace and resent fields, e.g. Received or Resent-From, are kept in Trace in the order they were read.
+// The other header fields, e.g. Date, Message-ID or the priority headers, are kept in Headers, which take
+// precedence over the corresponding fields when the message is encoded again. Calendar invitations are parsed
+// as alternatives, and the line breaks the encoder inserted into long lines are kept.
+func Parse(r io.Reader) (Message, error) {
+	br := bufio.NewReader(r)
+	header, err := readHeader(br)
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to parse message: %w", err)
+	}
+	mm, err := mail.ReadMessage(io.MultiReader(bytes.NewReader(header), br))
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to parse message: %w", err)
+	}
+	m := Message{Trace: traceFields(header)}
+	for key, values := range mm.Header {
+		if len(values) == 0 {
+			continue
+		}
+		switch key {
+		case "From":
+			if from := parseAddresses(values[0]); len(from) > 0 {
+				m.From = from[0]
+			}
+		case "To":
+			m.Recipients = parseAddresses(values...)
+		case "Cc":
+			m.Cc = parseAddresses(values...)
+		case "Bcc":
+			m.Bcc = parseAddresses(values...)
+		case "Subject":
+			m.Subject = decodeHeader(values[0])
+		case "Mime-Version", "Content-Type", "Content-Transfer-Encoding", "Content-Disposition", "Content-Id":
+			// the MIME structure is parsed below.
+		default:
+			if traceField(key) {
+				// the trace fields are read in order by traceFields, as the header map loses it.
+				continue
+			}
+			if m.Headers == nil {
+				m.Headers = make(mail.Header)
+			}
+			m.Headers[key] = values
+		}
+	}
+	p := &parser{msg: &m}
+	if err := p.entity(textproto.MIMEHeader(mm.Header), mm.Body, ""); err != nil {
+		return Message{}, fmt.Errorf("failed to parse message: %w", err)
+	}
+	return m, nil
+}
+
+// readHeader reads the header section of a message, up to and including the blank line ending it.
+func readHeader(r *bufio.Reader) ([]byte, error) {
+	var header []byte
+	for {
+		line, err := r.ReadBytes('\n')
+		header = append(header, line...)
+		if errors.Is(err, io.EOF) {
+			return header, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimRight(line, crlf)) == 0 {
+			return header, nil
+		}
+	}
+}
+
+// traceFields returns the trace fields of the header section in order, their folded values unfolded.
+func traceFields(header []byte) []Field {
+	var fields []Field
+	traced := false
+	for _, line := range strings.Split(string(header), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			break
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if last := len(fields) - 1; traced && strings.TrimSpace(line) != "" {
+				fields[last].Value = strings.TrimSpace(fields[last].Value + " " + strings.TrimSpace(line))
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		traced = ok && traceField(strings.TrimSpace(key))
+		if traced {
+			fields = append(fields, Field{Key: strings.TrimSpace(key), Value: strings.TrimSpace(value)})
+		}
+	}
+	return fields
+}
+
+// parser fills a Message with the entities of a parsed message.
+type parser struct {
+	msg *Message
+}
+
+// entity parses the MIME entity of the given header and body, enclosed in a multipart entity of the given subtype
+// or at the top level when empty.
+func (p *parser) entity(header textproto.MIMEHeader, body io.Reader, parent string) error {
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = plainContentType
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid content type %q: %w", contentType, err)
+	}
+	if subtype, ok := strings.CutPrefix(mediaType, "multipart/"); ok {
+		return p.multipart(body, subtype, params["boundary"])
+	}
+	content, err := decodeTransferEncoding(body, header.Get("Content-Transfer-Encoding"))
+	if err != nil {
+		return fmt.Errorf("failed to decode %s content: %w", mediaType, err)
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	contentID := strings.Trim(header.Get("Content-Id"), "<>")
+	isText := mediaType == "text/plain" || mediaType == "text/html"
+	switch {
+	case disposition == "attachment" || (contentID != "" && !isText) || (parent == "mixed" && !strings.HasPrefix(mediaType, "text/")):
+		filename := dispositionParams["filename"]
+		if filename == "" {
+			filename = params["name"]
+		}
+		p.msg.Attachments = append(p.msg.Attachments, Attachment{Filename: filename, Data: content, MIMEType: mediaType, ContentID: contentID})
+		return nil
+	}
+	text, err := decodeCharset(content, params["charset"])
+	if err != nil {
+		return fmt.Errorf("failed to decode %s content: %w", mediaType, err)
+	}
+	text = strings.TrimSuffix(text, crlf)
+	switch {
+	case mediaType == "text/plain" && p.msg.Body == "":
+		p.msg.Body = text
+	case mediaType == "text/html" && p.msg.HTMLBody == "":
+		p.msg.HTMLBody = text
+	case mediaType == "text/x-amp-html" && p.msg.AMPBody == "":
+		p.msg.AMPBody = text
+	default:
+		var headers mail.Header
+		for key, values := range header {
+			switch key {
+			case "Content-Type", "Content-Transfer-Encoding":
+				continue
+			}
+			if headers == nil {
+				headers = make(mail.Header)
+			}
+			headers[key] = values
+		}
+		p.msg.Alternatives = append(p.msg.Alternatives, Alternative{MIMEType: contentType, Content: text, Headers: headers})
+	}
+	return nil
+}
+
+// multipart parses the parts of a multipart entity of the given subtype.
+func (p *parser) multipart(body io.Reader, subtype, boundary string) error {
+	if boundary == "" {
+		return fmt.Errorf("multipart/%s entity without boundary", subtype)
+	}
+	mr := multipart.NewReader(body, boundary)
+	for {
+		// raw parts keep their Content-Transfer-Encoding, which is decoded like the one of single part messages.
+		part, err := mr.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read multipart/%s entity: %w", subtype, err)
+		}
+		if err := p.entity(part.Header, part, subtype); err != nil {
+			return err
+		}
+	}
+}
+
+// decodeTransferEncoding returns the content of body decoded from the given Content-Transfer-Encoding.
+func decodeTransferEncoding(body io.Reader, transferEncoding string) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(transferEncoding)) {
+	case transferEncodingBase64:
+		// the decoder ignores the line breaks wrapping the content.
+		return io.ReadAll(base64.NewDecoder(base64.StdEncoding, body))
+	case transferEncodingQuotedPrintable:
+		return io.ReadAll(quotedprintable.NewReader(body))
+	default:
+		return io.ReadAll(body)
+	}
+}
+
+// decodeCharset returns the text content decoded from the given charset to UTF-8.
+func decodeCharset(content []byte, charset string) (string, error) {
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "us-ascii":
+		return string(content), nil
+	}
+	enc, err := ianaindex.MIME.Encoding(charset)
+	if err != nil || enc == nil {
+		return "", fmt.Errorf("unsupported charset %q", charset)
+	}
+	decoded, err := enc.NewDecoder().Bytes(content)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.ToValidUTF8(decoded, []byte("�"))), nil
+}
+
+// headerDecoder decodes RFC 2047 encoded-words, of any charset known to ianaindex.
+var headerDecoder = &mime.WordDecoder{
+	CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		enc, err := ianaindex.MIME.Encoding(charset)
+		if err != nil || enc == nil {
+			return nil, fmt.Errorf("unsupported charset %q", charset)
+		}
+		return enc.NewDecoder().Reader(input), nil
+	},
+}
+
+// decodeHeader returns the header value with its encoded-words decoded, or as is when they cannot be.
+func decodeHeader(value string) string {
+	decoded, err := headerDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// parseAddresses returns the addresses of the header values formatted for Message fields,
+// addresses that cannot be parsed are returned as given.
+func parseAddresses(values ...string) []string {
+	var list []string
+	for _, value := range values {
+		for _, part := range splitAddressList(value) {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			if addr, err := headerAddressParser.Parse(part); err == nil {
+				part = displayAddress(addr)
+			}
+			list = append(list, part)
+		}
+	}
+	return list
+}
+
+// headerAddressParser parses addresses whose display names are encoded in any charset known to ianaindex.
+var headerAddressParser = &mail.AddressParser{WordDecoder: headerDecoder}
+
+// displayAddress formats the address with its display name quoted rather than RFC 2047 encoded,
+// so it is readable in the Message fields, which the encoder encodes again.
+func displayAddress(addr *mail.Address) string {
+	if is7Bit(addr.Name) {
+		return Address{Name: addr.Name, Email: addr.Address}.String()
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(addr.Name) + `" <` + addr.Address + ">"
+}
@@ -0,0 +1,169 @@
+package message
+
+import (
+	"bytes"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_RoundTrip(t *testing.T) {
+	for name, vector := range conformanceVectors {
+		if name == "calendar" {
+			// invitations are parsed as alternatives, which are encoded before the bodies.
+			continue
+		}
+		t.Run("should encode the parsed "+name+" vector identically", func(t *testing.T) {
+			t.Parallel()
+			golden, err := os.ReadFile(filepath.Join("testdata", "conformance", name+".eml"))
+			require.Nil(t, err)
+
+			parsed, err := Parse(bytes.NewReader(golden))
+			require.Nil(t, err)
+			encoded, err := parsed.Encode(append([]EncodeOption{WithDeterministicBoundaries(testSeed)}, vector.opts...)...)
+			require.Nil(t, err)
+			assert.Equal(t, string(golden), string(encoded))
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected Message
+	}{
+		"should parse a message of another client": {
+			input: strings.Join([]string{
+				"From: =?ISO-8859-1?Q?Andr=E9?= <andre@example.com>",
+				"To: a@example.com, \"B, Team\" <b@example.com>",
+				"Cc: c@example.com",
+				"Subject: =?ISO-8859-1?Q?Caf=E9?=",
+				"Date: Tue, 05 Mar 2024 10:30:00 +0000",
+				"Message-ID: <id@example.com>",
+				"MIME-Version: 1.0",
+				"Content-Type: multipart/mixed; boundary=\"outer\"",
+				"",
+				"--outer",
+				"Content-Type: multipart/alternative; boundary=\"inner\"",
+				"",
+				"--inner",
+				"Content-Type: text/plain; charset=ISO-8859-1",
+				"Content-Transfer-Encoding: quoted-printable",
+				"",
+				"Caf=E9 au lait",
+				"--inner",
+				"Content-Type: text/html; charset=UTF-8",
+				"Content-Transfer-Encoding: base64",
+				"",
+				"PHA+Q2Fmw6k8L3A+",
+				"--inner--",
+				"--outer",
+				"Content-Type: application/pdf",
+				"Content-Disposition: attachment; filename*=UTF-8''men%C3%BC.pdf",
+				"Content-Transfer-Encoding: base64",
+				"",
+				"JVBERg==",
+				"--outer--",
+				"",
+			}, "\r\n"),
+			expected: Message{
+				From:        `"André" <andre@example.com>`,
+				Recipients:  []string{"a@example.com", `"B, Team" <b@example.com>`},
+				Cc:          []string{"c@example.com"},
+				Subject:     "Café",
+				Body:        "Café au lait",
+				HTMLBody:    "<p>Café</p>",
+				Headers:     mail.Header{"Date": {"Tue, 05 Mar 2024 10:30:00 +0000"}, "Message-Id": {"<id@example.com>"}},
+				Attachments: []Attachment{{Filename: "menü.pdf", Data: []byte("%PDF"), MIMEType: "application/pdf"}},
+			},
+		},
+		"should parse a message without MIME header as plain text": {
+			input: "From: a@example.com\r\nTo: b@example.com\r\nSubject: hi\r\n\r\nhello\r\n",
+			expected: Message{
+				From:       "a@example.com",
+				Recipients: []string{"b@example.com"},
+				Subject:    "hi",
+				Body:       "hello",
+			},
+		},
+		"should keep the trace fields in the order they were read, their folded values unfolded": {
+			input: strings.Join([]string{
+				"Resent-From: b@example.net",
+				"Resent-Date: Fri, 16 Oct 2026 12:00:00 +0000",
+				"Received: from mx.example.net",
+				"\tby mx.example.org; Mon, 02 Jan 2006 15:04:05 +0000",
+				"Resent-From: a@example.net",
+				"From: a@example.com",
+				"X-Loop: a@example.net",
+				"",
+				"hello",
+			}, "\r\n"),
+			expected: Message{
+				From:    "a@example.com",
+				Body:    "hello",
+				Headers: mail.Header{"X-Loop": {"a@example.net"}},
+				Trace: []Field{
+					{Key: "Resent-From", Value: "b@example.net"},
+					{Key: "Resent-Date", Value: "Fri, 16 Oct 2026 12:00:00 +0000"},
+					{Key: "Received", Value: "from mx.example.net by mx.example.org; Mon, 02 Jan 2006 15:04:05 +0000"},
+					{Key: "Resent-From", Value: "a@example.net"},
+				},
+			},
+		},
+		"should parse inline images and other alternatives": {
+			input: strings.Join([]string{
+				"From: a@example.com",
+				"To: b@example.com",
+				"Content-Type: multipart/alternative; boundary=alt",
+				"",
+				"--alt",
+				"Content-Type: text/markdown; charset=UTF-8",
+				"X-Schema: md",
+				"",
+				"# hi",
+				"--alt",
+				"Content-Type: multipart/related; boundary=rel",
+				"",
+				"--rel",
+				"Content-Type: text/html; charset=UTF-8",
+				"",
+				"<img src=\"cid:logo\">",
+				"--rel",
+				"Content-Type: image/png; name=\"logo.png\"",
+				"Content-Transfer-Encoding: base64",
+				"Content-Disposition: inline; filename=\"logo.png\"",
+				"Content-ID: <logo>",
+				"",
+				"iVBORw0K",
+				"--rel--",
+				"--alt--",
+				"",
+			}, "\r\n"),
+			expected: Message{
+				From:         "a@example.com",
+				Recipients:   []string{"b@example.com"},
+				HTMLBody:     `<img src="cid:logo">`,
+				Alternatives: []Alternative{{MIMEType: "text/markdown; charset=UTF-8", Content: "# hi", Headers: mail.Header{"X-Schema": {"md"}}}},
+				Attachments:  []Attachment{{Filename: "logo.png", Data: []byte("\x89PNG\r\n"), MIMEType: "image/png", ContentID: "logo"}},
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := Parse(strings.NewReader(tc.input))
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+	t.Run("should fail to parse a multipart message without boundary", func(t *testing.T) {
+		t.Parallel()
+		_, err := Parse(strings.NewReader("From: a@example.com\r\nContent-Type: multipart/mixed\r\n\r\nbody\r\n"))
+		assert.ErrorContains(t, err, "failed to parse message: multipart/mixed entity without boundary")
+	})
+}
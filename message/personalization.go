@@ -0,0 +1,38 @@
+package message
+
+import (
+	"net/mail"
+	"net/textproto"
+)
+
+// Personalization overrides parts of a template Message for a single recipient of a batch send.
+type Personalization struct {
+	// Recipients the personalized copy is delivered to, replacing the template recipients.
+	Recipients []string
+	// Headers are merged over the template headers, a header given here replaces
+	// every value of the template header with the same (case-insensitive) key, e.g. Accept-Language or X-Customer-ID.
+	Headers mail.Header
+}
+
+// Personalize returns a copy of the template Message addressed to the personalization recipients
+// with the personalization headers merged over the template headers.
+// The template Message is left untouched, so it can be personalized for any number of recipients.
+func (m Message) Personalize(p Personalization) Message {
+	personalized := m
+	if len(p.Recipients) > 0 {
+		personalized.Recipients = p.Recipients
+	}
+	if len(p.Headers) == 0 {
+		return personalized
+	}
+
+	headers := make(mail.Header, len(m.Headers)+len(p.Headers))
+	for k, v := range m.Headers {
+		headers[textproto.CanonicalMIMEHeaderKey(k)] = v
+	}
+	for k, v := range p.Headers {
+		headers[textproto.CanonicalMIMEHeaderKey(k)] = v
+	}
+	personalized.Headers = headers
+	return personalized
+}
@@ -0,0 +1,53 @@
+package message
+
+import (
+	"net/mail"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage_Personalize(t *testing.T) {
+	t.Run("should merge personalization headers over the template headers", func(t *testing.T) {
+		t.Parallel()
+		tmpl := NewMessage()
+		tmpl.From = testEmail
+		tmpl.Recipients = []string{"template@smtp.com"}
+		tmpl.Headers = mail.Header{
+			"accept-language": {"en"},
+			"X-Campaign":      {"welcome"},
+		}
+
+		msg := tmpl.Personalize(Personalization{
+			Recipients: []string{testEmail},
+			Headers: mail.Header{
+				"Accept-Language": {"ar"},
+				"X-Customer-ID":   {"42"},
+			},
+		})
+
+		assert.Equal(t, []string{testEmail}, msg.Recipients)
+		assert.Equal(t, mail.Header{
+			"Accept-Language": {"ar"},
+			"X-Campaign":      {"welcome"},
+			"X-Customer-Id":   {"42"},
+		}, msg.Headers)
+		// template stays untouched.
+		assert.Equal(t, []string{"template@smtp.com"}, tmpl.Recipients)
+		assert.Equal(t, mail.Header{
+			"accept-language": {"en"},
+			"X-Campaign":      {"welcome"},
+		}, tmpl.Headers)
+	})
+	t.Run("should keep the template recipients and headers when personalization is empty", func(t *testing.T) {
+		t.Parallel()
+		tmpl := NewMessage()
+		tmpl.Recipients = []string{testEmail}
+		tmpl.Headers = mail.Header{"X-Campaign": {"welcome"}}
+
+		msg := tmpl.Personalize(Personalization{})
+
+		assert.Equal(t, tmpl.Recipients, msg.Recipients)
+		assert.Equal(t, tmpl.Headers, msg.Headers)
+	})
+}
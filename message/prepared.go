@@ -0,0 +1,69 @@
+package message
+
+import (
+	"fmt"
+	"io"
+)
+
+// Prepared is a message checked against its encode options and ready to be written, see Message.Prepare.
+// Only its header section is encoded in advance, its body is encoded while it is written.
+type Prepared struct {
+	m   Message
+	cfg encodeConfig
+	// header is the encoded header section, followed by the wrapped entity when wrapped is true.
+	header  []byte
+	wrapped bool
+}
+
+// Prepare validates the message and checks it can be encoded with the options, without encoding its body.
+// Every error Encode would return is returned by Prepare, so the message can be written afterward,
+// e.g. once an SMTP server is ready to receive it, knowing only the writer may fail. With WithMaxSize, the message
+// is measured by encoding it without keeping it, prefer Encode when the encoded bytes are needed anyway.
+func (m Message) Prepare(opts ...EncodeOption) (*Prepared, error) {
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("failed to encode message: %w", err)
+	}
+	p, err := prepare(m, newEncodeConfig(opts))
+	if err == nil {
+		err = p.checkMaxSize()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode message: %w", err)
+	}
+	return p, nil
+}
+
+// checkMaxSize returns ErrMessageTooLarge when the encoded message exceeds the size given to WithMaxSize.
+// The body is encoded without being kept, measuring the message costs no memory,
+// and the encoding stops at the first write exceeding the limit.
+func (p *Prepared) checkMaxSize() error {
+	if p.cfg.maxSize <= 0 {
+		return nil
+	}
+	if _, err := p.WriteTo(&limitWriter{w: io.Discard, limit: p.cfg.maxSize}); err != nil {
+		return fmt.Errorf("%w of %d bytes", err, p.cfg.maxSize)
+	}
+	return nil
+}
+
+// WriteTo encodes the message into w as it is written, instead of holding the whole encoded message in memory
+// like Encode, and returns the number of bytes written. It fails only when w does, every call writes the same bytes.
+func (p *Prepared) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	ew := &errWriter{w: cw}
+	_, _ = ew.Write(p.header)
+	if !p.wrapped {
+		writeBody(ew, p.m, p.cfg)
+	}
+	return cw.n, ew.err
+}
+
+// WriteTo encodes the message with the default options into w, see Prepare and Prepared.WriteTo.
+// The message is validated before anything is written.
+func (m Message) WriteTo(w io.Writer) (int64, error) {
+	p, err := m.Prepare()
+	if err != nil {
+		return 0, err
+	}
+	return p.WriteTo(w)
+}
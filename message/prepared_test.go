@@ -0,0 +1,102 @@
+package message
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessage_Prepare(t *testing.T) {
+	msg := Message{
+		From:       "gomailer@smtp.com",
+		Recipients: []string{testEmail},
+		Subject:    "report",
+		Body:       "hello",
+		HTMLBody:   "<p>hello</p>",
+		Attachments: []Attachment{{
+			Filename: "report.bin",
+			Data:     bytes.Repeat([]byte{0xde, 0xad, 0xbe, 0xef}, 1024),
+			MIMEType: "application/octet-stream",
+		}},
+	}
+	encoded, err := msg.Encode(WithDeterministicBoundaries(testSeed))
+	require.Nil(t, err)
+
+	t.Run("should write the bytes of Encode", func(t *testing.T) {
+		p, err := msg.Prepare(WithDeterministicBoundaries(testSeed))
+		require.Nil(t, err)
+		for range 2 {
+			var buf bytes.Buffer
+			n, err := p.WriteTo(&buf)
+			require.Nil(t, err)
+			assert.Equal(t, int64(len(encoded)), n)
+			assert.Equal(t, string(encoded), buf.String())
+		}
+	})
+	t.Run("should write the bytes of Encode for wrapped entities", func(t *testing.T) {
+		wrapper := EntityWrapperFunc(func(entity []byte) ([]byte, error) {
+			return append([]byte("Content-Type: text/plain\r\n\r\n"), bytes.ToUpper(entity)...), nil
+		})
+		want, err := msg.Encode(WithDeterministicBoundaries(testSeed), WithEntityWrapper(wrapper))
+		require.Nil(t, err)
+		p, err := msg.Prepare(WithDeterministicBoundaries(testSeed), WithEntityWrapper(wrapper))
+		require.Nil(t, err)
+		var buf bytes.Buffer
+		n, err := p.WriteTo(&buf)
+		require.Nil(t, err)
+		assert.Equal(t, int64(len(want)), n)
+		assert.Equal(t, string(want), buf.String())
+	})
+	t.Run("should report the errors of Encode", func(t *testing.T) {
+		tests := map[string]struct {
+			msg         Message
+			opts        []EncodeOption
+			expectedErr error
+		}{
+			"should refuse invalid messages": {
+				msg: Message{From: "gomailer@smtp.com"},
+			},
+			"should refuse messages exceeding the maximum size": {
+				msg:         msg,
+				opts:        []EncodeOption{WithMaxSize(int64(len(encoded) - 1))},
+				expectedErr: ErrMessageTooLarge,
+			},
+			"should refuse messages exceeding the header limits": {
+				msg:         msg.WithHeader("X-Long", strings.Repeat("a", 200)),
+				opts:        []EncodeOption{WithMaxHeaderBytes(100)},
+				expectedErr: ErrHeaderLimit,
+			},
+		}
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				p, err := tc.msg.Prepare(tc.opts...)
+				assert.NotNil(t, err)
+				assert.Nil(t, p)
+				if tc.expectedErr != nil {
+					assert.ErrorIs(t, err, tc.expectedErr)
+				}
+			})
+		}
+	})
+	t.Run("should write nothing for invalid messages", func(t *testing.T) {
+		var buf bytes.Buffer
+		n, err := Message{From: "gomailer@smtp.com"}.WriteTo(&buf)
+		assert.EqualError(t, err, "failed to encode message: recipients cannot be empty slice")
+		assert.Zero(t, n)
+		assert.Zero(t, buf.Len())
+	})
+	t.Run("should accept messages of the maximum size", func(t *testing.T) {
+		_, err := msg.Prepare(WithMaxSize(int64(len(encoded))))
+		assert.Nil(t, err)
+	})
+	t.Run("should stop writing when the writer fails", func(t *testing.T) {
+		writeErr := errors.New("connection reset")
+		n, err := msg.WriteTo(failingWriter{err: writeErr})
+		assert.Equal(t, writeErr, err)
+		assert.Zero(t, n)
+	})
+}
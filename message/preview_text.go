@@ -0,0 +1,61 @@
+package message
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// previewTextLength is the length of the longest snippets shown by mail clients, preview texts are padded up to it.
+	previewTextLength = 150
+	// previewPadding displays nothing, a combining grapheme joiner, a zero-width non-joiner and a non-breaking space
+	// that clients do not collapse, so the snippet shows blanks instead of the start of the visible content.
+	previewPadding = "&#847;&zwnj;&nbsp;"
+	// previewPaddingPerLine keeps the lines of the padding short of the line length limit of RFC 5322 section 2.1.1.
+	previewPaddingPerLine = 20
+	// previewTextStyle hides the preheader in every client, mso-hide for Outlook on Windows which ignores display.
+	previewTextStyle = "display:none;font-size:1px;line-height:1px;max-height:0;max-width:0;opacity:0;overflow:hidden;mso-hide:all"
+)
+
+// withPreviewText returns a copy of m with the preview text inserted at the start of the HTML body,
+// right after its body tag when it has one.
+func withPreviewText(m Message) Message {
+	if m.PreviewText == "" || m.HTMLBody == "" {
+		return m
+	}
+	at := 0
+	z := htmlTokenizer{s: m.HTMLBody}
+	for t, ok := z.next(); ok; t, ok = z.next() {
+		if t.typ == htmlStartTagToken && t.data == "body" {
+			at = len(m.HTMLBody) - len(z.s)
+			break
+		}
+	}
+	m.HTMLBody = m.HTMLBody[:at] + previewTextHTML(m.PreviewText) + m.HTMLBody[at:]
+	return m
+}
+
+// previewTextHTML returns the hidden preheader of the preview text, padded up to previewTextLength.
+// Characters outside ASCII are written as character references, so the preview text does not make the HTML body 8bit.
+func previewTextHTML(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	var b strings.Builder
+	b.WriteString(`<div style="` + previewTextStyle + `">`)
+	for _, r := range html.EscapeString(text) {
+		if r < utf8.RuneSelf {
+			b.WriteRune(r)
+		} else {
+			fmt.Fprintf(&b, "&#%d;", r)
+		}
+	}
+	for i := range previewTextLength - utf8.RuneCountInString(text) {
+		if i%previewPaddingPerLine == 0 {
+			b.WriteString(crlf)
+		}
+		b.WriteString(previewPadding)
+	}
+	b.WriteString("</div>" + crlf)
+	return b.String()
+}
@@ -0,0 +1,73 @@
+package message
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessage_PreviewText(t *testing.T) {
+	padding := func(n int) string {
+		var b strings.Builder
+		for i := range n {
+			if i%previewPaddingPerLine == 0 {
+				b.WriteString(crlf)
+			}
+			b.WriteString(previewPadding)
+		}
+		return b.String()
+	}
+	preheader := func(text string, n int) string {
+		return `<div style="` + previewTextStyle + `">` + text + padding(n) + "</div>\r\n"
+	}
+	tests := map[string]struct {
+		input Message
+		want  string
+	}{
+		"should insert the preview text after the body tag": {
+			input: Message{PreviewText: "Your order shipped", HTMLBody: `<html><head><title>Order</title></head><body class="main"><p>hi</p></body></html>`},
+			want:  `<html><head><title>Order</title></head><body class="main">` + preheader("Your order shipped", 132) + `<p>hi</p></body></html>`,
+		},
+		"should insert the preview text at the start of fragments": {
+			input: Message{PreviewText: "Your order shipped", HTMLBody: "<p>hi</p>"},
+			want:  preheader("Your order shipped", 132) + "<p>hi</p>",
+		},
+		"should escape the preview text and write references for characters outside ASCII": {
+			input: Message{PreviewText: "Tom & Jerry\r\n  <3 café", HTMLBody: "<p>hi</p>"},
+			want:  preheader("Tom &amp; Jerry &lt;3 caf&#233;", 131) + "<p>hi</p>",
+		},
+		"should not pad long preview texts": {
+			input: Message{PreviewText: strings.Repeat("a", 200), HTMLBody: "<p>hi</p>"},
+			want:  preheader(strings.Repeat("a", 200), 0) + "<p>hi</p>",
+		},
+		"should leave messages without preview text untouched": {
+			input: Message{HTMLBody: "<p>hi</p>"},
+			want:  "<p>hi</p>",
+		},
+		"should ignore the preview text without HTML body": {
+			input: Message{PreviewText: "Your order shipped", Body: "hi"},
+			want:  "",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.want, withPreviewText(tc.input).HTMLBody)
+		})
+	}
+
+	t.Run("should hide the preview text from text generated from the HTML body", func(t *testing.T) {
+		m := withPreviewText(Message{PreviewText: "Your order shipped", HTMLBody: "<body><p>hi</p></body>"})
+		assert.Equal(t, "hi", HTMLToText(m.HTMLBody))
+	})
+	t.Run("should encode the preview text in the HTML body", func(t *testing.T) {
+		m := Message{From: "gomailer@smtp.com", Recipients: []string{testEmail}, PreviewText: "Déjà vu", HTMLBody: "<p>hi</p>"}
+		assert.False(t, m.Requires8BitMIME())
+		encoded, err := m.Encode()
+		require.Nil(t, err)
+		assert.Contains(t, string(encoded), "\r\n\r\n"+preheader("D&#233;j&#224; vu", 143)+"<p>hi</p>")
+		assert.NotContains(t, string(encoded), "Preview")
+	})
+}
@@ -0,0 +1,50 @@
+package message
+
+import "fmt"
+
+// Priority is the importance of a message, shown by mail clients as a flag next to it.
+type Priority int
+
+const (
+	// PriorityNormal is the default priority, no priority header is sent.
+	PriorityNormal Priority = iota
+	// PriorityHigh flags the message as important.
+	PriorityHigh
+	// PriorityLow flags the message as unimportant.
+	PriorityLow
+)
+
+// String returns the name of the priority.
+func (p Priority) String() string {
+	switch p {
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	case PriorityLow:
+		return "low"
+	default:
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+}
+
+// headers returns the priority headers understood by the different mail clients: X-Priority by most clients,
+// Importance (RFC 2156) by Outlook and Gmail, and X-MSMail-Priority by older Outlook versions.
+func (p Priority) headers() [][2]string {
+	switch p {
+	case PriorityHigh:
+		return [][2]string{{"X-Priority", "1 (Highest)"}, {"X-MSMail-Priority", "High"}, {"Importance", "high"}}
+	case PriorityLow:
+		return [][2]string{{"X-Priority", "5 (Lowest)"}, {"X-MSMail-Priority", "Low"}, {"Importance", "low"}}
+	default:
+		return nil
+	}
+}
+
+// validate validates the priority.
+func (p Priority) validate() error {
+	if p < PriorityNormal || p > PriorityLow {
+		return fmt.Errorf("unknown priority %s", p)
+	}
+	return nil
+}
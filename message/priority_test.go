@@ -0,0 +1,60 @@
+package message
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage_EncodePriority(t *testing.T) {
+	tests := map[string]struct {
+		input       Message
+		expected    []string
+		notExpected []string
+		expectedErr bool
+	}{
+		"should not send priority headers for normal priority": {
+			input:       Message{From: testEmail, Recipients: []string{testEmail}, Body: "body"},
+			notExpected: []string{"X-Priority", "Importance", "X-MSMail-Priority"},
+		},
+		"should send the headers of high priority": {
+			input:    Message{From: testEmail, Recipients: []string{testEmail}, Body: "body", Priority: PriorityHigh},
+			expected: []string{"X-Priority: 1 (Highest)\r\n", "X-MSMail-Priority: High\r\n", "Importance: high\r\n"},
+		},
+		"should send the headers of low priority": {
+			input:    Message{From: testEmail, Recipients: []string{testEmail}, Body: "body", Priority: PriorityLow},
+			expected: []string{"X-Priority: 5 (Lowest)\r\n", "X-MSMail-Priority: Low\r\n", "Importance: low\r\n"},
+		},
+		"should keep the priority headers given in Headers": {
+			input: Message{
+				From: testEmail, Recipients: []string{testEmail}, Body: "body", Priority: PriorityHigh,
+				Headers: map[string][]string{"x-priority": {"2 (High)"}},
+			},
+			expected:    []string{"x-priority: 2 (High)\r\n", "Importance: high\r\n"},
+			notExpected: []string{"X-Priority: 1"},
+		},
+		"should refuse an unknown priority": {
+			input:       Message{From: testEmail, Recipients: []string{testEmail}, Body: "body", Priority: Priority(7)},
+			expectedErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := tc.input.Encode()
+			if tc.expectedErr {
+				assert.ErrorContains(t, err, "Priority(7)")
+				return
+			}
+			assert.Nil(t, err)
+			headers, _, _ := strings.Cut(string(got), "\r\n\r\n")
+			for _, expected := range tc.expected {
+				assert.Contains(t, headers+"\r\n", expected)
+			}
+			for _, notExpected := range tc.notExpected {
+				assert.NotContains(t, headers, notExpected)
+			}
+		})
+	}
+}
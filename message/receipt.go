@@ -0,0 +1,13 @@
+package message
+
+// receiptHeaders returns the Disposition-Notification-To (RFC 8098) and the legacy Return-Receipt-To headers of the message.
+func (m Message) receiptHeaders() [][2]string {
+	var headers [][2]string
+	if len(m.DispositionNotificationTo) > 0 {
+		headers = append(headers, [2]string{"Disposition-Notification-To", formatAddressList(m.DispositionNotificationTo)})
+	}
+	if m.ReturnReceiptTo != "" {
+		headers = append(headers, [2]string{"Return-Receipt-To", formatAddressList([]string{m.ReturnReceiptTo})})
+	}
+	return headers
+}
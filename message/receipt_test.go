@@ -0,0 +1,72 @@
+package message
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage_EncodeReceipt(t *testing.T) {
+	tests := map[string]struct {
+		input       Message
+		expected    []string
+		notExpected []string
+		expectedErr error
+	}{
+		"should request read receipts with both headers": {
+			input: Message{
+				From: testEmail, Recipients: []string{testEmail}, Body: "body",
+				DispositionNotificationTo: []string{"Nawaf <receipts@example.com>", "audit@example.com"},
+				ReturnReceiptTo:           "receipts@example.com",
+			},
+			expected: []string{
+				"Disposition-Notification-To: \"Nawaf\" <receipts@example.com>, audit@example.com\r\n",
+				"Return-Receipt-To: receipts@example.com\r\n",
+			},
+		},
+		"should not request read receipts by default": {
+			input:       Message{From: testEmail, Recipients: []string{testEmail}, Body: "body"},
+			notExpected: []string{"Disposition-Notification-To", "Return-Receipt-To"},
+		},
+		"should keep the header given in headers": {
+			input: Message{
+				From: testEmail, Recipients: []string{testEmail}, Body: "body",
+				DispositionNotificationTo: []string{"receipts@example.com"},
+				Headers:                   map[string][]string{"Disposition-Notification-To": {"own@example.com"}},
+			},
+			expected:    []string{"Disposition-Notification-To: own@example.com\r\n"},
+			notExpected: []string{"receipts@example.com"},
+		},
+		"should refuse invalid receipt addresses": {
+			input: Message{
+				From: testEmail, Recipients: []string{testEmail}, Body: "body",
+				DispositionNotificationTo: []string{"receipts@"},
+				ReturnReceiptTo:           "receipts",
+			},
+			expectedErr: fmt.Errorf("%w\n%w",
+				&AddressError{Field: "disposition notification", Address: "receipts@", err: fmt.Errorf("mail: missing '@' or angle-addr")},
+				&AddressError{Field: "return receipt", Address: "receipts", err: fmt.Errorf("mail: missing '@' or angle-addr")},
+			),
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := tc.input.Encode()
+			if tc.expectedErr != nil {
+				assert.ErrorContains(t, err, tc.expectedErr.Error())
+				return
+			}
+			assert.Nil(t, err)
+			headers, _, _ := strings.Cut(string(got), "\r\n\r\n")
+			for _, expected := range tc.expected {
+				assert.Contains(t, headers+"\r\n", expected)
+			}
+			for _, notExpected := range tc.notExpected {
+				assert.NotContains(t, headers, notExpected)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package message
+
+import (
+	"html"
+	"net/textproto"
+	"slices"
+	"strings"
+)
+
+const (
+	replySubjectPrefix   = "Re: "
+	forwardSubjectPrefix = "Fwd: "
+)
+
+// Reply returns a reply from the given address to the message, threaded by mail clients: it is addressed to the
+// Reply-To or From of the message, its subject is prefixed with "Re: " once, and In-Reply-To and References
+// refer to the Message-ID of the message when it has one, e.g. a message read with Parse.
+// The body is followed by the quoted body of the message, and the HTML body by the quoted HTML body, when it has them.
+func (m Message) Reply(from, body string) Message {
+	reply := Message{
+		From:       from,
+		Recipients: []string{m.From},
+		Subject:    prefixSubject(replySubjectPrefix, m.Subject),
+		Body:       body,
+	}
+	if m.Body != "" {
+		reply.Body += crlf + crlf + m.attribution() + crlf + quoteText(m.Body)
+	}
+	if replyTo := m.header("Reply-To"); replyTo != "" {
+		if recipients := parseAddresses(replyTo); len(recipients) > 0 {
+			reply.Recipients = recipients
+		}
+	}
+	if m.HTMLBody != "" {
+		reply.HTMLBody = "<p>" + htmlText(body) + "</p>" + crlf + "<p>" + html.EscapeString(m.attribution()) + "</p>" + crlf +
+			"<blockquote>" + m.HTMLBody + "</blockquote>"
+	}
+	if id := m.header("Message-ID"); id != "" {
+		reply = reply.WithHeader("In-Reply-To", id)
+		reply = reply.WithHeader("References", strings.TrimSpace(m.header("References")+" "+id))
+	}
+	return reply
+}
+
+// Forward returns a copy of the message forwarded from the given address to the recipients: its subject is prefixed
+// with "Fwd: " once, the body is followed by the header fields and the body of the message, the HTML body likewise
+// when the message has one, and the attachments of the message are carried. References refers to the Message-ID
+// of the message when it has one.
+func (m Message) Forward(from string, recipients []string, body string) Message {
+	forward := Message{
+		From:        from,
+		Recipients:  recipients,
+		Subject:     prefixSubject(forwardSubjectPrefix, m.Subject),
+		Body:        body + crlf + crlf + m.forwardedHeader() + crlf + m.Body,
+		Attachments: slices.Clone(m.Attachments),
+	}
+	if m.HTMLBody != "" {
+		forward.HTMLBody = "<p>" + htmlText(body) + "</p>" + crlf + "<p>" + htmlText(m.forwardedHeader()) + "</p>" + crlf + m.HTMLBody
+	}
+	if id := m.header("Message-ID"); id != "" {
+		forward = forward.WithHeader("References", strings.TrimSpace(m.header("References")+" "+id))
+	}
+	return forward
+}
+
+// attribution returns the line introducing the quoted message of a reply.
+func (m Message) attribution() string {
+	if date := m.header("Date"); date != "" {
+		return "On " + date + ", " + m.From + " wrote:"
+	}
+	return m.From + " wrote:"
+}
+
+// forwardedHeader returns the lines introducing the message of a forward, with its header fields.
+func (m Message) forwardedHeader() string {
+	lines := []string{"---------- Forwarded message ----------", "From: " + m.From}
+	if date := m.header("Date"); date != "" {
+		lines = append(lines, "Date: "+date)
+	}
+	lines = append(lines, "Subject: "+m.Subject)
+	if len(m.Recipients) > 0 {
+		lines = append(lines, "To: "+strings.Join(m.Recipients, separator))
+	}
+	if len(m.Cc) > 0 {
+		lines = append(lines, "Cc: "+strings.Join(m.Cc, separator))
+	}
+	return strings.Join(lines, crlf) + crlf
+}
+
+// header returns the first value of the header of the message, the key is matched case-insensitively.
+func (m Message) header(key string) string {
+	for k, v := range m.Headers {
+		if textproto.CanonicalMIMEHeaderKey(k) == textproto.CanonicalMIMEHeaderKey(key) && len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+// prefixSubject returns the subject prefixed once, a subject already starting with the prefix is returned as is.
+func prefixSubject(prefix, subject string) string {
+	if len(subject) >= len(prefix) && strings.EqualFold(subject[:len(prefix)], prefix) {
+		return subject
+	}
+	return prefix + subject
+}
+
+// quoteText returns the text with every line prefixed with "> ", as replies quote the text they reply to.
+func quoteText(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, crlf, "\n"), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ">") {
+			lines[i] = ">" + line
+		} else {
+			lines[i] = "> " + line
+		}
+	}
+	return strings.Join(lines, crlf)
+}
+
+// htmlText returns the text escaped for HTML, its line breaks made <br> elements.
+func htmlText(text string) string {
+	escaped := html.EscapeString(strings.TrimSuffix(strings.ReplaceAll(text, crlf, "\n"), "\n"))
+	return strings.ReplaceAll(escaped, "\n", "<br>")
+}
@@ -0,0 +1,87 @@
+package message
+
+import (
+	"net/mail"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage_Reply(t *testing.T) {
+	original := Message{
+		From:       "Alice <alice@example.com>",
+		Recipients: []string{"bob@example.com"},
+		Subject:    "Lunch",
+		Body:       "Lunch at noon?\r\n> earlier quote",
+		Headers: mail.Header{
+			"Message-Id": {"<2@example.com>"},
+			"References": {"<1@example.com>"},
+			"Date":       {"Tue, 05 Mar 2024 10:30:00 +0000"},
+		},
+	}
+	tests := map[string]struct {
+		input    Message
+		expected Message
+	}{
+		"should quote and thread the reply": {
+			input: original,
+			expected: Message{
+				From:       "bob@example.com",
+				Recipients: []string{"Alice <alice@example.com>"},
+				Subject:    "Re: Lunch",
+				Body: "Sure!\r\n\r\nOn Tue, 05 Mar 2024 10:30:00 +0000, Alice <alice@example.com> wrote:\r\n" +
+					"> Lunch at noon?\r\n>> earlier quote",
+				Headers: mail.Header{"In-Reply-To": {"<2@example.com>"}, "References": {"<1@example.com> <2@example.com>"}},
+			},
+		},
+		"should reply to the Reply-To address without prefixing the subject twice": {
+			input: Message{
+				From: "alice@example.com", Subject: "RE: Lunch", HTMLBody: "<p>Lunch?</p>",
+				Headers: mail.Header{"reply-to": {"Team <team@example.com>"}},
+			},
+			expected: Message{
+				From:       "bob@example.com",
+				Recipients: []string{`"Team" <team@example.com>`},
+				Subject:    "RE: Lunch",
+				Body:       "Sure!",
+				HTMLBody:   "<p>Sure!</p>\r\n<p>alice@example.com wrote:</p>\r\n<blockquote><p>Lunch?</p></blockquote>",
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, tc.input.Reply("bob@example.com", "Sure!"))
+		})
+	}
+}
+
+func TestMessage_Forward(t *testing.T) {
+	t.Parallel()
+	original := Message{
+		From:        "alice@example.com",
+		Recipients:  []string{"bob@example.com"},
+		Subject:     "Report",
+		Body:        "See attached.",
+		HTMLBody:    "<p>See attached.</p>",
+		Attachments: []Attachment{{Filename: "report.pdf", Data: []byte("%PDF")}},
+		Headers:     mail.Header{"Message-ID": {"<1@example.com>"}},
+	}
+	header := "---------- Forwarded message ----------\r\nFrom: alice@example.com\r\nSubject: Report\r\nTo: bob@example.com\r\n"
+
+	got := original.Forward("bob@example.com", []string{"carol@example.com"}, "FYI")
+	assert.Equal(t, Message{
+		From:        "bob@example.com",
+		Recipients:  []string{"carol@example.com"},
+		Subject:     "Fwd: Report",
+		Body:        "FYI\r\n\r\n" + header + "\r\nSee attached.",
+		HTMLBody:    "<p>FYI</p>\r\n<p>" + htmlText(header) + "</p>\r\n<p>See attached.</p>",
+		Attachments: []Attachment{{Filename: "report.pdf", Data: []byte("%PDF")}},
+		Headers:     mail.Header{"References": {"<1@example.com>"}},
+	}, got)
+	_, err := got.Encode()
+	assert.Nil(t, err)
+
+	got.Attachments[0].Filename = "changed.pdf"
+	assert.Equal(t, "report.pdf", original.Attachments[0].Filename)
+}
@@ -0,0 +1,55 @@
+package message
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// ErrAttachmentTooLarge is returned, wrapped in an *AttachmentSizeError, when an attachment exceeds the size given
+// to WithMaxAttachmentSize.
+var ErrAttachmentTooLarge = errors.New("attachment exceeds the maximum size")
+
+// AttachmentSizeError reports the attachment exceeding the size given to WithMaxAttachmentSize. Use errors.As to retrieve it.
+type AttachmentSizeError struct {
+	// Filename is the name of the attachment.
+	Filename string
+	// Size is the size of the attachment data in bytes, before it is encoded.
+	Size int64
+	// MaxSize is the limit given to WithMaxAttachmentSize.
+	MaxSize int64
+}
+
+// Error returns the attachment and the limit it exceeds.
+func (e *AttachmentSizeError) Error() string {
+	return fmt.Sprintf("%v: attachment %q of %d bytes exceeds the maximum of %d bytes", ErrAttachmentTooLarge, e.Filename, e.Size, e.MaxSize)
+}
+
+// Unwrap returns ErrAttachmentTooLarge.
+func (e *AttachmentSizeError) Unwrap() error {
+	return ErrAttachmentTooLarge
+}
+
+// CheckSize reports, without encoding the message, whether it exceeds the limits of the options: every attachment
+// larger than the size given to WithMaxAttachmentSize as an *AttachmentSizeError, and ErrMessageTooLarge when the
+// bodies and base64 encoded attachments alone exceed the size given to WithMaxSize. It lets callers refuse oversized
+// messages before connecting to the SMTP server, Encode enforces the exact size of the encoded message.
+func (m Message) CheckSize(opts ...EncodeOption) error {
+	return checkSize(m, newEncodeConfig(opts))
+}
+
+// checkSize implements CheckSize for the encode configuration.
+func checkSize(m Message, cfg encodeConfig) error {
+	var errs []error
+	size := int64(len(m.Body) + len(m.AMPBody) + len(m.HTMLBody))
+	for _, a := range m.Attachments {
+		if cfg.maxAttachmentSize > 0 && int64(len(a.Data)) > cfg.maxAttachmentSize {
+			errs = append(errs, &AttachmentSizeError{Filename: a.Filename, Size: int64(len(a.Data)), MaxSize: cfg.maxAttachmentSize})
+		}
+		size += int64(base64.StdEncoding.EncodedLen(len(a.Data)))
+	}
+	if cfg.maxSize > 0 && size > cfg.maxSize {
+		errs = append(errs, fmt.Errorf("%w of %d bytes, its content alone is %d bytes", ErrMessageTooLarge, cfg.maxSize, size))
+	}
+	return errors.Join(errs...)
+}
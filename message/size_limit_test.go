@@ -0,0 +1,74 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage_CheckSize(t *testing.T) {
+	msg := Message{
+		From:       testEmail,
+		Recipients: []string{testEmail},
+		Body:       "hello",
+		Attachments: []Attachment{
+			{Filename: "small.txt", Data: make([]byte, 10), MIMEType: "text/plain"},
+			{Filename: "large.bin", Data: make([]byte, 300), MIMEType: "application/octet-stream"},
+		},
+	}
+	tests := map[string]struct {
+		opts            []EncodeOption
+		expectedErrs    []error
+		expectedAttErrs []*AttachmentSizeError
+	}{
+		"should accept messages within the limits": {
+			opts: []EncodeOption{WithMaxAttachmentSize(300), WithMaxSize(1024)},
+		},
+		"should not limit the size by default": {},
+		"should refuse attachments exceeding the maximum size": {
+			opts:            []EncodeOption{WithMaxAttachmentSize(100)},
+			expectedErrs:    []error{ErrAttachmentTooLarge},
+			expectedAttErrs: []*AttachmentSizeError{{Filename: "large.bin", Size: 300, MaxSize: 100}},
+		},
+		"should refuse messages whose content alone exceeds the maximum size": {
+			opts:         []EncodeOption{WithMaxSize(400)},
+			expectedErrs: []error{ErrMessageTooLarge},
+		},
+		"should report every exceeded limit": {
+			opts:            []EncodeOption{WithMaxAttachmentSize(5), WithMaxSize(400)},
+			expectedErrs:    []error{ErrAttachmentTooLarge, ErrMessageTooLarge},
+			expectedAttErrs: []*AttachmentSizeError{{Filename: "small.txt", Size: 10, MaxSize: 5}, {Filename: "large.bin", Size: 300, MaxSize: 5}},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			err := msg.CheckSize(tc.opts...)
+			if tc.expectedErrs == nil {
+				assert.Nil(t, err)
+				return
+			}
+			for _, expected := range tc.expectedErrs {
+				assert.ErrorIs(t, err, expected)
+			}
+			var got []*AttachmentSizeError
+			for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+				var attErr *AttachmentSizeError
+				if errors.As(e, &attErr) {
+					got = append(got, attErr)
+				}
+			}
+			assert.Equal(t, tc.expectedAttErrs, got)
+
+			_, encodeErr := msg.Encode(tc.opts...)
+			assert.Equal(t, fmt.Errorf("failed to encode message: %w", err), encodeErr)
+		})
+	}
+
+	t.Run("should describe the attachment exceeding the maximum size", func(t *testing.T) {
+		err := &AttachmentSizeError{Filename: "large.bin", Size: 300, MaxSize: 100}
+		assert.Equal(t, `attachment exceeds the maximum size: attachment "large.bin" of 300 bytes exceeds the maximum of 100 bytes`, err.Error())
+	})
+}
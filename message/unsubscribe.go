@@ -0,0 +1,77 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// Unsubscribe lets recipients unsubscribe from a mailing list with the List-Unsubscribe header (RFC 2369),
+// which mail clients show as an unsubscribe button. Gmail and Yahoo require it from bulk senders, see Message.Bulk.
+type Unsubscribe struct {
+	// URL is an http or https URL unsubscribing the recipient, usually carrying a recipient-specific token.
+	URL string
+	// Mailto is the address of, or a mailto URI (RFC 6068) to, a mailbox unsubscribing the sender of the messages it receives.
+	Mailto string
+	// OneClick lets clients unsubscribe by posting to URL without visiting it, with the List-Unsubscribe-Post header (RFC 8058).
+	// URL must be https and must not require cookies or redirects.
+	OneClick bool
+}
+
+// mailtoURI returns the Mailto as a mailto URI.
+func (u Unsubscribe) mailtoURI() string {
+	if strings.HasPrefix(strings.ToLower(u.Mailto), "mailto:") {
+		return u.Mailto
+	}
+	return "mailto:" + u.Mailto
+}
+
+// validate validates the unsubscribe methods.
+func (u Unsubscribe) validate() error {
+	var errs []error
+	if u.URL == "" && u.Mailto == "" {
+		errs = append(errs, errors.New("unsubscribe requires a URL or a mailto address"))
+	}
+	if u.URL != "" {
+		if parsed, err := url.Parse(u.URL); err != nil {
+			errs = append(errs, fmt.Errorf("invalid unsubscribe URL: %w", err))
+		} else if parsed.Scheme != "https" && (u.OneClick || parsed.Scheme != "http") {
+			errs = append(errs, fmt.Errorf("unsupported unsubscribe URL scheme %q, one-click unsubscribe requires https", parsed.Scheme))
+		}
+	} else if u.OneClick {
+		errs = append(errs, errors.New("one-click unsubscribe requires an https URL"))
+	}
+	if u.Mailto != "" {
+		if parsed, err := url.Parse(u.mailtoURI()); err != nil {
+			errs = append(errs, fmt.Errorf("invalid unsubscribe mailto: %w", err))
+		} else if _, err := mail.ParseAddress(parsed.Opaque); err != nil {
+			errs = append(errs, fmt.Errorf("invalid unsubscribe mailto address: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// listHeaders returns the List-Unsubscribe, List-Unsubscribe-Post and Precedence headers of the message.
+func (m Message) listHeaders() [][2]string {
+	var headers [][2]string
+	if u := m.Unsubscribe; u != nil {
+		// the mailto URI comes first, as clients not supporting one-click unsubscribe commonly use the first method.
+		var methods []string
+		if u.Mailto != "" {
+			methods = append(methods, "<"+u.mailtoURI()+">")
+		}
+		if u.URL != "" {
+			methods = append(methods, "<"+u.URL+">")
+		}
+		headers = append(headers, [2]string{"List-Unsubscribe", strings.Join(methods, separator)})
+		if u.OneClick {
+			headers = append(headers, [2]string{"List-Unsubscribe-Post", "List-Unsubscribe=One-Click"})
+		}
+	}
+	if m.Bulk {
+		headers = append(headers, [2]string{"Precedence", "bulk"})
+	}
+	return headers
+}
@@ -0,0 +1,81 @@
+package message
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage_EncodeUnsubscribe(t *testing.T) {
+	tests := map[string]struct {
+		input       Message
+		expected    []string
+		notExpected []string
+		expectErr   bool
+	}{
+		"should send the unsubscribe methods, mailto first": {
+			input: Message{
+				From: testEmail, Recipients: []string{testEmail}, Body: "body",
+				Unsubscribe: &Unsubscribe{URL: "https://example.com/unsubscribe?token=42", Mailto: "unsubscribe@example.com"},
+			},
+			expected:    []string{"List-Unsubscribe: <mailto:unsubscribe@example.com>, <https://example.com/unsubscribe?token=42>\r\n"},
+			notExpected: []string{"List-Unsubscribe-Post", "Precedence"},
+		},
+		"should send the one-click and bulk headers": {
+			input: Message{
+				From: testEmail, Recipients: []string{testEmail}, Body: "body", Bulk: true,
+				Unsubscribe: &Unsubscribe{URL: "https://example.com/unsubscribe", OneClick: true},
+			},
+			expected: []string{
+				"List-Unsubscribe: <https://example.com/unsubscribe>\r\n",
+				"List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n",
+				"Precedence: bulk\r\n",
+			},
+		},
+		"should keep a mailto URI as given": {
+			input: Message{
+				From: testEmail, Recipients: []string{testEmail}, Body: "body",
+				Unsubscribe: &Unsubscribe{Mailto: "mailto:unsubscribe@example.com?subject=unsubscribe"},
+			},
+			expected: []string{"List-Unsubscribe: <mailto:unsubscribe@example.com?subject=unsubscribe>\r\n"},
+		},
+		"should refuse bulk messages without unsubscribe": {
+			input:     Message{From: testEmail, Recipients: []string{testEmail}, Body: "body", Bulk: true},
+			expectErr: true,
+		},
+		"should refuse unsubscribe without method": {
+			input:     Message{From: testEmail, Recipients: []string{testEmail}, Body: "body", Unsubscribe: &Unsubscribe{}},
+			expectErr: true,
+		},
+		"should refuse one-click unsubscribe over http": {
+			input: Message{
+				From: testEmail, Recipients: []string{testEmail}, Body: "body",
+				Unsubscribe: &Unsubscribe{URL: "http://example.com/unsubscribe", OneClick: true},
+			},
+			expectErr: true,
+		},
+		"should refuse an invalid mailto address": {
+			input:     Message{From: testEmail, Recipients: []string{testEmail}, Body: "body", Unsubscribe: &Unsubscribe{Mailto: "unsubscribe"}},
+			expectErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			got, err := tc.input.Encode()
+			if tc.expectErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			headers, _, _ := strings.Cut(string(got), "\r\n\r\n")
+			for _, expected := range tc.expected {
+				assert.Contains(t, headers+"\r\n", expected)
+			}
+			for _, notExpected := range tc.notExpected {
+				assert.NotContains(t, headers, notExpected)
+			}
+		})
+	}
+}
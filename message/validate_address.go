@@ -0,0 +1,134 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/nawafswe/gomailer/internal/mx"
+)
+
+// ErrNoMailServer is returned when the domain of an address has no mail server, see ValidateAddress.
+var ErrNoMailServer = mx.ErrNoMailServer
+
+// ValidationLevel selects how thoroughly ValidateAddress checks an address, every level includes the checks of the previous ones.
+type ValidationLevel int
+
+const (
+	// LevelSyntax only checks the address is a valid RFC 5322 address, without any network access.
+	LevelSyntax ValidationLevel = iota
+	// LevelMX also checks the domain accepts mail: it has MX records, or an address record its mail is delivered to
+	// when it has none (RFC 5321 section 5.1), and no null MX (RFC 7505).
+	LevelMX
+	// LevelCallout also asks the mail server of the domain whether it accepts the recipient, with the AddressVerifier
+	// given to WithCallout (e.g. *gomailer.Verifier). Mail servers accepting every recipient pass it.
+	LevelCallout
+)
+
+// String returns the name of the level.
+func (l ValidationLevel) String() string {
+	switch l {
+	case LevelMX:
+		return "mx"
+	case LevelCallout:
+		return "callout"
+	default:
+		return "syntax"
+	}
+}
+
+// AddressVerifier verifies whether the mail server of the address domain accepts it as a recipient,
+// it is implemented by gomailer.Verifier.
+type AddressVerifier interface {
+	Verify(ctx context.Context, address string) error
+}
+
+// ValidateOption configures ValidateAddress.
+type ValidateOption func(*validateConfig)
+
+// validateConfig holds the configuration applied by ValidateOption.
+type validateConfig struct {
+	// lookupMX looks up the MX records, mx.LookupMX when nil.
+	lookupMX   func(ctx context.Context, name string) ([]*net.MX, error)
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	verifier   AddressVerifier
+}
+
+// WithResolver looks up the domains with the resolver, net.DefaultResolver is used by default.
+func WithResolver(r *net.Resolver) ValidateOption {
+	return func(cfg *validateConfig) {
+		cfg.lookupMX = r.LookupMX
+		cfg.lookupHost = r.LookupHost
+	}
+}
+
+// WithCallout verifies the recipient with v at LevelCallout, which fails without it.
+func WithCallout(v AddressVerifier) ValidateOption {
+	return func(cfg *validateConfig) {
+		cfg.verifier = v
+	}
+}
+
+// ValidateAddress validates the address at the level, the lookups and the callout are bounded by ctx.
+// A domain without mail server is reported as ErrNoMailServer, a failing lookup as the *net.DNSError, so temporary
+// failures can be told apart from undeliverable addresses. Domain literals (e.g. "[192.0.2.1]") are not looked up.
+func ValidateAddress(ctx context.Context, addr string, level ValidationLevel, opts ...ValidateOption) error {
+	cfg := validateConfig{lookupHost: lookupHost}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := validateAddress(ctx, addr, level, cfg); err != nil {
+		return fmt.Errorf("failed to validate address %q: %w", addr, err)
+	}
+	return nil
+}
+
+// validateAddress implements ValidateAddress for the configuration.
+func validateAddress(ctx context.Context, addr string, level ValidationLevel, cfg validateConfig) error {
+	a, err := ParseAddress(addr)
+	if err != nil {
+		return err
+	}
+	// internationalized domains are looked up and verified by their punycode form.
+	if a, err = a.ToASCII(); err != nil {
+		return err
+	}
+	if level < LevelMX {
+		return nil
+	}
+	domain := a.Email[strings.LastIndexByte(a.Email, '@')+1:]
+	if !strings.HasPrefix(domain, "[") {
+		if err := checkMailServer(ctx, domain, cfg); err != nil {
+			return err
+		}
+	}
+	if level < LevelCallout {
+		return nil
+	}
+	if cfg.verifier == nil {
+		return errors.New("callout validation requires a verifier, see WithCallout")
+	}
+	return cfg.verifier.Verify(ctx, a.Email)
+}
+
+// checkMailServer returns ErrNoMailServer unless the domain has a mail server.
+func checkMailServer(ctx context.Context, domain string, cfg validateConfig) error {
+	hosts, err := mx.Hosts(ctx, domain, cfg.lookupMX)
+	if err != nil || len(hosts) > 0 {
+		return err
+	}
+	// without MX records, mail is delivered to the address records of the domain.
+	addrs, err := cfg.lookupHost(ctx, domain)
+	if err != nil && !mx.IsNotFound(err) {
+		return err
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("%w: %s", ErrNoMailServer, domain)
+	}
+	return nil
+}
+
+// lookupHost returns the addresses of a host, extracted to be stubbed during testing.
+var lookupHost = net.DefaultResolver.LookupHost
@@ -0,0 +1,160 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/nawafswe/gomailer/internal/mx"
+	"github.com/stretchr/testify/assert"
+)
+
+// verifierFunc is an AddressVerifier calling the function.
+type verifierFunc func(ctx context.Context, address string) error
+
+func (f verifierFunc) Verify(ctx context.Context, address string) error {
+	return f(ctx, address)
+}
+
+func TestValidateAddress(t *testing.T) {
+	notFound := &net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true}
+	timeout := &net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}
+	rejected := errors.New("550 5.1.1 unknown user")
+	var verified []string
+	verifier := verifierFunc(func(ctx context.Context, address string) error {
+		verified = append(verified, address)
+		if address == "unknown@example.com" {
+			return rejected
+		}
+		return nil
+	})
+	tests := map[string]struct {
+		addr        string
+		domain      string
+		level       ValidationLevel
+		opts        []ValidateOption
+		mxs         []*net.MX
+		mxErr       error
+		hosts       []string
+		hostErr     error
+		verified    []string
+		expectedErr error
+		errContains string
+	}{
+		"should accept valid addresses at syntax level without lookups": {
+			addr:  "Nawaf <nawaf@example.com>",
+			mxErr: timeout,
+		},
+		"should refuse invalid addresses": {
+			addr:        "nawaf@",
+			level:       LevelMX,
+			errContains: `failed to validate address "nawaf@": mail: missing '@' or angle-addr`,
+		},
+		"should accept domains with MX records": {
+			addr:  "nawaf@example.com",
+			level: LevelMX,
+			mxs:   []*net.MX{{Host: "mx.example.com.", Pref: 10}},
+		},
+		"should accept domains without MX records with an address record": {
+			addr:  "nawaf@example.com",
+			level: LevelMX,
+			mxErr: notFound,
+			hosts: []string{"192.0.2.1"},
+		},
+		"should refuse domains without mail server": {
+			addr:        "nawaf@example.com",
+			level:       LevelMX,
+			mxErr:       notFound,
+			hostErr:     notFound,
+			expectedErr: ErrNoMailServer,
+			errContains: `failed to validate address "nawaf@example.com": domain has no mail server: example.com`,
+		},
+		"should refuse domains declaring a null MX": {
+			addr:        "nawaf@example.com",
+			level:       LevelMX,
+			mxs:         []*net.MX{{Host: ".", Pref: 0}},
+			expectedErr: ErrNoMailServer,
+			errContains: "example.com declares a null MX",
+		},
+		"should report failing lookups": {
+			addr:        "nawaf@example.com",
+			level:       LevelMX,
+			mxErr:       timeout,
+			expectedErr: timeout,
+		},
+		"should look up and verify internationalized domains by their punycode form": {
+			addr:     "nawaf@bücher.de",
+			domain:   "xn--bcher-kva.de",
+			level:    LevelCallout,
+			opts:     []ValidateOption{WithCallout(verifier)},
+			mxs:      []*net.MX{{Host: "mx.xn--bcher-kva.de.", Pref: 10}},
+			verified: []string{"nawaf@xn--bcher-kva.de"},
+		},
+		"should not look up domain literals": {
+			addr:  "nawaf@[192.0.2.1]",
+			level: LevelMX,
+			mxErr: timeout,
+		},
+		"should verify the recipient with the verifier": {
+			addr:     "Nawaf <nawaf@example.com>",
+			level:    LevelCallout,
+			opts:     []ValidateOption{WithCallout(verifier)},
+			mxs:      []*net.MX{{Host: "mx.example.com.", Pref: 10}},
+			verified: []string{"nawaf@example.com"},
+		},
+		"should refuse recipients rejected by the verifier": {
+			addr:        "unknown@example.com",
+			level:       LevelCallout,
+			opts:        []ValidateOption{WithCallout(verifier)},
+			mxs:         []*net.MX{{Host: "mx.example.com.", Pref: 10}},
+			verified:    []string{"unknown@example.com"},
+			expectedErr: rejected,
+		},
+		"should not verify recipients of domains without mail server": {
+			addr:        "nawaf@example.com",
+			level:       LevelCallout,
+			opts:        []ValidateOption{WithCallout(verifier)},
+			mxs:         []*net.MX{{Host: ".", Pref: 0}},
+			expectedErr: ErrNoMailServer,
+		},
+		"should fail callout validation without verifier": {
+			addr:        "nawaf@example.com",
+			level:       LevelCallout,
+			mxs:         []*net.MX{{Host: "mx.example.com.", Pref: 10}},
+			errContains: "callout validation requires a verifier",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// stub functions
+			domain := tc.domain
+			if domain == "" {
+				domain = "example.com"
+			}
+			mx.LookupMX = func(ctx context.Context, name string) ([]*net.MX, error) {
+				assert.Equal(t, domain, name)
+				return tc.mxs, tc.mxErr
+			}
+			lookupHost = func(ctx context.Context, host string) ([]string, error) {
+				return tc.hosts, tc.hostErr
+			}
+			defer func() {
+				mx.LookupMX = net.DefaultResolver.LookupMX
+				lookupHost = net.DefaultResolver.LookupHost
+			}()
+			verified = nil
+
+			err := ValidateAddress(context.Background(), tc.addr, tc.level, tc.opts...)
+			assert.Equal(t, tc.verified, verified)
+			if tc.expectedErr == nil && tc.errContains == "" {
+				assert.Nil(t, err)
+				return
+			}
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+			}
+			assert.ErrorContains(t, err, tc.errContains)
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package gomailer
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// dataBytesBuckets are the buckets of the size of the messages written with DATA, from 1KiB to 64MiB.
+var dataBytesBuckets = []float64{1 << 10, 1 << 12, 1 << 14, 1 << 16, 1 << 18, 1 << 20, 1 << 22, 1 << 24, 1 << 26}
+
+// MetricOpts describes an instrument created by Metrics.
+type MetricOpts struct {
+	// Name of the instrument, e.g. "gomailer_messages_sent_total".
+	Name string
+	// Help describes what the instrument measures.
+	Help string
+	// Unit of the measurements, e.g. "seconds" or "bytes", empty for counts.
+	Unit string
+	// Labels are the names of the labels the measurements are recorded with, their values are given in the same order.
+	Labels []string
+	// Buckets are the upper bounds of the histogram buckets, the defaults of the backend are used when empty.
+	Buckets []float64
+}
+
+// Metrics creates the instruments Mailer records its measurements with. It is kept minimal so any metrics backend
+// can implement it; adapters for Prometheus and OpenTelemetry are provided by the prometheusmetrics and otelmetrics
+// modules, keeping gomailer itself free of their dependencies.
+type Metrics interface {
+	// Counter returns a counter, which only increases.
+	Counter(opts MetricOpts) Counter
+	// Histogram returns a histogram, recording the distribution of measurements such as durations or sizes.
+	Histogram(opts MetricOpts) Histogram
+	// Gauge returns a gauge, which may go up and down.
+	Gauge(opts MetricOpts) Gauge
+}
+
+// Counter is a monotonically increasing metric.
+type Counter interface {
+	// Add increases the counter by delta, labelValues are given in the order of MetricOpts.Labels.
+	Add(ctx context.Context, delta float64, labelValues ...string)
+}
+
+// Histogram records the distribution of measurements.
+type Histogram interface {
+	// Observe records value, labelValues are given in the order of MetricOpts.Labels.
+	Observe(ctx context.Context, value float64, labelValues ...string)
+}
+
+// Gauge is a metric set to arbitrary values.
+type Gauge interface {
+	// Set sets the gauge to value, labelValues are given in the order of MetricOpts.Labels.
+	Set(ctx context.Context, value float64, labelValues ...string)
+}
+
+// WithMetrics configures Mailer to record its measurements with m, labeled with the SMTP host: messages sent,
+// failures by SMTP reply code, connection setup time, bytes written with DATA, retries, frequency cap refusals
+// and sent folder failures.
+func WithMetrics(m Metrics) func(*Mailer) {
+	return func(mailer *Mailer) {
+		if m != nil {
+			mailer.metrics = newMailerMetrics(m)
+		}
+	}
+}
+
+// mailerMetrics holds the instruments of Mailer, a nil *mailerMetrics records nothing.
+type mailerMetrics struct {
+	// sent counts the messages accepted by the SMTP server.
+	sent Counter
+	// failures counts the messages that could not be sent, by SMTP reply code.
+	failures Counter
+	// connectionSetup measures the time to connect, secure and authenticate connections.
+	connectionSetup Histogram
+	// dataBytes measures the bytes of the messages written with DATA.
+	dataBytes Histogram
+	// retries counts the retries of failed sends.
+	retries Counter
+	// frequencyCapped counts the messages refused by the frequency cap.
+	frequencyCapped Counter
+	// sentFolderFailures counts the sent messages that could not be appended to the sent folder.
+	sentFolderFailures Counter
+}
+
+// newMailerMetrics creates the instruments of Mailer with m.
+func newMailerMetrics(m Metrics) *mailerMetrics {
+	return &mailerMetrics{
+		sent: m.Counter(MetricOpts{
+			Name:   "gomailer_messages_sent_total",
+			Help:   "Number of messages accepted by the SMTP server.",
+			Labels: []string{"host"},
+		}),
+		failures: m.Counter(MetricOpts{
+			Name:   "gomailer_messages_failed_total",
+			Help:   "Number of messages that could not be sent, by SMTP reply code (none when the server did not reply with an error).",
+			Labels: []string{"host", "code"},
+		}),
+		connectionSetup: m.Histogram(MetricOpts{
+			Name:   "gomailer_connection_setup_seconds",
+			Help:   "Time to connect, secure and authenticate connections to the SMTP server.",
+			Unit:   "seconds",
+			Labels: []string{"host"},
+		}),
+		dataBytes: m.Histogram(MetricOpts{
+			Name:    "gomailer_data_bytes",
+			Help:    "Size of the messages written with DATA.",
+			Unit:    "bytes",
+			Labels:  []string{"host"},
+			Buckets: dataBytesBuckets,
+		}),
+		retries: m.Counter(MetricOpts{
+			Name:   "gomailer_retries_total",
+			Help:   "Number of retries of messages that could not be sent.",
+			Labels: []string{"host"},
+		}),
+		frequencyCapped: m.Counter(MetricOpts{
+			Name:   "gomailer_frequency_capped_total",
+			Help:   "Number of messages refused because a recipient reached the frequency cap.",
+			Labels: []string{"host"},
+		}),
+		sentFolderFailures: m.Counter(MetricOpts{
+			Name:   "gomailer_sent_folder_failures_total",
+			Help:   "Number of sent messages that could not be appended to the sent folder.",
+			Labels: []string{"host"},
+		}),
+	}
+}
+
+// incSent counts a message accepted by host.
+func (mm *mailerMetrics) incSent(ctx context.Context, host string) {
+	if mm != nil {
+		mm.sent.Add(ctx, 1, host)
+	}
+}
+
+// incFailures counts a message that could not be sent to host because of err.
+func (mm *mailerMetrics) incFailures(ctx context.Context, host string, err error) {
+	if mm != nil {
+		mm.failures.Add(ctx, 1, host, failureCode(err))
+	}
+}
+
+// observeConnectionSetup records the time d taken to set up a connection to host.
+func (mm *mailerMetrics) observeConnectionSetup(ctx context.Context, host string, d time.Duration) {
+	if mm != nil {
+		mm.connectionSetup.Observe(ctx, d.Seconds(), host)
+	}
+}
+
+// observeDataBytes records the n bytes of a message written to host.
+func (mm *mailerMetrics) observeDataBytes(ctx context.Context, host string, n int) {
+	if mm != nil {
+		mm.dataBytes.Observe(ctx, float64(n), host)
+	}
+}
+
+// incRetries counts a retry of a send to host.
+func (mm *mailerMetrics) incRetries(ctx context.Context, host string) {
+	if mm != nil {
+		mm.retries.Add(ctx, 1, host)
+	}
+}
+
+// incFrequencyCapped counts a message refused by the frequency cap.
+func (mm *mailerMetrics) incFrequencyCapped(ctx context.Context, host string) {
+	if mm != nil {
+		mm.frequencyCapped.Add(ctx, 1, host)
+	}
+}
+
+// incSentFolderFailures counts a sent message that could not be appended to the sent folder.
+func (mm *mailerMetrics) incSentFolderFailures(ctx context.Context, host string) {
+	if mm != nil {
+		mm.sentFolderFailures.Add(ctx, 1, host)
+	}
+}
+
+// failureCode returns the SMTP reply code of err, or "none" when err is not an SMTP error reply.
+func failureCode(err error) string {
+	if code, ok := replyCode(err); ok {
+		return strconv.Itoa(code)
+	}
+	return "none"
+}
@@ -0,0 +1,209 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mailerMock "github.com/nawafswe/gomailer/internal/mock"
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMetrics records the measurements by instrument name and label values, e.g. "gomailer_retries_total{smtp.example.com}".
+type fakeMetrics struct {
+	mu     sync.Mutex
+	values map[string][]float64
+}
+
+// newFakeMetrics creates a new fakeMetrics.
+func newFakeMetrics() *fakeMetrics {
+	return &fakeMetrics{values: make(map[string][]float64)}
+}
+
+// Counter implements Metrics.
+func (f *fakeMetrics) Counter(opts MetricOpts) Counter {
+	return fakeInstrument{metrics: f, name: opts.Name}
+}
+
+// Histogram implements Metrics.
+func (f *fakeMetrics) Histogram(opts MetricOpts) Histogram {
+	return fakeInstrument{metrics: f, name: opts.Name}
+}
+
+// Gauge implements Metrics.
+func (f *fakeMetrics) Gauge(opts MetricOpts) Gauge {
+	return fakeInstrument{metrics: f, name: opts.Name}
+}
+
+// get returns the measurements recorded by the instrument name with labelValues.
+func (f *fakeMetrics) get(name string, labelValues ...string) []float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.values[name+"{"+strings.Join(labelValues, ",")+"}"]
+}
+
+// fakeInstrument records its measurements to fakeMetrics.
+type fakeInstrument struct {
+	metrics *fakeMetrics
+	name    string
+}
+
+// record appends value to the measurements of the instrument with labelValues.
+func (i fakeInstrument) record(value float64, labelValues []string) {
+	i.metrics.mu.Lock()
+	defer i.metrics.mu.Unlock()
+	key := i.name + "{" + strings.Join(labelValues, ",") + "}"
+	i.metrics.values[key] = append(i.metrics.values[key], value)
+}
+
+// Add implements Counter.
+func (i fakeInstrument) Add(_ context.Context, delta float64, labelValues ...string) {
+	i.record(delta, labelValues)
+}
+
+// Observe implements Histogram.
+func (i fakeInstrument) Observe(_ context.Context, value float64, labelValues ...string) {
+	i.record(value, labelValues)
+}
+
+// Set implements Gauge.
+func (i fakeInstrument) Set(_ context.Context, value float64, labelValues ...string) {
+	i.record(value, labelValues)
+}
+
+func TestMailer_Metrics(t *testing.T) {
+	msg := message.Message{
+		From:       testFromEmail,
+		Recipients: testRecipient,
+		Body:       "dummy body",
+	}
+	t.Run("should measure sent messages", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		metrics := newFakeMetrics()
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone), WithMetrics(metrics))
+
+		// expect on mocks
+		smtpMock.EXPECT().Mail(msg.From).Return(nil)
+		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
+		smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+		smtpMock.EXPECT().Quit().Return(nil)
+		var data []byte
+		writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			data = append(data, b...)
+			return len(b), nil
+		})
+		writeCloserMock.EXPECT().Close().Return(nil)
+
+		assert.Nil(t, mailer.Send(context.Background(), msg))
+		assert.Equal(t, []float64{1}, metrics.get("gomailer_messages_sent_total", testHost))
+		assert.Equal(t, []float64{float64(len(data))}, metrics.get("gomailer_data_bytes", testHost))
+		assert.Len(t, metrics.get("gomailer_connection_setup_seconds", testHost), 1)
+		assert.Nil(t, metrics.get("gomailer_messages_failed_total", testHost, "none"))
+	})
+	t.Run("should count failures by SMTP reply code", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+
+		metrics := newFakeMetrics()
+		mailer := NewMailer(testHost, testPort, "", "", WithMetrics(metrics))
+		sender := &mailSender{Client: smtpMock, mailer: mailer, endpoint: mailer.endpoint()}
+
+		// expect on mocks
+		smtpMock.EXPECT().Mail(msg.From).Return(nil)
+		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(&textproto.Error{Code: 550, Msg: "5.1.1 unknown user"})
+
+		assert.NotNil(t, sender.SendContext(context.Background(), msg))
+		assert.Equal(t, []float64{1}, metrics.get("gomailer_messages_failed_total", testHost, "550"))
+		assert.Nil(t, metrics.get("gomailer_messages_sent_total", testHost))
+	})
+	t.Run("should count connection failures without reply code", func(t *testing.T) {
+		// stub functions
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return nil, fmt.Errorf("dummy error")
+		}
+
+		metrics := newFakeMetrics()
+		mailer := NewMailer(testHost, testPort, "", "", withNetDial(netDial), WithEncryption(EncryptionNone), WithMetrics(metrics))
+
+		assert.NotNil(t, mailer.Send(context.Background(), msg))
+		assert.Equal(t, []float64{1}, metrics.get("gomailer_messages_failed_total", testHost, "none"))
+		assert.Nil(t, metrics.get("gomailer_connection_setup_seconds", testHost))
+	})
+	t.Run("should count retries", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		metrics := newFakeMetrics()
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone), WithMetrics(metrics),
+			WithRetryPolicy(Backoff{MaxRetries: 2, BaseDelay: time.Millisecond, Retryable: IsTemporary}),
+		)
+
+		// expect on mocks
+		smtpMock.EXPECT().Mail(msg.From).Return(&textproto.Error{Code: 451, Msg: "4.7.1 greylisted"}).Times(3)
+		smtpMock.EXPECT().Quit().Return(nil).Times(3)
+
+		assert.NotNil(t, mailer.Send(context.Background(), msg))
+		assert.Equal(t, []float64{1, 1}, metrics.get("gomailer_retries_total", testHost))
+	})
+	t.Run("should count messages refused by the frequency cap", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+
+		metrics := newFakeMetrics()
+		mailer := NewMailer(testHost, testPort, "", "", WithMetrics(metrics), WithFrequencyCap(FrequencyCap{Max: 1, Window: time.Hour}))
+		sender := &mailSender{Client: smtpMock, mailer: mailer, endpoint: mailer.endpoint()}
+
+		// expect on mocks
+		smtpMock.EXPECT().Mail(msg.From).Return(fmt.Errorf("dummy error"))
+
+		assert.NotErrorIs(t, sender.SendContext(context.Background(), msg), ErrFrequencyCapped)
+		assert.ErrorIs(t, sender.SendContext(context.Background(), msg), ErrFrequencyCapped)
+		assert.Equal(t, []float64{1}, metrics.get("gomailer_frequency_capped_total", testHost))
+	})
+	t.Run("should count sent folder failures", func(t *testing.T) {
+		metrics := newFakeMetrics()
+		mailer := NewMailer(testHost, testPort, "", "", WithMetrics(metrics),
+			WithSentFolder(&fakeIMAPAppender{err: fmt.Errorf("dummy error")}, "Sent"),
+		)
+
+		mailer.appendSent(context.Background(), msg, []byte("dummy"))
+		assert.Equal(t, []float64{1}, metrics.get("gomailer_sent_folder_failures_total", testHost))
+	})
+	t.Run("should record nothing without metrics", func(t *testing.T) {
+		mailer := NewMailer(testHost, testPort, "", "", WithMetrics(nil))
+		assert.Nil(t, mailer.metrics)
+		assert.NotPanics(t, func() {
+			mailer.metrics.incRetries(context.Background(), testHost)
+		})
+	})
+}
@@ -0,0 +1,124 @@
+// Package openpgp signs and encrypts messages following PGP/MIME (RFC 3156).
+//
+// The package does not implement OpenPGP itself, the keys and cryptography are provided by a
+// user-configured Signer or Encrypter (e.g. backed by github.com/ProtonMail/go-crypto/openpgp or gpg),
+// while this package emits the multipart/signed and multipart/encrypted structures around the message.
+// Sign and Encrypt plug into the encoder as message.EntityWrapper:
+//
+//	encoded, err := msg.Encode(
+//	    message.WithEntityWrapper(openpgp.Sign(signer, "pgp-sha256")),
+//	    message.WithEntityWrapper(openpgp.Encrypt(encrypter)),
+//	)
+package openpgp
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/nawafswe/gomailer/message"
+)
+
+const (
+	crlf = "\r\n"
+
+	// signedBoundary separates the signed entity from its signature.
+	signedBoundary = "SIGNED-BOUNDARY"
+	// encryptedBoundary separates the version identification from the encrypted entity.
+	encryptedBoundary = "ENCRYPTED-BOUNDARY"
+)
+
+// Signer creates OpenPGP signatures.
+type Signer interface {
+	// DetachSign returns the ASCII-armored detached signature of data.
+	DetachSign(data []byte) ([]byte, error)
+}
+
+// SignerFunc is an adapter to use an ordinary function as Signer.
+type SignerFunc func(data []byte) ([]byte, error)
+
+// DetachSign calls f(data).
+func (f SignerFunc) DetachSign(data []byte) ([]byte, error) {
+	return f(data)
+}
+
+// Encrypter encrypts data for the message recipients.
+type Encrypter interface {
+	// Encrypt returns the ASCII-armored OpenPGP message of data.
+	Encrypt(data []byte) ([]byte, error)
+}
+
+// EncrypterFunc is an adapter to use an ordinary function as Encrypter.
+type EncrypterFunc func(data []byte) ([]byte, error)
+
+// Encrypt calls f(data).
+func (f EncrypterFunc) Encrypt(data []byte) ([]byte, error) {
+	return f(data)
+}
+
+// Sign returns a message.EntityWrapper emitting a multipart/signed entity (RFC 3156 section 5)
+// made of the original entity and its detached signature.
+// micalg names the hash algorithm used by the signer, e.g. "pgp-sha256".
+//
+// The signature covers the entity exactly as encoded, so relays must not re-encode it:
+// use it with bodies that are 7bit clean or quoted-printable encoded.
+func Sign(s Signer, micalg string) message.EntityWrapper {
+	return message.EntityWrapperFunc(func(entity []byte) ([]byte, error) {
+		// the CRLF preceding the next boundary belongs to the boundary, not to the signed entity.
+		signed := bytes.TrimSuffix(entity, []byte(crlf))
+		signature, err := s.DetachSign(signed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign message: %w", err)
+		}
+
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "Content-Type: multipart/signed; boundary=%s; micalg=%s; protocol=\"application/pgp-signature\"%s", signedBoundary, micalg, crlf)
+		buf.WriteString(crlf)
+		fmt.Fprintf(&buf, "--%s%s", signedBoundary, crlf)
+		buf.Write(signed)
+		buf.WriteString(crlf)
+		fmt.Fprintf(&buf, "--%s%s", signedBoundary, crlf)
+		fmt.Fprintf(&buf, "Content-Type: application/pgp-signature; name=\"signature.asc\"%s", crlf)
+		fmt.Fprintf(&buf, "Content-Description: OpenPGP digital signature%s", crlf)
+		buf.WriteString(crlf)
+		buf.WriteString(toCRLF(signature))
+		fmt.Fprintf(&buf, "--%s--%s", signedBoundary, crlf)
+		return buf.Bytes(), nil
+	})
+}
+
+// Encrypt returns a message.EntityWrapper emitting a multipart/encrypted entity (RFC 3156 section 4)
+// carrying the encrypted original entity.
+// Apply it after Sign to sign and encrypt a message.
+func Encrypt(e Encrypter) message.EntityWrapper {
+	return message.EntityWrapperFunc(func(entity []byte) ([]byte, error) {
+		encrypted, err := e.Encrypt(entity)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt message: %w", err)
+		}
+
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "Content-Type: multipart/encrypted; boundary=%s; protocol=\"application/pgp-encrypted\"%s", encryptedBoundary, crlf)
+		buf.WriteString(crlf)
+		fmt.Fprintf(&buf, "--%s%s", encryptedBoundary, crlf)
+		fmt.Fprintf(&buf, "Content-Type: application/pgp-encrypted%s", crlf)
+		fmt.Fprintf(&buf, "Content-Description: PGP/MIME version identification%s", crlf)
+		buf.WriteString(crlf)
+		fmt.Fprintf(&buf, "Version: 1%s", crlf)
+		buf.WriteString(crlf)
+		fmt.Fprintf(&buf, "--%s%s", encryptedBoundary, crlf)
+		fmt.Fprintf(&buf, "Content-Type: application/octet-stream; name=\"encrypted.asc\"%s", crlf)
+		fmt.Fprintf(&buf, "Content-Description: OpenPGP encrypted message%s", crlf)
+		fmt.Fprintf(&buf, "Content-Disposition: inline; filename=\"encrypted.asc\"%s", crlf)
+		buf.WriteString(crlf)
+		buf.WriteString(toCRLF(encrypted))
+		fmt.Fprintf(&buf, "--%s--%s", encryptedBoundary, crlf)
+		return buf.Bytes(), nil
+	})
+}
+
+// toCRLF terminates every line of the armored data with CRLF, as armor produced by OpenPGP libraries uses LF.
+func toCRLF(armored []byte) string {
+	lines := strings.Split(strings.TrimRight(strings.ReplaceAll(string(armored), crlf, "\n"), "\n"), "\n")
+	return strings.Join(lines, crlf) + crlf
+}
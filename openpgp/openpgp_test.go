@@ -0,0 +1,77 @@
+package openpgp
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+const testEmail = "test.usr@smtp.com"
+
+func TestOpenPGP_Wrap(t *testing.T) {
+	dummyErr := fmt.Errorf("dummy error")
+	msg := message.Message{
+		From:       testEmail,
+		Recipients: []string{testEmail},
+		Subject:    "pgp",
+		Body:       "hello",
+	}
+	const header = "MIME-Version: 1.0\r\nSubject: =?UTF-8?B?cGdw?=\r\nFrom: test.usr@smtp.com\r\nTo: test.usr@smtp.com\r\n"
+	const entity = "Content-Type: text/plain; charset=us-ascii\r\n\r\nhello"
+	tests := map[string]struct {
+		opts        []message.EncodeOption
+		want        string
+		expectedErr error
+	}{
+		"should emit multipart/signed over the original entity": {
+			opts: []message.EncodeOption{message.WithEntityWrapper(Sign(SignerFunc(func(data []byte) ([]byte, error) {
+				assert.Equal(t, entity, string(data))
+				return []byte("-----BEGIN PGP SIGNATURE-----\n\nsig\n-----END PGP SIGNATURE-----\n"), nil
+			}), "pgp-sha256"))},
+			want: header +
+				"Content-Type: multipart/signed; boundary=SIGNED-BOUNDARY; micalg=pgp-sha256; protocol=\"application/pgp-signature\"\r\n\r\n" +
+				"--SIGNED-BOUNDARY\r\n" + entity + "\r\n" +
+				"--SIGNED-BOUNDARY\r\nContent-Type: application/pgp-signature; name=\"signature.asc\"\r\nContent-Description: OpenPGP digital signature\r\n\r\n" +
+				"-----BEGIN PGP SIGNATURE-----\r\n\r\nsig\r\n-----END PGP SIGNATURE-----\r\n" +
+				"--SIGNED-BOUNDARY--\r\n",
+		},
+		"should emit multipart/encrypted over the signed entity": {
+			opts: []message.EncodeOption{
+				message.WithEntityWrapper(Sign(SignerFunc(func(data []byte) ([]byte, error) {
+					return []byte("sig"), nil
+				}), "pgp-sha512")),
+				message.WithEntityWrapper(Encrypt(EncrypterFunc(func(data []byte) ([]byte, error) {
+					assert.Contains(t, string(data), "Content-Type: multipart/signed; boundary=SIGNED-BOUNDARY; micalg=pgp-sha512")
+					return []byte("-----BEGIN PGP MESSAGE-----\n\nmsg\n-----END PGP MESSAGE-----"), nil
+				}))),
+			},
+			want: header +
+				"Content-Type: multipart/encrypted; boundary=ENCRYPTED-BOUNDARY; protocol=\"application/pgp-encrypted\"\r\n\r\n" +
+				"--ENCRYPTED-BOUNDARY\r\nContent-Type: application/pgp-encrypted\r\nContent-Description: PGP/MIME version identification\r\n\r\nVersion: 1\r\n\r\n" +
+				"--ENCRYPTED-BOUNDARY\r\nContent-Type: application/octet-stream; name=\"encrypted.asc\"\r\nContent-Description: OpenPGP encrypted message\r\nContent-Disposition: inline; filename=\"encrypted.asc\"\r\n\r\n" +
+				"-----BEGIN PGP MESSAGE-----\r\n\r\nmsg\r\n-----END PGP MESSAGE-----\r\n" +
+				"--ENCRYPTED-BOUNDARY--\r\n",
+		},
+		"should fail encoding when signing fails": {
+			opts: []message.EncodeOption{message.WithEntityWrapper(Sign(SignerFunc(func(data []byte) ([]byte, error) {
+				return nil, dummyErr
+			}), "pgp-sha256"))},
+			expectedErr: fmt.Errorf("failed to encode message: %w", fmt.Errorf("failed to wrap MIME entity: %w", fmt.Errorf("failed to sign message: %w", dummyErr))),
+		},
+		"should fail encoding when encrypting fails": {
+			opts: []message.EncodeOption{message.WithEntityWrapper(Encrypt(EncrypterFunc(func(data []byte) ([]byte, error) {
+				return nil, dummyErr
+			})))},
+			expectedErr: fmt.Errorf("failed to encode message: %w", fmt.Errorf("failed to wrap MIME entity: %w", fmt.Errorf("failed to encrypt message: %w", dummyErr))),
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := msg.Encode(tc.opts...)
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.want, string(got))
+		})
+	}
+}
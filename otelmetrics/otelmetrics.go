@@ -0,0 +1,105 @@
+// Package otelmetrics adapts an OpenTelemetry meter to gomailer.Metrics.
+//
+// It is a module of its own, so gomailer does not depend on OpenTelemetry.
+package otelmetrics
+
+import (
+	"context"
+
+	"github.com/nawafswe/gomailer"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// Metrics implements gomailer.Metrics by creating OpenTelemetry instruments.
+type Metrics struct {
+	meter metric.Meter
+}
+
+// New returns gomailer.Metrics creating its instruments with meter, the meter of the global MeterProvider when nil.
+func New(meter metric.Meter) *Metrics {
+	if meter == nil {
+		meter = otel.Meter("github.com/nawafswe/gomailer")
+	}
+	return &Metrics{meter: meter}
+}
+
+// Counter implements gomailer.Metrics.
+func (m *Metrics) Counter(opts gomailer.MetricOpts) gomailer.Counter {
+	c, err := m.meter.Float64Counter(opts.Name, metric.WithDescription(opts.Help), metric.WithUnit(opts.Unit))
+	if err != nil {
+		otel.Handle(err)
+		c, _ = noop.Meter{}.Float64Counter(opts.Name)
+	}
+	return counter{counter: c, labels: opts.Labels}
+}
+
+// Histogram implements gomailer.Metrics, the default boundaries of the SDK are used when opts.Buckets is empty.
+func (m *Metrics) Histogram(opts gomailer.MetricOpts) gomailer.Histogram {
+	histOpts := []metric.Float64HistogramOption{metric.WithDescription(opts.Help), metric.WithUnit(opts.Unit)}
+	if len(opts.Buckets) > 0 {
+		histOpts = append(histOpts, metric.WithExplicitBucketBoundaries(opts.Buckets...))
+	}
+	h, err := m.meter.Float64Histogram(opts.Name, histOpts...)
+	if err != nil {
+		otel.Handle(err)
+		h, _ = noop.Meter{}.Float64Histogram(opts.Name)
+	}
+	return histogram{histogram: h, labels: opts.Labels}
+}
+
+// Gauge implements gomailer.Metrics.
+func (m *Metrics) Gauge(opts gomailer.MetricOpts) gomailer.Gauge {
+	g, err := m.meter.Float64Gauge(opts.Name, metric.WithDescription(opts.Help), metric.WithUnit(opts.Unit))
+	if err != nil {
+		otel.Handle(err)
+		g, _ = noop.Meter{}.Float64Gauge(opts.Name)
+	}
+	return gauge{gauge: g, labels: opts.Labels}
+}
+
+// attributes pairs the label names with their values.
+func attributes(labels, values []string) metric.MeasurementOption {
+	attrs := make([]attribute.KeyValue, 0, len(labels))
+	for i, label := range labels {
+		if i < len(values) {
+			attrs = append(attrs, attribute.String(label, values[i]))
+		}
+	}
+	return metric.WithAttributes(attrs...)
+}
+
+// counter implements gomailer.Counter.
+type counter struct {
+	counter metric.Float64Counter
+	labels  []string
+}
+
+// Add implements gomailer.Counter.
+func (c counter) Add(ctx context.Context, delta float64, labelValues ...string) {
+	c.counter.Add(ctx, delta, attributes(c.labels, labelValues))
+}
+
+// histogram implements gomailer.Histogram.
+type histogram struct {
+	histogram metric.Float64Histogram
+	labels    []string
+}
+
+// Observe implements gomailer.Histogram.
+func (h histogram) Observe(ctx context.Context, value float64, labelValues ...string) {
+	h.histogram.Record(ctx, value, attributes(h.labels, labelValues))
+}
+
+// gauge implements gomailer.Gauge.
+type gauge struct {
+	gauge  metric.Float64Gauge
+	labels []string
+}
+
+// Set implements gomailer.Gauge.
+func (g gauge) Set(ctx context.Context, value float64, labelValues ...string) {
+	g.gauge.Record(ctx, value, attributes(g.labels, labelValues))
+}
@@ -0,0 +1,42 @@
+package otelmetrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nawafswe/gomailer"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	m := New(provider.Meter("test"))
+
+	m.Counter(gomailer.MetricOpts{Name: "sent", Labels: []string{"host"}}).Add(ctx, 2, "smtp.example.com")
+	m.Histogram(gomailer.MetricOpts{Name: "size", Unit: "By", Buckets: []float64{10, 100}}).Observe(ctx, 42)
+	m.Gauge(gomailer.MetricOpts{Name: "connections"}).Set(ctx, 3)
+
+	var rm metricdata.ResourceMetrics
+	assert.Nil(t, reader.Collect(ctx, &rm))
+	metrics := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, metric := range sm.Metrics {
+			metrics[metric.Name] = metric.Data
+		}
+	}
+
+	sum := metrics["sent"].(metricdata.Sum[float64])
+	assert.Equal(t, float64(2), sum.DataPoints[0].Value)
+	host, _ := sum.DataPoints[0].Attributes.Value("host")
+	assert.Equal(t, attribute.StringValue("smtp.example.com"), host)
+	hist := metrics["size"].(metricdata.Histogram[float64])
+	assert.Equal(t, []float64{10, 100}, hist.DataPoints[0].Bounds)
+	assert.Equal(t, float64(42), hist.DataPoints[0].Sum)
+	gauge := metrics["connections"].(metricdata.Gauge[float64])
+	assert.Equal(t, float64(3), gauge.DataPoints[0].Value)
+}
@@ -0,0 +1,71 @@
+// Package oteltracing adapts an OpenTelemetry TracerProvider to gomailer.Tracer.
+//
+// It is a module of its own, so gomailer does not depend on OpenTelemetry.
+package oteltracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nawafswe/gomailer"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer spans are created with.
+const instrumentationName = "github.com/nawafswe/gomailer"
+
+// WithTracerProvider configures gomailer.Mailer to trace the phases of the send pipeline with tp,
+// so slow or failing sends show up in the traces of the requests sending them.
+func WithTracerProvider(tp trace.TracerProvider) func(*gomailer.Mailer) {
+	return gomailer.WithTracer(New(tp))
+}
+
+// Tracer implements gomailer.Tracer by starting OpenTelemetry client spans.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// New returns gomailer.Tracer starting its spans with tp, the global TracerProvider when nil.
+func New(tp trace.TracerProvider) *Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: tp.Tracer(instrumentationName)}
+}
+
+// Start implements gomailer.Tracer.
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, gomailer.Span) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, otelSpan{span: span}
+}
+
+// otelSpan implements gomailer.Span.
+type otelSpan struct {
+	span trace.Span
+}
+
+// SetAttribute implements gomailer.Span.
+func (s otelSpan) SetAttribute(key string, value any) {
+	switch v := value.(type) {
+	case string:
+		s.span.SetAttributes(attribute.String(key, v))
+	case int:
+		s.span.SetAttributes(attribute.Int(key, v))
+	case bool:
+		s.span.SetAttributes(attribute.Bool(key, v))
+	default:
+		s.span.SetAttributes(attribute.String(key, fmt.Sprint(v)))
+	}
+}
+
+// End implements gomailer.Span, the span status is set to error when err is not nil.
+func (s otelSpan) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
@@ -0,0 +1,42 @@
+package oteltracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := New(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	ctx, parent := tracer.Start(context.Background(), "smtp.send")
+	_, child := tracer.Start(ctx, "smtp.envelope")
+	child.SetAttribute("server.address", "smtp.example.com")
+	child.SetAttribute("smtp.response.code", 550)
+	child.SetAttribute("smtp.pipelined", true)
+	child.End(errors.New("550 5.1.1 unknown user"))
+	parent.End(nil)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	envelope, send := spans[0], spans[1]
+	assert.Equal(t, "smtp.envelope", envelope.Name())
+	assert.Equal(t, send.SpanContext().SpanID(), envelope.Parent().SpanID())
+	assert.Equal(t, trace.SpanKindClient, envelope.SpanKind())
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("server.address", "smtp.example.com"),
+		attribute.Int("smtp.response.code", 550),
+		attribute.Bool("smtp.pipelined", true),
+	}, envelope.Attributes())
+	assert.Equal(t, codes.Error, envelope.Status().Code)
+	assert.Equal(t, "550 5.1.1 unknown user", envelope.Status().Description)
+	assert.Equal(t, codes.Unset, send.Status().Code)
+}
@@ -0,0 +1,90 @@
+// Package prometheusmetrics adapts a Prometheus registry to gomailer.Metrics.
+//
+// It is a module of its own, so gomailer does not depend on the Prometheus client.
+package prometheusmetrics
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nawafswe/gomailer"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics implements gomailer.Metrics by registering Prometheus collectors.
+type Metrics struct {
+	registerer prometheus.Registerer
+}
+
+// New returns gomailer.Metrics registering its collectors with registerer, prometheus.DefaultRegisterer when nil.
+// Instruments requested again, e.g. by several Mailers, share the collector already registered.
+func New(registerer prometheus.Registerer) *Metrics {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	return &Metrics{registerer: registerer}
+}
+
+// Counter implements gomailer.Metrics.
+func (m *Metrics) Counter(opts gomailer.MetricOpts) gomailer.Counter {
+	vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: opts.Name, Help: opts.Help}, opts.Labels)
+	return counter{register(m.registerer, vec)}
+}
+
+// Histogram implements gomailer.Metrics, prometheus.DefBuckets are used when opts.Buckets is empty.
+func (m *Metrics) Histogram(opts gomailer.MetricOpts) gomailer.Histogram {
+	vec := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: opts.Name, Help: opts.Help, Buckets: opts.Buckets}, opts.Labels)
+	return histogram{register(m.registerer, vec)}
+}
+
+// Gauge implements gomailer.Metrics.
+func (m *Metrics) Gauge(opts gomailer.MetricOpts) gomailer.Gauge {
+	vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: opts.Name, Help: opts.Help}, opts.Labels)
+	return gauge{register(m.registerer, vec)}
+}
+
+// register registers c with registerer and returns it, or the equal collector already registered.
+// Like prometheus.MustRegister, it panics when c conflicts with a registered collector.
+func register[C prometheus.Collector](registerer prometheus.Registerer, c C) C {
+	err := registerer.Register(c)
+	if err == nil {
+		return c
+	}
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(C); ok {
+			return existing
+		}
+	}
+	panic(err)
+}
+
+// counter implements gomailer.Counter.
+type counter struct {
+	vec *prometheus.CounterVec
+}
+
+// Add implements gomailer.Counter.
+func (c counter) Add(_ context.Context, delta float64, labelValues ...string) {
+	c.vec.WithLabelValues(labelValues...).Add(delta)
+}
+
+// histogram implements gomailer.Histogram.
+type histogram struct {
+	vec *prometheus.HistogramVec
+}
+
+// Observe implements gomailer.Histogram.
+func (h histogram) Observe(_ context.Context, value float64, labelValues ...string) {
+	h.vec.WithLabelValues(labelValues...).Observe(value)
+}
+
+// gauge implements gomailer.Gauge.
+type gauge struct {
+	vec *prometheus.GaugeVec
+}
+
+// Set implements gomailer.Gauge.
+func (g gauge) Set(_ context.Context, value float64, labelValues ...string) {
+	g.vec.WithLabelValues(labelValues...).Set(value)
+}
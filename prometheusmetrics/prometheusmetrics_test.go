@@ -0,0 +1,70 @@
+package prometheusmetrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nawafswe/gomailer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	t.Run("should record counters, histograms and gauges", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		m := New(registry)
+
+		m.Counter(gomailer.MetricOpts{Name: "sent_total", Labels: []string{"host"}}).Add(ctx, 2, "smtp.example.com")
+		m.Histogram(gomailer.MetricOpts{Name: "size_bytes", Buckets: []float64{10, 100}}).Observe(ctx, 42)
+		m.Gauge(gomailer.MetricOpts{Name: "connections", Labels: []string{"host"}}).Set(ctx, 3, "smtp.example.com")
+
+		count, err := testutil.GatherAndCount(registry, "sent_total", "size_bytes", "connections")
+		assert.Nil(t, err)
+		assert.Equal(t, 3, count)
+		families, err := registry.Gather()
+		assert.Nil(t, err)
+		values := make(map[string]float64)
+		for _, family := range families {
+			metric := family.GetMetric()[0]
+			switch {
+			case metric.Counter != nil:
+				values[family.GetName()] = metric.GetCounter().GetValue()
+			case metric.Histogram != nil:
+				values[family.GetName()] = metric.GetHistogram().GetSampleSum()
+			case metric.Gauge != nil:
+				values[family.GetName()] = metric.GetGauge().GetValue()
+			}
+		}
+		assert.Equal(t, map[string]float64{"sent_total": 2, "size_bytes": 42, "connections": 3}, values)
+	})
+	t.Run("should share the collector of instruments requested again", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		opts := gomailer.MetricOpts{Name: "sent_total", Help: "Sent messages.", Labels: []string{"host"}}
+
+		New(registry).Counter(opts).Add(ctx, 1, "smtp.example.com")
+		New(registry).Counter(opts).Add(ctx, 1, "smtp.example.com")
+
+		count, err := testutil.GatherAndCount(registry, "sent_total")
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count)
+		families, err := registry.Gather()
+		assert.Nil(t, err)
+		assert.Equal(t, float64(2), families[0].GetMetric()[0].GetCounter().GetValue())
+	})
+	t.Run("should panic on conflicting instruments", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		m := New(registry)
+		m.Counter(gomailer.MetricOpts{Name: "sent_total", Labels: []string{"host"}})
+
+		assert.Panics(t, func() {
+			m.Counter(gomailer.MetricOpts{Name: "sent_total", Labels: []string{"port"}})
+		})
+	})
+}
+
+func TestMailer_WithMetrics(t *testing.T) {
+	_, err := gomailer.NewMailerE("smtp.example.com", 587, "", "", gomailer.WithMetrics(New(prometheus.NewRegistry())))
+	assert.Nil(t, err)
+}
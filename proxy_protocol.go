@@ -0,0 +1,91 @@
+package gomailer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// ProxyProtocolVersion is the version of the PROXY protocol header sent by the Mailer (see WithProxyProtocol).
+type ProxyProtocolVersion int
+
+const (
+	// ProxyProtocolV1 is the human-readable version 1 of the PROXY protocol, e.g. "PROXY TCP4 10.0.0.1 10.0.0.2 50000 25".
+	ProxyProtocolV1 ProxyProtocolVersion = 1
+	// ProxyProtocolV2 is the binary version 2 of the PROXY protocol.
+	ProxyProtocolV2 ProxyProtocolVersion = 2
+)
+
+// proxyV2Signature starts the version 2 PROXY protocol headers.
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// WithProxyProtocol configures Mailer to send a PROXY protocol header of the version right after connecting,
+// before the TLS handshake and the greeting, as relays behind HAProxy or other load balancers accepting it require.
+// The header carries the local and remote addresses of the connection, so the relay attributes the messages to the
+// Mailer host rather than to the load balancer. Connections over non-TCP addresses send an UNKNOWN header.
+func WithProxyProtocol(version ProxyProtocolVersion) func(*Mailer) {
+	return func(mailer *Mailer) {
+		if version != ProxyProtocolV1 && version != ProxyProtocolV2 {
+			mailer.invalidOption("proxy protocol version %d must be 1 or 2", version)
+			return
+		}
+		mailer.proxyProtocol = version
+	}
+}
+
+// writeProxyHeader writes the PROXY protocol header of the connection to it, nothing when no version is configured.
+func (m *Mailer) writeProxyHeader(conn net.Conn) error {
+	if m.proxyProtocol == 0 {
+		return nil
+	}
+	if _, err := conn.Write(proxyHeader(m.proxyProtocol, conn.LocalAddr(), conn.RemoteAddr())); err != nil {
+		return fmt.Errorf("failed to send proxy protocol header: %w", err)
+	}
+	return nil
+}
+
+// proxyHeader returns the PROXY protocol header of the version for a connection from src to dst.
+// IPv4 addresses are mapped to IPv6 when the other address is IPv6.
+func proxyHeader(version ProxyProtocolVersion, src, dst net.Addr) []byte {
+	srcAddr, srcOK := src.(*net.TCPAddr)
+	dstAddr, dstOK := dst.(*net.TCPAddr)
+	known := srcOK && dstOK
+	var srcIP, dstIP net.IP
+	ipv4 := known && srcAddr.IP.To4() != nil && dstAddr.IP.To4() != nil
+	if ipv4 {
+		srcIP, dstIP = srcAddr.IP.To4(), dstAddr.IP.To4()
+	} else if known {
+		srcIP, dstIP = srcAddr.IP.To16(), dstAddr.IP.To16()
+		known = srcIP != nil && dstIP != nil
+	}
+
+	if version == ProxyProtocolV1 {
+		if !known {
+			return []byte("PROXY UNKNOWN\r\n")
+		}
+		if ipv4 {
+			return fmt.Appendf(nil, "PROXY TCP4 %s %s %d %d\r\n", srcIP, dstIP, srcAddr.Port, dstAddr.Port)
+		}
+		// net.IP formats mapped IPv4 addresses as IPv4 ones, which TCP6 headers do not accept.
+		src, dst := netip.AddrFrom16([16]byte(srcIP)), netip.AddrFrom16([16]byte(dstIP))
+		return fmt.Appendf(nil, "PROXY TCP6 %s %s %d %d\r\n", src, dst, srcAddr.Port, dstAddr.Port)
+	}
+
+	// version 2 and PROXY command, followed by the address family and the length of the addresses.
+	header := append(proxyV2Signature[:len(proxyV2Signature):len(proxyV2Signature)], 0x21)
+	if !known {
+		// the UNSPEC family, the receiver uses the addresses of the connection.
+		return append(header, 0x00, 0x00, 0x00)
+	}
+	family := byte(0x21) // TCP over IPv6
+	if ipv4 {
+		family = 0x11 // TCP over IPv4
+	}
+	addresses := append(append([]byte{}, srcIP...), dstIP...)
+	addresses = binary.BigEndian.AppendUint16(addresses, uint16(srcAddr.Port))
+	addresses = binary.BigEndian.AppendUint16(addresses, uint16(dstAddr.Port))
+	header = append(header, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(addresses)))
+	return append(header, addresses...)
+}
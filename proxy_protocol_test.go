@@ -0,0 +1,88 @@
+package gomailer
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyHeader(t *testing.T) {
+	ipv4Src := &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 50000}
+	ipv4Dst := &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 25}
+	ipv6Dst := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 587}
+	unknown := &net.UnixAddr{Name: "/tmp/smtp.sock", Net: "unix"}
+	tests := map[string]struct {
+		version  ProxyProtocolVersion
+		src, dst net.Addr
+		expected string
+	}{
+		"should write a v1 TCP4 header": {
+			version: ProxyProtocolV1, src: ipv4Src, dst: ipv4Dst,
+			expected: "PROXY TCP4 192.0.2.10 198.51.100.1 50000 25\r\n",
+		},
+		"should write a v1 TCP6 header mapping the IPv4 address": {
+			version: ProxyProtocolV1, src: ipv4Src, dst: ipv6Dst,
+			expected: "PROXY TCP6 ::ffff:192.0.2.10 2001:db8::1 50000 587\r\n",
+		},
+		"should write a v1 UNKNOWN header for non-TCP addresses": {
+			version: ProxyProtocolV1, src: unknown, dst: ipv4Dst,
+			expected: "PROXY UNKNOWN\r\n",
+		},
+		"should write a v2 TCP over IPv4 header": {
+			version: ProxyProtocolV2, src: ipv4Src, dst: ipv4Dst,
+			expected: "\r\n\r\n\x00\r\nQUIT\n\x21\x11\x00\x0c\xc0\x00\x02\x0a\xc6\x33\x64\x01\xc3\x50\x00\x19",
+		},
+		"should write a v2 TCP over IPv6 header": {
+			version: ProxyProtocolV2, src: ipv4Src, dst: ipv6Dst,
+			expected: "\r\n\r\n\x00\r\nQUIT\n\x21\x21\x00\x24" +
+				"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\xff\xff\xc0\x00\x02\x0a" +
+				"\x20\x01\x0d\xb8\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01" +
+				"\xc3\x50\x02\x4b",
+		},
+		"should write a v2 UNSPEC header for non-TCP addresses": {
+			version: ProxyProtocolV2, src: ipv4Src, dst: unknown,
+			expected: "\r\n\r\n\x00\r\nQUIT\n\x21\x00\x00\x00",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, string(proxyHeader(tc.version, tc.src, tc.dst)))
+		})
+	}
+}
+
+func TestMailer_WithProxyProtocol(t *testing.T) {
+	t.Run("should send the header before the greeting", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		commands := make(chan string, 10)
+		preamble := make(chan string, 1)
+		go func() {
+			// pipe addresses are not TCP ones.
+			header := make([]byte, len("PROXY UNKNOWN\r\n"))
+			_, _ = io.ReadFull(serverConn, header)
+			preamble <- string(header)
+			serveSMTP(serverConn, "8BITMIME", map[string]string{"MAIL": "250 ok", "RCPT": "250 ok"}, commands)
+		}()
+
+		// stub functions
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return clientConn, nil
+		}
+
+		mailer := NewMailer("localhost", testPort, "", "", withNetDial(netDial), WithEncryption(EncryptionNone), WithProxyProtocol(ProxyProtocolV1))
+		err := mailer.Send(context.Background(), message.Message{From: testFromEmail, Recipients: testRecipient, Body: "hi"})
+		assert.Nil(t, err)
+		assert.Equal(t, "PROXY UNKNOWN\r\n", <-preamble)
+		assert.Contains(t, receive(commands), "MAIL FROM:<"+testFromEmail+"> BODY=8BITMIME")
+	})
+	t.Run("should reject an unknown version", func(t *testing.T) {
+		_, err := NewMailerE(testHost, testPort, testUser, testPassword, WithProxyProtocol(3))
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, "proxy protocol version 3 must be 1 or 2")
+	})
+}
@@ -0,0 +1,157 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nawafswe/gomailer/message"
+)
+
+// WithRateLimit configures Mailer to send at most n messages within any period of duration per, e.g. 14 per second,
+// so bulk sends stay under the quota of the SMTP provider. Sends exceeding the limit wait for their turn until
+// their context is done, which gives their turn back. The limit is shared by Send, SendBatch and the SendClosers
+// of the Mailer.
+func WithRateLimit(n int, per time.Duration) func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.rateLimit = newRateLimiter(n, per, mailer.now)
+	}
+}
+
+// WithDomainRateLimit configures Mailer to send at most n messages within any period of duration per to the
+// recipients of domain, e.g. 2000 per day to "gmail.com", on top of the limit of WithRateLimit.
+// A message counts once for every domain of its recipients. It may be given once for every domain.
+func WithDomainRateLimit(domain string, n int, per time.Duration) func(*Mailer) {
+	return func(mailer *Mailer) {
+		if mailer.domainRateLimits == nil {
+			mailer.domainRateLimits = make(map[string]*rateLimiter)
+		}
+		mailer.domainRateLimits[strings.ToLower(domain)] = newRateLimiter(n, per, mailer.now)
+	}
+}
+
+// waitRateLimit waits until the message is allowed by the rate limit of the Mailer and of the domains of its recipients.
+func (m *Mailer) waitRateLimit(ctx context.Context, msg message.Message) error {
+	limiters := make([]*rateLimiter, 0, 1)
+	if m.rateLimit != nil {
+		limiters = append(limiters, m.rateLimit)
+	}
+	seen := make(map[string]bool)
+	for _, r := range msg.Recipients {
+		addr := message.EnvelopeAddress(r)
+		domain := strings.ToLower(addr[strings.LastIndexByte(addr, '@')+1:])
+		if l, ok := m.domainRateLimits[domain]; ok && !seen[domain] {
+			seen[domain] = true
+			limiters = append(limiters, l)
+		}
+	}
+	var delay time.Duration
+	reservations := make([]reservation, 0, len(limiters))
+	for _, l := range limiters {
+		d, r := l.reserve()
+		delay = max(delay, d)
+		reservations = append(reservations, r)
+	}
+	if delay > 0 {
+		if err := sleep(ctx, delay); err != nil {
+			// the message is not sent, its turn is given back so it does not delay later messages.
+			for _, r := range reservations {
+				r.cancel()
+			}
+			return fmt.Errorf("failed to wait for rate limit: %w", err)
+		}
+	}
+	return nil
+}
+
+// rateLimiter allows n messages within any period of duration per, it remembers when the last n messages were sent.
+type rateLimiter struct {
+	n   int
+	per time.Duration
+	// now returns the current time of the Mailer clock.
+	now func() time.Time
+
+	mu sync.Mutex
+	// sent holds the times of the last n messages as a ring, sent[i] is the oldest, ids their reservations.
+	sent []time.Time
+	ids  []uint64
+	i    int
+	// evicted is the time of the last message dropped from the ring, which takes the oldest position back when
+	// a reservation is cancelled. It is not earlier than the message actually preceding the ring, so cancelling
+	// never lets more than n messages through within per.
+	evicted time.Time
+	// lastID is the identifier of the last reservation.
+	lastID uint64
+}
+
+// reservation is a message counted by a rateLimiter, see reservation.cancel.
+type reservation struct {
+	l  *rateLimiter
+	id uint64
+}
+
+// newRateLimiter returns a rateLimiter allowing n messages per period measured with now,
+// it allows every message when n or per is not positive.
+func newRateLimiter(n int, per time.Duration, now func() time.Time) *rateLimiter {
+	l := &rateLimiter{n: n, per: per, now: now}
+	if n > 0 && per > 0 {
+		l.sent = make([]time.Time, n)
+		l.ids = make([]uint64, n)
+	}
+	return l
+}
+
+// valid reports whether the limit allows at least one message within a positive period.
+func (l *rateLimiter) valid() bool {
+	return l.n > 0 && l.per > 0
+}
+
+// reserve counts a message and returns how long to wait before sending it, and its reservation.
+func (l *rateLimiter) reserve() (time.Duration, reservation) {
+	if len(l.sent) == 0 {
+		return 0, reservation{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	at := now
+	if oldest := l.sent[l.i]; !oldest.IsZero() && oldest.Add(l.per).After(at) {
+		// the message would be the n+1th within per.
+		at = oldest.Add(l.per)
+	}
+	if last := l.sent[(l.i+len(l.sent)-1)%len(l.sent)]; last.After(at) {
+		// keep the ring ordered as messages waiting for their turn are sent in order.
+		at = last
+	}
+	l.lastID++
+	l.evicted = l.sent[l.i]
+	l.sent[l.i], l.ids[l.i] = at, l.lastID
+	l.i = (l.i + 1) % len(l.sent)
+	return at.Sub(now), reservation{l: l, id: l.lastID}
+}
+
+// cancel gives back the turn of a message that is not sent, so it does not count against the limit.
+// The messages reserved after it keep their turn. It does nothing once the message left the ring.
+func (r reservation) cancel() {
+	l := r.l
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := len(l.sent)
+	for k := 0; k < n; k++ {
+		if l.ids[k] != r.id {
+			continue
+		}
+		// the older messages move up a position, so the ring stays ordered, and the evicted one takes the oldest back.
+		for ; k != l.i; k = (k + n - 1) % n {
+			prev := (k + n - 1) % n
+			l.sent[k], l.ids[k] = l.sent[prev], l.ids[prev]
+		}
+		l.sent[l.i], l.ids[l.i] = l.evicted, 0
+		return
+	}
+}
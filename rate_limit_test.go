@@ -0,0 +1,159 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mailerMock "github.com/nawafswe/gomailer/internal/mock"
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter_Reserve(t *testing.T) {
+	start := time.Date(2024, time.March, 5, 10, 30, 0, 0, time.UTC)
+	tests := map[string]struct {
+		n   int
+		per time.Duration
+		// at are the offsets from start messages are sent at.
+		at             []time.Duration
+		expectedDelays []time.Duration
+	}{
+		"should allow n messages at once": {
+			n:              3,
+			per:            time.Second,
+			at:             []time.Duration{0, 0, 0},
+			expectedDelays: []time.Duration{0, 0, 0},
+		},
+		"should delay messages exceeding n within the period": {
+			n:              2,
+			per:            time.Second,
+			at:             []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond},
+			expectedDelays: []time.Duration{0, 0, 800 * time.Millisecond, 800 * time.Millisecond},
+		},
+		"should allow messages again once the period passed": {
+			n:              1,
+			per:            time.Minute,
+			at:             []time.Duration{0, time.Minute, 90 * time.Second},
+			expectedDelays: []time.Duration{0, 0, 30 * time.Second},
+		},
+		"should allow every message without a valid limit": {
+			n:              0,
+			per:            time.Second,
+			at:             []time.Duration{0, 0},
+			expectedDelays: []time.Duration{0, 0},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var now time.Time
+			l := newRateLimiter(tc.n, tc.per, func() time.Time { return now })
+			var delays []time.Duration
+			for _, at := range tc.at {
+				now = start.Add(at)
+				delay, _ := l.reserve()
+				delays = append(delays, delay)
+			}
+			assert.Equal(t, tc.expectedDelays, delays)
+		})
+	}
+}
+
+func TestRateLimiter_Cancel(t *testing.T) {
+	now := time.Date(2024, time.March, 5, 10, 30, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	t.Run("should give back the turn of the last reserved message", func(t *testing.T) {
+		l := newRateLimiter(1, time.Hour, clock)
+		l.reserve()
+		_, r := l.reserve()
+		r.cancel()
+		delay, _ := l.reserve()
+		assert.Equal(t, time.Hour, delay)
+	})
+	t.Run("should give back the turn of an earlier message, later ones keeping theirs", func(t *testing.T) {
+		l := newRateLimiter(2, time.Hour, clock)
+		l.reserve()
+		l.reserve()
+		_, r := l.reserve()
+		delay, _ := l.reserve()
+		assert.Equal(t, time.Hour, delay)
+		r.cancel()
+		delay, _ = l.reserve()
+		assert.Equal(t, time.Hour, delay)
+		delay, _ = l.reserve()
+		assert.Equal(t, 2*time.Hour, delay)
+	})
+	t.Run("should not give back a turn twice", func(t *testing.T) {
+		l := newRateLimiter(1, time.Hour, clock)
+		l.reserve()
+		_, r := l.reserve()
+		r.cancel()
+		r.cancel()
+		delay, _ := l.reserve()
+		assert.Equal(t, time.Hour, delay)
+	})
+	t.Run("should do nothing without a valid limit", func(t *testing.T) {
+		_, r := newRateLimiter(0, time.Hour, clock).reserve()
+		r.cancel()
+	})
+}
+
+func TestMailer_RateLimit(t *testing.T) {
+	msg := message.Message{
+		From:       testFromEmail,
+		Recipients: []string{"a@example.com", "B <b@Example.com>"},
+		Body:       "dummy body",
+	}
+	t.Run("should wait for the rate limit of recipient domains", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+
+		mailer := NewMailer(testHost, testPort, "", "", WithDomainRateLimit("EXAMPLE.com", 1, time.Hour))
+		sender := &mailSender{Client: smtpMock, mailer: mailer, endpoint: mailer.endpoint()}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		// expect on mocks, only the first message reaches the server.
+		smtpMock.EXPECT().Mail(msg.From).Return(fmt.Errorf("dummy error"))
+
+		assert.NotErrorIs(t, sender.SendContext(context.Background(), msg), context.DeadlineExceeded)
+		assert.ErrorIs(t, sender.SendContext(ctx, msg), context.DeadlineExceeded)
+	})
+	t.Run("should not limit other domains", func(t *testing.T) {
+		mailer := NewMailer(testHost, testPort, "", "", WithDomainRateLimit("gmail.com", 1, time.Hour))
+		for range 3 {
+			assert.Nil(t, mailer.waitRateLimit(context.Background(), msg))
+		}
+	})
+	t.Run("should wait for the rate limit of the mailer", func(t *testing.T) {
+		mailer := NewMailer(testHost, testPort, "", "", WithRateLimit(1, time.Hour))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		assert.Nil(t, mailer.waitRateLimit(ctx, msg))
+		assert.ErrorIs(t, mailer.waitRateLimit(ctx, msg), context.DeadlineExceeded)
+	})
+	t.Run("should give back the turn of a message whose wait is cancelled", func(t *testing.T) {
+		now := time.Date(2024, time.March, 5, 10, 30, 0, 0, time.UTC)
+		mailer := NewMailer(testHost, testPort, "", "", withClock(func() time.Time { return now }),
+			WithRateLimit(1, time.Hour), WithDomainRateLimit("example.com", 1, time.Hour))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		assert.Nil(t, mailer.waitRateLimit(ctx, msg))
+		assert.ErrorIs(t, mailer.waitRateLimit(ctx, msg), context.DeadlineExceeded)
+		for _, l := range []*rateLimiter{mailer.rateLimit, mailer.domainRateLimits["example.com"]} {
+			delay, _ := l.reserve()
+			assert.Equal(t, time.Hour, delay)
+		}
+	})
+	t.Run("should refuse limits allowing no message", func(t *testing.T) {
+		_, err := NewMailerE(testHost, testPort, testUser, testPassword, WithRateLimit(0, time.Second))
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+		_, err = NewMailerE(testHost, testPort, testUser, testPassword, WithDomainRateLimit("gmail.com", 10, 0))
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+	})
+}
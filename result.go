@@ -0,0 +1,190 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/nawafswe/gomailer/message"
+)
+
+// Result describes a message accepted by the SMTP server, as returned by Mailer.SendResult.
+type Result struct {
+	// Endpoint is the SMTP server that accepted the message, a fallback host when the primary one failed.
+	Endpoint Endpoint
+	// MessageID is the Message-ID header of the sent message, generated by the Mailer when the message lacked one.
+	MessageID string
+	// Recipients are the envelope recipients the message was accepted for, after they were rewritten.
+	Recipients []string
+	// Bytes is the size of the encoded message transferred to the server.
+	Bytes int
+	// Reply is the reply of the server accepting the message, e.g. "2.0.0 Ok: queued as 4F2A1B3C".
+	Reply string
+	// QueueID is the identifier the server queued the message under, parsed from Reply, empty when not recognized.
+	QueueID string
+	// Attempts is the number of attempts it took to send the message, more than 1 when it was retried.
+	Attempts int
+	// ConnectDuration is the time taken to connect and authenticate to the server for the successful attempt.
+	ConnectDuration time.Duration
+	// TransactionDuration is the time taken from the MAIL command to the reply accepting the message.
+	TransactionDuration time.Duration
+	// Duration is the time taken by the whole send, retries included.
+	Duration time.Duration
+}
+
+// SendResult sends the message like Send and returns the Result describing the accepted message,
+// so the relay queue ID, timings and connection used are observable without hooks. The Result is nil on error.
+func (m *Mailer) SendResult(ctx context.Context, msg message.Message) (*Result, error) {
+	start := m.now()
+	ctx, err := m.withSendState(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	var result *Result
+	attempts, err := m.withRetries(ctx, func() (err error) {
+		result, err = m.sendOnce(ctx, msg)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Attempts = attempts
+	result.Duration = m.now().Sub(start)
+	return result, nil
+}
+
+// withRetries calls send until it succeeds or the retry policy of the Mailer gives up, it returns the number
+// of attempts and the error of the last one. Sends the server may have accepted are never retried (see maybeSent).
+func (m *Mailer) withRetries(ctx context.Context, send func() error) (attempts int, err error) {
+	err = send()
+	attempts = 1
+	if err == nil || m == nil || m.retryPolicy == nil {
+		return attempts, err
+	}
+	for retry := 1; err != nil && !maybeSent(err); retry++ {
+		delay, ok := m.retryPolicy.NextDelay(retry, err)
+		if !ok {
+			break
+		}
+		if ctxErr := sleep(ctx, delay); ctxErr != nil {
+			return attempts, errors.Join(err, ctxErr)
+		}
+		m.metrics.incRetries(ctx, m.Host)
+		err = send()
+		attempts++
+	}
+	return attempts, err
+}
+
+// sendStateKey is the context key of the sendState shared by the attempts of Mailer.SendResult.
+type sendStateKey struct{}
+
+// sendState is shared by the attempts of a send, so every attempt carries the same Message-ID and Date
+// and the message is counted against the frequency cap once.
+type sendState struct {
+	// messageID and date are stamped on the message when it lacks the headers, none when empty and zero.
+	messageID string
+	date      time.Time
+	// resentID is the Resent-Message-ID of a resend, see SendOptions.ResentFrom, none when empty.
+	resentID string
+	// capErr is the outcome of the frequency cap check, made when counted is true.
+	counted bool
+	capErr  error
+}
+
+// withSendState returns a copy of ctx carrying the state of a send of msg, its Message-ID, Resent-Message-ID
+// and Date generated once for all the attempts.
+func (m *Mailer) withSendState(ctx context.Context, msg message.Message) (context.Context, error) {
+	if m == nil {
+		return ctx, nil
+	}
+	state := &sendState{}
+	if m.messageID && !msg.HasHeader("Message-ID") {
+		id, err := m.newMessageID()
+		if err != nil {
+			return nil, fmt.Errorf("failed to send message: %w", err)
+		}
+		state.messageID = id
+	}
+	opts, _ := SendOptionsFromContext(ctx)
+	if m.messageID && opts.ResentFrom != "" {
+		id, err := m.newMessageID()
+		if err != nil {
+			return nil, fmt.Errorf("failed to send message: %w", err)
+		}
+		state.resentID = id
+	}
+	if (m.date && !msg.HasHeader("Date")) || opts.ResentFrom != "" {
+		state.date = m.now()
+	}
+	return context.WithValue(ctx, sendStateKey{}, state), nil
+}
+
+// sendStateFrom returns the state of the send carried by ctx, an empty one when ctx carries none,
+// e.g. for messages sent over a SendCloser.
+func sendStateFrom(ctx context.Context) *sendState {
+	if state, ok := ctx.Value(sendStateKey{}).(*sendState); ok {
+		return state
+	}
+	return &sendState{}
+}
+
+// resultKey is the context key of the Result of the message passed to the AfterSend and OnError hooks.
+type resultKey struct{}
+
+// contextWithResult returns a copy of ctx carrying the Result of the message, as far as it was sent.
+func contextWithResult(ctx context.Context, r Result) context.Context {
+	return context.WithValue(ctx, resultKey{}, r)
+}
+
+// resultFromContext returns the Result carried by ctx, see contextWithResult.
+func resultFromContext(ctx context.Context) (Result, bool) {
+	r, ok := ctx.Value(resultKey{}).(Result)
+	return r, ok
+}
+
+// queueIDMarkers precede the queue identifier in the replies of common servers,
+// e.g. "Ok: queued as 4F2A1B3C" for Postfix or "OK id=1rXyZa-0001" for Exim.
+var queueIDMarkers = []string{"queued as ", "id="}
+
+// parseQueueID returns the queue identifier of the reply accepting a message, empty when not recognized.
+func parseQueueID(reply string) string {
+	lower := strings.ToLower(reply)
+	for _, marker := range queueIDMarkers {
+		if i := strings.Index(lower, marker); i >= 0 {
+			id, _, _ := strings.Cut(reply[i+len(marker):], " ")
+			return strings.Trim(id, "<>.,;")
+		}
+	}
+	return ""
+}
+
+// headerValues returns all the values of the header of the message, those of its Headers then of its Trace,
+// the key is matched case-insensitively.
+func headerValues(msg message.Message, key string) []string {
+	var values []string
+	for k, v := range msg.Headers {
+		if textproto.CanonicalMIMEHeaderKey(k) == textproto.CanonicalMIMEHeaderKey(key) {
+			values = append(values, v...)
+		}
+	}
+	for _, f := range msg.Trace {
+		if textproto.CanonicalMIMEHeaderKey(f.Key) == textproto.CanonicalMIMEHeaderKey(key) {
+			values = append(values, f.Value)
+		}
+	}
+	return values
+}
+
+// headerValue returns the first value of the message header, the key is matched case-insensitively.
+func headerValue(msg message.Message, key string) string {
+	for k, v := range msg.Headers {
+		if textproto.CanonicalMIMEHeaderKey(k) == textproto.CanonicalMIMEHeaderKey(key) && len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
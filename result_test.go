@@ -0,0 +1,82 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQueueID(t *testing.T) {
+	tests := map[string]struct {
+		reply    string
+		expected string
+	}{
+		"should parse a Postfix reply":        {reply: "2.0.0 Ok: queued as 4F2A1B3C", expected: "4F2A1B3C"},
+		"should parse an Exim reply":          {reply: "OK id=1rXyZa-0001Ab-2C", expected: "1rXyZa-0001Ab-2C"},
+		"should parse a bracketed identifier": {reply: "2.0.0 Queued as <ABC123> (mx1)", expected: "ABC123"},
+		"should not parse an unknown reply":   {reply: "2.0.0 OK 1700000000 gsmtp", expected: ""},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, parseQueueID(tc.reply))
+		})
+	}
+}
+
+func TestMailer_SendResult(t *testing.T) {
+	t.Run("should describe the accepted message after a retry", func(t *testing.T) {
+		// stub functions, the clock advances a millisecond every time it is read.
+		now := time.Date(2024, time.March, 5, 10, 30, 0, 0, time.UTC)
+		clock := func() time.Time {
+			now = now.Add(time.Millisecond)
+			return now
+		}
+		var dials int
+		commands := make(chan string, 20)
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			if dials++; dials == 1 {
+				return nil, errors.New("connection refused")
+			}
+			clientConn, serverConn := net.Pipe()
+			go serveSMTP(serverConn, "8BITMIME", map[string]string{"MAIL": "250 ok", "RCPT": "250 ok", ".": "250 2.0.0 Ok: queued as 4F2A1B3C"}, commands)
+			return clientConn, nil
+		}
+
+		mailer := NewMailer("localhost", testPort, "", "", withNetDial(netDial), withClock(clock), WithEncryption(EncryptionNone),
+			WithRetryPolicy(Backoff{MaxRetries: 1}), WithRecipientRewriter(Subaddress("alerts")))
+		msg := message.Message{From: testFromEmail, Recipients: []string{"user@example.com"}, Body: "alert",
+			Headers: map[string][]string{"Message-Id": {"<1@localhost>"}, "Date": {"Tue, 05 Mar 2024 10:30:00 +0000"}}}
+		encoded, err := msg.Encode()
+		require.Nil(t, err)
+
+		result, err := mailer.SendResult(context.Background(), msg)
+		require.Nil(t, err)
+		receive(commands)
+		assert.Equal(t, &Result{
+			Endpoint:            Endpoint{Host: "localhost", Port: testPort},
+			MessageID:           "<1@localhost>",
+			Recipients:          []string{"user+alerts@example.com"},
+			Bytes:               len(encoded),
+			Reply:               "2.0.0 Ok: queued as 4F2A1B3C",
+			QueueID:             "4F2A1B3C",
+			Attempts:            2,
+			ConnectDuration:     3 * time.Millisecond,
+			TransactionDuration: 6 * time.Millisecond,
+			Duration:            15 * time.Millisecond,
+		}, result)
+	})
+	t.Run("should return no result when the message could not be sent", func(t *testing.T) {
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return nil, errors.New("connection refused")
+		}
+		result, err := NewMailer("localhost", testPort, "", "", withNetDial(netDial)).SendResult(context.Background(), message.Message{From: testFromEmail, Recipients: testRecipient})
+		assert.ErrorContains(t, err, "connection refused")
+		assert.Nil(t, result)
+	})
+}
@@ -0,0 +1,141 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/textproto"
+	"time"
+
+	"github.com/nawafswe/gomailer/smtpcode"
+)
+
+// RetryPolicy decides whether and when Mailer.Send retries a message that could not be sent.
+type RetryPolicy interface {
+	// NextDelay returns how long to wait before the given retry (starting at 1) of a send that failed with err,
+	// or false to give up and return err.
+	NextDelay(retry int, err error) (time.Duration, bool)
+}
+
+// RetryPolicyFunc is an adapter to use an ordinary function as RetryPolicy.
+type RetryPolicyFunc func(retry int, err error) (time.Duration, bool)
+
+// NextDelay calls f(retry, err).
+func (f RetryPolicyFunc) NextDelay(retry int, err error) (time.Duration, bool) {
+	return f(retry, err)
+}
+
+// Backoff is a RetryPolicy retrying with exponentially growing delays.
+type Backoff struct {
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries int
+	// BaseDelay is the delay before the first retry, doubled for every following retry.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries, no cap is applied when zero.
+	MaxDelay time.Duration
+	// Retryable reports whether a send failing with err is retried, every error is retried when nil.
+	Retryable func(err error) bool
+}
+
+// NextDelay implements RetryPolicy.
+func (b Backoff) NextDelay(retry int, err error) (time.Duration, bool) {
+	if retry > b.MaxRetries || (b.Retryable != nil && !b.Retryable(err)) {
+		return 0, false
+	}
+	delay := b.BaseDelay
+	for range retry - 1 {
+		if delay <= 0 {
+			break
+		}
+		if delay > math.MaxInt64/2 {
+			// doubling would overflow, the delay saturates instead.
+			delay = math.MaxInt64
+			break
+		}
+		delay *= 2
+	}
+	if b.MaxDelay > 0 && delay > b.MaxDelay {
+		delay = b.MaxDelay
+	}
+	return delay, true
+}
+
+// Retry policy presets, see WithRetryPolicy.
+var (
+	// RetryNone never retries, it is the default.
+	RetryNone RetryPolicy = RetryPolicyFunc(func(int, error) (time.Duration, bool) { return 0, false })
+
+	// RetryTransientOnly retries up to 3 times, from 1s up to 30s apart, failures the server or sendmail
+	// reported as temporary, e.g. 4xx replies such as greylisting.
+	RetryTransientOnly RetryPolicy = Backoff{MaxRetries: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second, Retryable: IsTemporary}
+
+	// RetryAggressive retries up to 5 times, from 500ms up to 1m apart, temporary failures as well as network
+	// failures such as refused connections, timeouts or connections dropped by the server.
+	RetryAggressive RetryPolicy = Backoff{MaxRetries: 5, BaseDelay: 500 * time.Millisecond, MaxDelay: time.Minute, Retryable: func(err error) bool {
+		return IsTemporary(err) || isNetworkError(err)
+	}}
+)
+
+// WithRetryPolicy configures how Mailer.Send retries messages that could not be sent,
+// every retry connects to the SMTP server again. Use one of the presets (RetryNone, RetryTransientOnly,
+// RetryAggressive), a Backoff or a custom RetryPolicy. Messages the server may have accepted, as the connection
+// failed once they were transferred but before the server replied (see SendStage.MaybeSent), are never retried
+// whatever the policy, so they are not delivered twice.
+func WithRetryPolicy(p RetryPolicy) func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.retryPolicy = p
+	}
+}
+
+// IsTemporary reports whether err is a temporary failure worth retrying later,
+// such as a 4xx reply of the SMTP server (see SMTPError.Temporary) or a sendmail EX_TEMPFAIL exit (see SendmailError.Temporary).
+func IsTemporary(err error) bool {
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) {
+		return temporary.Temporary()
+	}
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr) && smtpcode.Code(protoErr.Code).Temporary()
+}
+
+// maybeSentError wraps the failure of a send whose message was fully transferred without the server reply
+// being received, so the server may have accepted it (see SendStage.MaybeSent).
+type maybeSentError struct {
+	err error
+}
+
+// Error returns the underlying error message.
+func (e *maybeSentError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *maybeSentError) Unwrap() error {
+	return e.err
+}
+
+// maybeSent reports whether err is the failure of a send the server may have accepted, which is never retried.
+func maybeSent(err error) bool {
+	var e *maybeSentError
+	return errors.As(err, &e)
+}
+
+// isNetworkError reports whether err is a failure of the connection to the SMTP server.
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// sleep waits for the delay, or until ctx is done.
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
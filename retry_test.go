@@ -0,0 +1,317 @@
+package gomailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mailerMock "github.com/nawafswe/gomailer/internal/mock"
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff_NextDelay(t *testing.T) {
+	backoff := Backoff{MaxRetries: 4, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	tests := map[string]struct {
+		policy        RetryPolicy
+		retry         int
+		err           error
+		expectedDelay time.Duration
+		expectedRetry bool
+	}{
+		"should wait the base delay before the first retry": {
+			policy: backoff, retry: 1, expectedDelay: time.Second, expectedRetry: true,
+		},
+		"should double the delay for every retry": {
+			policy: backoff, retry: 3, expectedDelay: 4 * time.Second, expectedRetry: true,
+		},
+		"should cap the delay": {
+			policy: backoff, retry: 4, expectedDelay: 5 * time.Second, expectedRetry: true,
+		},
+		"should cap the delay once doubling it overflows": {
+			policy: Backoff{MaxRetries: 100, BaseDelay: time.Second, MaxDelay: time.Hour}, retry: 64, expectedDelay: time.Hour, expectedRetry: true,
+		},
+		"should saturate the delay once doubling it overflows without a cap": {
+			policy: Backoff{MaxRetries: 100, BaseDelay: time.Second}, retry: 64, expectedDelay: math.MaxInt64, expectedRetry: true,
+		},
+		"should keep a zero base delay": {
+			policy: Backoff{MaxRetries: 100}, retry: 64, expectedRetry: true,
+		},
+		"should give up after the maximum retries": {
+			policy: backoff, retry: 5,
+		},
+		"should never retry with RetryNone": {
+			policy: RetryNone, retry: 1, err: &SMTPError{Code: 450},
+		},
+		"should retry temporary failures with RetryTransientOnly": {
+			policy: RetryTransientOnly, retry: 2, err: &SMTPError{Code: 450}, expectedDelay: 2 * time.Second, expectedRetry: true,
+		},
+		"should not retry permanent failures with RetryTransientOnly": {
+			policy: RetryTransientOnly, retry: 1, err: &SMTPError{Code: 550},
+		},
+		"should not retry network failures with RetryTransientOnly": {
+			policy: RetryTransientOnly, retry: 1, err: fmt.Errorf("failed to dial: %w", io.EOF),
+		},
+		"should retry network failures with RetryAggressive": {
+			policy: RetryAggressive, retry: 1, err: fmt.Errorf("failed to dial: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), expectedDelay: 500 * time.Millisecond, expectedRetry: true,
+		},
+		"should not retry permanent failures with RetryAggressive": {
+			policy: RetryAggressive, retry: 1, err: &SMTPError{Code: 550},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			delay, retry := tc.policy.NextDelay(tc.retry, tc.err)
+			assert.Equal(t, tc.expectedDelay, delay)
+			assert.Equal(t, tc.expectedRetry, retry)
+		})
+	}
+}
+
+func TestIsTemporary(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"should report 4xx rejections as temporary":          {err: fmt.Errorf("wrapped: %w", &SMTPError{Code: 451}), expected: true},
+		"should report 5xx rejections as permanent":          {err: &SMTPError{Code: 554}},
+		"should report 4xx replies outside a transaction":    {err: &textproto.Error{Code: 421, Msg: "try later"}, expected: true},
+		"should report sendmail temporary failures":          {err: &SendmailError{ExitCode: sendmailTempFail}, expected: true},
+		"should not report errors without a temporary state": {err: errors.New("dummy error")},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, IsTemporary(tc.err))
+		})
+	}
+}
+
+func TestMailer_SendRetry(t *testing.T) {
+	greylisted := &textproto.Error{Code: 451, Msg: "4.7.1 greylisted"}
+	msg := message.Message{
+		From:       testFromEmail,
+		Recipients: testRecipient,
+		Body:       "dummy body",
+	}
+	t.Run("should retry until the message is sent", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		var retries []int
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone),
+			WithRetryPolicy(RetryPolicyFunc(func(retry int, err error) (time.Duration, bool) {
+				retries = append(retries, retry)
+				return time.Millisecond, IsTemporary(err)
+			})),
+		)
+
+		// expect on mocks
+		gomock.InOrder(
+			smtpMock.EXPECT().Mail(msg.From).Return(greylisted),
+			smtpMock.EXPECT().Mail(msg.From).Return(greylisted),
+			smtpMock.EXPECT().Mail(msg.From).Return(nil),
+		)
+		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
+		smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+		smtpMock.EXPECT().Quit().Return(nil).Times(3)
+		writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			return len(b), nil
+		})
+		writeCloserMock.EXPECT().Close().Return(nil)
+
+		err := mailer.Send(context.Background(), msg)
+		assert.Nil(t, err)
+		assert.Equal(t, []int{1, 2}, retries)
+	})
+	t.Run("should send every attempt with the same Message-ID and count it once against the frequency cap", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone),
+			WithFrequencyCap(FrequencyCap{Max: 1, Window: time.Hour}),
+			WithRetryPolicy(Backoff{MaxRetries: 2, BaseDelay: time.Millisecond, Retryable: IsTemporary}),
+		)
+
+		// expect on mocks
+		var messageIDs []string
+		smtpMock.EXPECT().Mail(msg.From).Return(nil).Times(3)
+		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil).Times(3)
+		smtpMock.EXPECT().Data().Return(writeCloserMock, nil).Times(3)
+		smtpMock.EXPECT().Quit().Return(nil).Times(3)
+		writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			parsed, err := message.Parse(bytes.NewReader(b))
+			assert.Nil(t, err)
+			messageIDs = append(messageIDs, headerValue(parsed, "Message-ID"))
+			return len(b), nil
+		}).Times(3)
+		gomock.InOrder(
+			writeCloserMock.EXPECT().Close().Return(greylisted),
+			writeCloserMock.EXPECT().Close().Return(greylisted),
+			writeCloserMock.EXPECT().Close().Return(nil),
+		)
+
+		result, err := mailer.SendResult(context.Background(), msg)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, result.Attempts)
+		assert.Len(t, messageIDs, 3)
+		assert.Equal(t, []string{result.MessageID, result.MessageID, result.MessageID}, messageIDs)
+	})
+	t.Run("should not retry a message the server may have accepted whatever the policy", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		var retries []int
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone),
+			WithRetryPolicy(RetryPolicyFunc(func(retry int, err error) (time.Duration, bool) {
+				retries = append(retries, retry)
+				return time.Millisecond, true
+			})),
+		)
+
+		// expect on mocks
+		smtpMock.EXPECT().Mail(msg.From).Return(nil)
+		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
+		smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+		smtpMock.EXPECT().Quit().Return(nil)
+		writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			return len(b), nil
+		})
+		// the connection drops after the final dot, before the reply of the server.
+		writeCloserMock.EXPECT().Close().Return(io.EOF)
+
+		result, err := mailer.SendResult(context.Background(), msg)
+		assert.ErrorIs(t, err, io.EOF)
+		assert.Nil(t, result)
+		assert.Empty(t, retries)
+	})
+	t.Run("should retry a message the server rejected after its transfer", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone),
+			WithRetryPolicy(Backoff{MaxRetries: 1, BaseDelay: time.Millisecond, Retryable: IsTemporary}),
+		)
+
+		// expect on mocks
+		smtpMock.EXPECT().Mail(msg.From).Return(nil).Times(2)
+		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil).Times(2)
+		smtpMock.EXPECT().Data().Return(writeCloserMock, nil).Times(2)
+		smtpMock.EXPECT().Quit().Return(nil).Times(2)
+		writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			return len(b), nil
+		}).Times(2)
+		gomock.InOrder(
+			writeCloserMock.EXPECT().Close().Return(greylisted),
+			writeCloserMock.EXPECT().Close().Return(nil),
+		)
+
+		result, err := mailer.SendResult(context.Background(), msg)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, result.Attempts)
+	})
+	t.Run("should return the last error when the policy gives up", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone),
+			WithRetryPolicy(Backoff{MaxRetries: 1, BaseDelay: time.Millisecond, Retryable: IsTemporary}),
+		)
+
+		// expect on mocks
+		smtpMock.EXPECT().Mail(msg.From).Return(greylisted).Times(2)
+		smtpMock.EXPECT().Quit().Return(nil).Times(2)
+
+		err := mailer.Send(context.Background(), msg)
+		var smtpErr *SMTPError
+		assert.ErrorAs(t, err, &smtpErr)
+		assert.Equal(t, 451, smtpErr.Code)
+	})
+	t.Run("should stop retrying when the context is done", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone),
+			WithRetryPolicy(RetryPolicyFunc(func(retry int, err error) (time.Duration, bool) {
+				cancel()
+				return time.Hour, true
+			})),
+		)
+
+		// expect on mocks
+		smtpMock.EXPECT().Mail(msg.From).Return(greylisted)
+		smtpMock.EXPECT().Quit().Return(nil)
+
+		err := mailer.Send(ctx, msg)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.True(t, IsTemporary(err))
+	})
+}
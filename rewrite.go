@@ -0,0 +1,85 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nawafswe/gomailer/message"
+)
+
+// RecipientRewriter rewrites the envelope recipients of the messages sent by Mailer, e.g. to tag them with a subaddress
+// or to map internal aliases to external addresses. Only the addresses given to RCPT TO are rewritten,
+// the To, Cc and Bcc headers recipients see are left untouched.
+type RecipientRewriter interface {
+	// RewriteRecipient returns the address msg is delivered to in place of the recipient, both without display name.
+	RewriteRecipient(ctx context.Context, msg message.Message, recipient string) (string, error)
+}
+
+// RecipientRewriterFunc is an adapter to allow the use of ordinary functions as RecipientRewriter.
+type RecipientRewriterFunc func(ctx context.Context, msg message.Message, recipient string) (string, error)
+
+// RewriteRecipient calls f(ctx, msg, recipient).
+func (f RecipientRewriterFunc) RewriteRecipient(ctx context.Context, msg message.Message, recipient string) (string, error) {
+	return f(ctx, msg, recipient)
+}
+
+// WithRecipientRewriter configures Mailer to rewrite the envelope recipients of the messages sent with r,
+// the rewriters given by repeated calls are applied in order. A message is not sent when a rewriter fails.
+// Rejections of rewritten recipients report the rewritten address (see SMTPError.Recipient).
+func WithRecipientRewriter(r RecipientRewriter) func(*Mailer) {
+	return func(mailer *Mailer) {
+		if r == nil {
+			mailer.invalidOption("recipient rewriter cannot be nil")
+			return
+		}
+		mailer.recipientRewriters = append(mailer.recipientRewriters, r)
+	}
+}
+
+// Subaddress returns a RecipientRewriter tagging the recipients with a subaddress (RFC 5233),
+// e.g. user@example.com becomes user+tag@example.com, so replies and bounces can be traced to a campaign.
+func Subaddress(tag string) RecipientRewriter {
+	return RecipientRewriterFunc(func(_ context.Context, _ message.Message, recipient string) (string, error) {
+		at := strings.LastIndexByte(recipient, '@')
+		if tag == "" || at < 0 {
+			return recipient, nil
+		}
+		return recipient[:at] + "+" + tag + recipient[at:], nil
+	})
+}
+
+// RecipientAliases returns a RecipientRewriter mapping the recipients found in aliases, matched case-insensitively,
+// to the address they alias. Other recipients are left as is.
+func RecipientAliases(aliases map[string]string) RecipientRewriter {
+	lower := make(map[string]string, len(aliases))
+	for alias, addr := range aliases {
+		lower[strings.ToLower(alias)] = addr
+	}
+	return RecipientRewriterFunc(func(_ context.Context, _ message.Message, recipient string) (string, error) {
+		if addr, ok := lower[strings.ToLower(recipient)]; ok {
+			return addr, nil
+		}
+		return recipient, nil
+	})
+}
+
+// rewriteRecipients returns the envelope recipients of msg rewritten by the configured rewriters, see envelopeRecipients.
+func (m *mailSender) rewriteRecipients(ctx context.Context, msg message.Message, recipients []string) ([]string, error) {
+	recipients = envelopeRecipients(msg, recipients)
+	if len(m.mailer.recipientRewriters) == 0 {
+		return recipients, nil
+	}
+	rewritten := make([]string, 0, len(recipients))
+	for _, recipient := range recipients {
+		addr := message.EnvelopeAddress(recipient)
+		for _, r := range m.mailer.recipientRewriters {
+			var err error
+			if addr, err = r.RewriteRecipient(ctx, msg, addr); err != nil {
+				return nil, fmt.Errorf("failed to rewrite recipient %s: %w", recipient, err)
+			}
+		}
+		rewritten = append(rewritten, addr)
+	}
+	return rewritten, nil
+}
@@ -0,0 +1,126 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mailerMock "github.com/nawafswe/gomailer/internal/mock"
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecipientRewriters(t *testing.T) {
+	tests := map[string]struct {
+		rewriter  RecipientRewriter
+		recipient string
+		expected  string
+	}{
+		"should tag the recipient with a subaddress": {
+			rewriter:  Subaddress("campaign42"),
+			recipient: "user@example.com",
+			expected:  "user+campaign42@example.com",
+		},
+		"should keep the recipient without tag": {
+			rewriter:  Subaddress(""),
+			recipient: "user@example.com",
+			expected:  "user@example.com",
+		},
+		"should map an alias case-insensitively": {
+			rewriter:  RecipientAliases(map[string]string{"Support@internal.example.com": "help@example.com"}),
+			recipient: "support@INTERNAL.example.com",
+			expected:  "help@example.com",
+		},
+		"should keep recipients that are not aliases": {
+			rewriter:  RecipientAliases(map[string]string{"support@internal.example.com": "help@example.com"}),
+			recipient: "user@example.com",
+			expected:  "user@example.com",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.rewriter.RewriteRecipient(context.Background(), message.Message{}, tc.recipient)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestMailer_RecipientRewriter(t *testing.T) {
+	t.Run("should rewrite the envelope recipients only, in order", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone),
+			WithRecipientRewriter(RecipientAliases(map[string]string{"alias@internal.example.com": "user@example.com"})),
+			WithRecipientRewriter(Subaddress("campaign42")),
+		)
+		msg := message.Message{
+			From:       testFromEmail,
+			Recipients: []string{"Alias <alias@internal.example.com>", "other@example.com"},
+			Body:       "dummy body",
+		}
+		// expect on mocks
+		smtpMock.EXPECT().Extension(gomock.Any()).Return(false, "").AnyTimes()
+		smtpMock.EXPECT().Mail(msg.From).Return(nil)
+		smtpMock.EXPECT().Rcpt("user+campaign42@example.com").Return(nil)
+		smtpMock.EXPECT().Rcpt("other+campaign42@example.com").Return(nil)
+		smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+		smtpMock.EXPECT().Quit().Return(nil)
+		writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			assert.Contains(t, string(b), "To: \"Alias\" <alias@internal.example.com>, other@example.com\r\n")
+			assert.False(t, strings.Contains(string(b), "campaign42"))
+			return len(b), nil
+		})
+		writeCloserMock.EXPECT().Close().Return(nil)
+
+		err := mailer.Send(context.Background(), msg)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"Alias <alias@internal.example.com>", "other@example.com"}, msg.Recipients)
+	})
+	t.Run("should not send the message when a rewriter fails", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		rewriteErr := errors.New("dummy error")
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone),
+			WithRecipientRewriter(RecipientRewriterFunc(func(ctx context.Context, msg message.Message, recipient string) (string, error) {
+				return "", rewriteErr
+			})),
+		)
+		// expect on mocks
+		smtpMock.EXPECT().Extension(gomock.Any()).Return(false, "").AnyTimes()
+		smtpMock.EXPECT().Quit().Return(nil)
+
+		err := mailer.Send(context.Background(), message.Message{From: testFromEmail, Recipients: testRecipient, Body: "dummy body"})
+		assert.ErrorIs(t, err, rewriteErr)
+		assert.ErrorContains(t, err, "failed to rewrite recipient "+testRecipient[0])
+	})
+	t.Run("should reject a nil rewriter", func(t *testing.T) {
+		_, err := NewMailerE(testHost, testPort, "", "", WithRecipientRewriter(nil))
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+	})
+}
@@ -0,0 +1,77 @@
+package gomailer
+
+import (
+	"context"
+	"time"
+
+	"github.com/nawafswe/gomailer/message"
+)
+
+// SendOptions override settings of the Mailer for the sends of a context, so variations (e.g. a bounce address per
+// tenant or a shorter timeout for interactive mail) do not need a Mailer each. Zero fields keep the Mailer settings.
+type SendOptions struct {
+	// Timeout bounds every attempt of Mailer.Send in place of the timeout given to WithSendTimeout.
+	Timeout time.Duration
+	// EnvelopeFrom is the envelope sender address given to the MAIL command in place of the From address of the
+	// message, e.g. to route bounces to a dedicated mailbox. The From header is left as is, and the EnvelopeFrom of
+	// the message takes precedence.
+	EnvelopeFrom string
+	// RequireTLS refuses the connections opened by Mailer.Send and Mailer.SendBatch that cannot be encrypted, as
+	// WithRequireSTARTTLS does, STARTTLS is negotiated even when the Mailer is configured with EncryptionNone.
+	RequireTLS bool
+	// ResentFrom resends the message on behalf of the mailbox, e.g. a message read with message.Parse forwarded by a
+	// pipeline: Resent-From, Resent-Date and Resent-Message-ID fields (RFC 5322 section 3.6.6) are added above those of
+	// earlier resends, and the original From, Date and Message-ID are kept. It is the envelope sender when no
+	// EnvelopeFrom is set.
+	ResentFrom string
+}
+
+// sendOptionsKey is the context key of the SendOptions.
+type sendOptionsKey struct{}
+
+// WithSendOptions returns a copy of ctx carrying the options, which override the Mailer settings for the messages
+// sent with the returned context by Mailer.Send, Mailer.SendBatch and SendCloser.SendContext.
+func WithSendOptions(ctx context.Context, opts SendOptions) context.Context {
+	return context.WithValue(ctx, sendOptionsKey{}, opts)
+}
+
+// SendOptionsFromContext returns the options carried by ctx, see WithSendOptions.
+func SendOptionsFromContext(ctx context.Context) (SendOptions, bool) {
+	opts, ok := ctx.Value(sendOptionsKey{}).(SendOptions)
+	return opts, ok
+}
+
+// sendTimeoutOf returns the timeout bounding the attempts of Send with ctx, none when zero.
+func (m *Mailer) sendTimeoutOf(ctx context.Context) time.Duration {
+	if m == nil {
+		return 0
+	}
+	if opts, _ := SendOptionsFromContext(ctx); opts.Timeout > 0 {
+		return opts.Timeout
+	}
+	return m.sendTimeout
+}
+
+// tlsPolicyOf returns the encryption mode and whether STARTTLS is required for the connections opened with ctx.
+func (m *Mailer) tlsPolicyOf(ctx context.Context) (encryption Encryption, requireSTARTTLS bool) {
+	if opts, _ := SendOptionsFromContext(ctx); opts.RequireTLS && m.encryption != EncryptionSSLTLS {
+		return EncryptionSTARTTLS, true
+	}
+	return m.encryption, m.requireSTARTTLS
+}
+
+// envelopeFrom returns the envelope sender address of the message sent with ctx: the EnvelopeFrom of the message,
+// the EnvelopeFrom or ResentFrom of the SendOptions, or its From address.
+func envelopeFrom(ctx context.Context, msg message.Message) string {
+	if msg.EnvelopeFrom != "" {
+		return msg.EnvelopeFrom
+	}
+	opts, _ := SendOptionsFromContext(ctx)
+	if opts.EnvelopeFrom != "" {
+		return opts.EnvelopeFrom
+	}
+	if opts.ResentFrom != "" {
+		return opts.ResentFrom
+	}
+	return msg.From
+}
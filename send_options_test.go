@@ -0,0 +1,101 @@
+package gomailer
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMailer_SendOptions(t *testing.T) {
+	var (
+		ext      string
+		commands chan string
+	)
+	// serve serves a scripted SMTP session advertising ext over a new pipe for every following dial, recording the commands.
+	serve := func(e string) chan string {
+		ext, commands = e, make(chan string, 20)
+		return commands
+	}
+	dial := withNetDial(func(network string, host string, t time.Duration) (net.Conn, error) {
+		clientConn, serverConn := net.Pipe()
+		go serveSMTP(serverConn, ext, map[string]string{"MAIL": "250 ok", "RCPT": "250 ok"}, commands)
+		return clientConn, nil
+	})
+	msg := message.Message{From: "Alerts <alerts@example.com>", Recipients: testRecipient, Body: "alert"}
+
+	t.Run("should send with the envelope sender of the context over Send and SendCloser", func(t *testing.T) {
+		ctx := WithSendOptions(context.Background(), SendOptions{EnvelopeFrom: "bounces@example.com"})
+		mailer := NewMailer("localhost", testPort, "", "", dial, WithEncryption(EncryptionNone))
+
+		commands := serve("8BITMIME")
+		assert.Nil(t, mailer.Send(ctx, msg))
+		assert.Contains(t, receive(commands), "MAIL FROM:<bounces@example.com> BODY=8BITMIME")
+
+		commands = serve("8BITMIME")
+		sender, err := mailer.ConnectAndAuthenticate()
+		assert.Nil(t, err)
+		assert.Nil(t, sender.SendContext(ctx, msg))
+		assert.Nil(t, sender.SendContext(context.Background(), msg))
+		assert.Nil(t, sender.Close())
+		got := receive(commands)
+		assert.Contains(t, got, "MAIL FROM:<bounces@example.com> BODY=8BITMIME")
+		assert.Contains(t, got, "MAIL FROM:<alerts@example.com> BODY=8BITMIME")
+	})
+	t.Run("should send with the envelope sender of the message over the one of the context", func(t *testing.T) {
+		ctx := WithSendOptions(context.Background(), SendOptions{EnvelopeFrom: "bounces@example.com"})
+		var encoded []byte
+		mailer := NewMailer("localhost", testPort, "", "", dial, WithEncryption(EncryptionNone),
+			WithHooks(Hooks{BeforeSend: func(ctx context.Context, msg message.Message, e []byte) error {
+				encoded = e
+				return nil
+			}}))
+		verp := msg
+		verp.EnvelopeFrom = "bounces+user=example.com@example.com"
+
+		commands := serve("8BITMIME")
+		assert.Nil(t, mailer.Send(ctx, verp))
+		assert.Contains(t, receive(commands), "MAIL FROM:<bounces+user=example.com@example.com> BODY=8BITMIME")
+		assert.Contains(t, string(encoded), "From: \"Alerts\" <alerts@example.com>\r\n")
+		assert.NotContains(t, string(encoded), "bounces+user")
+	})
+	t.Run("should bound the send with the timeout of the context", func(t *testing.T) {
+		serve("8BITMIME")
+		var deadline time.Time
+		mailer := NewMailer("localhost", testPort, "", "", dial, WithEncryption(EncryptionNone), WithSendTimeout(time.Hour),
+			WithHooks(Hooks{BeforeSend: func(ctx context.Context, msg message.Message, encoded []byte) error {
+				deadline, _ = ctx.Deadline()
+				return nil
+			}}))
+
+		assert.Nil(t, mailer.Send(WithSendOptions(context.Background(), SendOptions{Timeout: time.Minute}), msg))
+		assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 10*time.Second)
+	})
+	t.Run("should refuse a connection that cannot be encrypted when the context requires TLS", func(t *testing.T) {
+		commands := serve("8BITMIME")
+		mailer := NewMailer("localhost", testPort, "", "", dial, WithEncryption(EncryptionNone))
+
+		err := mailer.Send(WithSendOptions(context.Background(), SendOptions{RequireTLS: true}), msg)
+		assert.ErrorIs(t, err, ErrSTARTTLSRequired)
+		assert.NotContains(t, receive(commands), "MAIL FROM:<alerts@example.com> BODY=8BITMIME")
+	})
+	t.Run("should report the options of the context", func(t *testing.T) {
+		_, ok := SendOptionsFromContext(context.Background())
+		assert.False(t, ok)
+		opts, ok := SendOptionsFromContext(WithSendOptions(context.Background(), SendOptions{EnvelopeFrom: "bounces@example.com"}))
+		assert.True(t, ok)
+		assert.Equal(t, SendOptions{EnvelopeFrom: "bounces@example.com"}, opts)
+	})
+}
+
+// receive returns the values received from c until it is closed.
+func receive[T any](c <-chan T) []T {
+	var values []T
+	for v := range c {
+		values = append(values, v)
+	}
+	return values
+}
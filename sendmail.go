@@ -0,0 +1,116 @@
+package gomailer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"os/exec"
+	"strings"
+
+	"github.com/nawafswe/gomailer/message"
+)
+
+const (
+	// defaultSendmailPath is where sendmail compatible binaries are usually installed.
+	defaultSendmailPath = "/usr/sbin/sendmail"
+	// sendmailTempFail is the EX_TEMPFAIL exit code from sysexits.h, used by MTAs for temporary failures.
+	sendmailTempFail = 75
+)
+
+// SendmailOptions to configure SendmailTransport.
+type SendmailOptions func(*SendmailTransport)
+
+// WithSendmailPath configures SendmailTransport with the path of the sendmail binary.
+func WithSendmailPath(path string) func(*SendmailTransport) {
+	return func(transport *SendmailTransport) {
+		if path != "" {
+			transport.path = path
+		}
+	}
+}
+
+// WithSendmailArgs configures SendmailTransport with extra arguments passed to the sendmail binary.
+func WithSendmailArgs(args ...string) func(*SendmailTransport) {
+	return func(transport *SendmailTransport) {
+		transport.args = append(transport.args, args...)
+	}
+}
+
+// SendmailTransport is a Transport piping the encoded message to a local sendmail compatible binary (`sendmail -t`),
+// for environments without network SMTP access. Recipients are read by sendmail from the message headers.
+type SendmailTransport struct {
+	// path of the sendmail binary.
+	path string
+	// args are extra arguments passed to the sendmail binary.
+	args []string
+}
+
+// SendmailTransport must implement Transport.
+var _ Transport = (*SendmailTransport)(nil)
+
+// NewSendmailTransport creates a new Transport delivering messages through the local sendmail binary.
+func NewSendmailTransport(opts ...SendmailOptions) *SendmailTransport {
+	transport := &SendmailTransport{path: defaultSendmailPath}
+	for _, opt := range opts {
+		opt(transport)
+	}
+	return transport
+}
+
+// SendmailError is returned when the sendmail binary exits with a non-zero exit code.
+type SendmailError struct {
+	// ExitCode of the sendmail binary, usually one of the sysexits.h codes.
+	ExitCode int
+	// Stderr is what the sendmail binary reported on its standard error.
+	Stderr string
+
+	err error
+}
+
+// Error returns the exit code along with what sendmail reported.
+func (e *SendmailError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("sendmail exited with code %d", e.ExitCode)
+	}
+	return fmt.Sprintf("sendmail exited with code %d: %s", e.ExitCode, e.Stderr)
+}
+
+// Unwrap returns the underlying error.
+func (e *SendmailError) Unwrap() error {
+	return e.err
+}
+
+// Temporary reports whether sendmail reported a temporary failure (EX_TEMPFAIL), so the message may be retried later.
+func (e *SendmailError) Temporary() bool {
+	return e.ExitCode == sendmailTempFail
+}
+
+// Send encodes the message and pipes it to `sendmail -t -i -f <from>`, where from is the envelope sender: the
+// EnvelopeFrom of the message, the one of the SendOptions of ctx or the From address.
+// The sendmail process is killed when ctx is done.
+func (s *SendmailTransport) Send(ctx context.Context, msg message.Message) error {
+	encodedMsg, err := msg.Encode()
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	args := []string{"-t", "-i"}
+	if from, err := mail.ParseAddress(envelopeFrom(ctx, msg)); err == nil {
+		args = append(args, "-f", from.Address)
+	}
+	args = append(args, s.args...)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.path, args...)
+	cmd.Stdin = bytes.NewReader(encodedMsg)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && ctx.Err() == nil {
+			return &SendmailError{ExitCode: exitErr.ExitCode(), Stderr: strings.TrimSpace(stderr.String()), err: err}
+		}
+		return fmt.Errorf("failed to run sendmail: %w", err)
+	}
+	return nil
+}
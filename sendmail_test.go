@@ -0,0 +1,113 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSendmail writes a script recording its arguments and standard input, then exiting with the given code.
+func fakeSendmail(t *testing.T, exitCode string) (path, argsFile, stdinFile string) {
+	t.Helper()
+	dir := t.TempDir()
+	path = filepath.Join(dir, "sendmail")
+	argsFile = filepath.Join(dir, "args")
+	stdinFile = filepath.Join(dir, "stdin")
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\ncat > " + stdinFile + "\necho 'sendmail: failure' >&2\nexit " + exitCode + "\n"
+	if err := os.WriteFile(path, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	return path, argsFile, stdinFile
+}
+
+func TestSendmailTransport_Send(t *testing.T) {
+	msg := message.Message{
+		From:       testFromEmail,
+		Recipients: testRecipient,
+		Body:       "dummy body",
+	}
+	t.Run("should pipe the encoded message to sendmail", func(t *testing.T) {
+		t.Parallel()
+		path, argsFile, stdinFile := fakeSendmail(t, "0")
+		transport := NewSendmailTransport(WithSendmailPath(path), WithSendmailArgs("-oi"))
+
+		err := transport.Send(context.Background(), msg)
+		assert.Nil(t, err)
+
+		args, _ := os.ReadFile(argsFile)
+		assert.Equal(t, "-t -i -f "+testFromEmail+" -oi\n", string(args))
+		stdin, _ := os.ReadFile(stdinFile)
+		encoded, _ := msg.Encode()
+		assert.Equal(t, encoded, stdin)
+	})
+	t.Run("should pass the envelope sender to sendmail", func(t *testing.T) {
+		t.Parallel()
+		tests := map[string]struct {
+			envelopeFrom string
+			ctx          context.Context
+			expectedArgs string
+		}{
+			"should pass the envelope sender of the message": {
+				envelopeFrom: "Bounces <bounces@smtp.com>",
+				ctx:          WithSendOptions(context.Background(), SendOptions{EnvelopeFrom: "tenant@smtp.com"}),
+				expectedArgs: "-t -i -f bounces@smtp.com\n",
+			},
+			"should pass the envelope sender of the send options": {
+				ctx:          WithSendOptions(context.Background(), SendOptions{EnvelopeFrom: "tenant@smtp.com"}),
+				expectedArgs: "-t -i -f tenant@smtp.com\n",
+			},
+		}
+		for name, tc := range tests {
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				path, argsFile, stdinFile := fakeSendmail(t, "0")
+				transport := NewSendmailTransport(WithSendmailPath(path))
+				msg := msg
+				msg.EnvelopeFrom = tc.envelopeFrom
+
+				err := transport.Send(tc.ctx, msg)
+				assert.Nil(t, err)
+
+				args, _ := os.ReadFile(argsFile)
+				assert.Equal(t, tc.expectedArgs, string(args))
+				stdin, _ := os.ReadFile(stdinFile)
+				assert.NotContains(t, string(stdin), "bounces@smtp.com")
+			})
+		}
+	})
+	t.Run("should return structured error when sendmail exits with non-zero code", func(t *testing.T) {
+		t.Parallel()
+		path, _, _ := fakeSendmail(t, "75")
+		transport := NewSendmailTransport(WithSendmailPath(path))
+
+		err := transport.Send(context.Background(), msg)
+
+		var sendmailErr *SendmailError
+		assert.True(t, errors.As(err, &sendmailErr))
+		assert.Equal(t, 75, sendmailErr.ExitCode)
+		assert.Equal(t, "sendmail: failure", sendmailErr.Stderr)
+		assert.True(t, sendmailErr.Temporary())
+		assert.Equal(t, "sendmail exited with code 75: sendmail: failure", err.Error())
+	})
+	t.Run("should fail when sendmail binary does not exist", func(t *testing.T) {
+		t.Parallel()
+		transport := NewSendmailTransport(WithSendmailPath(filepath.Join(t.TempDir(), "missing")))
+
+		err := transport.Send(context.Background(), msg)
+		assert.NotNil(t, err)
+		var sendmailErr *SendmailError
+		assert.False(t, errors.As(err, &sendmailErr))
+	})
+	t.Run("should fail without running sendmail when message is invalid", func(t *testing.T) {
+		t.Parallel()
+		transport := NewSendmailTransport(WithSendmailPath(filepath.Join(t.TempDir(), "missing")))
+
+		err := transport.Send(context.Background(), message.Message{})
+		assert.Equal(t, "failed to send message: failed to encode message: from address cannot be empty", err.Error())
+	})
+}
@@ -0,0 +1,46 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nawafswe/gomailer/message"
+)
+
+// ErrSentFolderAppend is reported to the OnError hooks when a sent message could not be appended to the sent folder.
+// The message itself was accepted by the SMTP server, so Send does not fail.
+var ErrSentFolderAppend = errors.New("failed to append message to sent folder")
+
+// IMAPAppender appends messages to an IMAP mailbox, it is implemented by wrapping the IMAP client of choice.
+type IMAPAppender interface {
+	// Append appends the message to the mailbox with the given flags and internal date, like the IMAP APPEND command.
+	Append(ctx context.Context, mailbox string, flags []string, date time.Time, msg []byte) error
+}
+
+// WithSentFolder configures Mailer to append every successfully sent message to the given IMAP mailbox (e.g. "Sent"),
+// marked as seen, so mail sent by services shows up in the shared mailbox.
+func WithSentFolder(client IMAPAppender, mailbox string) func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.sentFolder = &sentFolder{client: client, mailbox: mailbox}
+	}
+}
+
+// sentFolder is the IMAP mailbox sent messages are appended to.
+type sentFolder struct {
+	client  IMAPAppender
+	mailbox string
+}
+
+// appendSent appends the encoded message to the sent folder, if one is configured.
+// Failures are reported to the OnError hooks wrapping ErrSentFolderAppend.
+func (m *Mailer) appendSent(ctx context.Context, msg message.Message, encoded []byte) {
+	if m.sentFolder == nil {
+		return
+	}
+	if err := m.sentFolder.client.Append(ctx, m.sentFolder.mailbox, []string{`\Seen`}, m.now(), encoded); err != nil {
+		m.metrics.incSentFolderFailures(ctx, m.Host)
+		m.hooks.onError(ctx, msg, fmt.Errorf("%w %s: %w", ErrSentFolderAppend, m.sentFolder.mailbox, err))
+	}
+}
@@ -0,0 +1,95 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mailerMock "github.com/nawafswe/gomailer/internal/mock"
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeIMAPAppender records the appended messages.
+type fakeIMAPAppender struct {
+	mailbox string
+	flags   []string
+	date    time.Time
+	msg     []byte
+	err     error
+}
+
+// Append implements IMAPAppender.
+func (f *fakeIMAPAppender) Append(ctx context.Context, mailbox string, flags []string, date time.Time, msg []byte) error {
+	f.mailbox, f.flags, f.date, f.msg = mailbox, flags, date, msg
+	return f.err
+}
+
+func TestMailer_SentFolder(t *testing.T) {
+	dummyErr := fmt.Errorf("dummy error")
+	now := time.Date(2024, time.March, 5, 10, 30, 0, 0, time.UTC)
+	tests := map[string]struct {
+		appendErr   error
+		expectedErr error
+	}{
+		"should append the sent message to the sent folder": {},
+		"should report append failures to OnError without failing the send": {
+			appendErr:   dummyErr,
+			expectedErr: fmt.Errorf("%w %s: %w", ErrSentFolderAppend, "Sent", dummyErr),
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			clock := func() time.Time { return now }
+			ctrl := gomock.NewController(t)
+			// prepare mocks
+			smtpMock := mailerMock.NewMockClient(ctrl)
+			netConnMock := mailerMock.NewMockconn(ctrl)
+			writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+			// stub functions
+			newClient := func(conn net.Conn, host string) (Client, error) {
+				return smtpMock, nil
+			}
+			netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+				return netConnMock, nil
+			}
+
+			appender := &fakeIMAPAppender{err: tc.appendErr}
+			var hookErr error
+			var sent []byte
+			mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), withClock(clock), WithEncryption(EncryptionNone), WithSentFolder(appender, "Sent"),
+				WithHooks(Hooks{
+					OnError: func(ctx context.Context, msg message.Message, err error) {
+						hookErr = err
+					},
+				}),
+			)
+			msg := message.Message{
+				From:       testFromEmail,
+				Recipients: testRecipient,
+				Body:       "dummy body",
+			}
+			// expect on mocks
+			smtpMock.EXPECT().Mail(msg.From).Return(nil)
+			smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
+			smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+			smtpMock.EXPECT().Quit().Return(nil)
+			writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+				sent = b
+				return len(b), nil
+			})
+			writeCloserMock.EXPECT().Close().Return(nil)
+
+			err := mailer.Send(context.Background(), msg)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedErr, hookErr)
+			assert.Equal(t, "Sent", appender.mailbox)
+			assert.Equal(t, []string{`\Seen`}, appender.flags)
+			assert.Equal(t, now, appender.date)
+			assert.Equal(t, sent, appender.msg)
+		})
+	}
+}
@@ -0,0 +1,509 @@
+package gomailer
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// pipeliningClient is implemented by smtp clients able to pipeline the MAIL and RCPT commands (see protocolClient.MailRcpt).
+type pipeliningClient interface {
+	MailRcpt(from string, mailParams []string, to []string, rcptParams []string) (mailErr error, rcptErrs []error)
+}
+
+// deadlineClient is implemented by smtp clients able to bound their I/O with timeouts (see protocolClient.setTimeouts).
+type deadlineClient interface {
+	setTimeouts(command, data time.Duration, deadline time.Time)
+	setClock(now func() time.Time)
+}
+
+// dataReplyClient is implemented by smtp clients keeping the reply of the server to the last message (see protocolClient.dataReply).
+type dataReplyClient interface {
+	dataReply() string
+}
+
+// protocolClient is the SMTP client (RFC 5321) used by Mailer, implementing Client on top of textproto.
+// It replaces smtp.Client, which is frozen, so the protocol layer can support extensions such as DSN parameters,
+// command pipelining and chunking. Authentication mechanisms are still given as smtp.Auth.
+type protocolClient struct {
+	// text is the textproto connection to the server.
+	text *textproto.Conn
+	// conn is the underlying connection, replaced by a *tls.Conn after STARTTLS.
+	conn net.Conn
+	// tls reports whether the connection is secured with TLS.
+	tls bool
+	// serverName is the host name of the server, given to the authentication mechanisms.
+	serverName string
+	// ext maps the extensions advertised by the server to their parameters, nil when the server only supports HELO.
+	ext map[string]string
+	// auth holds the advertised authentication mechanisms.
+	auth []string
+	// localName is the name sent with EHLO and HELO.
+	localName string
+	// didHello reports whether the server was greeted with EHLO or HELO.
+	didHello bool
+	// helloError is the error of greeting the server.
+	helloError error
+	// commandTimeout bounds every command and its reply, no timeout applies when zero.
+	commandTimeout time.Duration
+	// dataTimeout bounds the transfer of the message and the reply to it, no timeout applies when zero.
+	dataTimeout time.Duration
+	// deadline bounds the whole session, no deadline applies when zero.
+	deadline time.Time
+	// now returns the current time the timeouts and the logged durations are measured with.
+	now func() time.Time
+	// logger the protocol exchange is logged to at the debug level, nothing is logged when nil.
+	logger *slog.Logger
+	// authenticating indicates whether an authentication exchange is in progress, so the logged lines are redacted.
+	authenticating bool
+	// lastDataReply is the reply of the server accepting the last message, e.g. "2.0.0 Ok: queued as 4F2A1".
+	lastDataReply string
+}
+
+// newProtocolClient returns a protocolClient using conn, after reading the server greeting.
+// host is the server name used for authentication.
+func newProtocolClient(conn net.Conn, host string) (*protocolClient, error) {
+	text := textproto.NewConn(conn)
+	if _, _, err := text.ReadResponse(220); err != nil {
+		_ = text.Close()
+		return nil, err
+	}
+	c := &protocolClient{text: text, conn: conn, serverName: host, localName: "localhost", now: time.Now}
+	_, c.tls = conn.(*tls.Conn)
+	return c, nil
+}
+
+// tlsState returns the state of the TLS connection, false when the connection is not secured with TLS.
+func (c *protocolClient) tlsState() (tls.ConnectionState, bool) {
+	tc, ok := c.conn.(*tls.Conn)
+	if !ok {
+		return tls.ConnectionState{}, false
+	}
+	return tc.ConnectionState(), true
+}
+
+// setTimeouts configures the timeouts applied to the commands and the message transfer, and the deadline of the session.
+func (c *protocolClient) setTimeouts(command, data time.Duration, deadline time.Time) {
+	c.commandTimeout, c.dataTimeout, c.deadline = command, data, deadline
+}
+
+// setClock configures the clock the timeouts and the logged durations are measured with.
+func (c *protocolClient) setClock(now func() time.Time) {
+	c.now = now
+}
+
+// setLogger configures the logger the protocol exchange is logged to.
+func (c *protocolClient) setLogger(logger *slog.Logger) {
+	c.logger = logger
+}
+
+// trace logs the command line sent at start and the reply of the server at the debug level.
+func (c *protocolClient) trace(line string, start time.Time, code int, msg string, err error) {
+	if c.logger == nil || !c.logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	if c.authenticating {
+		line = redactAuth(line)
+		if code == 334 {
+			// challenges may carry data of the exchange too.
+			msg = "[redacted]"
+		}
+	}
+	attrs := []any{slog.String("command", line), slog.Int("code", code), slog.String("reply", msg), slog.Duration("duration", c.now().Sub(start))}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	c.logger.Debug("smtp command", attrs...)
+}
+
+// extendDeadline sets the deadline of the connection to timeout from now, capped by the deadline of the session.
+func (c *protocolClient) extendDeadline(timeout time.Duration) error {
+	var d time.Time
+	if timeout > 0 {
+		d = c.now().Add(timeout)
+	}
+	if !c.deadline.IsZero() && (d.IsZero() || c.deadline.Before(d)) {
+		d = c.deadline
+	}
+	if d.IsZero() && c.commandTimeout == 0 && c.dataTimeout == 0 {
+		// no timeout was ever configured, leave the connection as is.
+		return nil
+	}
+	return c.conn.SetDeadline(d)
+}
+
+// Hello greets the server with EHLO, falling back to HELO, using localName.
+// It must be called before any other command, which otherwise greet the server as "localhost".
+func (c *protocolClient) Hello(localName string) error {
+	if err := validateLine(localName); err != nil {
+		return err
+	}
+	if c.didHello {
+		return errors.New("smtp: Hello called after other methods")
+	}
+	c.localName = localName
+	return c.hello()
+}
+
+// hello greets the server if not done yet.
+func (c *protocolClient) hello() error {
+	if !c.didHello {
+		c.didHello = true
+		if err := c.ehlo(); err != nil {
+			c.helloError = c.helo()
+		}
+	}
+	return c.helloError
+}
+
+// ehlo sends EHLO and records the advertised extensions.
+func (c *protocolClient) ehlo() error {
+	_, msg, err := c.cmd(250, "EHLO "+c.localName)
+	if err != nil {
+		return err
+	}
+	ext := make(map[string]string)
+	// the first line of the reply is the greeting, each following line advertises an extension.
+	if lines := strings.Split(msg, "\n"); len(lines) > 1 {
+		for _, line := range lines[1:] {
+			k, v, _ := strings.Cut(line, " ")
+			ext[strings.ToUpper(k)] = v
+		}
+	}
+	if mechs, ok := ext["AUTH"]; ok {
+		c.auth = strings.Split(mechs, " ")
+	}
+	c.ext = ext
+	return nil
+}
+
+// helo sends HELO to servers not supporting EHLO, which advertise no extensions.
+func (c *protocolClient) helo() error {
+	c.ext = nil
+	_, _, err := c.cmd(250, "HELO "+c.localName)
+	return err
+}
+
+// Extension reports whether the server advertises ext, along with its parameters.
+func (c *protocolClient) Extension(ext string) (bool, string) {
+	if err := c.hello(); err != nil {
+		return false, ""
+	}
+	param, ok := c.ext[strings.ToUpper(ext)]
+	return ok, param
+}
+
+// StartTLS upgrades the connection with STARTTLS using config, then greets the server again
+// as the extensions advertised over TLS may differ.
+func (c *protocolClient) StartTLS(config *tls.Config) error {
+	if err := c.hello(); err != nil {
+		return err
+	}
+	if _, _, err := c.cmd(220, "STARTTLS"); err != nil {
+		return err
+	}
+	c.conn = tls.Client(c.conn, config)
+	c.text = textproto.NewConn(c.conn)
+	c.tls = true
+	return c.ehlo()
+}
+
+// Auth authenticates with the given mechanism, the connection is closed when authentication fails.
+func (c *protocolClient) Auth(a smtp.Auth) error {
+	if err := c.hello(); err != nil {
+		return err
+	}
+	c.authenticating = true
+	defer func() { c.authenticating = false }()
+	encoding := base64.StdEncoding
+	mech, resp, err := a.Start(&smtp.ServerInfo{Name: c.serverName, TLS: c.tls, Auth: c.auth})
+	if err != nil {
+		_ = c.Quit()
+		return err
+	}
+	code, msg64, err := c.cmd(0, strings.TrimSpace("AUTH "+mech+" "+encoding.EncodeToString(resp)))
+	for err == nil {
+		var msg []byte
+		switch code {
+		case 334:
+			msg, err = encoding.DecodeString(msg64)
+		case 235:
+			// some servers send a final message along with the success reply.
+			msg = []byte(msg64)
+		default:
+			err = &textproto.Error{Code: code, Msg: msg64}
+		}
+		if err == nil {
+			resp, err = a.Next(msg, code == 334)
+		}
+		if err != nil {
+			// abort the exchange.
+			_, _, _ = c.cmd(501, "*")
+			_ = c.Quit()
+			break
+		}
+		if resp == nil {
+			break
+		}
+		code, msg64, err = c.cmd(0, encoding.EncodeToString(resp))
+	}
+	return err
+}
+
+// Mail issues a MAIL command for the from address with the given parameters.
+// BODY=8BITMIME is added when the server advertises it.
+func (c *protocolClient) Mail(from string, params ...string) error {
+	if err := c.hello(); err != nil {
+		return err
+	}
+	_, _, err := c.cmd(250, c.mailLine(from, params))
+	return err
+}
+
+// mailLine returns the MAIL command line for the from address with the given parameters.
+func (c *protocolClient) mailLine(from string, params []string) string {
+	line := "MAIL FROM:<" + from + ">"
+	if _, ok := c.ext["8BITMIME"]; ok {
+		line += " BODY=8BITMIME"
+	}
+	if len(params) > 0 {
+		line += " " + strings.Join(params, " ")
+	}
+	return line
+}
+
+// Rcpt issues a RCPT command for the to address with the given parameters.
+func (c *protocolClient) Rcpt(to string, params ...string) error {
+	// 25 accepts both 250 and 251 (user not local, will forward).
+	_, _, err := c.cmd(25, rcptLine(to, params))
+	return err
+}
+
+// rcptLine returns the RCPT command line for the to address with the given parameters.
+func rcptLine(to string, params []string) string {
+	line := "RCPT TO:<" + to + ">"
+	if len(params) > 0 {
+		line += " " + strings.Join(params, " ")
+	}
+	return line
+}
+
+// MailRcpt pipelines (RFC 2920) the MAIL command for the from address and the RCPT commands for the to addresses,
+// writing them at once and then reading their replies in order. rcptErrs holds the RCPT reply error of each to address.
+// The caller must check the server advertises PIPELINING.
+func (c *protocolClient) MailRcpt(from string, mailParams []string, to []string, rcptParams []string) (mailErr error, rcptErrs []error) {
+	if err := c.hello(); err != nil {
+		return err, nil
+	}
+	lines := make([]string, 0, len(to)+1)
+	lines = append(lines, c.mailLine(from, mailParams))
+	for _, t := range to {
+		lines = append(lines, rcptLine(t, rcptParams))
+	}
+	for _, line := range lines {
+		if err := validateLine(line); err != nil {
+			return err, nil
+		}
+	}
+	if err := c.extendDeadline(c.commandTimeout); err != nil {
+		return err, nil
+	}
+	for _, line := range lines {
+		if _, err := c.text.W.WriteString(line + "\r\n"); err != nil {
+			return err, nil
+		}
+	}
+	if err := c.text.W.Flush(); err != nil {
+		return err, nil
+	}
+	// every reply must be read to keep the connection in sync, even when MAIL is rejected.
+	start := c.now()
+	code, msg, mailErr := c.text.ReadResponse(250)
+	c.trace(lines[0], start, code, msg, mailErr)
+	rcptErrs = make([]error, len(to))
+	for i := range to {
+		code, msg, rcptErrs[i] = c.text.ReadResponse(25)
+		c.trace(lines[i+1], start, code, msg, rcptErrs[i])
+	}
+	return mailErr, rcptErrs
+}
+
+// Data returns a writer for the message. When the server advertises CHUNKING (RFC 3030), the message is sent
+// as is in BDAT chunks, otherwise a DATA command is issued and the message is dot-stuffed.
+// Closing the writer ends the message and returns the reply of the server accepting or rejecting it.
+func (c *protocolClient) Data() (io.WriteCloser, error) {
+	if _, ok := c.ext["CHUNKING"]; ok {
+		if err := c.extendDeadline(c.dataTimeout); err != nil {
+			return nil, err
+		}
+		return &bdatWriter{c: c}, nil
+	}
+	if _, _, err := c.cmd(354, "DATA"); err != nil {
+		return nil, err
+	}
+	if err := c.extendDeadline(c.dataTimeout); err != nil {
+		return nil, err
+	}
+	return &dataCloser{c: c, WriteCloser: c.text.DotWriter()}, nil
+}
+
+// dataCloser reads the reply to the message when closed.
+type dataCloser struct {
+	c *protocolClient
+	io.WriteCloser
+}
+
+// Close ends the message and reads the reply of the server.
+func (d *dataCloser) Close() error {
+	start := d.c.now()
+	if err := d.WriteCloser.Close(); err != nil {
+		return err
+	}
+	code, msg, err := d.c.text.ReadResponse(250)
+	d.c.trace(".", start, code, msg, err)
+	if err == nil {
+		d.c.lastDataReply = msg
+	}
+	return err
+}
+
+// dataReply returns the reply of the server accepting the last message.
+func (c *protocolClient) dataReply() string {
+	return c.lastDataReply
+}
+
+// bdatChunkSize is the size of the BDAT chunks, the last chunk may be smaller.
+const bdatChunkSize = 64 * 1024
+
+// bdatWriter sends the message in BDAT chunks of bdatChunkSize bytes, the last chunk is sent when closed.
+type bdatWriter struct {
+	c   *protocolClient
+	buf []byte
+	// err is the first chunk rejection, the server discards the message after it.
+	err error
+}
+
+// Write buffers p, sending every full chunk.
+func (w *bdatWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.buf = append(w.buf, p...)
+	for len(w.buf) >= bdatChunkSize {
+		if w.err = w.chunk(w.buf[:bdatChunkSize], false); w.err != nil {
+			return 0, w.err
+		}
+		w.buf = w.buf[bdatChunkSize:]
+	}
+	return len(p), nil
+}
+
+// Close sends the buffered bytes as the last chunk and returns the reply of the server to the message.
+func (w *bdatWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.chunk(w.buf, true)
+	w.buf = nil
+	return w.err
+}
+
+// chunk sends a BDAT command followed by data and reads its reply.
+func (w *bdatWriter) chunk(data []byte, last bool) error {
+	start := w.c.now()
+	text := w.c.text
+	id := text.Next()
+	text.StartRequest(id)
+	_, err := fmt.Fprintf(text.W, "BDAT %d", len(data))
+	if err == nil && last {
+		_, err = text.W.WriteString(" LAST")
+	}
+	if err == nil {
+		_, err = text.W.WriteString("\r\n")
+	}
+	if err == nil {
+		_, err = text.W.Write(data)
+	}
+	if err == nil {
+		err = text.W.Flush()
+	}
+	text.EndRequest(id)
+	if err != nil {
+		return err
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+	code, msg, err := text.ReadResponse(250)
+	line := fmt.Sprintf("BDAT %d", len(data))
+	if last {
+		line += " LAST"
+	}
+	w.c.trace(line, start, code, msg, err)
+	if err == nil && last {
+		w.c.lastDataReply = msg
+	}
+	return err
+}
+
+// Reset issues a RSET command, aborting the current mail transaction.
+func (c *protocolClient) Reset() error {
+	if err := c.hello(); err != nil {
+		return err
+	}
+	_, _, err := c.cmd(250, "RSET")
+	return err
+}
+
+// Quit issues a QUIT command and closes the connection.
+func (c *protocolClient) Quit() error {
+	if err := c.hello(); err != nil {
+		return err
+	}
+	if _, _, err := c.cmd(221, "QUIT"); err != nil {
+		return err
+	}
+	return c.text.Close()
+}
+
+// Close closes the connection without issuing QUIT.
+func (c *protocolClient) Close() error {
+	return c.text.Close()
+}
+
+// cmd sends the command line and reads the response, which must start with expectCode (0 accepts any code).
+func (c *protocolClient) cmd(expectCode int, line string) (int, string, error) {
+	if err := validateLine(line); err != nil {
+		return 0, "", err
+	}
+	if err := c.extendDeadline(c.commandTimeout); err != nil {
+		return 0, "", err
+	}
+	start := c.now()
+	id, err := c.text.Cmd("%s", line)
+	if err != nil {
+		c.trace(line, start, 0, "", err)
+		return 0, "", err
+	}
+	c.text.StartResponse(id)
+	defer c.text.EndResponse(id)
+	code, msg, err := c.text.ReadResponse(expectCode)
+	c.trace(line, start, code, msg, err)
+	return code, msg, err
+}
+
+// validateLine checks that line does not contain CR or LF, which would allow injecting commands.
+func validateLine(line string) error {
+	if strings.ContainsAny(line, "\r\n") {
+		return errors.New("smtp: A line must not contain CR or LF")
+	}
+	return nil
+}
@@ -0,0 +1,393 @@
+package gomailer
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveSMTP replies to the commands read from conn with the scripted replies and records the commands.
+// A server without extensions (ext is empty) rejects EHLO and only supports HELO. BDAT chunks are read and replied with replies["BDAT"],
+// messages ended with "." with replies["."] or "250 queued".
+func serveSMTP(conn net.Conn, ext string, replies map[string]string, commands chan<- string) {
+	defer close(commands)
+	tc := textproto.NewConn(conn)
+	_ = tc.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tc.ReadLine()
+		if err != nil {
+			return
+		}
+		commands <- line
+		verb, _, _ := strings.Cut(line, " ")
+		switch verb {
+		case "EHLO":
+			if ext == "" {
+				// HELO-only server.
+				_ = tc.PrintfLine("502 command not implemented")
+				continue
+			}
+			_ = tc.PrintfLine("250-localhost")
+			_ = tc.PrintfLine("250 %s", ext)
+		case "HELO":
+			_ = tc.PrintfLine("250 localhost")
+		case "DATA":
+			_ = tc.PrintfLine("354 go ahead")
+			if _, err := tc.ReadDotBytes(); err != nil {
+				return
+			}
+			_ = tc.PrintfLine("%s", cmp.Or(replies["."], "250 queued"))
+		case "BDAT":
+			var size int
+			if _, err := fmt.Sscanf(line, "BDAT %d", &size); err != nil {
+				return
+			}
+			if _, err := io.ReadFull(tc.R, make([]byte, size)); err != nil {
+				return
+			}
+			_ = tc.PrintfLine("%s", replies[verb])
+		case "QUIT":
+			_ = tc.PrintfLine("221 bye")
+			return
+		case "*":
+			_ = tc.PrintfLine("501 5.7.0 authentication aborted")
+		default:
+			_ = tc.PrintfLine("%s", replies[verb])
+		}
+	}
+}
+
+func TestProtocolClient_MailRcpt(t *testing.T) {
+	tests := map[string]struct {
+		ext              string
+		replies          map[string]string
+		mailParams       []string
+		rcptParams       []string
+		expectedCommands []string
+		expectedMailErr  error
+		expectedRcptErr  error
+	}{
+		"should send commands without parameters": {
+			ext:              "8BITMIME",
+			replies:          map[string]string{"MAIL": "250 ok", "RCPT": "250 ok"},
+			expectedCommands: []string{"EHLO localhost", "MAIL FROM:<from@example.com> BODY=8BITMIME", "RCPT TO:<to@example.com>", "QUIT"},
+		},
+		"should append parameters to MAIL and RCPT": {
+			ext:              "DSN",
+			replies:          map[string]string{"MAIL": "250 ok", "RCPT": "251 will forward"},
+			mailParams:       []string{"RET=HDRS", "ENVID=id"},
+			rcptParams:       []string{"NOTIFY=FAILURE,DELAY"},
+			expectedCommands: []string{"EHLO localhost", "MAIL FROM:<from@example.com> RET=HDRS ENVID=id", "RCPT TO:<to@example.com> NOTIFY=FAILURE,DELAY", "QUIT"},
+		},
+		"should not add SMTPUTF8 of its own when the server advertises it": {
+			ext:              "SMTPUTF8",
+			replies:          map[string]string{"MAIL": "250 ok", "RCPT": "250 ok"},
+			mailParams:       []string{"RET=FULL"},
+			expectedCommands: []string{"EHLO localhost", "MAIL FROM:<from@example.com> RET=FULL", "RCPT TO:<to@example.com>", "QUIT"},
+		},
+		"should report rejections of commands with parameters": {
+			ext:              "DSN",
+			replies:          map[string]string{"MAIL": "555 5.5.4 unsupported parameter", "RCPT": "550 5.1.1 unknown"},
+			mailParams:       []string{"RET=FULL"},
+			rcptParams:       []string{"NOTIFY=NEVER"},
+			expectedCommands: []string{"EHLO localhost", "MAIL FROM:<from@example.com> RET=FULL", "RCPT TO:<to@example.com> NOTIFY=NEVER", "QUIT"},
+			expectedMailErr:  &textproto.Error{Code: 555, Msg: "5.5.4 unsupported parameter"},
+			expectedRcptErr:  &textproto.Error{Code: 550, Msg: "5.1.1 unknown"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			clientConn, serverConn := net.Pipe()
+			commands := make(chan string, 10)
+			go serveSMTP(serverConn, tc.ext, tc.replies, commands)
+
+			client, err := newProtocolClient(clientConn, "localhost")
+			assert.Nil(t, err)
+
+			assert.Equal(t, tc.expectedMailErr, client.Mail("from@example.com", tc.mailParams...))
+			assert.Equal(t, tc.expectedRcptErr, client.Rcpt("to@example.com", tc.rcptParams...))
+			assert.Nil(t, client.Quit())
+
+			var got []string
+			for cmd := range commands {
+				got = append(got, cmd)
+			}
+			assert.Equal(t, tc.expectedCommands, got)
+		})
+	}
+
+	t.Run("should refuse parameters containing line breaks", func(t *testing.T) {
+		t.Parallel()
+		clientConn, serverConn := net.Pipe()
+		commands := make(chan string, 10)
+		go serveSMTP(serverConn, "DSN", nil, commands)
+
+		client, err := newProtocolClient(clientConn, "localhost")
+		assert.Nil(t, err)
+		assert.NotNil(t, client.Mail("from@example.com", "ENVID=x\r\nRCPT TO:<evil@example.com>"))
+		_ = client.Close()
+	})
+}
+
+func TestMailer_HELOOnlyServer(t *testing.T) {
+	t.Run("should send through a server advertising no extensions and warn about skipped probes", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		commands := make(chan string, 10)
+		go serveSMTP(serverConn, "", map[string]string{"MAIL": "250 ok", "RCPT": "250 ok"}, commands)
+
+		// stub functions
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return clientConn, nil
+		}
+
+		var warnings []error
+		mailer := NewMailer(testHost, testPort, testUser, testPassword, withNetDial(netDial), WithLocalName("localhost"), WithHooks(Hooks{
+			OnWarning: func(ctx context.Context, warning error) {
+				warnings = append(warnings, warning)
+			},
+		}))
+		msg := message.Message{
+			From:       testFromEmail,
+			Recipients: testRecipient,
+			Body:       "dummy body",
+		}
+
+		err := mailer.Send(context.Background(), msg)
+		assert.Nil(t, err)
+		assert.Equal(t, []error{
+			fmt.Errorf("%w STARTTLS, continuing without TLS", ErrExtensionNotAdvertised),
+			fmt.Errorf("%w AUTH, continuing without authentication", ErrExtensionNotAdvertised),
+		}, warnings)
+
+		var got []string
+		for cmd := range commands {
+			got = append(got, cmd)
+		}
+		assert.Equal(t, []string{"EHLO localhost", "HELO localhost", "MAIL FROM:<test@gomailer.com>", "RCPT TO:<test@gomailer.com>", "DATA", "QUIT"}, got)
+	})
+}
+
+func TestProtocolClient_MailRcpt_Pipelining(t *testing.T) {
+	tests := map[string]struct {
+		replies          map[string]string
+		expectedMailErr  error
+		expectedRcptErrs []error
+	}{
+		"should pipeline MAIL and RCPT commands": {
+			replies:          map[string]string{"MAIL": "250 ok", "RCPT": "250 ok"},
+			expectedRcptErrs: []error{nil, nil},
+		},
+		"should read every reply when commands are rejected": {
+			replies:         map[string]string{"MAIL": "550 5.7.1 sender rejected", "RCPT": "503 5.5.1 need MAIL"},
+			expectedMailErr: &textproto.Error{Code: 550, Msg: "5.7.1 sender rejected"},
+			expectedRcptErrs: []error{
+				&textproto.Error{Code: 503, Msg: "5.5.1 need MAIL"},
+				&textproto.Error{Code: 503, Msg: "5.5.1 need MAIL"},
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			clientConn, serverConn := net.Pipe()
+			commands := make(chan string, 10)
+			go serveSMTP(serverConn, "PIPELINING", tc.replies, commands)
+
+			client, err := newProtocolClient(clientConn, "localhost")
+			assert.Nil(t, err)
+
+			mailErr, rcptErrs := client.MailRcpt("from@example.com", []string{"RET=HDRS"}, []string{"a@example.com", "b@example.com"}, nil)
+			assert.Equal(t, tc.expectedMailErr, mailErr)
+			assert.Equal(t, tc.expectedRcptErrs, rcptErrs)
+			// the connection is still in sync.
+			assert.Nil(t, client.Quit())
+
+			var got []string
+			for cmd := range commands {
+				got = append(got, cmd)
+			}
+			assert.Equal(t, []string{"EHLO localhost", "MAIL FROM:<from@example.com> RET=HDRS", "RCPT TO:<a@example.com>", "RCPT TO:<b@example.com>", "QUIT"}, got)
+		})
+	}
+
+	t.Run("should refuse parameters containing line breaks", func(t *testing.T) {
+		t.Parallel()
+		clientConn, serverConn := net.Pipe()
+		commands := make(chan string, 10)
+		go serveSMTP(serverConn, "PIPELINING", nil, commands)
+
+		client, err := newProtocolClient(clientConn, "localhost")
+		assert.Nil(t, err)
+		mailErr, _ := client.MailRcpt("from@example.com", nil, []string{"to@example.com"}, []string{"NOTIFY=NEVER\r\nDATA"})
+		assert.NotNil(t, mailErr)
+		_ = client.Close()
+	})
+}
+
+func TestMailer_Pipelining(t *testing.T) {
+	t.Run("should report the rejected recipient of pipelined commands", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		commands := make(chan string, 10)
+		go serveSMTP(serverConn, "PIPELINING", map[string]string{"MAIL": "250 ok", "RCPT": "550 5.1.1 unknown"}, commands)
+
+		// stub functions
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return clientConn, nil
+		}
+
+		mailer := NewMailer(testHost, testPort, "", "", withNetDial(netDial), WithLocalName("localhost"), WithEncryption(EncryptionNone))
+		msg := message.Message{
+			From:       testFromEmail,
+			Recipients: testRecipient,
+			Body:       "dummy body",
+		}
+
+		err := mailer.Send(context.Background(), msg)
+		var smtpErr *SMTPError
+		assert.ErrorAs(t, err, &smtpErr)
+		assert.Equal(t, "RCPT", smtpErr.Command)
+		assert.Equal(t, testRecipient[0], smtpErr.Recipient)
+		assert.Equal(t, BounceHard, smtpErr.Bounce())
+
+		var got []string
+		for cmd := range commands {
+			got = append(got, cmd)
+		}
+		assert.Equal(t, []string{"EHLO localhost", "MAIL FROM:<test@gomailer.com>", "RCPT TO:<test@gomailer.com>", "QUIT"}, got)
+	})
+}
+
+func TestProtocolClient_Auth(t *testing.T) {
+	tests := map[string]struct {
+		replies          map[string]string
+		expectedErr      error
+		expectedCommands []string
+	}{
+		"should authenticate with the given mechanism": {
+			replies:          map[string]string{"AUTH": "235 2.7.0 authenticated"},
+			expectedCommands: []string{"EHLO localhost", "AUTH PLAIN AHVzZXIAcGFzcw==", "QUIT"},
+		},
+		"should report rejected credentials": {
+			replies:          map[string]string{"AUTH": "535 5.7.8 invalid credentials"},
+			expectedErr:      &textproto.Error{Code: 535, Msg: "5.7.8 invalid credentials"},
+			expectedCommands: []string{"EHLO localhost", "AUTH PLAIN AHVzZXIAcGFzcw==", "*", "QUIT"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			clientConn, serverConn := net.Pipe()
+			commands := make(chan string, 10)
+			go serveSMTP(serverConn, "AUTH PLAIN", tc.replies, commands)
+
+			client, err := newProtocolClient(clientConn, "localhost")
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expectedErr, client.Auth(smtp.PlainAuth("", "user", "pass", "localhost")))
+			if tc.expectedErr == nil {
+				assert.Nil(t, client.Quit())
+			}
+
+			var got []string
+			for cmd := range commands {
+				got = append(got, cmd)
+			}
+			assert.Equal(t, tc.expectedCommands, got)
+		})
+	}
+}
+
+func TestProtocolClient_Data_Chunking(t *testing.T) {
+	tests := map[string]struct {
+		size             int
+		replies          map[string]string
+		expectedErr      error
+		expectedCommands []string
+	}{
+		"should send the message as the last chunk": {
+			size:             100,
+			replies:          map[string]string{"BDAT": "250 queued"},
+			expectedCommands: []string{"EHLO localhost", "BDAT 100 LAST", "QUIT"},
+		},
+		"should split large messages in chunks": {
+			size:             bdatChunkSize + 10,
+			replies:          map[string]string{"BDAT": "250 ok"},
+			expectedCommands: []string{"EHLO localhost", fmt.Sprintf("BDAT %d", bdatChunkSize), "BDAT 10 LAST", "QUIT"},
+		},
+		"should report a rejected chunk": {
+			size:             bdatChunkSize,
+			replies:          map[string]string{"BDAT": "552 5.3.4 message too big"},
+			expectedErr:      &textproto.Error{Code: 552, Msg: "5.3.4 message too big"},
+			expectedCommands: []string{"EHLO localhost", fmt.Sprintf("BDAT %d", bdatChunkSize), "QUIT"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			clientConn, serverConn := net.Pipe()
+			commands := make(chan string, 10)
+			go serveSMTP(serverConn, "CHUNKING", tc.replies, commands)
+
+			client, err := newProtocolClient(clientConn, "localhost")
+			assert.Nil(t, err)
+			assert.Nil(t, client.Hello("localhost"))
+			w, err := client.Data()
+			assert.Nil(t, err)
+			_, writeErr := w.Write(make([]byte, tc.size))
+			closeErr := w.Close()
+			if tc.expectedErr != nil {
+				assert.Equal(t, tc.expectedErr, writeErr)
+			} else {
+				assert.Nil(t, writeErr)
+			}
+			assert.Equal(t, tc.expectedErr, closeErr)
+			assert.Nil(t, client.Quit())
+
+			var got []string
+			for cmd := range commands {
+				got = append(got, cmd)
+			}
+			assert.Equal(t, tc.expectedCommands, got)
+		})
+	}
+}
+
+func TestProtocolClient_Data_DotStuffing(t *testing.T) {
+	t.Run("should dot-stuff lines so a lone dot does not end the message early", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		received := make(chan []byte, 1)
+		go func() {
+			tc := textproto.NewConn(serverConn)
+			_ = tc.PrintfLine("220 localhost ESMTP")
+			_, _ = tc.ReadLine()
+			_ = tc.PrintfLine("250 localhost")
+			_, _ = tc.ReadLine()
+			_ = tc.PrintfLine("354 go ahead")
+			data, _ := tc.ReadDotBytes()
+			received <- data
+			_ = tc.PrintfLine("250 queued")
+		}()
+
+		client, err := newProtocolClient(clientConn, "localhost")
+		assert.Nil(t, err)
+		assert.Nil(t, client.Hello("localhost"))
+		w, err := client.Data()
+		assert.Nil(t, err)
+		_, err = w.Write([]byte("Subject: hi\r\n\r\nfirst\r\n.\r\n..second\r\n"))
+		assert.Nil(t, err)
+		assert.Nil(t, w.Close())
+		// ReadDotBytes undoes the dot-stuffing and returns LF line endings.
+		assert.Equal(t, "Subject: hi\n\nfirst\n.\n..second\n", string(<-received))
+		_ = client.Close()
+	})
+}
@@ -0,0 +1,249 @@
+// Package smtpcode names the common SMTP reply codes (RFC 5321 section 4.2) and enhanced status codes (RFC 3463),
+// along with human-readable descriptions, for callers handling the rejections of SMTP servers:
+//
+//	var smtpErr *gomailer.SMTPError
+//	if errors.As(err, &smtpErr) && smtpErr.Code == smtpcode.MailboxUnavailable {
+//	    ...
+//	}
+package smtpcode
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Code is a three digits SMTP reply code.
+type Code int
+
+// Reply codes of RFC 5321 section 4.2.3 and of the AUTH extension (RFC 4954 section 6).
+const (
+	ServiceReady                  Code = 220
+	ServiceClosing                Code = 221
+	AuthSucceeded                 Code = 235
+	OK                            Code = 250
+	UserNotLocalWillForward       Code = 251
+	CannotVerifyUser              Code = 252
+	AuthContinue                  Code = 334
+	StartMailInput                Code = 354
+	ServiceNotAvailable           Code = 421
+	MailboxUnavailableTemporarily Code = 450
+	LocalError                    Code = 451
+	InsufficientStorage           Code = 452
+	TemporaryAuthFailure          Code = 454
+	ParametersNotAccommodated     Code = 455
+	SyntaxError                   Code = 500
+	ParameterSyntaxError          Code = 501
+	CommandNotImplemented         Code = 502
+	BadSequence                   Code = 503
+	ParameterNotImplemented       Code = 504
+	AuthRequired                  Code = 530
+	AuthFailed                    Code = 535
+	MailboxUnavailable            Code = 550
+	UserNotLocal                  Code = 551
+	ExceededStorage               Code = 552
+	MailboxNameNotAllowed         Code = 553
+	TransactionFailed             Code = 554
+	ParametersNotRecognized       Code = 555
+)
+
+// codeDescriptions are the descriptions of the named reply codes.
+var codeDescriptions = map[Code]string{
+	ServiceReady:                  "service ready",
+	ServiceClosing:                "service closing transmission channel",
+	AuthSucceeded:                 "authentication succeeded",
+	OK:                            "requested action completed",
+	UserNotLocalWillForward:       "user not local, will forward",
+	CannotVerifyUser:              "cannot verify user, will attempt delivery",
+	AuthContinue:                  "authentication challenge",
+	StartMailInput:                "start mail input",
+	ServiceNotAvailable:           "service not available, closing transmission channel",
+	MailboxUnavailableTemporarily: "mailbox temporarily unavailable",
+	LocalError:                    "local error in processing",
+	InsufficientStorage:           "insufficient system storage",
+	TemporaryAuthFailure:          "temporary authentication failure",
+	ParametersNotAccommodated:     "server unable to accommodate parameters",
+	SyntaxError:                   "syntax error, command unrecognized",
+	ParameterSyntaxError:          "syntax error in parameters or arguments",
+	CommandNotImplemented:         "command not implemented",
+	BadSequence:                   "bad sequence of commands",
+	ParameterNotImplemented:       "command parameter not implemented",
+	AuthRequired:                  "authentication required",
+	AuthFailed:                    "authentication credentials invalid",
+	MailboxUnavailable:            "mailbox unavailable",
+	UserNotLocal:                  "user not local",
+	ExceededStorage:               "exceeded storage allocation",
+	MailboxNameNotAllowed:         "mailbox name not allowed",
+	TransactionFailed:             "transaction failed",
+	ParametersNotRecognized:       "MAIL or RCPT parameters not recognized or not implemented",
+}
+
+// Description returns the description of the reply code, or of its class when the code is not named.
+func (c Code) Description() string {
+	if d, ok := codeDescriptions[c]; ok {
+		return d
+	}
+	switch {
+	case c.Positive():
+		return "positive reply"
+	case c.Temporary():
+		return "transient negative reply"
+	case c.Permanent():
+		return "permanent negative reply"
+	default:
+		return "unknown reply"
+	}
+}
+
+// Positive reports whether the reply is positive (2xx and 3xx reply codes).
+func (c Code) Positive() bool {
+	return c >= 200 && c < 400
+}
+
+// Temporary reports whether the reply is a transient failure (4xx reply code), the command may be retried later.
+func (c Code) Temporary() bool {
+	return c >= 400 && c < 500
+}
+
+// Permanent reports whether the reply is a permanent failure (5xx reply code), the command must not be retried as is.
+func (c Code) Permanent() bool {
+	return c >= 500 && c < 600
+}
+
+// String returns the reply code followed by its description, e.g. "550 mailbox unavailable".
+func (c Code) String() string {
+	return fmt.Sprintf("%d %s", int(c), c.Description())
+}
+
+// Status is the subject and detail of an enhanced status code, shared by its success, transient and permanent forms,
+// e.g. BadDestinationMailbox for both 4.1.1 and 5.1.1.
+type Status struct {
+	Subject, Detail int
+}
+
+// Statuses of RFC 3463 section 3 and of later RFCs registered by IANA, named after their meaning.
+var (
+	OtherStatus                   = Status{0, 0}
+	BadDestinationMailbox         = Status{1, 1}
+	BadDestinationSystem          = Status{1, 2}
+	BadDestinationSyntax          = Status{1, 3}
+	BadSenderAddress              = Status{1, 7}
+	BadSenderSystem               = Status{1, 8}
+	DestinationDoesNotAcceptMail  = Status{1, 10}
+	MailboxDisabled               = Status{2, 1}
+	MailboxFull                   = Status{2, 2}
+	MessageTooLongForMailbox      = Status{2, 3}
+	MailSystemFull                = Status{3, 1}
+	MessageTooBig                 = Status{3, 4}
+	NoAnswerFromHost              = Status{4, 1}
+	BadConnection                 = Status{4, 2}
+	RoutingServerFailure          = Status{4, 3}
+	NetworkCongestion             = Status{4, 5}
+	DeliveryTimeExpired           = Status{4, 7}
+	InvalidCommand                = Status{5, 1}
+	SyntaxErrorStatus             = Status{5, 2}
+	TooManyRecipients             = Status{5, 3}
+	InvalidCommandArguments       = Status{5, 4}
+	ContentConversionNotSupported = Status{6, 1}
+	SecurityOther                 = Status{7, 0}
+	DeliveryNotAuthorized         = Status{7, 1}
+	AuthCredentialsInvalid        = Status{7, 8}
+	EncryptionRequired            = Status{7, 11}
+	AuthChecksFailed              = Status{7, 26}
+)
+
+// statusDescriptions are the descriptions of the named statuses (RFC 3463 section 3 and the IANA registry).
+var statusDescriptions = map[Status]string{
+	OtherStatus:                   "other or undefined status",
+	BadDestinationMailbox:         "bad destination mailbox address",
+	BadDestinationSystem:          "bad destination system address",
+	BadDestinationSyntax:          "bad destination mailbox address syntax",
+	BadSenderAddress:              "bad sender's mailbox address syntax",
+	BadSenderSystem:               "bad sender's system address",
+	DestinationDoesNotAcceptMail:  "destination mailbox address does not accept mail",
+	MailboxDisabled:               "mailbox disabled, not accepting messages",
+	MailboxFull:                   "mailbox full",
+	MessageTooLongForMailbox:      "message length exceeds administrative limit",
+	MailSystemFull:                "mail system full",
+	MessageTooBig:                 "message too big for system",
+	NoAnswerFromHost:              "no answer from host",
+	BadConnection:                 "bad connection",
+	RoutingServerFailure:          "directory server failure",
+	NetworkCongestion:             "network congestion",
+	DeliveryTimeExpired:           "delivery time expired",
+	InvalidCommand:                "invalid command",
+	SyntaxErrorStatus:             "syntax error",
+	TooManyRecipients:             "too many recipients",
+	InvalidCommandArguments:       "invalid command arguments",
+	ContentConversionNotSupported: "media not supported",
+	SecurityOther:                 "other or undefined security status",
+	DeliveryNotAuthorized:         "delivery not authorized, message refused",
+	AuthCredentialsInvalid:        "authentication credentials invalid",
+	EncryptionRequired:            "encryption required for requested authentication mechanism",
+	AuthChecksFailed:              "multiple authentication checks failed",
+}
+
+// Description returns the description of the status, or of its subject when the detail is not named.
+func (s Status) Description() string {
+	if d, ok := statusDescriptions[s]; ok {
+		return d
+	}
+	switch s.Subject {
+	case 1:
+		return "addressing status"
+	case 2:
+		return "mailbox status"
+	case 3:
+		return "mail system status"
+	case 4:
+		return "network and routing status"
+	case 5:
+		return "mail delivery protocol status"
+	case 6:
+		return "message content or media status"
+	case 7:
+		return "security or policy status"
+	default:
+		return "other or undefined status"
+	}
+}
+
+// EnhancedCode is an RFC 3463 enhanced status code, e.g. 5.1.1.
+type EnhancedCode struct {
+	// Class is 2 for success, 4 for transient and 5 for permanent failures.
+	Class int
+	Status
+}
+
+// enhancedCodePattern matches enhanced status codes at the start of a reply text, e.g. "5.1.1 user unknown".
+var enhancedCodePattern = regexp.MustCompile(`^([245])\.(\d{1,3})\.(\d{1,3})\b`)
+
+// ParseEnhancedCode parses the enhanced status code at the start of the reply text s, as sent by servers
+// advertising the ENHANCEDSTATUSCODES extension (RFC 2034). It returns the code and the text following it,
+// or false when s does not start with an enhanced status code.
+func ParseEnhancedCode(s string) (EnhancedCode, string, bool) {
+	match := enhancedCodePattern.FindStringSubmatchIndex(s)
+	if match == nil {
+		return EnhancedCode{}, s, false
+	}
+	atoi := func(i int) int {
+		n, _ := strconv.Atoi(s[match[2*i]:match[2*i+1]])
+		return n
+	}
+	return EnhancedCode{Class: atoi(1), Status: Status{Subject: atoi(2), Detail: atoi(3)}}, s[match[1]:], true
+}
+
+// Temporary reports whether the code is a transient failure (class 4).
+func (c EnhancedCode) Temporary() bool {
+	return c.Class == 4
+}
+
+// Permanent reports whether the code is a permanent failure (class 5).
+func (c EnhancedCode) Permanent() bool {
+	return c.Class == 5
+}
+
+// String returns the code in its dotted form, e.g. "5.1.1".
+func (c EnhancedCode) String() string {
+	return fmt.Sprintf("%d.%d.%d", c.Class, c.Subject, c.Detail)
+}
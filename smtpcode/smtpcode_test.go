@@ -0,0 +1,90 @@
+package smtpcode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCode(t *testing.T) {
+	tests := map[string]struct {
+		code                                         Code
+		expectedString                               string
+		expectedPositive, expectedTemp, expectedPerm bool
+	}{
+		"should describe a positive reply": {
+			code:             OK,
+			expectedString:   "250 requested action completed",
+			expectedPositive: true,
+		},
+		"should describe a transient failure": {
+			code:           ServiceNotAvailable,
+			expectedString: "421 service not available, closing transmission channel",
+			expectedTemp:   true,
+		},
+		"should describe a permanent failure": {
+			code:           MailboxUnavailable,
+			expectedString: "550 mailbox unavailable",
+			expectedPerm:   true,
+		},
+		"should describe an unnamed code by its class": {
+			code:           Code(599),
+			expectedString: "599 permanent negative reply",
+			expectedPerm:   true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expectedString, tc.code.String())
+			assert.Equal(t, tc.expectedPositive, tc.code.Positive())
+			assert.Equal(t, tc.expectedTemp, tc.code.Temporary())
+			assert.Equal(t, tc.expectedPerm, tc.code.Permanent())
+		})
+	}
+}
+
+func TestParseEnhancedCode(t *testing.T) {
+	tests := map[string]struct {
+		input               string
+		expectedCode        EnhancedCode
+		expectedMsg         string
+		expectedOK          bool
+		expectedDescription string
+	}{
+		"should parse the code at the start of the reply": {
+			input:               "5.1.1 user unknown",
+			expectedCode:        EnhancedCode{Class: 5, Status: BadDestinationMailbox},
+			expectedMsg:         " user unknown",
+			expectedOK:          true,
+			expectedDescription: "bad destination mailbox address",
+		},
+		"should describe an unnamed status by its subject": {
+			input:               "4.2.99",
+			expectedCode:        EnhancedCode{Class: 4, Status: Status{Subject: 2, Detail: 99}},
+			expectedOK:          true,
+			expectedDescription: "mailbox status",
+		},
+		"should not parse a reply without code": {
+			input:       "user unknown",
+			expectedMsg: "user unknown",
+		},
+		"should not parse an invalid class": {
+			input:       "3.1.1 user unknown",
+			expectedMsg: "3.1.1 user unknown",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			code, msg, ok := ParseEnhancedCode(tc.input)
+			assert.Equal(t, tc.expectedCode, code)
+			assert.Equal(t, tc.expectedMsg, msg)
+			assert.Equal(t, tc.expectedOK, ok)
+			if ok {
+				assert.Equal(t, tc.input[:len(tc.input)-len(msg)], code.String())
+				assert.Equal(t, tc.expectedDescription, code.Description())
+			}
+		})
+	}
+}
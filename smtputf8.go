@@ -0,0 +1,111 @@
+package gomailer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nawafswe/gomailer/message"
+)
+
+// ErrSMTPUTF8Required is returned when an address has a non-ASCII local part but the SMTP server does not advertise SMTPUTF8,
+// so the address can neither be sent as is nor converted.
+var ErrSMTPUTF8Required = errors.New("smtp server does not advertise SMTPUTF8")
+
+// internationalize prepares the addresses of a message for the SMTP server.
+// Messages with non-ASCII addresses are sent as is when the server advertises SMTPUTF8, the SMTPUTF8 parameter
+// is then added to MAIL FROM (see requiresSMTPUTF8). Otherwise the domains are converted to punycode, which is not possible for
+// non-ASCII local parts and reported as ErrSMTPUTF8Required. The envelope recipients overriding those of the message
+// are converted alike and returned along with it.
+func (m *mailSender) internationalize(msg message.Message, recipients []string) (message.Message, []string, error) {
+	if !hasNonASCIIAddress(msg) {
+		return msg, recipients, nil
+	}
+	if ok, _ := m.Extension("SMTPUTF8"); ok {
+		return msg, recipients, nil
+	}
+	var err error
+	for _, a := range []*string{&msg.From, &msg.EnvelopeFrom} {
+		if *a, err = asciiAddress(*a); err != nil {
+			return msg, recipients, err
+		}
+	}
+	for _, list := range []*[]string{&msg.Recipients, &msg.Cc, &msg.Bcc} {
+		if *list, err = asciiAddresses(*list); err != nil {
+			return msg, recipients, err
+		}
+	}
+	if recipients != nil {
+		if recipients, err = asciiAddresses(recipients); err != nil {
+			return msg, recipients, err
+		}
+	}
+	return msg, recipients, nil
+}
+
+// hasNonASCIIAddress reports whether any address of the message contains non-ASCII characters.
+func hasNonASCIIAddress(msg message.Message) bool {
+	for _, list := range [][]string{{msg.From, msg.EnvelopeFrom}, msg.Recipients, msg.Cc, msg.Bcc} {
+		for _, a := range list {
+			if !isASCII(message.EnvelopeAddress(a)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requiresSMTPUTF8 reports whether the transaction of the message to the envelope recipients requires the SMTPUTF8
+// parameter of MAIL FROM, which RFC 6531 section 3.4 only allows when an address of the envelope or of the header
+// fields is internationalized. internationalize leaves such addresses only when the server advertises SMTPUTF8.
+func requiresSMTPUTF8(msg message.Message, recipients []string) bool {
+	if hasNonASCIIAddress(msg) {
+		return true
+	}
+	for _, r := range recipients {
+		if !isASCII(message.EnvelopeAddress(r)) {
+			return true
+		}
+	}
+	return false
+}
+
+// asciiAddresses returns a copy of the list with every address converted by asciiAddress.
+func asciiAddresses(list []string) ([]string, error) {
+	converted := make([]string, 0, len(list))
+	for _, a := range list {
+		c, err := asciiAddress(a)
+		if err != nil {
+			return nil, err
+		}
+		converted = append(converted, c)
+	}
+	return converted, nil
+}
+
+// asciiAddress converts the domain of the address to punycode, keeping its display name.
+func asciiAddress(a string) (string, error) {
+	addr, err := message.ParseAddress(a)
+	if err != nil || isASCII(addr.Email) {
+		return a, nil
+	}
+	at := strings.LastIndexByte(addr.Email, '@')
+	if !isASCII(addr.Email[:at]) {
+		return "", fmt.Errorf("address %s has a non-ASCII local part: %w", addr.Email, ErrSMTPUTF8Required)
+	}
+	if addr, err = addr.ToASCII(); err != nil {
+		return "", err
+	}
+	return addr.String(), nil
+}
+
+// isASCII reports whether s only contains ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
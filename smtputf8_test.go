@@ -0,0 +1,112 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mailerMock "github.com/nawafswe/gomailer/internal/mock"
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMailer_SMTPUTF8(t *testing.T) {
+	tests := map[string]struct {
+		from              string
+		recipient         string
+		advertised        bool
+		expectedFrom      string
+		expectedParams    []any
+		expectedRecipient string
+		expectedHeader    string
+		expectedErr       error
+	}{
+		"should send internationalized addresses as is when SMTPUTF8 is advertised": {
+			from:              "نواف <نواف@مثال.com>",
+			recipient:         "user@bücher.example",
+			advertised:        true,
+			expectedFrom:      "نواف@مثال.com",
+			expectedParams:    []any{"SMTPUTF8"},
+			expectedRecipient: "user@bücher.example",
+			expectedHeader:    "To: user@bücher.example\r\n",
+		},
+		"should convert domains to punycode when SMTPUTF8 is not advertised": {
+			from:              testFromEmail,
+			recipient:         "Bücher <user@bücher.example>",
+			expectedFrom:      testFromEmail,
+			expectedRecipient: "user@xn--bcher-kva.example",
+			expectedHeader:    "To: =?utf-8?q?B=C3=BCcher?= <user@xn--bcher-kva.example>\r\n",
+		},
+		"should fail when a local part is not ascii and SMTPUTF8 is not advertised": {
+			from:        testFromEmail,
+			recipient:   "üser@example.com",
+			expectedErr: fmt.Errorf("failed to send message: %w", fmt.Errorf("failed to send message: %w", fmt.Errorf("address üser@example.com has a non-ASCII local part: %w", ErrSMTPUTF8Required))),
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			// prepare mocks
+			smtpMock := mailerMock.NewMockClient(ctrl)
+			netConnMock := mailerMock.NewMockconn(ctrl)
+			writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+			// stub functions
+			newClient := func(conn net.Conn, host string) (Client, error) {
+				return smtpMock, nil
+			}
+			netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+				return netConnMock, nil
+			}
+
+			mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone))
+			msg := message.Message{
+				From:       tc.from,
+				Recipients: []string{tc.recipient},
+				Body:       "dummy body",
+			}
+			// expect on mocks
+			smtpMock.EXPECT().Extension("SMTPUTF8").Return(tc.advertised, "")
+			smtpMock.EXPECT().Quit().Return(nil)
+			if tc.expectedErr == nil {
+				smtpMock.EXPECT().Mail(tc.expectedFrom, tc.expectedParams...).Return(nil)
+				smtpMock.EXPECT().Rcpt(tc.expectedRecipient).Return(nil)
+				smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+				writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+					assert.Contains(t, string(b), tc.expectedHeader)
+					return len(b), nil
+				})
+				writeCloserMock.EXPECT().Close().Return(nil)
+			}
+
+			err := mailer.Send(context.Background(), msg)
+			assert.Equal(t, tc.expectedErr, err)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, ErrSMTPUTF8Required)
+			}
+			assert.Equal(t, []string{tc.recipient}, msg.Recipients)
+		})
+	}
+	t.Run("should not send SMTPUTF8 for ASCII messages when the server advertises it", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		commands := make(chan string, 10)
+		go serveSMTP(serverConn, "SMTPUTF8", map[string]string{"MAIL": "250 ok", "RCPT": "250 ok"}, commands)
+
+		// stub functions
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return clientConn, nil
+		}
+
+		mailer := NewMailer("localhost", testPort, "", "", withNetDial(netDial), WithEncryption(EncryptionNone))
+		sender, err := mailer.ConnectAndAuthenticate()
+		assert.Nil(t, err)
+		assert.Nil(t, sender.Send(message.Message{From: "Nawaf <" + testFromEmail + ">", Recipients: []string{"Bücher <user@example.com>"}, Body: "dummy body"}))
+		assert.Nil(t, sender.Send(message.Message{From: testFromEmail, Recipients: []string{"user@bücher.example"}, Body: "dummy body"}))
+		assert.Nil(t, sender.Close())
+		got := receive(commands)
+		assert.Contains(t, got, "MAIL FROM:<"+testFromEmail+">")
+		assert.Contains(t, got, "MAIL FROM:<"+testFromEmail+"> SMTPUTF8")
+	})
+}
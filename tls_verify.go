@@ -0,0 +1,63 @@
+package gomailer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+)
+
+// VerifyConnectionFunc verifies the TLS connection negotiated with the SMTP server at host, see WithVerifyConnection.
+type VerifyConnectionFunc func(host string, state tls.ConnectionState) error
+
+// TLSVerificationError is returned when the VerifyConnectionFunc given to WithVerifyConnection rejects the TLS connection
+// to an SMTP server. Use errors.As to retrieve it from errors returned by Mailer and SendCloser.
+type TLSVerificationError struct {
+	// Host is the SMTP server whose connection was rejected.
+	Host string
+	// Certificates are the certificates presented by the server, leaf first.
+	Certificates []*x509.Certificate
+	// Err is the error returned by the VerifyConnectionFunc.
+	Err error
+}
+
+// Error returns the reason of the rejection.
+func (e *TLSVerificationError) Error() string {
+	return fmt.Sprintf("tls connection to %s rejected: %v", e.Host, e.Err)
+}
+
+// Unwrap returns the error returned by the VerifyConnectionFunc.
+func (e *TLSVerificationError) Unwrap() error {
+	return e.Err
+}
+
+// WithVerifyConnection configures Mailer to verify every TLS connection, implicit or STARTTLS, with verify once the
+// handshake completed and the certificate chain was verified, e.g. to check the SPKI of the certificates against an
+// allow-list or warn about certificates close to expiry. Connections verify rejects fail with a *TLSVerificationError.
+// It applies along with the VerifyConnection of the tls.Config given to WithTLSConfig or WithHostTLSConfig, which runs first.
+func WithVerifyConnection(verify VerifyConnectionFunc) func(*Mailer) {
+	return func(mailer *Mailer) {
+		if verify == nil {
+			mailer.invalidOption("verify connection func cannot be nil")
+			return
+		}
+		mailer.verifyConnection = verify
+	}
+}
+
+// withVerifyConnection returns a copy of cfg verifying connections to host with the VerifyConnectionFunc of the Mailer.
+func (m *Mailer) withVerifyConnection(host string, cfg *tls.Config) *tls.Config {
+	cfg = cfg.Clone()
+	configured := cfg.VerifyConnection
+	cfg.VerifyConnection = func(state tls.ConnectionState) error {
+		if configured != nil {
+			if err := configured(state); err != nil {
+				return err
+			}
+		}
+		if err := m.verifyConnection(host, state); err != nil {
+			return &TLSVerificationError{Host: host, Certificates: state.PeerCertificates, Err: err}
+		}
+		return nil
+	}
+	return cfg
+}
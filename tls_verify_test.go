@@ -0,0 +1,60 @@
+package gomailer
+
+import (
+	"crypto/tls"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMailer_WithVerifyConnection(t *testing.T) {
+	errExpiring := errors.New("certificate expires within 7 days")
+	errConfigured := errors.New("rejected by the tls config")
+	tests := map[string]struct {
+		configured  func(tls.ConnectionState) error
+		verifyErr   error
+		expectedErr error
+	}{
+		"should accept the connection": {},
+		"should reject the connection with a verification error": {
+			verifyErr:   errExpiring,
+			expectedErr: errExpiring,
+		},
+		"should reject the connection with the configured verification first": {
+			configured:  func(tls.ConnectionState) error { return errConfigured },
+			verifyErr:   errExpiring,
+			expectedErr: errConfigured,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &tls.Config{ServerName: "mx2.example.com", VerifyConnection: tc.configured}
+			var verifiedHost string
+			mailer := NewMailer(testHost, testPort, testUser, testPassword, WithHostTLSConfig("mx2.example.com", cfg),
+				WithVerifyConnection(func(host string, state tls.ConnectionState) error {
+					verifiedHost = host
+					return tc.verifyErr
+				}))
+
+			got := mailer.tlsCfg("mx2.example.com")
+			assert.NotSame(t, cfg, got)
+			assert.Equal(t, "mx2.example.com", got.ServerName)
+			err := got.VerifyConnection(tls.ConnectionState{})
+			assert.ErrorIs(t, err, tc.expectedErr)
+			if tc.expectedErr == errConfigured {
+				assert.Empty(t, verifiedHost)
+				return
+			}
+			assert.Equal(t, "mx2.example.com", verifiedHost)
+			if tc.expectedErr != nil {
+				assert.EqualError(t, err, "tls connection to mx2.example.com rejected: certificate expires within 7 days")
+			}
+		})
+	}
+	t.Run("should reject a nil func", func(t *testing.T) {
+		_, err := NewMailerE(testHost, testPort, testUser, testPassword, WithVerifyConnection(nil))
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, "verify connection func cannot be nil")
+	})
+}
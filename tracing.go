@@ -0,0 +1,79 @@
+package gomailer
+
+import "context"
+
+// Span names of the phases of the send pipeline.
+const (
+	// SpanSend covers the whole Send of a message, connection included.
+	SpanSend = "smtp.send"
+	// SpanDial covers the connection to the server, the handshake of implicit TLS and the greeting.
+	SpanDial = "smtp.dial"
+	// SpanStartTLS covers the STARTTLS command and the TLS handshake.
+	SpanStartTLS = "smtp.starttls"
+	// SpanAuth covers the authentication exchange.
+	SpanAuth = "smtp.auth"
+	// SpanEnvelope covers the MAIL and RCPT commands.
+	SpanEnvelope = "smtp.envelope"
+	// SpanData covers the transfer of the message with DATA or BDAT, until the server accepts it.
+	SpanData = "smtp.data"
+)
+
+// Tracer starts the spans of the send pipeline. It is kept minimal so any tracing backend can implement it,
+// an OpenTelemetry adapter is provided by the oteltracing module, keeping gomailer itself free of its dependencies.
+type Tracer interface {
+	// Start starts a span named name as a child of the span carried by ctx, if any,
+	// and returns a context carrying the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a phase of the send pipeline started by Tracer.
+type Span interface {
+	// SetAttribute sets an attribute of the span, value is a string, an int or a bool.
+	SetAttribute(key string, value any)
+	// End ends the span, err is the error the phase failed with or nil.
+	End(err error)
+}
+
+// WithTracer configures Mailer to trace the phases of the send pipeline with t (see the Span constants),
+// labeled with the SMTP server and the reply code of the server when it rejects a command.
+func WithTracer(t Tracer) func(*Mailer) {
+	return func(mailer *Mailer) {
+		mailer.tracer = t
+	}
+}
+
+// noopSpan is the Span of Mailers without Tracer.
+type noopSpan struct{}
+
+// SetAttribute implements Span.
+func (noopSpan) SetAttribute(string, any) {}
+
+// End implements Span.
+func (noopSpan) End(error) {}
+
+// startSpan starts the span named name with the attributes of the primary SMTP server, a noopSpan when no Tracer is configured.
+func (m *Mailer) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	if m == nil {
+		return ctx, noopSpan{}
+	}
+	return m.startEndpointSpan(ctx, name, m.endpoint())
+}
+
+// startEndpointSpan starts the span named name with the attributes of the SMTP server at e.
+func (m *Mailer) startEndpointSpan(ctx context.Context, name string, e Endpoint) (context.Context, Span) {
+	if m.tracer == nil {
+		return ctx, noopSpan{}
+	}
+	ctx, span := m.tracer.Start(ctx, name)
+	span.SetAttribute("server.address", e.Host)
+	span.SetAttribute("server.port", e.Port)
+	return ctx, span
+}
+
+// endSpan ends span with err, recording the reply code of the server when err is an SMTP error reply.
+func endSpan(span Span, err error) {
+	if code, ok := replyCode(err); ok {
+		span.SetAttribute("smtp.response.code", code)
+	}
+	span.End(err)
+}
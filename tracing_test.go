@@ -0,0 +1,138 @@
+package gomailer
+
+import (
+	"context"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mailerMock "github.com/nawafswe/gomailer/internal/mock"
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSpan is a span recorded by fakeTracer.
+type fakeSpan struct {
+	name       string
+	parent     string
+	attributes map[string]any
+	err        error
+	ended      bool
+}
+
+// SetAttribute implements Span.
+func (s *fakeSpan) SetAttribute(key string, value any) {
+	s.attributes[key] = value
+}
+
+// End implements Span.
+func (s *fakeSpan) End(err error) {
+	s.err, s.ended = err, true
+}
+
+// fakeSpanKey is the context key of the current fakeSpan.
+type fakeSpanKey struct{}
+
+// fakeTracer records the started spans.
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []*fakeSpan
+}
+
+// Start implements Tracer.
+func (f *fakeTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	span := &fakeSpan{name: name, attributes: make(map[string]any)}
+	if parent, ok := ctx.Value(fakeSpanKey{}).(*fakeSpan); ok {
+		span.parent = parent.name
+	}
+	f.spans = append(f.spans, span)
+	return context.WithValue(ctx, fakeSpanKey{}, span), span
+}
+
+func TestMailer_Tracing(t *testing.T) {
+	msg := message.Message{
+		From:       testFromEmail,
+		Recipients: testRecipient,
+		Body:       "dummy body",
+	}
+	t.Run("should trace the phases of the send", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		writeCloserMock := mailerMock.NewMockwriteCloser(ctrl)
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		tracer := &fakeTracer{}
+		mailer := NewMailer(testHost, testPort, testUser, testPassword, WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone), WithTracer(tracer),
+			WithAuth(smtp.PlainAuth("", testUser, testPassword, testHost)),
+		)
+
+		// expect on mocks
+		smtpMock.EXPECT().Auth(gomock.Any()).Return(nil)
+		smtpMock.EXPECT().Mail(msg.From).Return(nil)
+		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(nil)
+		smtpMock.EXPECT().Data().Return(writeCloserMock, nil)
+		smtpMock.EXPECT().Quit().Return(nil)
+		writeCloserMock.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			return len(b), nil
+		})
+		writeCloserMock.EXPECT().Close().Return(nil)
+
+		assert.Nil(t, mailer.Send(context.Background(), msg))
+		var names, parents []string
+		for _, span := range tracer.spans {
+			names, parents = append(names, span.name), append(parents, span.parent)
+			assert.True(t, span.ended)
+			assert.Nil(t, span.err)
+			assert.Equal(t, testHost, span.attributes["server.address"])
+			assert.Equal(t, testPort, span.attributes["server.port"])
+		}
+		assert.Equal(t, []string{SpanSend, SpanDial, SpanAuth, SpanEnvelope, SpanData}, names)
+		assert.Equal(t, []string{"", SpanSend, SpanSend, SpanSend, SpanSend}, parents)
+		assert.Equal(t, 1, tracer.spans[3].attributes["smtp.recipients"])
+		assert.Greater(t, tracer.spans[4].attributes["smtp.data.bytes"], 0)
+	})
+	t.Run("should record the reply code of rejected commands", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		// prepare mocks
+		smtpMock := mailerMock.NewMockClient(ctrl)
+		netConnMock := mailerMock.NewMockconn(ctrl)
+		// stub functions
+		newClient := func(conn net.Conn, host string) (Client, error) {
+			return smtpMock, nil
+		}
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return netConnMock, nil
+		}
+
+		tracer := &fakeTracer{}
+		mailer := NewMailer(testHost, testPort, "", "", WithClientFactory(newClient), withNetDial(netDial), WithEncryption(EncryptionNone), WithTracer(tracer))
+
+		// expect on mocks
+		smtpMock.EXPECT().Mail(msg.From).Return(nil)
+		smtpMock.EXPECT().Rcpt(msg.Recipients[0]).Return(&textproto.Error{Code: 550, Msg: "5.1.1 unknown user"})
+		smtpMock.EXPECT().Quit().Return(nil)
+
+		err := mailer.Send(context.Background(), msg)
+		assert.NotNil(t, err)
+		envelope, send := tracer.spans[2], tracer.spans[0]
+		assert.Equal(t, SpanEnvelope, envelope.name)
+		assert.Equal(t, 550, envelope.attributes["smtp.response.code"])
+		assert.NotNil(t, envelope.err)
+		assert.Equal(t, 550, send.attributes["smtp.response.code"])
+		assert.Equal(t, err, send.err)
+	})
+}
@@ -0,0 +1,76 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/nawafswe/gomailer/message"
+)
+
+// ErrUnknownTransport is returned when looking up a transport that was never registered.
+var ErrUnknownTransport = errors.New("unknown transport")
+
+// Transport delivers a message.Message, Mailer implements it over SMTP.
+// Alternative transports (e.g. provider HTTP APIs such as SendGrid, Mailgun or SES) implement it
+// to be used behind the same message.Message type.
+type Transport interface {
+	// Send delivers the message, ctx bounds the whole delivery.
+	Send(ctx context.Context, msg message.Message) error
+}
+
+// TransportFunc is an adapter to allow the use of ordinary functions as Transport.
+type TransportFunc func(ctx context.Context, msg message.Message) error
+
+// Send calls f(ctx, msg).
+func (f TransportFunc) Send(ctx context.Context, msg message.Message) error {
+	return f(ctx, msg)
+}
+
+// Mailer must implement Transport.
+var _ Transport = (*Mailer)(nil)
+
+var (
+	transportsMu sync.RWMutex
+	transports   = make(map[string]Transport)
+)
+
+// RegisterTransport makes a transport available by the provided name, e.g. from the init function
+// of a package implementing a provider API, so applications can select it by configuration.
+// If RegisterTransport is called twice with the same name or if transport is nil, it panics.
+func RegisterTransport(name string, transport Transport) {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+	if transport == nil {
+		panic("gomailer: RegisterTransport transport is nil")
+	}
+	if _, dup := transports[name]; dup {
+		panic("gomailer: RegisterTransport called twice for transport " + name)
+	}
+	transports[name] = transport
+}
+
+// LookupTransport returns the transport registered by the provided name.
+func LookupTransport(name string) (Transport, error) {
+	transportsMu.RLock()
+	defer transportsMu.RUnlock()
+	transport, ok := transports[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q (forgotten import?)", ErrUnknownTransport, name)
+	}
+	return transport, nil
+}
+
+// Transports returns a sorted list of the names of the registered transports.
+func Transports() []string {
+	transportsMu.RLock()
+	defer transportsMu.RUnlock()
+	names := make([]string, 0, len(transports))
+	for name := range transports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
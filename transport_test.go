@@ -0,0 +1,42 @@
+package gomailer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransport_Registry(t *testing.T) {
+	t.Run("should register and look up transports by name", func(t *testing.T) {
+		var sent message.Message
+		api := TransportFunc(func(ctx context.Context, msg message.Message) error {
+			sent = msg
+			return nil
+		})
+		RegisterTransport("test-api", api)
+		RegisterTransport("test-smtp", NewMailer(testHost, testPort, testUser, testPassword))
+
+		transport, err := LookupTransport("test-api")
+		assert.Nil(t, err)
+		msg := message.Message{From: testFromEmail, Recipients: testRecipient}
+		assert.Nil(t, transport.Send(context.Background(), msg))
+		assert.Equal(t, msg, sent)
+
+		assert.Subset(t, Transports(), []string{"test-api", "test-smtp"})
+	})
+	t.Run("should fail to look up unknown transport", func(t *testing.T) {
+		transport, err := LookupTransport("unknown")
+		assert.Nil(t, transport)
+		assert.Equal(t, fmt.Errorf("%w: %q (forgotten import?)", ErrUnknownTransport, "unknown"), err)
+	})
+	t.Run("should panic when registering nil or duplicate transport", func(t *testing.T) {
+		assert.Panics(t, func() { RegisterTransport("test-nil", nil) })
+		RegisterTransport("test-dup", TransportFunc(func(context.Context, message.Message) error { return nil }))
+		assert.Panics(t, func() {
+			RegisterTransport("test-dup", TransportFunc(func(context.Context, message.Message) error { return nil }))
+		})
+	})
+}
@@ -0,0 +1,183 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nawafswe/gomailer/internal/mx"
+	"github.com/nawafswe/gomailer/message"
+)
+
+const (
+	// defaultVerifyInterval is the minimum time between two probes of a Verifier.
+	defaultVerifyInterval = time.Second
+	// defaultVerifyTimeout bounds the whole probe of an address.
+	defaultVerifyTimeout = 30 * time.Second
+)
+
+// ErrNoMailServer is returned by Verifier.Verify when the domain of the address has no mail server to probe,
+// it is message.ErrNoMailServer.
+var ErrNoMailServer = message.ErrNoMailServer
+
+// VerifierOptions to configure Verifier.
+type VerifierOptions func(*Verifier)
+
+// WithVerifyLocalName configures Verifier with the hostname sent with EHLO, it should resolve back to the probing host
+// as mail servers often reject clients with a mismatching name.
+func WithVerifyLocalName(l string) func(*Verifier) {
+	return func(verifier *Verifier) {
+		verifier.localName = l
+	}
+}
+
+// WithVerifySender configures Verifier with the sender address of the MAIL command, the null reverse-path (<>) is used by default.
+func WithVerifySender(from string) func(*Verifier) {
+	return func(verifier *Verifier) {
+		verifier.from = from
+	}
+}
+
+// WithVerifyInterval configures the minimum time between two probes of Verifier, probes are delayed to respect it.
+// It defaults to one second, probing mail servers too often gets the probing host blocked.
+func WithVerifyInterval(d time.Duration) func(*Verifier) {
+	return func(verifier *Verifier) {
+		verifier.interval = d
+	}
+}
+
+// WithVerifyTimeout configures how long Verifier probes an address before giving up, it defaults to 30 seconds.
+func WithVerifyTimeout(d time.Duration) func(*Verifier) {
+	return func(verifier *Verifier) {
+		if d > 0 {
+			verifier.timeout = d
+		}
+	}
+}
+
+// WithVerifyMailerOptions configures Verifier with options applied to the Mailer probing every mail server,
+// e.g. WithDialer, WithTLSConfig or WithCommandTimeout. The host, port, local name and encryption are set by Verifier.
+func WithVerifyMailerOptions(opts ...Options) func(*Verifier) {
+	return func(verifier *Verifier) {
+		verifier.opts = append(verifier.opts, opts...)
+	}
+}
+
+// Verifier checks whether the mail server of an address accepts it as a recipient, e.g. to check addresses given at signup.
+// It connects to the MX of the domain and issues MAIL and RCPT commands, the transaction is aborted before DATA so no message is sent.
+// Mail servers may accept every recipient (catch-all) or reject probes, so a successful check is a strong hint, not a guarantee.
+type Verifier struct {
+	// localName is the hostname sent with EHLO.
+	localName string
+	// from is the sender address of the MAIL command.
+	from string
+	// interval is the minimum time between two probes.
+	interval time.Duration
+	// timeout bounds the probe of an address.
+	timeout time.Duration
+	// opts are applied to the Mailer probing every mail server.
+	opts []Options
+	// now returns the current time the interval is measured with.
+	now func() time.Time
+
+	mu sync.Mutex
+	// next is the earliest time of the next probe.
+	next time.Time
+}
+
+// Verifier validates addresses at message.LevelCallout, see message.WithCallout.
+var _ message.AddressVerifier = (*Verifier)(nil)
+
+// NewVerifier creates a new Verifier.
+func NewVerifier(opts ...VerifierOptions) *Verifier {
+	verifier := &Verifier{interval: defaultVerifyInterval, timeout: defaultVerifyTimeout, now: time.Now}
+	for _, opt := range opts {
+		opt(verifier)
+	}
+	return verifier
+}
+
+// Verify probes the mail servers of the address domain, by MX preference, and returns nil when the recipient is accepted.
+// A rejection is returned as *SMTPError, use SMTPError.Bounce to tell unknown recipients (hard) from temporary failures (soft).
+// Probes are rate-limited (see WithVerifyInterval), Verify waits for its turn until ctx is done.
+func (v *Verifier) Verify(ctx context.Context, address string) error {
+	addr, err := message.ParseAddress(address)
+	if err != nil {
+		return fmt.Errorf("failed to verify address: %w", err)
+	}
+	if err := v.wait(ctx); err != nil {
+		return fmt.Errorf("failed to verify address %s: %w", addr.Email, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+
+	domain := addr.Email[strings.LastIndexByte(addr.Email, '@')+1:]
+	hosts, err := mailServers(ctx, domain)
+	if err != nil {
+		return fmt.Errorf("failed to verify address %s: %w", addr.Email, err)
+	}
+	var errs []error
+	for _, host := range hosts {
+		err := v.probe(ctx, host, addr.Email)
+		var smtpErr *SMTPError
+		if err == nil || errors.As(err, &smtpErr) {
+			// the server answered, other servers of the domain are expected to answer the same.
+			return err
+		}
+		errs = append(errs, err)
+	}
+	return fmt.Errorf("failed to verify address %s: %w", addr.Email, errors.Join(errs...))
+}
+
+// wait blocks until the next probe is allowed by the interval.
+func (v *Verifier) wait(ctx context.Context) error {
+	v.mu.Lock()
+	now := v.now()
+	start := v.next
+	if start.Before(now) {
+		start = now
+	}
+	v.next = start.Add(v.interval)
+	v.mu.Unlock()
+	if delay := start.Sub(now); delay > 0 {
+		return sleep(ctx, delay)
+	}
+	return nil
+}
+
+// probe connects to the mail server host and issues the MAIL and RCPT commands for address, then resets the transaction.
+func (v *Verifier) probe(ctx context.Context, host, address string) error {
+	// the probe reuses the Mailer connection setup, without authentication and over plaintext when STARTTLS is not advertised.
+	opts := append(v.opts[:len(v.opts):len(v.opts)], WithLocalName(v.localName), WithEncryption(EncryptionOpportunistic))
+	mailer := NewMailer(host, smtpPort, "", "", opts...)
+	sender, err := mailer.connectAndAuthenticate(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", host, err)
+	}
+	defer sender.Close()
+
+	if err := sender.Mail(v.from); err != nil {
+		return fmt.Errorf("mail server %s rejected MAIL command: %w", host, newSMTPError("MAIL", "", err))
+	}
+	if err := sender.Rcpt(address); err != nil {
+		return fmt.Errorf("mail server %s rejected recipient %s: %w", host, address, newSMTPError("RCPT", address, err))
+	}
+	// abort the transaction, no message is sent.
+	_ = sender.Reset()
+	return nil
+}
+
+// mailServers returns the mail servers of domain by MX preference, or the domain itself when it has no MX records (RFC 5321 section 5.1).
+func mailServers(ctx context.Context, domain string) ([]string, error) {
+	hosts, err := mx.Hosts(ctx, domain, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(hosts) == 0 {
+		return []string{domain}, nil
+	}
+	return hosts, nil
+}
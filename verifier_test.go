@@ -0,0 +1,118 @@
+package gomailer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nawafswe/gomailer/internal/mx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifier_Verify(t *testing.T) {
+	dialErr := errors.New("connection refused")
+	tests := map[string]struct {
+		mxs              []*net.MX
+		unreachable      map[string]bool
+		replies          map[string]string
+		expectedHost     string
+		expectedErr      error
+		expectedBounce   BounceType
+		expectedCommands []string
+	}{
+		"should accept recipients the mail server accepts": {
+			mxs:              []*net.MX{{Host: "mx2.example.com.", Pref: 20}, {Host: "mx1.example.com.", Pref: 10}},
+			replies:          map[string]string{"MAIL": "250 ok", "RCPT": "250 ok", "RSET": "250 ok"},
+			expectedHost:     "mx1.example.com:25",
+			expectedCommands: []string{"EHLO probe.example.com", "MAIL FROM:<>", "RCPT TO:<user@example.com>", "RSET", "QUIT"},
+		},
+		"should report recipients the mail server rejects": {
+			mxs:              []*net.MX{{Host: "mx1.example.com.", Pref: 10}},
+			replies:          map[string]string{"MAIL": "250 ok", "RCPT": "550 5.1.1 user unknown"},
+			expectedHost:     "mx1.example.com:25",
+			expectedBounce:   BounceHard,
+			expectedCommands: []string{"EHLO probe.example.com", "MAIL FROM:<>", "RCPT TO:<user@example.com>", "QUIT"},
+		},
+		"should probe the next mail server when one is unreachable": {
+			mxs:              []*net.MX{{Host: "mx1.example.com.", Pref: 10}, {Host: "mx2.example.com.", Pref: 20}},
+			unreachable:      map[string]bool{"mx1.example.com:25": true},
+			replies:          map[string]string{"MAIL": "250 ok", "RCPT": "250 ok", "RSET": "250 ok"},
+			expectedHost:     "mx2.example.com:25",
+			expectedCommands: []string{"EHLO probe.example.com", "MAIL FROM:<>", "RCPT TO:<user@example.com>", "RSET", "QUIT"},
+		},
+		"should probe the domain itself when it has no MX records": {
+			replies:          map[string]string{"MAIL": "250 ok", "RCPT": "250 ok", "RSET": "250 ok"},
+			expectedHost:     "example.com:25",
+			expectedCommands: []string{"EHLO probe.example.com", "MAIL FROM:<>", "RCPT TO:<user@example.com>", "RSET", "QUIT"},
+		},
+		"should refuse domains declaring a null MX": {
+			mxs:         []*net.MX{{Host: ".", Pref: 0}},
+			expectedErr: ErrNoMailServer,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			commands := make(chan string, 10)
+			go serveSMTP(serverConn, "PIPELINING", tc.replies, commands)
+
+			// stub functions
+			mx.LookupMX = func(ctx context.Context, name string) ([]*net.MX, error) {
+				assert.Equal(t, "example.com", name)
+				return tc.mxs, nil
+			}
+			defer func() { mx.LookupMX = net.DefaultResolver.LookupMX }()
+			var dialed string
+			netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+				if tc.unreachable[host] {
+					return nil, dialErr
+				}
+				dialed = host
+				return clientConn, nil
+			}
+
+			verifier := NewVerifier(WithVerifyLocalName("probe.example.com"), WithVerifyInterval(0), WithVerifyMailerOptions(withNetDial(netDial)))
+			err := verifier.Verify(context.Background(), "User <user@example.com>")
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				_ = clientConn.Close()
+				return
+			}
+			var smtpErr *SMTPError
+			if tc.expectedBounce != BounceNone {
+				assert.ErrorAs(t, err, &smtpErr)
+				assert.Equal(t, tc.expectedBounce, smtpErr.Bounce())
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, tc.expectedHost, dialed)
+
+			var got []string
+			for cmd := range commands {
+				got = append(got, cmd)
+			}
+			assert.Equal(t, tc.expectedCommands, got)
+		})
+	}
+}
+
+func TestVerifier_Wait(t *testing.T) {
+	t.Run("should space probes by the interval", func(t *testing.T) {
+		verifier := NewVerifier(WithVerifyInterval(50 * time.Millisecond))
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			assert.Nil(t, verifier.wait(context.Background()))
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("should stop waiting when the context is done", func(t *testing.T) {
+		verifier := NewVerifier(WithVerifyInterval(time.Hour))
+		assert.Nil(t, verifier.wait(context.Background()))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.ErrorIs(t, verifier.wait(ctx), context.Canceled)
+	})
+}
@@ -0,0 +1,74 @@
+package gomailer
+
+import (
+	"strings"
+
+	"github.com/nawafswe/gomailer/message"
+)
+
+// WithVERP configures Mailer.SendBatch to send every copy once per recipient, with an envelope sender encoding the
+// recipient in the bounce address (Variable Envelope Return Path), e.g. bounces+user=example.com@example.org for
+// user@example.com and bounces@example.org. Bounces returned to such an address are attributed to the recipient with
+// VERPRecipient, even when the bounce does not name it. The VERP address replaces Message.EnvelopeFrom and
+// SendOptions.EnvelopeFrom, the bounce mailbox must accept the addresses with a subaddress.
+func WithVERP(bounceAddress string) func(*Mailer) {
+	return func(mailer *Mailer) {
+		addr := message.EnvelopeAddress(bounceAddress)
+		if at := strings.LastIndexByte(addr, '@'); at <= 0 || strings.Contains(addr[:at], "+") {
+			mailer.invalidOption("verp bounce address %q must be an address without subaddress", bounceAddress)
+			return
+		}
+		mailer.verpAddress = addr
+	}
+}
+
+// VERPAddress returns the VERP address of bounceAddress for recipient, e.g. bounces+user=example.com@example.org
+// for bounces@example.org and user@example.com.
+func VERPAddress(bounceAddress, recipient string) string {
+	bounce, rcpt := message.EnvelopeAddress(bounceAddress), message.EnvelopeAddress(recipient)
+	at := strings.LastIndexByte(bounce, '@')
+	if at < 0 {
+		return bounce
+	}
+	return bounce[:at] + "+" + strings.Replace(rcpt, "@", "=", 1) + bounce[at:]
+}
+
+// VERPRecipient returns the recipient encoded in the VERP address, e.g. user@example.com for
+// bounces+user=example.com@example.org, false when address is not a VERP address.
+func VERPRecipient(address string) (string, bool) {
+	addr := message.EnvelopeAddress(address)
+	at := strings.LastIndexByte(addr, '@')
+	if at < 0 {
+		return "", false
+	}
+	_, encoded, ok := strings.Cut(addr[:at], "+")
+	if !ok {
+		return "", false
+	}
+	eq := strings.LastIndexByte(encoded, '=')
+	if eq <= 0 || eq == len(encoded)-1 {
+		return "", false
+	}
+	return encoded[:eq] + "@" + encoded[eq+1:], true
+}
+
+// batchTransaction is an SMTP transaction of Mailer.SendBatch.
+type batchTransaction struct {
+	msg        message.Message
+	recipients []string
+}
+
+// batchTransactions returns the transactions sending msg, one per recipient with its VERP address as envelope sender
+// when VERP is configured, or a single one to every recipient otherwise.
+func (m *Mailer) batchTransactions(msg message.Message) []batchTransaction {
+	if m.verpAddress == "" {
+		return []batchTransaction{{msg: msg, recipients: msg.Recipients}}
+	}
+	transactions := make([]batchTransaction, 0, len(msg.Recipients))
+	for _, recipient := range msg.Recipients {
+		verp := msg
+		verp.EnvelopeFrom = VERPAddress(m.verpAddress, recipient)
+		transactions = append(transactions, batchTransaction{msg: verp, recipients: []string{recipient}})
+	}
+	return transactions
+}
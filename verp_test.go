@@ -0,0 +1,89 @@
+package gomailer
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nawafswe/gomailer/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVERPAddress(t *testing.T) {
+	tests := map[string]struct {
+		bounce, recipient string
+		expected          string
+	}{
+		"should encode the recipient in the bounce address": {
+			bounce: "bounces@example.org", recipient: "user@example.com",
+			expected: "bounces+user=example.com@example.org",
+		},
+		"should encode the bare addresses": {
+			bounce: "Bounces <bounces@example.org>", recipient: "User <user+tag@example.com>",
+			expected: "bounces+user+tag=example.com@example.org",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			verp := VERPAddress(tc.bounce, tc.recipient)
+			assert.Equal(t, tc.expected, verp)
+			recipient, ok := VERPRecipient(verp)
+			assert.True(t, ok)
+			assert.Equal(t, message.EnvelopeAddress(tc.recipient), recipient)
+		})
+	}
+}
+
+func TestVERPRecipient(t *testing.T) {
+	tests := map[string]struct {
+		address  string
+		expected string
+		ok       bool
+	}{
+		"should decode a VERP address":             {address: "<bounces+user=example.com@example.org>", expected: "user@example.com", ok: true},
+		"should not decode an address without tag": {address: "bounces@example.org"},
+		"should not decode a tag without domain":   {address: "bounces+user@example.org"},
+		"should not decode an invalid address":     {address: "bounces"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			recipient, ok := VERPRecipient(tc.address)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.expected, recipient)
+		})
+	}
+}
+
+func TestMailer_WithVERP(t *testing.T) {
+	t.Run("should send every recipient its own transaction from its VERP address", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+		commands := make(chan string, 30)
+		go serveSMTP(serverConn, "8BITMIME", map[string]string{"MAIL": "250 ok", "RCPT": "250 ok"}, commands)
+
+		// stub functions
+		netDial := func(network string, host string, t time.Duration) (net.Conn, error) {
+			return clientConn, nil
+		}
+
+		mailer := NewMailer("localhost", testPort, "", "", withNetDial(netDial), WithEncryption(EncryptionNone), WithVERP("bounces@example.org"))
+		tmpl := message.Message{From: testFromEmail, EnvelopeFrom: "ignored@example.org", Body: "newsletter"}
+		err := mailer.SendBatch(context.Background(), tmpl, []message.Personalization{
+			{Recipients: []string{"a@example.com", "b@example.net"}},
+			{Recipients: []string{"c@example.com"}},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{
+			"EHLO localhost",
+			"MAIL FROM:<bounces+a=example.com@example.org> BODY=8BITMIME", "RCPT TO:<a@example.com>", "DATA",
+			"MAIL FROM:<bounces+b=example.net@example.org> BODY=8BITMIME", "RCPT TO:<b@example.net>", "DATA",
+			"MAIL FROM:<bounces+c=example.com@example.org> BODY=8BITMIME", "RCPT TO:<c@example.com>", "DATA",
+			"QUIT",
+		}, receive(commands))
+	})
+	t.Run("should reject a bounce address with a subaddress", func(t *testing.T) {
+		_, err := NewMailerE(testHost, testPort, testUser, testPassword, WithVERP("bounces+x@example.org"))
+		assert.ErrorIs(t, err, ErrInvalidConfig)
+		assert.ErrorContains(t, err, `verp bounce address "bounces+x@example.org" must be an address without subaddress`)
+	})
+}